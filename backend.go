@@ -12,3 +12,25 @@ type Backend interface {
 
 	GraceTime(Container) time.Duration
 }
+
+// IntraSubnetIsolator is implemented by backends that can enforce
+// ContainerSpec.IsolateIntraSubnet. The server refuses containers asking for
+// it with an UnsupportedOperationError unless IsolatesIntraSubnet reports
+// true, so that a wrapping backend can answer for the one it wraps.
+type IntraSubnetIsolator interface {
+	IsolatesIntraSubnet() bool
+}
+
+// ProcessLimiter is implemented by backends that can enforce
+// ProcessSpec.ProcessLimits. The server refuses processes with limits of their
+// own with an UnsupportedOperationError unless LimitsProcesses reports true.
+type ProcessLimiter interface {
+	LimitsProcesses() bool
+}
+
+// ScratchSpaceProvider is implemented by backends that can mount
+// ContainerSpec.ScratchSpaces. The server refuses containers asking for them
+// with an UnsupportedOperationError unless ProvidesScratchSpaces reports true.
+type ScratchSpaceProvider interface {
+	ProvidesScratchSpaces() bool
+}
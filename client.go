@@ -35,11 +35,15 @@ type Client interface {
 	//
 	// TODO: list the resources that can be acquired during the lifetime of a container.
 	//
+	// Destroy returns once they have all been released, so the handle may be
+	// reused straight away.
+	//
 	// Errors:
 	// * TODO.
 	Destroy(handle string) error
 
 	// Containers lists all containers filtered by Properties (which are ANDed together).
+	// They are sorted by handle, and each is listed once.
 	//
 	// Errors:
 	// * None.
@@ -96,6 +100,10 @@ type ContainerSpec struct {
 	// * bind_mounts: a list of mount point descriptions which will result in corresponding mount
 	// points being created in the container's file system.
 	//
+	// Mount points are applied in the order they appear in the list, so a later
+	// mount point shadows an earlier one at the same or an enclosing path. The
+	// order is preserved on the wire and reported back in ContainerInfo.
+	//
 	// An error is returned if:
 	// * one or more of the mount points has a non-existent source directory, or
 	// * one or more of the mount points cannot be created.
@@ -116,7 +124,8 @@ type ContainerSpec struct {
 	//
 	// Multiple containers may share a subnet by passing the same subnet address on the corresponding
 	// create calls. Containers on the same subnet can communicate with each other over IP
-	// without restriction. In particular, they are not affected by packet filtering.
+	// without restriction. In particular, they are not affected by packet filtering, unless
+	// IsolateIntraSubnet is set.
 	//
 	// Note that a container can use TCP, UDP, and ICMP, although its external access is governed
 	// by filters (see Container.NetOut()) and by any implementation-specific filters.
@@ -128,6 +137,31 @@ type ContainerSpec struct {
 	//   already had a container allocated from it.
 	Network string `json:"network,omitempty"`
 
+	// NetworkFrom names an existing container whose subnet the new container
+	// should share, as if its subnet address had been passed as Network. A
+	// destroy of the named container waits for the create to finish. Where the
+	// server knows the subnet, it passes it on as Network and reports it in
+	// ContainerInfo; otherwise the backend resolves the container itself.
+	//
+	// An error is returned if:
+	// * InvalidRequestError, if Network is also specified, or
+	// * ContainerNotFoundError, if the named container does not exist or is
+	//   being destroyed. No allocation is made in that case.
+	NetworkFrom string `json:"network_from,omitempty"`
+
+	// IsolateIntraSubnet, if true, makes the container accept traffic from
+	// the other containers on its subnet only as its NetIn and NetOut rules
+	// allow, as for traffic from anywhere else. Isolation is per container and
+	// applies to its own ingress: an isolated container may still reach peers
+	// that are not isolated, subject to its own rules. The backend enforces it
+	// and reports it in ContainerInfo; a server whose backend cannot fails
+	// Create with an UnsupportedOperationError.
+	//
+	// On a Network of a /30 subnet or smaller, the container has no peers and
+	// isolation has no effect; the server warns of it but creates the
+	// container.
+	IsolateIntraSubnet bool `json:"isolate_intra_subnet,omitempty"`
+
 	// Properties is a sequence of string key/value pairs providing arbitrary
 	// data about the container. The keys are assumed to be unique but this is not
 	// enforced via the protocol.
@@ -139,10 +173,60 @@ type ContainerSpec struct {
 	// If Privileged is true the container does not have a user namespace and the root user in the container
 	// is the same as the root user in the host. Otherwise, the container has a user namespace and the root
 	// user in the container is mapped to a non-root user in the host. Defaults to false.
+	//
+	// A server may refuse privileged containers, failing Create with an UnsupportedOperationError. Whether a
+	// container is privileged is reported in ContainerInfo.
 	Privileged bool `json:"privileged,omitempty"`
 
 	// Limits to be applied to the newly created container.
 	Limits Limits `json:"limits,omitempty"`
+
+	// ScratchSpaces are writable areas mounted at the given paths, each with
+	// its own byte limit that does not count against the container's disk
+	// limits. Their contents are discarded when the container is destroyed.
+	//
+	// An error is returned if:
+	// * InvalidPathError, if a scratch path is not absolute or is refused by
+	//   the server's path policy, or
+	// * InvalidRequestError, if a scratch path is the same as, or nested in or
+	//   around, another scratch path or a bind mount destination, or
+	// * UnsupportedOperationError, if the backend cannot mount scratch spaces.
+	ScratchSpaces []ScratchSpec `json:"scratch_spaces,omitempty"`
+
+	// RecordMetrics has the server sample the container's metrics at an
+	// interval, so that short spikes can be looked back on with the client's
+	// Container.MetricsHistory and the latest samples survive in its
+	// Tombstone. A server recording as many containers as it has room for
+	// creates the container without a recording; ContainerInfo.RecordingMetrics
+	// tells which. The server marks the container with RecordMetricsProperty,
+	// so that it resumes the recording when it restarts.
+	RecordMetrics bool `json:"record_metrics,omitempty"`
+}
+
+// RecordMetricsProperty is set to "true" on containers created with
+// RecordMetrics.
+const RecordMetricsProperty = "garden.record_metrics"
+
+// EffectiveSpec is what a container was effectively created with: its spec
+// as the server resolved it, with the handle, grace time and network filled
+// in, and what was allocated for it. The fields Info also reports are as it
+// would report them immediately after creation.
+type EffectiveSpec struct {
+	ContainerSpec
+
+	HostIP      string        `json:"host_ip,omitempty"`
+	ContainerIP string        `json:"container_ip,omitempty"`
+	ExternalIP  string        `json:"external_ip,omitempty"`
+	MappedPorts []PortMapping `json:"mapped_ports,omitempty"`
+}
+
+// ScratchSpec specifies a single scratch space.
+type ScratchSpec struct {
+	// Path is where the scratch space is mounted in the container.
+	Path string `json:"path,omitempty"`
+
+	// ByteLimit is the maximum number of bytes the scratch space may hold.
+	ByteLimit uint64 `json:"byte_limit,omitempty"`
 }
 
 type Limits struct {
@@ -182,10 +266,85 @@ type Capacity struct {
 	MemoryInBytes uint64 `json:"memory_in_bytes,omitempty"`
 	DiskInBytes   uint64 `json:"disk_in_bytes,omitempty"`
 	MaxContainers uint64 `json:"max_containers,omitempty"`
+
+	// CPU and scheduling information, if the backend reports it. Each is nil
+	// when it is not reported, so that a reported zero can be told apart.
+	CPUCores  *uint64 `json:"cpu_cores,omitempty"`
+	CPUShares *uint64 `json:"cpu_shares,omitempty"`
+
+	// SchedulableMemoryInBytes is MemoryInBytes less the memory the backend
+	// reserves for itself and the host.
+	SchedulableMemoryInBytes *uint64 `json:"schedulable_memory_in_bytes,omitempty"`
+
+	// Usage of the pools containers are allocated from, if the backend has
+	// them. The same numbers are carried by the matching *PoolExhaustedError.
+	SubnetPool *PoolUsage `json:"subnet_pool,omitempty"`
+	UIDPool    *PoolUsage `json:"uid_pool,omitempty"`
+	PortPool   *PoolUsage `json:"port_pool,omitempty"`
+}
+
+// PoolUsage describes how much of an allocation pool is in use.
+type PoolUsage struct {
+	Size  uint64 `json:"size"`
+	InUse uint64 `json:"in_use"`
 }
 
 type Properties map[string]string
 
+// ListOptions select a page of a container listing.
+type ListOptions struct {
+	// Limit is the most handles to return. Zero means no limit.
+	Limit uint64 `json:"limit,omitempty"`
+
+	// Token continues a listing from the NextToken of the previous page. It
+	// is empty for the first page.
+	Token string `json:"token,omitempty"`
+}
+
+// ContainerPage is one page of a container listing.
+type ContainerPage struct {
+	// Handles are sorted, and follow those of the previous page.
+	Handles []string `json:"handles,omitempty"`
+
+	// NextToken continues the listing after this page. It is empty on the
+	// last page.
+	NextToken string `json:"next_token,omitempty"`
+}
+
+// Tombstone records why and when a container was destroyed.
+type Tombstone struct {
+	Handle string `json:"handle"`
+	Reason string `json:"reason"`
+
+	// RequestedAt is when the container was asked to be destroyed, and
+	// DestroyedAt when the backend finished releasing its resources.
+	RequestedAt time.Time `json:"requested_at"`
+	DestroyedAt time.Time `json:"destroyed_at"`
+
+	// Metrics holds the latest samples of a container that was recording
+	// its metrics, oldest first.
+	Metrics []MetricsSample `json:"metrics,omitempty"`
+
+	// Error is set if an asynchronous destroy failed, in which case the
+	// container may still exist and DestroyedAt is when the destroy gave up.
+	Error string `json:"error,omitempty"`
+}
+
+// Reasons a container may have been destroyed, as recorded in its Tombstone.
+const (
+	// DestroyReasonAPI means the container was destroyed by a Destroy or
+	// DestroyMatching request.
+	DestroyReasonAPI = "api-destroy"
+
+	// DestroyReasonGraceTime means the container was reaped after its grace
+	// time passed with no client activity.
+	DestroyReasonGraceTime = "grace-time"
+
+	// DestroyReasonCreateRollback means the container was destroyed because
+	// its create request failed after the container was made.
+	DestroyReasonCreateRollback = "failed-create-rollback"
+)
+
 type BindMountMode uint8
 
 const BindMountModeRO BindMountMode = 0
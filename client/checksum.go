@@ -0,0 +1,27 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// TarStreamChecksum returns the hex-encoded SHA-256 digest of a tar stream,
+// for use as StreamInSpec.Checksum, and seeks the stream back to where it was.
+func TarStreamChecksum(tarStream io.ReadSeeker) (string, error) {
+	start, err := tarStream.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, tarStream); err != nil {
+		return "", err
+	}
+
+	if _, err := tarStream.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,52 @@
+package client_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	. "code.cloudfoundry.org/garden/client"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TarStreamChecksum", func() {
+	It("returns the hex-encoded sha256 digest of the rest of the stream", func() {
+		stream := bytes.NewReader([]byte("skipped;some-tar-contents"))
+		_, err := stream.Seek(int64(len("skipped;")), io.SeekStart)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		checksum, err := TarStreamChecksum(stream)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// sha256 of "some-tar-contents"
+		Ω(checksum).Should(Equal("b6b90dd7cf8ce493050a0c53595249ccfcbe6abeb542c0dc166424022642b7b1"))
+	})
+
+	It("seeks the stream back to where it was", func() {
+		stream := bytes.NewReader([]byte("skipped;some-tar-contents"))
+		_, err := stream.Seek(int64(len("skipped;")), io.SeekStart)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = TarStreamChecksum(stream)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(ioutil.ReadAll(stream)).Should(Equal([]byte("some-tar-contents")))
+	})
+
+	Context("when seeking fails", func() {
+		It("returns the error", func() {
+			_, err := TarStreamChecksum(unseekable{bytes.NewReader(nil)})
+			Ω(err).Should(MatchError("cannot seek"))
+		})
+	})
+})
+
+type unseekable struct {
+	io.Reader
+}
+
+func (unseekable) Seek(int64, int) (int64, error) {
+	return 0, errors.New("cannot seek")
+}
@@ -7,6 +7,87 @@ import (
 
 type Client interface {
 	garden.Client
+
+	// DestroyMatching destroys every container whose properties match the
+	// filter (ANDed together) at the time the server receives the request.
+	// Containers created afterwards are not affected, even if they match.
+	//
+	// The handles of the destroyed containers are returned, along with a
+	// MultiError holding any per-handle failures. If dryRun is true, nothing
+	// is destroyed and the handles that would have been are returned.
+	//
+	// Errors:
+	// * When the matching containers cannot be listed.
+	// * When the filter is empty; use DestroyAll to destroy every container.
+	DestroyMatching(filter garden.Properties, dryRun bool) ([]string, *garden.MultiError, error)
+
+	// DestroyAll destroys every container, as DestroyMatching would with an
+	// empty filter.
+	DestroyAll(dryRun bool) ([]string, *garden.MultiError, error)
+
+	// CreateWithInfo creates a container and returns it along with its info,
+	// with defaults applied and network and ports allocated, as Info would
+	// report it immediately after creation.
+	//
+	// If the info cannot be gathered, the container is destroyed and the
+	// error is returned.
+	CreateWithInfo(spec garden.ContainerSpec) (garden.Container, garden.ContainerInfo, error)
+
+	// CreateWithEffectiveSpec creates a container and returns it along with
+	// the spec it was effectively created with: as the server resolved it,
+	// with what was allocated for it, so that it can be stored along with the
+	// container. Only the fields with the given JSON names are returned, or
+	// all of them if none are given.
+	//
+	// An error is returned if:
+	// * InvalidRequestError, if a field is not one of EffectiveSpec's; no
+	//   container is created.
+	//
+	// If the spec cannot be gathered, the container is destroyed and the
+	// error is returned.
+	CreateWithEffectiveSpec(spec garden.ContainerSpec, fields ...string) (garden.Container, garden.EffectiveSpec, error)
+
+	// ContainersPage lists one page of the handles of containers matching the
+	// filter, sorted by handle. Pass the page's NextToken in the options to
+	// get the next one. Listing is stable across pages: containers created or
+	// destroyed meanwhile may or may not appear, but no other container is
+	// skipped or repeated.
+	//
+	// Errors:
+	// * When the token was not issued by a previous page.
+	ContainersPage(filter garden.Properties, opts garden.ListOptions) (garden.ContainerPage, error)
+
+	// ContainersWithFilter lists the containers matching the filter, which
+	// can also test whether a property is set at all, or match its value
+	// against a glob pattern, and negate any of these. They are sorted by
+	// handle.
+	//
+	// Errors:
+	// * InvalidPropertyFilterError, if a match is not well formed. It is
+	//   returned without making a request; a server sent one responds with
+	//   the same error.
+	ContainersWithFilter(filter garden.PropertyFilter) ([]garden.Container, error)
+
+	// Tombstone returns why and when a recently destroyed container was
+	// destroyed. The server keeps tombstones for a limited time only.
+	//
+	// Errors:
+	// * ContainerNotFoundError, if the server has no tombstone for the handle.
+	Tombstone(handle string) (garden.Tombstone, error)
+
+	// DestroyAsync begins destroying a container, returning without waiting
+	// for its resources to be released. Until they are, its info reports
+	// garden.StateDestroying, and creating a container with its handle fails
+	// with a HandleStillDestroyingError. Its tombstone records when the
+	// destroy finished.
+	//
+	// Errors:
+	// * When the container is already being destroyed.
+	DestroyAsync(handle string) error
+
+	// ForceDestroy destroys a container even if it holds a maintenance lock.
+	// It is meant for administrators; automation should respect locks.
+	ForceDestroy(handle string) error
 }
 
 type client struct {
@@ -36,6 +117,28 @@ func (client *client) Create(spec garden.ContainerSpec) (garden.Container, error
 	return newContainer(handle, client.connection), nil
 }
 
+func (client *client) CreateWithInfo(spec garden.ContainerSpec) (garden.Container, garden.ContainerInfo, error) {
+	handle, info, err := client.connection.CreateWithInfo(spec)
+	if err != nil {
+		return nil, garden.ContainerInfo{}, err
+	}
+
+	return newContainer(handle, client.connection), info, nil
+}
+
+func (client *client) CreateWithEffectiveSpec(spec garden.ContainerSpec, fields ...string) (garden.Container, garden.EffectiveSpec, error) {
+	handle, effective, err := client.connection.CreateWithEffectiveSpec(spec, fields)
+	if err != nil {
+		return nil, garden.EffectiveSpec{}, err
+	}
+
+	return newContainer(handle, client.connection), effective, nil
+}
+
+func (client *client) Tombstone(handle string) (garden.Tombstone, error) {
+	return client.connection.Tombstone(handle)
+}
+
 func (client *client) Containers(properties garden.Properties) ([]garden.Container, error) {
 	handles, err := client.connection.List(properties)
 	if err != nil {
@@ -50,12 +153,50 @@ func (client *client) Containers(properties garden.Properties) ([]garden.Contain
 	return containers, nil
 }
 
+func (client *client) ContainersWithFilter(filter garden.PropertyFilter) ([]garden.Container, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	handles, err := client.connection.ListFiltered(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []garden.Container{}
+	for _, handle := range handles {
+		containers = append(containers, newContainer(handle, client.connection))
+	}
+
+	return containers, nil
+}
+
+func (client *client) ContainersPage(filter garden.Properties, opts garden.ListOptions) (garden.ContainerPage, error) {
+	return client.connection.ListPage(filter, opts)
+}
+
 func (client *client) Destroy(handle string) error {
 	err := client.connection.Destroy(handle)
 
 	return err
 }
 
+func (client *client) ForceDestroy(handle string) error {
+	return client.connection.ForceDestroy(handle)
+}
+
+func (client *client) DestroyAsync(handle string) error {
+	return client.connection.DestroyAsync(handle)
+}
+
+func (client *client) DestroyMatching(filter garden.Properties, dryRun bool) ([]string, *garden.MultiError, error) {
+	return client.connection.DestroyMatching(filter, dryRun)
+}
+
+func (client *client) DestroyAll(dryRun bool) ([]string, *garden.MultiError, error) {
+	return client.connection.DestroyAll(dryRun)
+}
+
 func (client *client) BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error) {
 	return client.connection.BulkInfo(handles)
 }
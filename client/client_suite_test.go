@@ -11,3 +11,7 @@ func TestClient(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Client Suite")
 }
+
+func uint64ptr(n uint64) *uint64 {
+	return &n
+}
@@ -178,6 +178,75 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("CreateWithInfo", func() {
+		It("sends a create request and returns a container with its info", func() {
+			spec := garden.ContainerSpec{
+				RootFSPath: "/some/roofs",
+			}
+
+			fakeConnection.CreateWithInfoReturns("some-handle", garden.ContainerInfo{State: "active"}, nil)
+
+			container, info, err := client.CreateWithInfo(spec)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(fakeConnection.CreateWithInfoArgsForCall(0)).Should(Equal(spec))
+
+			Ω(container.Handle()).Should(Equal("some-handle"))
+			Ω(info).Should(Equal(garden.ContainerInfo{State: "active"}))
+		})
+
+		Context("when there is a connection error", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeConnection.CreateWithInfoReturns("", garden.ContainerInfo{}, disaster)
+			})
+
+			It("returns it", func() {
+				_, _, err := client.CreateWithInfo(garden.ContainerSpec{})
+				Ω(err).Should(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("CreateWithEffectiveSpec", func() {
+		It("sends a create request and returns a container with its effective spec", func() {
+			spec := garden.ContainerSpec{
+				RootFSPath: "/some/roofs",
+			}
+
+			effective := garden.EffectiveSpec{
+				ContainerSpec: garden.ContainerSpec{Handle: "some-handle"},
+				ContainerIP:   "10.0.0.2",
+			}
+
+			fakeConnection.CreateWithEffectiveSpecReturns("some-handle", effective, nil)
+
+			container, returned, err := client.CreateWithEffectiveSpec(spec, "handle", "container_ip")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			sentSpec, sentFields := fakeConnection.CreateWithEffectiveSpecArgsForCall(0)
+			Ω(sentSpec).Should(Equal(spec))
+			Ω(sentFields).Should(Equal([]string{"handle", "container_ip"}))
+
+			Ω(container.Handle()).Should(Equal("some-handle"))
+			Ω(returned).Should(Equal(effective))
+		})
+
+		Context("when there is a connection error", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeConnection.CreateWithEffectiveSpecReturns("", garden.EffectiveSpec{}, disaster)
+			})
+
+			It("returns it", func() {
+				_, _, err := client.CreateWithEffectiveSpec(garden.ContainerSpec{})
+				Ω(err).Should(Equal(disaster))
+			})
+		})
+	})
+
 	Describe("Containers", func() {
 		It("sends a list request and returns all containers", func() {
 			fakeConnection.ListReturns([]string{"handle-a", "handle-b"}, nil)
@@ -230,6 +299,144 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("DestroyAsync", func() {
+		It("sends a destroy request that does not wait", func() {
+			Ω(client.DestroyAsync("some-handle")).Should(Succeed())
+
+			Ω(fakeConnection.DestroyAsyncArgsForCall(0)).Should(Equal("some-handle"))
+			Ω(fakeConnection.DestroyCallCount()).Should(BeZero())
+		})
+	})
+
+	Describe("ForceDestroy", func() {
+		It("sends a destroy request that overrides a lock", func() {
+			Ω(client.ForceDestroy("some-handle")).Should(Succeed())
+
+			Ω(fakeConnection.ForceDestroyArgsForCall(0)).Should(Equal("some-handle"))
+			Ω(fakeConnection.DestroyCallCount()).Should(BeZero())
+		})
+	})
+
+	Describe("DestroyMatching", func() {
+		It("sends a destroy matching request", func() {
+			multiErr := &garden.MultiError{Errors: map[string]*garden.Error{"b": garden.NewError("oh no!")}}
+			fakeConnection.DestroyMatchingReturns([]string{"a"}, multiErr, nil)
+
+			handles, returnedErr, err := client.DestroyMatching(garden.Properties{"foo": "bar"}, true)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(handles).Should(Equal([]string{"a"}))
+			Ω(returnedErr).Should(Equal(multiErr))
+
+			properties, dryRun := fakeConnection.DestroyMatchingArgsForCall(0)
+			Ω(properties).Should(Equal(garden.Properties{"foo": "bar"}))
+			Ω(dryRun).Should(BeTrue())
+		})
+
+		Context("when there is a connection error", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeConnection.DestroyMatchingReturns(nil, nil, disaster)
+			})
+
+			It("returns it", func() {
+				_, _, err := client.DestroyMatching(nil, false)
+				Ω(err).Should(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("DestroyAll", func() {
+		It("sends a request to destroy every container", func() {
+			fakeConnection.DestroyAllReturns([]string{"a", "b"}, nil, nil)
+
+			handles, multiErr, err := client.DestroyAll(true)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(multiErr).Should(BeNil())
+			Ω(handles).Should(Equal([]string{"a", "b"}))
+
+			Ω(fakeConnection.DestroyAllArgsForCall(0)).Should(BeTrue())
+		})
+	})
+
+	Describe("ContainersWithFilter", func() {
+		It("sends a filtered list request", func() {
+			fakeConnection.ListFilteredReturns([]string{"a", "b"}, nil)
+
+			filter := garden.PropertyFilter{garden.PropertyExists("task-id")}
+
+			containers, err := client.ContainersWithFilter(filter)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(containers).Should(HaveLen(2))
+			Ω(containers[0].Handle()).Should(Equal("a"))
+			Ω(containers[1].Handle()).Should(Equal("b"))
+
+			Ω(fakeConnection.ListFilteredArgsForCall(0)).Should(Equal(filter))
+		})
+
+		Context("when the filter is not well formed", func() {
+			It("returns an error without making a request", func() {
+				_, err := client.ContainersWithFilter(garden.PropertyFilter{{Name: "task-id", Op: "like"}})
+				Ω(err).Should(BeAssignableToTypeOf(garden.InvalidPropertyFilterError{}))
+
+				Ω(fakeConnection.ListFilteredCallCount()).Should(BeZero())
+			})
+		})
+	})
+
+	Describe("ContainersPage", func() {
+		It("sends a list page request", func() {
+			fakeConnection.ListPageReturns(garden.ContainerPage{Handles: []string{"a", "b"}, NextToken: "next"}, nil)
+
+			page, err := client.ContainersPage(garden.Properties{"foo": "bar"}, garden.ListOptions{Limit: 2, Token: "token"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page).Should(Equal(garden.ContainerPage{Handles: []string{"a", "b"}, NextToken: "next"}))
+
+			properties, opts := fakeConnection.ListPageArgsForCall(0)
+			Ω(properties).Should(Equal(garden.Properties{"foo": "bar"}))
+			Ω(opts).Should(Equal(garden.ListOptions{Limit: 2, Token: "token"}))
+		})
+
+		Context("when there is a connection error", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeConnection.ListPageReturns(garden.ContainerPage{}, disaster)
+			})
+
+			It("returns it", func() {
+				_, err := client.ContainersPage(nil, garden.ListOptions{})
+				Ω(err).Should(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Tombstone", func() {
+		It("sends a tombstone request", func() {
+			tombstone := garden.Tombstone{Handle: "some-handle", Reason: garden.DestroyReasonAPI}
+			fakeConnection.TombstoneReturns(tombstone, nil)
+
+			returned, err := client.Tombstone("some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(returned).Should(Equal(tombstone))
+
+			Ω(fakeConnection.TombstoneArgsForCall(0)).Should(Equal("some-handle"))
+		})
+
+		Context("when there is a connection error", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeConnection.TombstoneReturns(garden.Tombstone{}, disaster)
+			})
+
+			It("returns it", func() {
+				_, err := client.Tombstone("some-handle")
+				Ω(err).Should(Equal(disaster))
+			})
+		})
+	})
+
 	Describe("Lookup", func() {
 		It("sends a list request", func() {
 			fakeConnection.ListReturns([]string{"some-handle", "some-other-handle"}, nil)
@@ -9,7 +9,10 @@ import (
 	"io"
 	"net"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/garden"
@@ -26,18 +29,60 @@ var ErrInvalidMessage = errors.New("invalid message payload")
 type Connection interface {
 	Ping() error
 
+	// Connected reports whether the most recent request reached the server. It
+	// is false until a request has been made.
+	Connected() bool
+
+	// LastError returns the error that stopped the most recent request from
+	// reaching the server, or nil if it did.
+	LastError() error
+
 	Capacity() (garden.Capacity, error)
 
 	Create(spec garden.ContainerSpec) (string, error)
+
+	// Creates a container and returns its info as reported immediately after
+	// creation, in one round trip.
+	CreateWithInfo(spec garden.ContainerSpec) (string, garden.ContainerInfo, error)
+	CreateWithEffectiveSpec(spec garden.ContainerSpec, fields []string) (string, garden.EffectiveSpec, error)
 	List(properties garden.Properties) ([]string, error)
 
 	// Destroys the container with the given handle. If the container cannot be
 	// found, garden.ContainerNotFoundError is returned. If deletion fails for another
 	// reason, another error type is returned.
 	Destroy(handle string) error
+	DestroyAsync(handle string) error
+
+	// Destroys the container even if it holds a maintenance lock.
+	ForceDestroy(handle string) error
+
+	// Lists one page of the handles of containers matching the given
+	// properties, in handle order.
+	ListPage(properties garden.Properties, opts garden.ListOptions) (garden.ContainerPage, error)
+	ListFiltered(filter garden.PropertyFilter) ([]string, error)
+
+	// Destroys every container matching the given properties at the time the
+	// server receives the request, returning the handles destroyed and any
+	// per-handle failures. If dryRun is true, the matching handles are
+	// returned without destroying anything. The server refuses an empty
+	// filter; use DestroyAll instead.
+	DestroyMatching(properties garden.Properties, dryRun bool) ([]string, *garden.MultiError, error)
+
+	// Destroys every container, as DestroyMatching does with an empty filter.
+	DestroyAll(dryRun bool) ([]string, *garden.MultiError, error)
+
+	// Returns the record of a recently destroyed container. If the server has
+	// none, garden.ContainerNotFoundError is returned.
+	Tombstone(handle string) (garden.Tombstone, error)
 
 	Stop(handle string, kill bool) error
 
+	Lock(handle string, holder string, reason string, ttl time.Duration) error
+	Unlock(handle string, holder string) error
+
+	// Releases the container's maintenance lock, whoever holds it.
+	ForceUnlock(handle string) error
+
 	Info(handle string) (garden.ContainerInfo, error)
 	BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error)
 	BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error)
@@ -45,6 +90,11 @@ type Connection interface {
 	StreamIn(handle string, spec garden.StreamInSpec) error
 	StreamOut(handle string, spec garden.StreamOutSpec) (io.ReadCloser, error)
 
+	// Reads or writes a single small file without building a tar stream. Files
+	// larger than the server allows fail with garden.FileTooLargeError.
+	ReadFile(handle string, path string) ([]byte, error)
+	WriteFile(handle string, path string, data []byte, mode os.FileMode) error
+
 	CurrentBandwidthLimits(handle string) (garden.BandwidthLimits, error)
 	CurrentCPULimits(handle string) (garden.CPULimits, error)
 	CurrentDiskLimits(handle string) (garden.DiskLimits, error)
@@ -53,8 +103,13 @@ type Connection interface {
 	Run(handle string, spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error)
 	Attach(handle string, processID string, io garden.ProcessIO) (garden.Process, error)
 
+	// Reads the output captured of one stream of a process run with an
+	// OutputCapture, as garden.CapturedStdout or garden.CapturedStderr.
+	CapturedOutput(handle string, processID string, stream string) (io.ReadCloser, error)
+
 	NetIn(handle string, hostPort, containerPort uint32) (uint32, uint32, error)
 	NetOut(handle string, rule garden.NetOutRule) error
+	BulkNetOut(handle string, rules []garden.NetOutRule) error
 
 	SetGraceTime(handle string, graceTime time.Duration) error
 
@@ -63,7 +118,11 @@ type Connection interface {
 	SetProperty(handle string, name string, value string) error
 
 	Metrics(handle string) (garden.Metrics, error)
+	MetricsHistory(handle string, since time.Time) ([]garden.MetricsSample, error)
 	RemoveProperty(handle string, name string) error
+	CompareAndSetProperty(handle string, name string, oldValue string, newValue string) (bool, error)
+	SetProperties(handle string, properties garden.Properties) error
+	RemoveProperties(handle string, names []string) error
 }
 
 //go:generate counterfeiter . HijackStreamer
@@ -75,6 +134,29 @@ type HijackStreamer interface {
 type connection struct {
 	hijacker HijackStreamer
 	log      lager.Logger
+
+	// canonical has request bodies encoded with transport.CanonicalBytes
+	canonical bool
+
+	// shared with the connections derived from this one, as they talk to
+	// the same server
+	health *connectionHealth
+}
+
+type connectionHealth struct {
+	connected bool
+	lastErr   error
+	mu        sync.RWMutex
+}
+
+// ConnectionError is returned when the server at Address cannot be reached.
+type ConnectionError struct {
+	Address string
+	Err     error
+}
+
+func (err ConnectionError) Error() string {
+	return fmt.Sprintf("garden server at %s is unreachable: %s", err.Address, err.Err)
 }
 
 type Error struct {
@@ -95,6 +177,19 @@ func NewWithLogger(network, address string, logger lager.Logger) Connection {
 	return NewWithHijacker(hijacker, logger)
 }
 
+// NewValidated creates a connection and pings the server, returning a
+// ConnectionError straight away if it cannot be reached rather than on the
+// first real call.
+func NewValidated(network, address string, logger lager.Logger) (Connection, error) {
+	conn := NewWithLogger(network, address, logger)
+
+	if err := conn.Ping(); err != nil {
+		return nil, ConnectionError{Address: address, Err: err}
+	}
+
+	return conn, nil
+}
+
 func NewWithDialerAndLogger(dialer DialerFunc, log lager.Logger) Connection {
 	hijacker := NewHijackStreamerWithDialer(dialer)
 	return NewWithHijacker(hijacker, log)
@@ -104,6 +199,57 @@ func NewWithHijacker(hijacker HijackStreamer, log lager.Logger) Connection {
 	return &connection{
 		hijacker: hijacker,
 		log:      log,
+		health:   &connectionHealth{},
+	}
+}
+
+// WithIdempotencyKey returns a connection to the same server whose requests
+// carry the given idempotency key. The server applies a mutating request made
+// with a key only once: retrying it with the same key within the server's
+// window returns the original outcome, and reusing the key for a different
+// request fails with garden.IdempotencyConflictError.
+//
+// A connection created with NewWithHijacker sends the key only if its
+// HijackStreamer is one of this package's, and any other Connection, such as
+// a fake, is returned as it is. Request bodies sent with a key are encoded
+// canonically, as by WithCanonicalEncoding. The returned connection shares
+// conn's Connected and LastError.
+func WithIdempotencyKey(conn Connection, key string) Connection {
+	c, ok := conn.(*connection)
+	if !ok {
+		return conn
+	}
+
+	hijacker, ok := c.hijacker.(*hijackable)
+	if !ok {
+		return conn
+	}
+
+	return &connection{
+		hijacker:  hijacker.withHeader(transport.IdempotencyKeyHeader, key),
+		log:       c.log,
+		canonical: true,
+		health:    c.health,
+	}
+}
+
+// WithCanonicalEncoding returns a connection to the same server whose request
+// bodies are encoded with transport.CanonicalBytes, so that equal requests
+// are sent as the same bytes and can be signed or cached. It only applies to
+// this package's connections; any other Connection, such as a fake, is
+// returned as it is. The returned connection shares conn's Connected and
+// LastError.
+func WithCanonicalEncoding(conn Connection) Connection {
+	c, ok := conn.(*connection)
+	if !ok {
+		return conn
+	}
+
+	return &connection{
+		hijacker:  c.hijacker,
+		log:       c.log,
+		canonical: true,
+		health:    c.health,
 	}
 }
 
@@ -111,6 +257,36 @@ func (c *connection) Ping() error {
 	return c.do(routes.Ping, nil, &struct{}{}, nil, nil)
 }
 
+func (c *connection) Connected() bool {
+	c.health.mu.RLock()
+	defer c.health.mu.RUnlock()
+
+	return c.health.connected
+}
+
+func (c *connection) LastError() error {
+	c.health.mu.RLock()
+	defer c.health.mu.RUnlock()
+
+	return c.health.lastErr
+}
+
+// record notes whether a request reached the server. Errors returned by the
+// server itself still count as reaching it.
+func (c *connection) record(err error) {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+
+	if _, unreachable := err.(net.Error); unreachable {
+		c.health.connected = false
+		c.health.lastErr = err
+		return
+	}
+
+	c.health.connected = true
+	c.health.lastErr = nil
+}
+
 func (c *connection) Capacity() (garden.Capacity, error) {
 	capacity := garden.Capacity{}
 	err := c.do(routes.Capacity, nil, &capacity, nil, nil)
@@ -122,23 +298,89 @@ func (c *connection) Capacity() (garden.Capacity, error) {
 }
 
 func (c *connection) Create(spec garden.ContainerSpec) (string, error) {
-	res := struct {
-		Handle string `json:"handle"`
-	}{}
+	res := transport.CreateResponse{}
 
 	err := c.do(routes.Create, spec, &res, nil, nil)
 	if err != nil {
 		return "", err
 	}
 
+	c.logCreateWarnings(res)
+
 	return res.Handle, nil
 }
 
+func (c *connection) CreateWithInfo(spec garden.ContainerSpec) (string, garden.ContainerInfo, error) {
+	res := transport.CreateResponse{}
+
+	err := c.do(routes.Create, spec, &res, nil, url.Values{"echo_info": []string{"true"}})
+	if err != nil {
+		return "", garden.ContainerInfo{}, err
+	}
+
+	if res.Info == nil {
+		return "", garden.ContainerInfo{}, ErrInvalidMessage
+	}
+
+	c.logCreateWarnings(res)
+
+	info := *res.Info
+	info.Warnings = res.Warnings
+
+	return res.Handle, info, nil
+}
+
+func (c *connection) CreateWithEffectiveSpec(spec garden.ContainerSpec, fields []string) (string, garden.EffectiveSpec, error) {
+	res := transport.CreateResponse{}
+
+	query := url.Values{"echo_effective_spec": []string{"true"}}
+	if len(fields) > 0 {
+		query.Set("effective_spec_fields", strings.Join(fields, ","))
+	}
+
+	err := c.do(routes.Create, spec, &res, nil, query)
+	if err != nil {
+		return "", garden.EffectiveSpec{}, err
+	}
+
+	if res.EffectiveSpec == nil {
+		return "", garden.EffectiveSpec{}, ErrInvalidMessage
+	}
+
+	c.logCreateWarnings(res)
+
+	return res.Handle, *res.EffectiveSpec, nil
+}
+
+// logCreateWarnings logs what the server said about a container it created
+// other than as asked, as Create has no other way to tell the caller.
+func (c *connection) logCreateWarnings(res transport.CreateResponse) {
+	for _, warning := range res.Warnings {
+		c.log.Info("create-warning", lager.Data{"handle": res.Handle, "warning": warning})
+	}
+}
+
 func (c *connection) Stop(handle string, kill bool) error {
 	return c.do(
 		routes.Stop,
-		map[string]bool{
-			"kill": kill,
+		&transport.StopRequest{
+			Kill: kill,
+		},
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		nil,
+	)
+}
+
+func (c *connection) Lock(handle string, holder string, reason string, ttl time.Duration) error {
+	return c.do(
+		routes.Lock,
+		&transport.LockRequest{
+			Holder: holder,
+			Reason: reason,
+			TTL:    ttl,
 		},
 		&struct{}{},
 		rata.Params{
@@ -148,6 +390,30 @@ func (c *connection) Stop(handle string, kill bool) error {
 	)
 }
 
+func (c *connection) Unlock(handle string, holder string) error {
+	return c.do(
+		routes.Unlock,
+		nil,
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		url.Values{"holder": []string{holder}},
+	)
+}
+
+func (c *connection) ForceUnlock(handle string) error {
+	return c.do(
+		routes.Unlock,
+		nil,
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		url.Values{"force": []string{"true"}},
+	)
+}
+
 func (c *connection) Destroy(handle string) error {
 	return c.do(
 		routes.Destroy,
@@ -160,10 +426,80 @@ func (c *connection) Destroy(handle string) error {
 	)
 }
 
+func (c *connection) ForceDestroy(handle string) error {
+	return c.do(
+		routes.Destroy,
+		nil,
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		url.Values{"force": []string{"true"}},
+	)
+}
+
+func (c *connection) DestroyAsync(handle string) error {
+	return c.do(
+		routes.Destroy,
+		nil,
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		url.Values{"async": []string{"true"}},
+	)
+}
+
+func (c *connection) DestroyMatching(properties garden.Properties, dryRun bool) ([]string, *garden.MultiError, error) {
+	return c.destroyMatching(&transport.DestroyMatchingRequest{
+		Properties: properties,
+		DryRun:     dryRun,
+	})
+}
+
+func (c *connection) DestroyAll(dryRun bool) ([]string, *garden.MultiError, error) {
+	return c.destroyMatching(&transport.DestroyMatchingRequest{
+		DryRun: dryRun,
+		All:    true,
+	})
+}
+
+func (c *connection) destroyMatching(request *transport.DestroyMatchingRequest) ([]string, *garden.MultiError, error) {
+	res := transport.DestroyMatchingResponse{}
+
+	err := c.do(
+		routes.DestroyMatching,
+		request,
+		&res,
+		nil,
+		nil,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(res.Errors) > 0 {
+		return res.Handles, &garden.MultiError{Errors: res.Errors}, nil
+	}
+
+	return res.Handles, nil, nil
+}
+
+func (c *connection) Tombstone(handle string) (garden.Tombstone, error) {
+	res := garden.Tombstone{}
+
+	err := c.do(routes.Tombstone, nil, &res, rata.Params{"handle": handle}, nil)
+	if err != nil {
+		return garden.Tombstone{}, err
+	}
+
+	return res, nil
+}
+
 func (c *connection) Run(handle string, spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
 	reqBody := new(bytes.Buffer)
 
-	err := transport.WriteMessage(reqBody, spec)
+	err := c.writeMessage(reqBody, spec)
 	if err != nil {
 		return nil, err
 	}
@@ -177,8 +513,9 @@ func (c *connection) Run(handle string, spec garden.ProcessSpec, processIO garde
 		nil,
 		"application/json",
 	)
+	c.record(err)
 	if err != nil {
-		return nil, fmt.Errorf("hijack: %s", err)
+		return nil, err
 	}
 
 	return c.streamProcess(handle, processIO, hijackedConn, hijackedResponseReader)
@@ -197,6 +534,7 @@ func (c *connection) Attach(handle string, processID string, processIO garden.Pr
 		nil,
 		"",
 	)
+	c.record(err)
 	if err != nil {
 		return nil, err
 	}
@@ -321,10 +659,22 @@ func (c *connection) NetOut(handle string, rule garden.NetOutRule) error {
 	)
 }
 
+func (c *connection) BulkNetOut(handle string, rules []garden.NetOutRule) error {
+	return c.do(
+		routes.BulkNetOut,
+		&transport.BulkNetOutRequest{
+			Rules: rules,
+		},
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		nil,
+	)
+}
+
 func (c *connection) Property(handle string, name string) (string, error) {
-	var res struct {
-		Value string `json:"value"`
-	}
+	var res transport.PropertyResponse
 
 	err := c.do(
 		routes.Property,
@@ -343,8 +693,8 @@ func (c *connection) Property(handle string, name string) (string, error) {
 func (c *connection) SetProperty(handle string, name string, value string) error {
 	err := c.do(
 		routes.SetProperty,
-		map[string]string{
-			"value": value,
+		&transport.SetPropertyRequest{
+			Value: value,
 		},
 		&struct{}{},
 		rata.Params{
@@ -380,6 +730,54 @@ func (c *connection) RemoveProperty(handle string, name string) error {
 	return nil
 }
 
+func (c *connection) SetProperties(handle string, properties garden.Properties) error {
+	return c.do(
+		routes.SetProperties,
+		&transport.SetPropertiesRequest{
+			Properties: properties,
+		},
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		nil,
+	)
+}
+
+func (c *connection) RemoveProperties(handle string, names []string) error {
+	return c.do(
+		routes.RemoveProperties,
+		&transport.RemovePropertiesRequest{
+			Names: names,
+		},
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		nil,
+	)
+}
+
+func (c *connection) CompareAndSetProperty(handle string, name string, oldValue string, newValue string) (bool, error) {
+	var res transport.CompareAndSetPropertyResponse
+
+	err := c.do(
+		routes.CompareAndSetProperty,
+		&transport.CompareAndSetPropertyRequest{
+			Old: oldValue,
+			New: newValue,
+		},
+		&res,
+		rata.Params{
+			"handle": handle,
+			"key":    name,
+		},
+		nil,
+	)
+
+	return res.Swapped, err
+}
+
 func (c *connection) CurrentBandwidthLimits(handle string) (garden.BandwidthLimits, error) {
 	res := garden.BandwidthLimits{}
 
@@ -445,16 +843,26 @@ func (c *connection) CurrentMemoryLimits(handle string) (garden.MemoryLimits, er
 }
 
 func (c *connection) StreamIn(handle string, spec garden.StreamInSpec) error {
+	query := url.Values{
+		"user":        []string{spec.User},
+		"destination": []string{spec.Path},
+	}
+
+	if spec.ExpectedBytes > 0 {
+		query.Set("expected_bytes", strconv.FormatUint(spec.ExpectedBytes, 10))
+	}
+
+	if spec.Checksum != "" {
+		query.Set("checksum", spec.Checksum)
+	}
+
 	body, err := c.hijacker.Stream(
 		routes.StreamIn,
 		spec.TarStream,
 		rata.Params{
 			"handle": handle,
 		},
-		url.Values{
-			"user":        []string{spec.User},
-			"destination": []string{spec.Path},
-		},
+		query,
 		"application/x-tar",
 	)
 	if err != nil {
@@ -465,29 +873,93 @@ func (c *connection) StreamIn(handle string, spec garden.StreamInSpec) error {
 }
 
 func (c *connection) StreamOut(handle string, spec garden.StreamOutSpec) (io.ReadCloser, error) {
+	query := url.Values{
+		"user":   []string{spec.User},
+		"source": []string{spec.Path},
+	}
+
+	if !spec.ChangedSince.IsZero() {
+		query.Set("changed_since", spec.ChangedSince.Format(time.RFC3339Nano))
+	}
+
+	if spec.Resumable {
+		query.Set("resumable", "true")
+	}
+
+	if spec.Offset > 0 {
+		query.Set("offset", strconv.FormatInt(spec.Offset, 10))
+		query.Set("generation", spec.Generation)
+	}
+
 	return c.hijacker.Stream(
 		routes.StreamOut,
 		nil,
 		rata.Params{
 			"handle": handle,
 		},
-		url.Values{
-			"user":   []string{spec.User},
-			"source": []string{spec.Path},
+		query,
+		"",
+	)
+}
+
+func (c *connection) CapturedOutput(handle string, processID string, stream string) (io.ReadCloser, error) {
+	return c.hijacker.Stream(
+		routes.CapturedOutput,
+		nil,
+		rata.Params{
+			"handle": handle,
+			"pid":    processID,
+			"stream": stream,
 		},
+		nil,
 		"",
 	)
 }
 
+func (c *connection) ReadFile(handle string, path string) ([]byte, error) {
+	res := &transport.ReadFileResponse{}
+
+	err := c.do(
+		routes.ReadFile,
+		nil,
+		res,
+		rata.Params{
+			"handle": handle,
+		},
+		url.Values{
+			"path": []string{path},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+func (c *connection) WriteFile(handle string, path string, data []byte, mode os.FileMode) error {
+	return c.do(
+		routes.WriteFile,
+		&transport.WriteFileRequest{
+			Path: path,
+			Mode: mode,
+			Data: data,
+		},
+		&struct{}{},
+		rata.Params{
+			"handle": handle,
+		},
+		nil,
+	)
+}
+
 func (c *connection) List(filterProperties garden.Properties) ([]string, error) {
 	values := url.Values{}
 	for name, val := range filterProperties {
 		values[name] = []string{val}
 	}
 
-	res := &struct {
-		Handles []string
-	}{}
+	res := &transport.ListResponse{}
 
 	if err := c.do(
 		routes.List,
@@ -502,6 +974,45 @@ func (c *connection) List(filterProperties garden.Properties) ([]string, error)
 	return res.Handles, nil
 }
 
+func (c *connection) ListPage(properties garden.Properties, opts garden.ListOptions) (garden.ContainerPage, error) {
+	res := garden.ContainerPage{}
+
+	err := c.do(
+		routes.ListPage,
+		&transport.ListPageRequest{
+			Properties:  properties,
+			ListOptions: opts,
+		},
+		&res,
+		nil,
+		nil,
+	)
+	if err != nil {
+		return garden.ContainerPage{}, err
+	}
+
+	return res, nil
+}
+
+func (c *connection) ListFiltered(filter garden.PropertyFilter) ([]string, error) {
+	res := garden.ContainerPage{}
+
+	err := c.do(
+		routes.ListPage,
+		&transport.ListPageRequest{
+			Filter: filter,
+		},
+		&res,
+		nil,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Handles, nil
+}
+
 func (c *connection) SetGraceTime(handle string, graceTime time.Duration) error {
 	return c.do(routes.SetGraceTime, graceTime, &struct{}{}, rata.Params{"handle": handle}, nil)
 }
@@ -518,6 +1029,22 @@ func (c *connection) Metrics(handle string) (garden.Metrics, error) {
 	return res, err
 }
 
+func (c *connection) MetricsHistory(handle string, since time.Time) ([]garden.MetricsSample, error) {
+	var res transport.MetricsHistoryResponse
+
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.Format(time.RFC3339Nano))
+	}
+
+	err := c.do(routes.MetricsHistory, nil, &res, rata.Params{"handle": handle}, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Samples, nil
+}
+
 func (c *connection) Info(handle string) (garden.ContainerInfo, error) {
 	res := garden.ContainerInfo{}
 
@@ -547,6 +1074,14 @@ func (c *connection) BulkMetrics(handles []string) (map[string]garden.ContainerM
 	return res, err
 }
 
+func (c *connection) writeMessage(writer io.Writer, req interface{}) error {
+	if c.canonical {
+		return transport.WriteCanonicalMessage(writer, req)
+	}
+
+	return transport.WriteMessage(writer, req)
+}
+
 func (c *connection) do(
 	handler string,
 	req, res interface{},
@@ -558,7 +1093,7 @@ func (c *connection) do(
 	if req != nil {
 		buf := new(bytes.Buffer)
 
-		err := transport.WriteMessage(buf, req)
+		err := c.writeMessage(buf, req)
 		if err != nil {
 			return err
 		}
@@ -578,6 +1113,7 @@ func (c *connection) do(
 		query,
 		contentType,
 	)
+	c.record(err)
 	if err != nil {
 		return err
 	}
@@ -2,6 +2,8 @@ package connection
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,10 +12,13 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/routes"
+	"code.cloudfoundry.org/garden/transport"
 	"github.com/tedsuo/rata"
 )
 
@@ -23,6 +28,9 @@ type hijackable struct {
 	req               *rata.RequestGenerator
 	noKeepaliveClient *http.Client
 	dialer            DialerFunc
+
+	// set while the server advertises that it accepts gzipped request bodies
+	serverAcceptsGzip int32
 }
 
 func NewHijackStreamer(network, address string) HijackStreamer {
@@ -44,6 +52,23 @@ func NewHijackStreamerWithDialer(dialFunc DialerFunc) HijackStreamer {
 	}
 }
 
+// withHeader returns a copy of the hijacker that sets the header on every
+// request.
+func (h *hijackable) withHeader(name, value string) *hijackable {
+	req := rata.NewRequestGenerator("http://api", routes.Routes)
+	for n, values := range h.req.Header {
+		req.Header[n] = values
+	}
+	req.Header.Set(name, value)
+
+	return &hijackable{
+		req:               req,
+		noKeepaliveClient: h.noKeepaliveClient,
+		dialer:            h.dialer,
+		serverAcceptsGzip: atomic.LoadInt32(&h.serverAcceptsGzip),
+	}
+}
+
 func (h *hijackable) Hijack(handler string, body io.Reader, params rata.Params, query url.Values, contentType string) (net.Conn, *bufio.Reader, error) {
 	request, err := h.req.CreateRequest(handler, params, body)
 	if err != nil {
@@ -78,7 +103,13 @@ func (h *hijackable) Hijack(handler string, body io.Reader, params rata.Params,
 			return nil, nil, fmt.Errorf("Backend error: Exit status: %d, error reading response body: %s", httpResp.StatusCode, err)
 		}
 
-		return nil, nil, fmt.Errorf("Backend error: Exit status: %d, message: %s", httpResp.StatusCode, errRespBytes)
+		return nil, nil, hijackError(httpResp.StatusCode, errRespBytes)
+	}
+
+	if httpResp.Header.Get(transport.StreamTransportHeader) == transport.StreamTransportChunked {
+		// the server could not hijack its side, so the stream is the response
+		// body; let the client connection decode the chunking
+		return conn, bufio.NewReader(httpResp.Body), nil
 	}
 
 	hijackedConn, hijackedResponseReader := client.Hijack()
@@ -86,25 +117,48 @@ func (h *hijackable) Hijack(handler string, body io.Reader, params rata.Params,
 	return hijackedConn, hijackedResponseReader, nil
 }
 
-func (c *hijackable) Stream(handler string, body io.Reader, params rata.Params, query url.Values, contentType string) (io.ReadCloser, error) {
-	request, err := c.req.CreateRequest(handler, params, body)
-	if err != nil {
-		return nil, err
+// hijackError returns the typed error the server sent, so that callers can
+// tell it from other failures; anything else is reported with the response's
+// status and body.
+func hijackError(statusCode int, body []byte) error {
+	var typed struct {
+		Type string
 	}
 
-	if contentType != "" {
-		request.Header.Set("Content-Type", contentType)
+	if json.Unmarshal(body, &typed) == nil && typed.Type != "" {
+		var result garden.Error
+		if result.UnmarshalJSON(body) == nil {
+			return result.Err
+		}
 	}
 
-	if query != nil {
-		request.URL.RawQuery = query.Encode()
+	return fmt.Errorf("Backend error: Exit status: %d, message: %s", statusCode, body)
+}
+
+func (c *hijackable) Stream(handler string, body io.Reader, params rata.Params, query url.Values, contentType string) (io.ReadCloser, error) {
+	// only JSON bodies are compressed; file streams are usually compressed
+	// already
+	var plain *bytes.Buffer
+	if buf, ok := body.(*bytes.Buffer); ok && contentType == "application/json" &&
+		buf.Len() >= transport.CompressionThreshold && atomic.LoadInt32(&c.serverAcceptsGzip) == 1 {
+		plain = buf
 	}
 
-	httpResp, err := c.noKeepaliveClient.Do(request)
+	httpResp, err := c.send(handler, body, plain, params, query, contentType)
 	if err != nil {
 		return nil, err
 	}
 
+	if plain != nil && httpResp.StatusCode == http.StatusUnsupportedMediaType {
+		// the server stopped accepting gzip since it last said it did
+		httpResp.Body.Close()
+
+		httpResp, err = c.send(handler, plain, nil, params, query, contentType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
 		defer httpResp.Body.Close()
 
@@ -119,3 +173,52 @@ func (c *hijackable) Stream(handler string, body io.Reader, params rata.Params,
 
 	return httpResp.Body, nil
 }
+
+// send makes a request, gzipping the body if compress is set. compress must
+// hold the same contents as body.
+func (c *hijackable) send(handler string, body io.Reader, compress *bytes.Buffer, params rata.Params, query url.Values, contentType string) (*http.Response, error) {
+	if compress != nil {
+		gzipped := new(bytes.Buffer)
+
+		gzipWriter := gzip.NewWriter(gzipped)
+		if _, err := gzipWriter.Write(compress.Bytes()); err != nil {
+			return nil, err
+		}
+
+		if err := gzipWriter.Close(); err != nil {
+			return nil, err
+		}
+
+		body = gzipped
+	}
+
+	request, err := c.req.CreateRequest(handler, params, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	if compress != nil {
+		request.Header.Set("Content-Encoding", "gzip")
+	}
+
+	if query != nil {
+		request.URL.RawQuery = query.Encode()
+	}
+
+	httpResp, err := c.noKeepaliveClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(httpResp.Header.Get("Accept-Encoding"), "gzip") {
+		atomic.StoreInt32(&c.serverAcceptsGzip, 1)
+	} else {
+		atomic.StoreInt32(&c.serverAcceptsGzip, 0)
+	}
+
+	return httpResp, nil
+}
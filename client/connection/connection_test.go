@@ -3,6 +3,7 @@ package connection_test
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +25,7 @@ import (
 	"code.cloudfoundry.org/garden"
 	. "code.cloudfoundry.org/garden/client/connection"
 	"code.cloudfoundry.org/garden/client/connection/fakes"
+	"code.cloudfoundry.org/garden/routes"
 	"code.cloudfoundry.org/garden/transport"
 )
 
@@ -86,6 +88,105 @@ var _ = Describe("Connection", func() {
 		}
 	})
 
+	Describe("NewValidated", func() {
+		Context("when the server responds to a ping", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/ping"),
+						ghttp.RespondWith(200, "{}"),
+					),
+				)
+			})
+
+			It("returns a connected connection", func() {
+				conn, err := NewValidated(network, address, lagertest.NewTestLogger("test-connection"))
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(conn.Connected()).Should(BeTrue())
+			})
+		})
+
+		Context("when the server cannot be reached", func() {
+			BeforeEach(func() {
+				server.Close()
+			})
+
+			It("returns a ConnectionError naming the address", func() {
+				_, err := NewValidated(network, address, lagertest.NewTestLogger("test-connection"))
+				Ω(err).Should(BeAssignableToTypeOf(ConnectionError{}))
+
+				connErr := err.(ConnectionError)
+				Ω(connErr.Address).Should(Equal(address))
+				Ω(connErr.Err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Connected", func() {
+		It("is false before any request has been made", func() {
+			Ω(connection.Connected()).Should(BeFalse())
+			Ω(connection.LastError()).ShouldNot(HaveOccurred())
+		})
+
+		Context("when the server responds, even with an error", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/ping"),
+						ghttp.RespondWith(500, `{"Message":"oh no!"}`),
+					),
+				)
+			})
+
+			It("is true", func() {
+				Ω(connection.Ping()).Should(HaveOccurred())
+
+				Ω(connection.Connected()).Should(BeTrue())
+				Ω(connection.LastError()).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when the server cannot be reached", func() {
+			BeforeEach(func() {
+				server.Close()
+			})
+
+			It("is false and reports why", func() {
+				pingErr := connection.Ping()
+				Ω(pingErr).Should(HaveOccurred())
+
+				Ω(connection.Connected()).Should(BeFalse())
+				Ω(connection.LastError()).Should(Equal(pingErr))
+			})
+
+			It("is shared with the connections derived from it", func() {
+				derived := []Connection{
+					WithCanonicalEncoding(connection),
+					WithIdempotencyKey(connection, "some-key"),
+				}
+
+				pingErr := derived[0].Ping()
+				Ω(pingErr).Should(HaveOccurred())
+
+				Ω(connection.LastError()).Should(Equal(pingErr))
+				for _, conn := range derived {
+					Ω(conn.Connected()).Should(BeFalse())
+					Ω(conn.LastError()).Should(Equal(pingErr))
+				}
+			})
+		})
+	})
+
+	Describe("deriving a connection from one that is not this package's", func() {
+		It("returns it as it is", func() {
+			fake := new(fakes.FakeConnection)
+
+			Ω(WithCanonicalEncoding(fake)).Should(BeIdenticalTo(fake))
+			Ω(WithIdempotencyKey(fake, "some-key")).Should(BeIdenticalTo(fake))
+		})
+	})
+
 	Describe("Ping", func() {
 		Context("when the response is successful", func() {
 			BeforeEach(func() {
@@ -223,6 +324,23 @@ var _ = Describe("Connection", func() {
 			})
 		})
 
+		Context("with bind mounts out of lexical order", func() {
+			BeforeEach(func() {
+				spec = garden.ContainerSpec{
+					BindMounts: []garden.BindMount{
+						{SrcPath: "/src-z", DstPath: "/dst-z"},
+						{SrcPath: "/src-a", DstPath: "/dst-z/inner"},
+						{SrcPath: "/src-m", DstPath: "/dst-a"},
+					},
+				}
+			})
+
+			It("sends them in the order given", func() {
+				_, err := connection.Create(spec)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+
 		Context("with a fully specified ContainerSpec", func() {
 			BeforeEach(func() {
 				spec = garden.ContainerSpec{
@@ -259,6 +377,135 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("Creating with info", func() {
+		Context("when the server returns the info", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers", "echo_info=true"),
+						verifyRequestBody(&garden.ContainerSpec{Handle: "some-handle"}, &garden.ContainerSpec{}),
+						ghttp.RespondWith(200, marshalProto(&transport.CreateResponse{
+							Handle: "foohandle",
+							Info:   &garden.ContainerInfo{State: "active", ContainerIP: "10.0.0.2"},
+						}))))
+			})
+
+			It("returns the handle and the info", func() {
+				handle, info, err := connection.CreateWithInfo(garden.ContainerSpec{Handle: "some-handle"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(handle).Should(Equal("foohandle"))
+				Ω(info).Should(Equal(garden.ContainerInfo{State: "active", ContainerIP: "10.0.0.2"}))
+			})
+		})
+
+		Context("when the server warns about the spec", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers", "echo_info=true"),
+						ghttp.RespondWith(200, marshalProto(&transport.CreateResponse{
+							Handle:   "foohandle",
+							Info:     &garden.ContainerInfo{State: "active"},
+							Warnings: []string{"isolate_intra_subnet has no effect"},
+						}))))
+			})
+
+			It("returns the warnings in the info", func() {
+				_, info, err := connection.CreateWithInfo(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(info.Warnings).Should(Equal([]string{"isolate_intra_subnet has no effect"}))
+			})
+		})
+
+		Context("when the server does not return the info", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers", "echo_info=true"),
+						ghttp.RespondWith(200, marshalProto(&struct{ Handle string }{"foohandle"}))))
+			})
+
+			It("returns an error", func() {
+				_, _, err := connection.CreateWithInfo(garden.ContainerSpec{})
+				Ω(err).Should(Equal(ErrInvalidMessage))
+			})
+		})
+	})
+
+	Describe("Creating with the effective spec", func() {
+		Context("when the server returns the effective spec", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers", "echo_effective_spec=true&effective_spec_fields=handle%2Cmapped_ports"),
+						verifyRequestBody(&garden.ContainerSpec{Handle: "some-handle"}, &garden.ContainerSpec{}),
+						ghttp.RespondWith(200, marshalProto(&transport.CreateResponse{
+							Handle: "foohandle",
+							EffectiveSpec: &garden.EffectiveSpec{
+								ContainerSpec: garden.ContainerSpec{Handle: "foohandle"},
+								MappedPorts:   []garden.PortMapping{{HostPort: 1234, ContainerPort: 8080}},
+							},
+						}))))
+			})
+
+			It("returns the handle and the effective spec", func() {
+				handle, effective, err := connection.CreateWithEffectiveSpec(garden.ContainerSpec{Handle: "some-handle"}, []string{"handle", "mapped_ports"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(handle).Should(Equal("foohandle"))
+				Ω(effective).Should(Equal(garden.EffectiveSpec{
+					ContainerSpec: garden.ContainerSpec{Handle: "foohandle"},
+					MappedPorts:   []garden.PortMapping{{HostPort: 1234, ContainerPort: 8080}},
+				}))
+			})
+		})
+
+		Context("when the server does not return the effective spec", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers", "echo_effective_spec=true"),
+						ghttp.RespondWith(200, marshalProto(&struct{ Handle string }{"foohandle"}))))
+			})
+
+			It("returns an error", func() {
+				_, _, err := connection.CreateWithEffectiveSpec(garden.ContainerSpec{}, nil)
+				Ω(err).Should(Equal(ErrInvalidMessage))
+			})
+		})
+	})
+
+	Describe("Encoding requests canonically", func() {
+		var spec garden.ContainerSpec
+
+		BeforeEach(func() {
+			spec = garden.ContainerSpec{
+				Properties: garden.Properties{"b": "2", "a": "1"},
+				Env:        []string{"PATH=/bin", "HOME=/root"},
+			}
+
+			canonical, err := transport.CanonicalBytes(spec)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/containers"),
+					ghttp.VerifyBody(canonical),
+					ghttp.RespondWith(200, marshalProto(&struct{ Handle string }{"foohandle"}))))
+		})
+
+		It("sends the canonical bytes of the request", func() {
+			_, err := WithCanonicalEncoding(connection).Create(spec)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		Context("with an idempotency key", func() {
+			It("sends the canonical bytes of the request", func() {
+				_, err := WithIdempotencyKey(connection, "some-key").Create(spec)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+
 	Describe("Destroying", func() {
 		Context("when destroying succeeds", func() {
 			BeforeEach(func() {
@@ -287,6 +534,334 @@ var _ = Describe("Connection", func() {
 				Ω(err).Should(MatchError(garden.ContainerNotFoundError{Handle: "some handle"}))
 			})
 		})
+
+		Context("without waiting", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("DELETE", "/containers/foo", "async=true"),
+						ghttp.RespondWith(200, "{}")))
+			})
+
+			It("asks the server not to wait for the destroy", func() {
+				Ω(connection.DestroyAsync("foo")).Should(Succeed())
+			})
+		})
+
+		Context("by force", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("DELETE", "/containers/foo", "force=true"),
+						ghttp.RespondWith(200, "{}")))
+			})
+
+			It("asks the server to destroy it despite any lock", func() {
+				Ω(connection.ForceDestroy("foo")).Should(Succeed())
+			})
+		})
+
+		Context("when the container is locked", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("DELETE", "/containers/foo"),
+						ghttp.RespondWith(423, `{ "Type": "ContainerLockedError", "Handle": "foo", "Lock": { "holder": "10.0.0.1:1234", "reason": "debugging", "expires_at": "2016-01-02T03:04:05Z" } }`)))
+			})
+
+			It("returns a ContainerLockedError", func() {
+				err := connection.Destroy("foo")
+				Ω(err).Should(Equal(garden.ContainerLockedError{
+					Handle: "foo",
+					Lock: garden.ContainerLock{
+						Holder:    "10.0.0.1:1234",
+						Reason:    "debugging",
+						ExpiresAt: time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+					},
+				}))
+			})
+		})
+
+		Context("when a container with the handle is still being destroyed", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers"),
+						ghttp.RespondWith(409, `{ "Type": "HandleStillDestroyingError", "Handle": "foo", "EstimatedCompletion": "2016-01-02T03:04:05Z" }`)))
+			})
+
+			It("creating returns a HandleStillDestroyingError", func() {
+				_, err := connection.Create(garden.ContainerSpec{Handle: "foo"})
+				Ω(err).Should(Equal(garden.HandleStillDestroyingError{
+					Handle:              "foo",
+					EstimatedCompletion: time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+				}))
+			})
+		})
+
+		Context("with an idempotency key", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("DELETE", "/containers/foo"),
+						ghttp.VerifyHeaderKV("Idempotency-Key", "some-key"),
+						ghttp.RespondWith(200, "{}")))
+			})
+
+			It("sends the key", func() {
+				err := WithIdempotencyKey(connection, "some-key").Destroy("foo")
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Listing a page of containers", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/containers/list_page"),
+					verifyRequestBody(map[string]interface{}{
+						"properties": map[string]interface{}{"foo": "bar"},
+						"limit":      float64(2),
+						"token":      "some-token",
+					}, make(map[string]interface{})),
+					ghttp.RespondWith(200, `{"handles":["a","b"],"next_token":"next-token"}`)))
+		})
+
+		It("returns the page", func() {
+			page, err := connection.ListPage(garden.Properties{"foo": "bar"}, garden.ListOptions{Limit: 2, Token: "some-token"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(page).Should(Equal(garden.ContainerPage{Handles: []string{"a", "b"}, NextToken: "next-token"}))
+		})
+	})
+
+	Describe("Listing containers with a filter", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/containers/list_page"),
+					verifyRequestBody(map[string]interface{}{
+						"filter": []interface{}{
+							map[string]interface{}{"name": "task-id", "op": "exists"},
+							map[string]interface{}{"name": "app-guid", "op": "glob", "value": "abc*"},
+						},
+					}, make(map[string]interface{})),
+					ghttp.RespondWith(200, `{"handles":["a","b"]}`)))
+		})
+
+		It("sends the operators and returns the handles", func() {
+			handles, err := connection.ListFiltered(garden.PropertyFilter{
+				garden.PropertyExists("task-id"),
+				garden.PropertyHasPrefix("app-guid", "abc"),
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handles).Should(Equal([]string{"a", "b"}))
+		})
+	})
+
+	Describe("Destroying matching containers", func() {
+		Context("when destroying succeeds", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers/destroy_matching"),
+						verifyRequestBody(map[string]interface{}{
+							"properties": map[string]interface{}{"foo": "bar"},
+							"dry_run":    true,
+						}, make(map[string]interface{})),
+						ghttp.RespondWith(200, `{"handles":["a","b"]}`)))
+			})
+
+			It("returns the matching handles", func() {
+				handles, multiErr, err := connection.DestroyMatching(garden.Properties{"foo": "bar"}, true)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(multiErr).Should(BeNil())
+
+				Ω(handles).Should(Equal([]string{"a", "b"}))
+			})
+		})
+
+		Context("when some containers fail to be destroyed", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers/destroy_matching"),
+						ghttp.RespondWith(200, `{"handles":["a"],"errors":{"b":{"Type":"ContainerNotFoundError","Handle":"b"}}}`)))
+			})
+
+			It("returns the failures keyed by handle", func() {
+				handles, multiErr, err := connection.DestroyMatching(garden.Properties{"foo": "bar"}, false)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(handles).Should(Equal([]string{"a"}))
+
+				Ω(multiErr).ShouldNot(BeNil())
+				Ω(multiErr.Errors["b"].Err).Should(Equal(garden.ContainerNotFoundError{Handle: "b"}))
+			})
+		})
+	})
+
+	Describe("Destroying all containers", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/containers/destroy_matching"),
+					verifyRequestBody(map[string]interface{}{
+						"all": true,
+					}, make(map[string]interface{})),
+					ghttp.RespondWith(200, `{"handles":["a","b"]}`)))
+		})
+
+		It("asks for every container to be destroyed", func() {
+			handles, multiErr, err := connection.DestroyAll(false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(multiErr).Should(BeNil())
+
+			Ω(handles).Should(Equal([]string{"a", "b"}))
+		})
+	})
+
+	Describe("Compressing request bodies", func() {
+		var largeSpec garden.ContainerSpec
+
+		advertisingGzip := http.Header{"Accept-Encoding": []string{"gzip"}}
+
+		verifyGzipped := func(gzipped bool) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+
+				if !gzipped {
+					Ω(r.Header.Get("Content-Encoding")).Should(BeEmpty())
+					return
+				}
+
+				Ω(r.Header.Get("Content-Encoding")).Should(Equal("gzip"))
+
+				gzipReader, err := gzip.NewReader(r.Body)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				var spec garden.ContainerSpec
+				Ω(json.NewDecoder(gzipReader).Decode(&spec)).Should(Succeed())
+				Ω(spec.Env).Should(Equal(largeSpec.Env))
+			}
+		}
+
+		BeforeEach(func() {
+			largeSpec = garden.ContainerSpec{}
+			for i := 0; i < 1000; i++ {
+				largeSpec.Env = append(largeSpec.Env, fmt.Sprintf("VAR_%d=value", i))
+			}
+		})
+
+		Context("when the server has advertised that it accepts gzip", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(200, "{}", advertisingGzip),
+				)
+			})
+
+			It("gzips large JSON bodies", func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/containers"),
+						verifyGzipped(true),
+						ghttp.RespondWith(200, `{"handle":"foo"}`, advertisingGzip)))
+
+				Ω(connection.Ping()).Should(Succeed())
+
+				handle, err := connection.Create(largeSpec)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(handle).Should(Equal("foo"))
+			})
+
+			It("does not gzip small JSON bodies", func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						verifyGzipped(false),
+						ghttp.RespondWith(200, `{"handle":"foo"}`, advertisingGzip)))
+
+				Ω(connection.Ping()).Should(Succeed())
+
+				_, err := connection.Create(garden.ContainerSpec{Handle: "foo"})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("resends the body uncompressed if the server has since stopped accepting gzip", func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						verifyGzipped(true),
+						ghttp.RespondWith(415, `{"Type":"","Message":"gzip request bodies are not accepted by this server"}`)),
+					ghttp.CombineHandlers(
+						verifyGzipped(false),
+						func(w http.ResponseWriter, r *http.Request) {
+							defer GinkgoRecover()
+
+							var spec garden.ContainerSpec
+							Ω(json.NewDecoder(r.Body).Decode(&spec)).Should(Succeed())
+							Ω(spec.Env).Should(Equal(largeSpec.Env))
+						},
+						ghttp.RespondWith(200, `{"handle":"foo"}`)))
+
+				Ω(connection.Ping()).Should(Succeed())
+
+				handle, err := connection.Create(largeSpec)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(handle).Should(Equal("foo"))
+			})
+		})
+
+		Context("when the server has not advertised that it accepts gzip", func() {
+			It("does not gzip large JSON bodies", func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(200, "{}"),
+					ghttp.CombineHandlers(
+						verifyGzipped(false),
+						ghttp.RespondWith(200, `{"handle":"foo"}`)))
+
+				Ω(connection.Ping()).Should(Succeed())
+
+				_, err := connection.Create(largeSpec)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Getting a tombstone", func() {
+		Context("when the server has one", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo/tombstone"),
+						ghttp.RespondWith(200, `{"handle":"foo","reason":"grace-time","destroyed_at":"2016-01-02T03:04:05Z"}`)))
+			})
+
+			It("returns it", func() {
+				tombstone, err := connection.Tombstone("foo")
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(tombstone).Should(Equal(garden.Tombstone{
+					Handle:      "foo",
+					Reason:      garden.DestroyReasonGraceTime,
+					DestroyedAt: time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+				}))
+			})
+		})
+
+		Context("when the server has none", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo/tombstone"),
+						ghttp.RespondWith(404, `{"Type":"ContainerNotFoundError","Handle":"foo"}`)))
+			})
+
+			It("returns a ContainerNotFoundError", func() {
+				_, err := connection.Tombstone("foo")
+				Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "foo"}))
+			})
+		})
 	})
 
 	Describe("Stopping", func() {
@@ -306,6 +881,50 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("Locking", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/containers/foo/lock"),
+					verifyRequestBody(map[string]interface{}{
+						"holder": "frank",
+						"reason": "debugging",
+						"ttl":    float64(time.Hour),
+					}, make(map[string]interface{})),
+					ghttp.RespondWith(200, "{}")))
+		})
+
+		It("should lock the container", func() {
+			Ω(connection.Lock("foo", "frank", "debugging", time.Hour)).Should(Succeed())
+		})
+	})
+
+	Describe("Unlocking", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", "/containers/foo/lock", "holder=frank"),
+					ghttp.RespondWith(200, "{}")))
+		})
+
+		It("should unlock the container", func() {
+			Ω(connection.Unlock("foo", "frank")).Should(Succeed())
+		})
+	})
+
+	Describe("Force unlocking", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", "/containers/foo/lock", "force=true"),
+					ghttp.RespondWith(200, "{}")))
+		})
+
+		It("should unlock the container whoever holds the lock", func() {
+			Ω(connection.ForceUnlock("foo")).Should(Succeed())
+		})
+	})
+
 	Describe("fetching limit info", func() {
 		Describe("getting memory limits", func() {
 			BeforeEach(func() {
@@ -419,12 +1038,37 @@ var _ = Describe("Connection", func() {
 					Ports:    []garden.PortRange{garden.PortRangeFromPort(2), garden.PortRangeFromPort(4)},
 					ICMPs:    &garden.ICMPControl{Type: 3, Code: garden.ICMPControlCode(3)},
 					Log:      true,
-				}
-			})
+				}
+			})
+
+			It("should send the rule over the wire", func() {
+				Ω(connection.NetOut(handle, rule)).Should(Succeed())
+			})
+		})
+	})
+
+	Describe("BulkNetOut", func() {
+		It("should send every rule over the wire in one request", func() {
+			rules := []garden.NetOutRule{
+				{
+					Protocol: garden.ProtocolTCP,
+					Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP("1.2.3.4"))},
+					Ports:    []garden.PortRange{garden.PortRangeFromPort(2)},
+				},
+				{
+					Protocol: garden.ProtocolICMP,
+					ICMPs:    &garden.ICMPControl{Type: 3, Code: garden.ICMPControlCode(3)},
+					Log:      true,
+				},
+			}
 
-			It("should send the rule over the wire", func() {
-				Ω(connection.NetOut(handle, rule)).Should(Succeed())
-			})
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/containers/foo-handle/net/out/bulk"),
+					verifyRequestBody(&transport.BulkNetOutRequest{Rules: rules}, &transport.BulkNetOutRequest{}),
+					ghttp.RespondWith(200, "{}")))
+
+			Ω(connection.BulkNetOut("foo-handle", rules)).Should(Succeed())
 		})
 	})
 
@@ -524,6 +1168,83 @@ var _ = Describe("Connection", func() {
 
 	})
 
+	Describe("Set container properties", func() {
+		handle := "container-handle"
+
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", fmt.Sprintf("/containers/%s/properties", handle)),
+					verifyRequestBody(map[string]interface{}{
+						"properties": map[string]interface{}{"a": "1", "b": "2"},
+					}, make(map[string]interface{})),
+					ghttp.RespondWith(200, "{}")))
+		})
+
+		It("sends them in one request", func() {
+			err := connection.SetProperties(handle, garden.Properties{"a": "1", "b": "2"})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Describe("Remove container properties", func() {
+		handle := "container-handle"
+
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", fmt.Sprintf("/containers/%s/properties/remove", handle)),
+					verifyRequestBody(map[string]interface{}{
+						"names": []interface{}{"a", "b"},
+					}, make(map[string]interface{})),
+					ghttp.RespondWith(200, "{}")))
+		})
+
+		It("sends them in one request", func() {
+			err := connection.RemoveProperties(handle, []string{"a", "b"})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Describe("Compare and set container property", func() {
+		handle := "container-handle"
+		propertyName := "property_name"
+		var swapped bool
+
+		BeforeEach(func() {
+			swapped = true
+		})
+
+		JustBeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", fmt.Sprintf("/containers/%s/properties/%s/compare_and_set", handle, propertyName)),
+					verifyRequestBody(map[string]interface{}{
+						"old": "old-value",
+						"new": "new-value",
+					}, make(map[string]interface{})),
+					ghttp.RespondWith(200, fmt.Sprintf(`{"swapped": %t}`, swapped))))
+		})
+
+		It("reports that the property was swapped", func() {
+			result, err := connection.CompareAndSetProperty(handle, propertyName, "old-value", "new-value")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result).Should(BeTrue())
+		})
+
+		Context("when the property held some other value", func() {
+			BeforeEach(func() {
+				swapped = false
+			})
+
+			It("reports that it was not", func() {
+				result, err := connection.CompareAndSetProperty(handle, propertyName, "old-value", "new-value")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(result).Should(BeFalse())
+			})
+		})
+	})
+
 	Describe("Getting container metrics", func() {
 		handle := "container-handle"
 		metrics := garden.Metrics{
@@ -603,6 +1324,36 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("Getting container metrics history", func() {
+		sampledAt := time.Date(2016, 1, 2, 3, 4, 5, 6, time.UTC)
+		samples := []garden.MetricsSample{
+			{SampledAt: sampledAt, Metrics: garden.Metrics{CPUStat: garden.ContainerCPUStat{Usage: 1}}},
+			{SampledAt: sampledAt.Add(time.Second), Metrics: garden.Metrics{CPUStat: garden.ContainerCPUStat{Usage: 2}}},
+		}
+
+		It("returns the samples", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/containers/container-handle/metrics/history", ""),
+					ghttp.RespondWith(200, marshalProto(transport.MetricsHistoryResponse{Samples: samples}))))
+
+			history, err := connection.MetricsHistory("container-handle", time.Time{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(history).Should(Equal(samples))
+		})
+
+		It("asks only for the samples taken since the given time", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/containers/container-handle/metrics/history", "since=2016-01-02T03%3A04%3A05.000000006Z"),
+					ghttp.RespondWith(200, marshalProto(transport.MetricsHistoryResponse{Samples: samples[1:]}))))
+
+			history, err := connection.MetricsHistory("container-handle", sampledAt)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(history).Should(Equal(samples[1:]))
+		})
+	})
+
 	Describe("Setting the grace time", func() {
 		var (
 			status    int
@@ -663,6 +1414,10 @@ var _ = Describe("Connection", func() {
 					{HostPort: 1234, ContainerPort: 5678},
 					{HostPort: 1235, ContainerPort: 5679},
 				},
+				BindMounts: []garden.BindMount{
+					{SrcPath: "/src-z", DstPath: "/dst"},
+					{SrcPath: "/src-a", DstPath: "/dst/inner"},
+				},
 			}
 
 			server.AppendHandlers(
@@ -867,6 +1622,60 @@ var _ = Describe("Connection", func() {
 			})
 		})
 
+		Context("when the expected size is given", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/containers/foo-handle/files", "destination=%2Fbar&expected_bytes=14&user=alice"),
+						ghttp.RespondWith(200, "{}"),
+					),
+				)
+			})
+
+			It("sends it along with the stream", func() {
+				buffer := bytes.NewBufferString("chunk-1chunk-2")
+
+				err := connection.StreamIn("foo-handle", garden.StreamInSpec{User: "alice", Path: "/bar", TarStream: buffer, ExpectedBytes: 14})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when a checksum is given", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/containers/foo-handle/files", "checksum=abc123&destination=%2Fbar&user=alice"),
+						ghttp.RespondWith(200, "{}"),
+					),
+				)
+			})
+
+			It("sends it along with the stream", func() {
+				buffer := bytes.NewBufferString("chunk-1chunk-2")
+
+				err := connection.StreamIn("foo-handle", garden.StreamInSpec{User: "alice", Path: "/bar", TarStream: buffer, Checksum: "abc123"})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when the disk quota is exceeded", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/containers/foo-handle/files"),
+						ghttp.RespondWith(http.StatusRequestEntityTooLarge, `{"Type":"QuotaExceededError","Handle":"foo-handle"}`),
+					),
+				)
+			})
+
+			It("returns a QuotaExceededError", func() {
+				buffer := bytes.NewBufferString("chunk-1chunk-2")
+
+				err := connection.StreamIn("foo-handle", garden.StreamInSpec{User: "alice", Path: "/bar", TarStream: buffer, ExpectedBytes: 14})
+				Ω(err).Should(Equal(garden.QuotaExceededError{Handle: "foo-handle"}))
+			})
+		})
+
 		Context("when streaming in returns an error response", func() {
 			BeforeEach(func() {
 				server.AppendHandlers(
@@ -933,6 +1742,73 @@ var _ = Describe("Connection", func() {
 			})
 		})
 
+		Context("when only changed files are asked for", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo-handle/files", "changed_since=2016-01-02T03%3A04%3A05.5Z&source=%2Fbar&user=frank"),
+						ghttp.RespondWith(200, "hello-world!"),
+					),
+				)
+			})
+
+			It("sends the marker", func() {
+				reader, err := connection.StreamOut("foo-handle", garden.StreamOutSpec{
+					User:         "frank",
+					Path:         "/bar",
+					ChangedSince: time.Date(2016, 1, 2, 3, 4, 5, 500000000, time.UTC),
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				reader.Close()
+			})
+		})
+
+		Context("when resuming a resumable stream", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo-handle/files", "generation=abc123&offset=1024&resumable=true&source=%2Fbar&user=frank"),
+						ghttp.RespondWith(200, "hello-world!"),
+					),
+				)
+			})
+
+			It("sends the offset and generation", func() {
+				reader, err := connection.StreamOut("foo-handle", garden.StreamOutSpec{
+					User:       "frank",
+					Path:       "/bar",
+					Resumable:  true,
+					Offset:     1024,
+					Generation: "abc123",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				reader.Close()
+			})
+		})
+
+		Context("when the files changed since the stream being resumed began", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo-handle/files"),
+						ghttp.RespondWith(409, `{ "Type": "StreamOutChangedError", "Handle": "foo-handle", "Path": "/bar" }`),
+					),
+				)
+			})
+
+			It("returns a StreamOutChangedError", func() {
+				_, err := connection.StreamOut("foo-handle", garden.StreamOutSpec{
+					Path:       "/bar",
+					Resumable:  true,
+					Offset:     1024,
+					Generation: "abc123",
+				})
+				Ω(err).Should(Equal(garden.StreamOutChangedError{Handle: "foo-handle", Path: "/bar"}))
+			})
+		})
+
 		Context("when streaming fails", func() {
 			BeforeEach(func() {
 				server.AppendHandlers(
@@ -956,6 +1832,101 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("Reading captured output", func() {
+		Context("when the output was captured", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo-handle/processes/some-process/captured/stdout"),
+						ghttp.RespondWith(200, "hello-world!"),
+					),
+				)
+			})
+
+			It("returns the output", func() {
+				reader, err := connection.CapturedOutput("foo-handle", "some-process", garden.CapturedStdout)
+				Ω(err).ShouldNot(HaveOccurred())
+				defer reader.Close()
+
+				Ω(ioutil.ReadAll(reader)).Should(Equal([]byte("hello-world!")))
+			})
+		})
+
+		Context("when the process's output was not captured", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo-handle/processes/some-process/captured/stdout"),
+						ghttp.RespondWith(404, `{ "Type": "ProcessNotFoundError", "Handle": "foo-handle", "ProcessID": "some-process" }`),
+					),
+				)
+			})
+
+			It("returns a ProcessNotFoundError", func() {
+				_, err := connection.CapturedOutput("foo-handle", "some-process", garden.CapturedStdout)
+				Ω(err).Should(Equal(garden.ProcessNotFoundError{Handle: "foo-handle", ProcessID: "some-process"}))
+			})
+		})
+	})
+
+	Describe("Reading a file", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/containers/foo-handle/file", "path=%2Fbar"),
+					ghttp.RespondWithJSONEncoded(200, &transport.ReadFileResponse{Data: []byte("hello-world!")}),
+				),
+			)
+		})
+
+		It("returns the file's contents", func() {
+			data, err := connection.ReadFile("foo-handle", "/bar")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(data).Should(Equal([]byte("hello-world!")))
+		})
+	})
+
+	Describe("Writing a file", func() {
+		Context("when the server accepts it", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/containers/foo-handle/file"),
+						ghttp.VerifyJSONRepresenting(&transport.WriteFileRequest{
+							Path: "/bar",
+							Mode: 0600,
+							Data: []byte("hello-world!"),
+						}),
+						ghttp.RespondWith(200, "{}"),
+					),
+				)
+			})
+
+			It("sends the file's path, mode and contents", func() {
+				err := connection.WriteFile("foo-handle", "/bar", []byte("hello-world!"), 0600)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when the file is too large", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/containers/foo-handle/file"),
+						ghttp.RespondWithJSONEncoded(http.StatusRequestEntityTooLarge, &garden.Error{
+							Err: garden.FileTooLargeError{Handle: "foo-handle", Path: "/bar", Limit: 4},
+						}),
+					),
+				)
+			})
+
+			It("returns a FileTooLargeError", func() {
+				err := connection.WriteFile("foo-handle", "/bar", []byte("hello-world!"), 0600)
+				Ω(err).Should(Equal(garden.FileTooLargeError{Handle: "foo-handle", Path: "/bar", Limit: 4}))
+			})
+		})
+	})
+
 	Describe("Running", func() {
 		var (
 			spec         garden.ProcessSpec
@@ -1165,7 +2136,12 @@ var _ = Describe("Connection", func() {
 		})
 
 		Context("when the process is killed", func() {
+			var clientClosed chan struct{}
+
 			BeforeEach(func() {
+				closed := make(chan struct{})
+				clientClosed = closed
+
 				server.AppendHandlers(
 					ghttp.CombineHandlers(
 						ghttp.VerifyRequest("POST", "/containers/foo-handle/processes"),
@@ -1197,6 +2173,10 @@ var _ = Describe("Connection", func() {
 								"process_id":  "process-handle",
 								"exit_status": 3,
 							})
+
+							// the client closes its end once it has the exit status
+							io.Copy(ioutil.Discard, br)
+							close(closed)
 						},
 					),
 					emptyStdoutStream("foo-handle", "process-handle", 123),
@@ -1217,6 +2197,19 @@ var _ = Describe("Connection", func() {
 				Ω(err).ShouldNot(HaveOccurred())
 				Ω(status).Should(Equal(3))
 			})
+
+			It("does nothing once the process has exited", func() {
+				process, err := connection.Run("foo-handle", garden.ProcessSpec{}, garden.ProcessIO{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(process.Signal(garden.SignalKill)).Should(Succeed())
+
+				_, err = process.Wait()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(clientClosed).Should(BeClosed())
+				Ω(process.Signal(garden.SignalKill)).Should(Succeed())
+			})
 		})
 
 		Context("when the process's window is resized", func() {
@@ -1455,6 +2448,54 @@ var _ = Describe("Connection", func() {
 				Ω(err).Should(MatchError(ContainSubstring("an error occurred!")))
 			})
 		})
+
+		Context("when the server returns a typed error", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/containers/foo-handle/processes"),
+					ghttp.RespondWith(400, `{"Type":"InvalidPathError","Message":"invalid path /proc: under denied prefix /proc","Path":"/proc","Reason":"under denied prefix /proc"}`),
+				))
+			})
+
+			It("returns it as its type", func() {
+				_, err := connection.Run("foo-handle", garden.ProcessSpec{Path: "lol"}, garden.ProcessIO{})
+				Ω(err).Should(Equal(garden.InvalidPathError{Path: "/proc", Rule: "under denied prefix /proc"}))
+			})
+		})
+	})
+
+	Describe("Hijacking a stream", func() {
+		Context("when the server sends the stream as a chunked body", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/containers/foo-handle/processes/process-handle/attaches/42/stdout"),
+						func(w http.ResponseWriter, r *http.Request) {
+							w.Header().Set(transport.StreamTransportHeader, transport.StreamTransportChunked)
+							w.WriteHeader(http.StatusOK)
+
+							w.Write([]byte("chunk-1;"))
+							w.(http.Flusher).Flush()
+							w.Write([]byte("chunk-2;"))
+						},
+					),
+				)
+			})
+
+			It("returns a reader of the decoded body", func() {
+				conn, br, err := hijacker.Hijack(
+					routes.Stdout,
+					nil,
+					rata.Params{"handle": "foo-handle", "pid": "process-handle", "streamid": "42"},
+					nil,
+					"application/json",
+				)
+				Ω(err).ShouldNot(HaveOccurred())
+				defer conn.Close()
+
+				Ω(ioutil.ReadAll(br)).Should(Equal([]byte("chunk-1;chunk-2;")))
+			})
+		})
 	})
 
 	Describe("Attaching", func() {
@@ -3,6 +3,7 @@ package connectionfakes
 
 import (
 	"io"
+	"os"
 	"sync"
 	"time"
 
@@ -240,6 +241,213 @@ type FakeConnection struct {
 	removePropertyReturns struct {
 		result1 error
 	}
+	DestroyMatchingStub        func(properties garden.Properties, dryRun bool) ([]string, *garden.MultiError, error)
+	destroyMatchingMutex       sync.RWMutex
+	destroyMatchingArgsForCall []struct {
+		properties garden.Properties
+		dryRun     bool
+	}
+	destroyMatchingReturns struct {
+		result1 []string
+		result2 *garden.MultiError
+		result3 error
+	}
+	CreateWithInfoStub        func(spec garden.ContainerSpec) (string, garden.ContainerInfo, error)
+	createWithInfoMutex       sync.RWMutex
+	createWithInfoArgsForCall []struct {
+		spec garden.ContainerSpec
+	}
+	createWithInfoReturns struct {
+		result1 string
+		result2 garden.ContainerInfo
+		result3 error
+	}
+	CreateWithEffectiveSpecStub        func(spec garden.ContainerSpec, fields []string) (string, garden.EffectiveSpec, error)
+	createWithEffectiveSpecMutex       sync.RWMutex
+	createWithEffectiveSpecArgsForCall []struct {
+		spec   garden.ContainerSpec
+		fields []string
+	}
+	createWithEffectiveSpecReturns struct {
+		result1 string
+		result2 garden.EffectiveSpec
+		result3 error
+	}
+	ConnectedStub        func() bool
+	connectedMutex       sync.RWMutex
+	connectedArgsForCall []struct{}
+	connectedReturns     struct {
+		result1 bool
+	}
+	LastErrorStub        func() error
+	lastErrorMutex       sync.RWMutex
+	lastErrorArgsForCall []struct{}
+	lastErrorReturns     struct {
+		result1 error
+	}
+	TombstoneStub        func(handle string) (garden.Tombstone, error)
+	tombstoneMutex       sync.RWMutex
+	tombstoneArgsForCall []struct {
+		handle string
+	}
+	tombstoneReturns struct {
+		result1 garden.Tombstone
+		result2 error
+	}
+	ReadFileStub        func(handle string, path string) ([]byte, error)
+	readFileMutex       sync.RWMutex
+	readFileArgsForCall []struct {
+		handle string
+		path   string
+	}
+	readFileReturns struct {
+		result1 []byte
+		result2 error
+	}
+	WriteFileStub        func(handle string, path string, data []byte, mode os.FileMode) error
+	writeFileMutex       sync.RWMutex
+	writeFileArgsForCall []struct {
+		handle string
+		path   string
+		data   []byte
+		mode   os.FileMode
+	}
+	writeFileReturns struct {
+		result1 error
+	}
+	ListPageStub        func(properties garden.Properties, opts garden.ListOptions) (garden.ContainerPage, error)
+	listPageMutex       sync.RWMutex
+	listPageArgsForCall []struct {
+		properties garden.Properties
+		opts       garden.ListOptions
+	}
+	listPageReturns struct {
+		result1 garden.ContainerPage
+		result2 error
+	}
+	BulkNetOutStub        func(handle string, rules []garden.NetOutRule) error
+	bulkNetOutMutex       sync.RWMutex
+	bulkNetOutArgsForCall []struct {
+		handle string
+		rules  []garden.NetOutRule
+	}
+	bulkNetOutReturns struct {
+		result1 error
+	}
+	MetricsHistoryStub        func(handle string, since time.Time) ([]garden.MetricsSample, error)
+	metricsHistoryMutex       sync.RWMutex
+	metricsHistoryArgsForCall []struct {
+		handle string
+		since  time.Time
+	}
+	metricsHistoryReturns struct {
+		result1 []garden.MetricsSample
+		result2 error
+	}
+	DestroyAsyncStub        func(handle string) error
+	destroyAsyncMutex       sync.RWMutex
+	destroyAsyncArgsForCall []struct {
+		handle string
+	}
+	destroyAsyncReturns struct {
+		result1 error
+	}
+	ForceDestroyStub        func(handle string) error
+	forceDestroyMutex       sync.RWMutex
+	forceDestroyArgsForCall []struct {
+		handle string
+	}
+	forceDestroyReturns struct {
+		result1 error
+	}
+	CompareAndSetPropertyStub        func(handle string, name string, oldValue string, newValue string) (bool, error)
+	compareAndSetPropertyMutex       sync.RWMutex
+	compareAndSetPropertyArgsForCall []struct {
+		handle   string
+		name     string
+		oldValue string
+		newValue string
+	}
+	compareAndSetPropertyReturns struct {
+		result1 bool
+		result2 error
+	}
+	SetPropertiesStub        func(handle string, properties garden.Properties) error
+	setPropertiesMutex       sync.RWMutex
+	setPropertiesArgsForCall []struct {
+		handle     string
+		properties garden.Properties
+	}
+	setPropertiesReturns struct {
+		result1 error
+	}
+	RemovePropertiesStub        func(handle string, names []string) error
+	removePropertiesMutex       sync.RWMutex
+	removePropertiesArgsForCall []struct {
+		handle string
+		names  []string
+	}
+	removePropertiesReturns struct {
+		result1 error
+	}
+	ListFilteredStub        func(filter garden.PropertyFilter) ([]string, error)
+	listFilteredMutex       sync.RWMutex
+	listFilteredArgsForCall []struct {
+		filter garden.PropertyFilter
+	}
+	listFilteredReturns struct {
+		result1 []string
+		result2 error
+	}
+	CapturedOutputStub        func(handle string, processID string, stream string) (io.ReadCloser, error)
+	capturedOutputMutex       sync.RWMutex
+	capturedOutputArgsForCall []struct {
+		handle    string
+		processID string
+		stream    string
+	}
+	capturedOutputReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	DestroyAllStub        func(dryRun bool) ([]string, *garden.MultiError, error)
+	destroyAllMutex       sync.RWMutex
+	destroyAllArgsForCall []struct {
+		dryRun bool
+	}
+	destroyAllReturns struct {
+		result1 []string
+		result2 *garden.MultiError
+		result3 error
+	}
+	LockStub        func(handle string, holder string, reason string, ttl time.Duration) error
+	lockMutex       sync.RWMutex
+	lockArgsForCall []struct {
+		handle string
+		holder string
+		reason string
+		ttl    time.Duration
+	}
+	lockReturns struct {
+		result1 error
+	}
+	UnlockStub        func(handle string, holder string) error
+	unlockMutex       sync.RWMutex
+	unlockArgsForCall []struct {
+		handle string
+		holder string
+	}
+	unlockReturns struct {
+		result1 error
+	}
+	ForceUnlockStub        func(handle string) error
+	forceUnlockMutex       sync.RWMutex
+	forceUnlockArgsForCall []struct {
+		handle string
+	}
+	forceUnlockReturns struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -1096,6 +1304,771 @@ func (fake *FakeConnection) RemovePropertyReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeConnection) DestroyMatching(properties garden.Properties, dryRun bool) ([]string, *garden.MultiError, error) {
+	fake.destroyMatchingMutex.Lock()
+	fake.destroyMatchingArgsForCall = append(fake.destroyMatchingArgsForCall, struct {
+		properties garden.Properties
+		dryRun     bool
+	}{properties, dryRun})
+	fake.recordInvocation("DestroyMatching", []interface{}{properties, dryRun})
+	fake.destroyMatchingMutex.Unlock()
+	if fake.DestroyMatchingStub != nil {
+		return fake.DestroyMatchingStub(properties, dryRun)
+	} else {
+		return fake.destroyMatchingReturns.result1, fake.destroyMatchingReturns.result2, fake.destroyMatchingReturns.result3
+	}
+}
+
+func (fake *FakeConnection) DestroyMatchingCallCount() int {
+	fake.destroyMatchingMutex.RLock()
+	defer fake.destroyMatchingMutex.RUnlock()
+	return len(fake.destroyMatchingArgsForCall)
+}
+
+func (fake *FakeConnection) DestroyMatchingArgsForCall(i int) (garden.Properties, bool) {
+	fake.destroyMatchingMutex.RLock()
+	defer fake.destroyMatchingMutex.RUnlock()
+	return fake.destroyMatchingArgsForCall[i].properties, fake.destroyMatchingArgsForCall[i].dryRun
+}
+
+func (fake *FakeConnection) DestroyMatchingReturns(result1 []string, result2 *garden.MultiError, result3 error) {
+	fake.DestroyMatchingStub = nil
+	fake.destroyMatchingReturns = struct {
+		result1 []string
+		result2 *garden.MultiError
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeConnection) CreateWithInfo(spec garden.ContainerSpec) (string, garden.ContainerInfo, error) {
+	fake.createWithInfoMutex.Lock()
+	fake.createWithInfoArgsForCall = append(fake.createWithInfoArgsForCall, struct {
+		spec garden.ContainerSpec
+	}{spec})
+	fake.recordInvocation("CreateWithInfo", []interface{}{spec})
+	fake.createWithInfoMutex.Unlock()
+	if fake.CreateWithInfoStub != nil {
+		return fake.CreateWithInfoStub(spec)
+	} else {
+		return fake.createWithInfoReturns.result1, fake.createWithInfoReturns.result2, fake.createWithInfoReturns.result3
+	}
+}
+
+func (fake *FakeConnection) CreateWithInfoCallCount() int {
+	fake.createWithInfoMutex.RLock()
+	defer fake.createWithInfoMutex.RUnlock()
+	return len(fake.createWithInfoArgsForCall)
+}
+
+func (fake *FakeConnection) CreateWithInfoArgsForCall(i int) garden.ContainerSpec {
+	fake.createWithInfoMutex.RLock()
+	defer fake.createWithInfoMutex.RUnlock()
+	return fake.createWithInfoArgsForCall[i].spec
+}
+
+func (fake *FakeConnection) CreateWithInfoReturns(result1 string, result2 garden.ContainerInfo, result3 error) {
+	fake.CreateWithInfoStub = nil
+	fake.createWithInfoReturns = struct {
+		result1 string
+		result2 garden.ContainerInfo
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeConnection) CreateWithEffectiveSpec(spec garden.ContainerSpec, fields []string) (string, garden.EffectiveSpec, error) {
+	var fieldsCopy []string
+	if fields != nil {
+		fieldsCopy = make([]string, len(fields))
+		copy(fieldsCopy, fields)
+	}
+	fake.createWithEffectiveSpecMutex.Lock()
+	fake.createWithEffectiveSpecArgsForCall = append(fake.createWithEffectiveSpecArgsForCall, struct {
+		spec   garden.ContainerSpec
+		fields []string
+	}{spec, fieldsCopy})
+	fake.recordInvocation("CreateWithEffectiveSpec", []interface{}{spec, fieldsCopy})
+	fake.createWithEffectiveSpecMutex.Unlock()
+	if fake.CreateWithEffectiveSpecStub != nil {
+		return fake.CreateWithEffectiveSpecStub(spec, fields)
+	} else {
+		return fake.createWithEffectiveSpecReturns.result1, fake.createWithEffectiveSpecReturns.result2, fake.createWithEffectiveSpecReturns.result3
+	}
+}
+
+func (fake *FakeConnection) CreateWithEffectiveSpecCallCount() int {
+	fake.createWithEffectiveSpecMutex.RLock()
+	defer fake.createWithEffectiveSpecMutex.RUnlock()
+	return len(fake.createWithEffectiveSpecArgsForCall)
+}
+
+func (fake *FakeConnection) CreateWithEffectiveSpecArgsForCall(i int) (garden.ContainerSpec, []string) {
+	fake.createWithEffectiveSpecMutex.RLock()
+	defer fake.createWithEffectiveSpecMutex.RUnlock()
+	return fake.createWithEffectiveSpecArgsForCall[i].spec, fake.createWithEffectiveSpecArgsForCall[i].fields
+}
+
+func (fake *FakeConnection) CreateWithEffectiveSpecReturns(result1 string, result2 garden.EffectiveSpec, result3 error) {
+	fake.CreateWithEffectiveSpecStub = nil
+	fake.createWithEffectiveSpecReturns = struct {
+		result1 string
+		result2 garden.EffectiveSpec
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeConnection) Connected() bool {
+	fake.connectedMutex.Lock()
+	fake.connectedArgsForCall = append(fake.connectedArgsForCall, struct{}{})
+	fake.recordInvocation("Connected", []interface{}{})
+	fake.connectedMutex.Unlock()
+	if fake.ConnectedStub != nil {
+		return fake.ConnectedStub()
+	} else {
+		return fake.connectedReturns.result1
+	}
+}
+
+func (fake *FakeConnection) ConnectedCallCount() int {
+	fake.connectedMutex.RLock()
+	defer fake.connectedMutex.RUnlock()
+	return len(fake.connectedArgsForCall)
+}
+
+func (fake *FakeConnection) ConnectedReturns(result1 bool) {
+	fake.ConnectedStub = nil
+	fake.connectedReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeConnection) LastError() error {
+	fake.lastErrorMutex.Lock()
+	fake.lastErrorArgsForCall = append(fake.lastErrorArgsForCall, struct{}{})
+	fake.recordInvocation("LastError", []interface{}{})
+	fake.lastErrorMutex.Unlock()
+	if fake.LastErrorStub != nil {
+		return fake.LastErrorStub()
+	} else {
+		return fake.lastErrorReturns.result1
+	}
+}
+
+func (fake *FakeConnection) LastErrorCallCount() int {
+	fake.lastErrorMutex.RLock()
+	defer fake.lastErrorMutex.RUnlock()
+	return len(fake.lastErrorArgsForCall)
+}
+
+func (fake *FakeConnection) LastErrorReturns(result1 error) {
+	fake.LastErrorStub = nil
+	fake.lastErrorReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) Tombstone(handle string) (garden.Tombstone, error) {
+	fake.tombstoneMutex.Lock()
+	fake.tombstoneArgsForCall = append(fake.tombstoneArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.recordInvocation("Tombstone", []interface{}{handle})
+	fake.tombstoneMutex.Unlock()
+	if fake.TombstoneStub != nil {
+		return fake.TombstoneStub(handle)
+	} else {
+		return fake.tombstoneReturns.result1, fake.tombstoneReturns.result2
+	}
+}
+
+func (fake *FakeConnection) TombstoneCallCount() int {
+	fake.tombstoneMutex.RLock()
+	defer fake.tombstoneMutex.RUnlock()
+	return len(fake.tombstoneArgsForCall)
+}
+
+func (fake *FakeConnection) TombstoneArgsForCall(i int) string {
+	fake.tombstoneMutex.RLock()
+	defer fake.tombstoneMutex.RUnlock()
+	return fake.tombstoneArgsForCall[i].handle
+}
+
+func (fake *FakeConnection) TombstoneReturns(result1 garden.Tombstone, result2 error) {
+	fake.TombstoneStub = nil
+	fake.tombstoneReturns = struct {
+		result1 garden.Tombstone
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) ReadFile(handle string, path string) ([]byte, error) {
+	fake.readFileMutex.Lock()
+	fake.readFileArgsForCall = append(fake.readFileArgsForCall, struct {
+		handle string
+		path   string
+	}{handle, path})
+	fake.recordInvocation("ReadFile", []interface{}{handle, path})
+	fake.readFileMutex.Unlock()
+	if fake.ReadFileStub != nil {
+		return fake.ReadFileStub(handle, path)
+	} else {
+		return fake.readFileReturns.result1, fake.readFileReturns.result2
+	}
+}
+
+func (fake *FakeConnection) ReadFileCallCount() int {
+	fake.readFileMutex.RLock()
+	defer fake.readFileMutex.RUnlock()
+	return len(fake.readFileArgsForCall)
+}
+
+func (fake *FakeConnection) ReadFileArgsForCall(i int) (string, string) {
+	fake.readFileMutex.RLock()
+	defer fake.readFileMutex.RUnlock()
+	return fake.readFileArgsForCall[i].handle, fake.readFileArgsForCall[i].path
+}
+
+func (fake *FakeConnection) ReadFileReturns(result1 []byte, result2 error) {
+	fake.ReadFileStub = nil
+	fake.readFileReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) WriteFile(handle string, path string, data []byte, mode os.FileMode) error {
+	var dataCopy []byte
+	if data != nil {
+		dataCopy = make([]byte, len(data))
+		copy(dataCopy, data)
+	}
+	fake.writeFileMutex.Lock()
+	fake.writeFileArgsForCall = append(fake.writeFileArgsForCall, struct {
+		handle string
+		path   string
+		data   []byte
+		mode   os.FileMode
+	}{handle, path, dataCopy, mode})
+	fake.recordInvocation("WriteFile", []interface{}{handle, path, dataCopy, mode})
+	fake.writeFileMutex.Unlock()
+	if fake.WriteFileStub != nil {
+		return fake.WriteFileStub(handle, path, data, mode)
+	} else {
+		return fake.writeFileReturns.result1
+	}
+}
+
+func (fake *FakeConnection) WriteFileCallCount() int {
+	fake.writeFileMutex.RLock()
+	defer fake.writeFileMutex.RUnlock()
+	return len(fake.writeFileArgsForCall)
+}
+
+func (fake *FakeConnection) WriteFileArgsForCall(i int) (string, string, []byte, os.FileMode) {
+	fake.writeFileMutex.RLock()
+	defer fake.writeFileMutex.RUnlock()
+	return fake.writeFileArgsForCall[i].handle, fake.writeFileArgsForCall[i].path, fake.writeFileArgsForCall[i].data, fake.writeFileArgsForCall[i].mode
+}
+
+func (fake *FakeConnection) WriteFileReturns(result1 error) {
+	fake.WriteFileStub = nil
+	fake.writeFileReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) ListPage(properties garden.Properties, opts garden.ListOptions) (garden.ContainerPage, error) {
+	fake.listPageMutex.Lock()
+	fake.listPageArgsForCall = append(fake.listPageArgsForCall, struct {
+		properties garden.Properties
+		opts       garden.ListOptions
+	}{properties, opts})
+	fake.recordInvocation("ListPage", []interface{}{properties, opts})
+	fake.listPageMutex.Unlock()
+	if fake.ListPageStub != nil {
+		return fake.ListPageStub(properties, opts)
+	} else {
+		return fake.listPageReturns.result1, fake.listPageReturns.result2
+	}
+}
+
+func (fake *FakeConnection) ListPageCallCount() int {
+	fake.listPageMutex.RLock()
+	defer fake.listPageMutex.RUnlock()
+	return len(fake.listPageArgsForCall)
+}
+
+func (fake *FakeConnection) ListPageArgsForCall(i int) (garden.Properties, garden.ListOptions) {
+	fake.listPageMutex.RLock()
+	defer fake.listPageMutex.RUnlock()
+	return fake.listPageArgsForCall[i].properties, fake.listPageArgsForCall[i].opts
+}
+
+func (fake *FakeConnection) ListPageReturns(result1 garden.ContainerPage, result2 error) {
+	fake.ListPageStub = nil
+	fake.listPageReturns = struct {
+		result1 garden.ContainerPage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) BulkNetOut(handle string, rules []garden.NetOutRule) error {
+	var rulesCopy []garden.NetOutRule
+	if rules != nil {
+		rulesCopy = make([]garden.NetOutRule, len(rules))
+		copy(rulesCopy, rules)
+	}
+	fake.bulkNetOutMutex.Lock()
+	fake.bulkNetOutArgsForCall = append(fake.bulkNetOutArgsForCall, struct {
+		handle string
+		rules  []garden.NetOutRule
+	}{handle, rulesCopy})
+	fake.recordInvocation("BulkNetOut", []interface{}{handle, rulesCopy})
+	fake.bulkNetOutMutex.Unlock()
+	if fake.BulkNetOutStub != nil {
+		return fake.BulkNetOutStub(handle, rules)
+	} else {
+		return fake.bulkNetOutReturns.result1
+	}
+}
+
+func (fake *FakeConnection) BulkNetOutCallCount() int {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return len(fake.bulkNetOutArgsForCall)
+}
+
+func (fake *FakeConnection) BulkNetOutArgsForCall(i int) (string, []garden.NetOutRule) {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return fake.bulkNetOutArgsForCall[i].handle, fake.bulkNetOutArgsForCall[i].rules
+}
+
+func (fake *FakeConnection) BulkNetOutReturns(result1 error) {
+	fake.BulkNetOutStub = nil
+	fake.bulkNetOutReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) MetricsHistory(handle string, since time.Time) ([]garden.MetricsSample, error) {
+	fake.metricsHistoryMutex.Lock()
+	fake.metricsHistoryArgsForCall = append(fake.metricsHistoryArgsForCall, struct {
+		handle string
+		since  time.Time
+	}{handle, since})
+	fake.recordInvocation("MetricsHistory", []interface{}{handle, since})
+	fake.metricsHistoryMutex.Unlock()
+	if fake.MetricsHistoryStub != nil {
+		return fake.MetricsHistoryStub(handle, since)
+	} else {
+		return fake.metricsHistoryReturns.result1, fake.metricsHistoryReturns.result2
+	}
+}
+
+func (fake *FakeConnection) MetricsHistoryCallCount() int {
+	fake.metricsHistoryMutex.RLock()
+	defer fake.metricsHistoryMutex.RUnlock()
+	return len(fake.metricsHistoryArgsForCall)
+}
+
+func (fake *FakeConnection) MetricsHistoryArgsForCall(i int) (string, time.Time) {
+	fake.metricsHistoryMutex.RLock()
+	defer fake.metricsHistoryMutex.RUnlock()
+	return fake.metricsHistoryArgsForCall[i].handle, fake.metricsHistoryArgsForCall[i].since
+}
+
+func (fake *FakeConnection) MetricsHistoryReturns(result1 []garden.MetricsSample, result2 error) {
+	fake.MetricsHistoryStub = nil
+	fake.metricsHistoryReturns = struct {
+		result1 []garden.MetricsSample
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) DestroyAsync(handle string) error {
+	fake.destroyAsyncMutex.Lock()
+	fake.destroyAsyncArgsForCall = append(fake.destroyAsyncArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.recordInvocation("DestroyAsync", []interface{}{handle})
+	fake.destroyAsyncMutex.Unlock()
+	if fake.DestroyAsyncStub != nil {
+		return fake.DestroyAsyncStub(handle)
+	} else {
+		return fake.destroyAsyncReturns.result1
+	}
+}
+
+func (fake *FakeConnection) DestroyAsyncCallCount() int {
+	fake.destroyAsyncMutex.RLock()
+	defer fake.destroyAsyncMutex.RUnlock()
+	return len(fake.destroyAsyncArgsForCall)
+}
+
+func (fake *FakeConnection) DestroyAsyncArgsForCall(i int) string {
+	fake.destroyAsyncMutex.RLock()
+	defer fake.destroyAsyncMutex.RUnlock()
+	return fake.destroyAsyncArgsForCall[i].handle
+}
+
+func (fake *FakeConnection) DestroyAsyncReturns(result1 error) {
+	fake.DestroyAsyncStub = nil
+	fake.destroyAsyncReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) ForceDestroy(handle string) error {
+	fake.forceDestroyMutex.Lock()
+	fake.forceDestroyArgsForCall = append(fake.forceDestroyArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.recordInvocation("ForceDestroy", []interface{}{handle})
+	fake.forceDestroyMutex.Unlock()
+	if fake.ForceDestroyStub != nil {
+		return fake.ForceDestroyStub(handle)
+	} else {
+		return fake.forceDestroyReturns.result1
+	}
+}
+
+func (fake *FakeConnection) ForceDestroyCallCount() int {
+	fake.forceDestroyMutex.RLock()
+	defer fake.forceDestroyMutex.RUnlock()
+	return len(fake.forceDestroyArgsForCall)
+}
+
+func (fake *FakeConnection) ForceDestroyArgsForCall(i int) string {
+	fake.forceDestroyMutex.RLock()
+	defer fake.forceDestroyMutex.RUnlock()
+	return fake.forceDestroyArgsForCall[i].handle
+}
+
+func (fake *FakeConnection) ForceDestroyReturns(result1 error) {
+	fake.ForceDestroyStub = nil
+	fake.forceDestroyReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) CompareAndSetProperty(handle string, name string, oldValue string, newValue string) (bool, error) {
+	fake.compareAndSetPropertyMutex.Lock()
+	fake.compareAndSetPropertyArgsForCall = append(fake.compareAndSetPropertyArgsForCall, struct {
+		handle   string
+		name     string
+		oldValue string
+		newValue string
+	}{handle, name, oldValue, newValue})
+	fake.recordInvocation("CompareAndSetProperty", []interface{}{handle, name, oldValue, newValue})
+	fake.compareAndSetPropertyMutex.Unlock()
+	if fake.CompareAndSetPropertyStub != nil {
+		return fake.CompareAndSetPropertyStub(handle, name, oldValue, newValue)
+	} else {
+		return fake.compareAndSetPropertyReturns.result1, fake.compareAndSetPropertyReturns.result2
+	}
+}
+
+func (fake *FakeConnection) CompareAndSetPropertyCallCount() int {
+	fake.compareAndSetPropertyMutex.RLock()
+	defer fake.compareAndSetPropertyMutex.RUnlock()
+	return len(fake.compareAndSetPropertyArgsForCall)
+}
+
+func (fake *FakeConnection) CompareAndSetPropertyArgsForCall(i int) (string, string, string, string) {
+	fake.compareAndSetPropertyMutex.RLock()
+	defer fake.compareAndSetPropertyMutex.RUnlock()
+	return fake.compareAndSetPropertyArgsForCall[i].handle, fake.compareAndSetPropertyArgsForCall[i].name, fake.compareAndSetPropertyArgsForCall[i].oldValue, fake.compareAndSetPropertyArgsForCall[i].newValue
+}
+
+func (fake *FakeConnection) CompareAndSetPropertyReturns(result1 bool, result2 error) {
+	fake.CompareAndSetPropertyStub = nil
+	fake.compareAndSetPropertyReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) SetProperties(handle string, properties garden.Properties) error {
+	fake.setPropertiesMutex.Lock()
+	fake.setPropertiesArgsForCall = append(fake.setPropertiesArgsForCall, struct {
+		handle     string
+		properties garden.Properties
+	}{handle, properties})
+	fake.recordInvocation("SetProperties", []interface{}{handle, properties})
+	fake.setPropertiesMutex.Unlock()
+	if fake.SetPropertiesStub != nil {
+		return fake.SetPropertiesStub(handle, properties)
+	} else {
+		return fake.setPropertiesReturns.result1
+	}
+}
+
+func (fake *FakeConnection) SetPropertiesCallCount() int {
+	fake.setPropertiesMutex.RLock()
+	defer fake.setPropertiesMutex.RUnlock()
+	return len(fake.setPropertiesArgsForCall)
+}
+
+func (fake *FakeConnection) SetPropertiesArgsForCall(i int) (string, garden.Properties) {
+	fake.setPropertiesMutex.RLock()
+	defer fake.setPropertiesMutex.RUnlock()
+	return fake.setPropertiesArgsForCall[i].handle, fake.setPropertiesArgsForCall[i].properties
+}
+
+func (fake *FakeConnection) SetPropertiesReturns(result1 error) {
+	fake.SetPropertiesStub = nil
+	fake.setPropertiesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) RemoveProperties(handle string, names []string) error {
+	var namesCopy []string
+	if names != nil {
+		namesCopy = make([]string, len(names))
+		copy(namesCopy, names)
+	}
+	fake.removePropertiesMutex.Lock()
+	fake.removePropertiesArgsForCall = append(fake.removePropertiesArgsForCall, struct {
+		handle string
+		names  []string
+	}{handle, namesCopy})
+	fake.recordInvocation("RemoveProperties", []interface{}{handle, namesCopy})
+	fake.removePropertiesMutex.Unlock()
+	if fake.RemovePropertiesStub != nil {
+		return fake.RemovePropertiesStub(handle, names)
+	} else {
+		return fake.removePropertiesReturns.result1
+	}
+}
+
+func (fake *FakeConnection) RemovePropertiesCallCount() int {
+	fake.removePropertiesMutex.RLock()
+	defer fake.removePropertiesMutex.RUnlock()
+	return len(fake.removePropertiesArgsForCall)
+}
+
+func (fake *FakeConnection) RemovePropertiesArgsForCall(i int) (string, []string) {
+	fake.removePropertiesMutex.RLock()
+	defer fake.removePropertiesMutex.RUnlock()
+	return fake.removePropertiesArgsForCall[i].handle, fake.removePropertiesArgsForCall[i].names
+}
+
+func (fake *FakeConnection) RemovePropertiesReturns(result1 error) {
+	fake.RemovePropertiesStub = nil
+	fake.removePropertiesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) ListFiltered(filter garden.PropertyFilter) ([]string, error) {
+	fake.listFilteredMutex.Lock()
+	fake.listFilteredArgsForCall = append(fake.listFilteredArgsForCall, struct {
+		filter garden.PropertyFilter
+	}{filter})
+	fake.recordInvocation("ListFiltered", []interface{}{filter})
+	fake.listFilteredMutex.Unlock()
+	if fake.ListFilteredStub != nil {
+		return fake.ListFilteredStub(filter)
+	} else {
+		return fake.listFilteredReturns.result1, fake.listFilteredReturns.result2
+	}
+}
+
+func (fake *FakeConnection) ListFilteredCallCount() int {
+	fake.listFilteredMutex.RLock()
+	defer fake.listFilteredMutex.RUnlock()
+	return len(fake.listFilteredArgsForCall)
+}
+
+func (fake *FakeConnection) ListFilteredArgsForCall(i int) garden.PropertyFilter {
+	fake.listFilteredMutex.RLock()
+	defer fake.listFilteredMutex.RUnlock()
+	return fake.listFilteredArgsForCall[i].filter
+}
+
+func (fake *FakeConnection) ListFilteredReturns(result1 []string, result2 error) {
+	fake.ListFilteredStub = nil
+	fake.listFilteredReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) CapturedOutput(handle string, processID string, stream string) (io.ReadCloser, error) {
+	fake.capturedOutputMutex.Lock()
+	fake.capturedOutputArgsForCall = append(fake.capturedOutputArgsForCall, struct {
+		handle    string
+		processID string
+		stream    string
+	}{handle, processID, stream})
+	fake.recordInvocation("CapturedOutput", []interface{}{handle, processID, stream})
+	fake.capturedOutputMutex.Unlock()
+	if fake.CapturedOutputStub != nil {
+		return fake.CapturedOutputStub(handle, processID, stream)
+	} else {
+		return fake.capturedOutputReturns.result1, fake.capturedOutputReturns.result2
+	}
+}
+
+func (fake *FakeConnection) CapturedOutputCallCount() int {
+	fake.capturedOutputMutex.RLock()
+	defer fake.capturedOutputMutex.RUnlock()
+	return len(fake.capturedOutputArgsForCall)
+}
+
+func (fake *FakeConnection) CapturedOutputArgsForCall(i int) (string, string, string) {
+	fake.capturedOutputMutex.RLock()
+	defer fake.capturedOutputMutex.RUnlock()
+	return fake.capturedOutputArgsForCall[i].handle, fake.capturedOutputArgsForCall[i].processID, fake.capturedOutputArgsForCall[i].stream
+}
+
+func (fake *FakeConnection) CapturedOutputReturns(result1 io.ReadCloser, result2 error) {
+	fake.CapturedOutputStub = nil
+	fake.capturedOutputReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) DestroyAll(dryRun bool) ([]string, *garden.MultiError, error) {
+	fake.destroyAllMutex.Lock()
+	fake.destroyAllArgsForCall = append(fake.destroyAllArgsForCall, struct {
+		dryRun bool
+	}{dryRun})
+	fake.recordInvocation("DestroyAll", []interface{}{dryRun})
+	fake.destroyAllMutex.Unlock()
+	if fake.DestroyAllStub != nil {
+		return fake.DestroyAllStub(dryRun)
+	} else {
+		return fake.destroyAllReturns.result1, fake.destroyAllReturns.result2, fake.destroyAllReturns.result3
+	}
+}
+
+func (fake *FakeConnection) DestroyAllCallCount() int {
+	fake.destroyAllMutex.RLock()
+	defer fake.destroyAllMutex.RUnlock()
+	return len(fake.destroyAllArgsForCall)
+}
+
+func (fake *FakeConnection) DestroyAllArgsForCall(i int) bool {
+	fake.destroyAllMutex.RLock()
+	defer fake.destroyAllMutex.RUnlock()
+	return fake.destroyAllArgsForCall[i].dryRun
+}
+
+func (fake *FakeConnection) DestroyAllReturns(result1 []string, result2 *garden.MultiError, result3 error) {
+	fake.DestroyAllStub = nil
+	fake.destroyAllReturns = struct {
+		result1 []string
+		result2 *garden.MultiError
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeConnection) Lock(handle string, holder string, reason string, ttl time.Duration) error {
+	fake.lockMutex.Lock()
+	fake.lockArgsForCall = append(fake.lockArgsForCall, struct {
+		handle string
+		holder string
+		reason string
+		ttl    time.Duration
+	}{handle, holder, reason, ttl})
+	fake.recordInvocation("Lock", []interface{}{handle, holder, reason, ttl})
+	fake.lockMutex.Unlock()
+	if fake.LockStub != nil {
+		return fake.LockStub(handle, holder, reason, ttl)
+	} else {
+		return fake.lockReturns.result1
+	}
+}
+
+func (fake *FakeConnection) LockCallCount() int {
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	return len(fake.lockArgsForCall)
+}
+
+func (fake *FakeConnection) LockArgsForCall(i int) (string, string, string, time.Duration) {
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	return fake.lockArgsForCall[i].handle, fake.lockArgsForCall[i].holder, fake.lockArgsForCall[i].reason, fake.lockArgsForCall[i].ttl
+}
+
+func (fake *FakeConnection) LockReturns(result1 error) {
+	fake.LockStub = nil
+	fake.lockReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) Unlock(handle string, holder string) error {
+	fake.unlockMutex.Lock()
+	fake.unlockArgsForCall = append(fake.unlockArgsForCall, struct {
+		handle string
+		holder string
+	}{handle, holder})
+	fake.recordInvocation("Unlock", []interface{}{handle, holder})
+	fake.unlockMutex.Unlock()
+	if fake.UnlockStub != nil {
+		return fake.UnlockStub(handle, holder)
+	} else {
+		return fake.unlockReturns.result1
+	}
+}
+
+func (fake *FakeConnection) UnlockCallCount() int {
+	fake.unlockMutex.RLock()
+	defer fake.unlockMutex.RUnlock()
+	return len(fake.unlockArgsForCall)
+}
+
+func (fake *FakeConnection) UnlockArgsForCall(i int) (string, string) {
+	fake.unlockMutex.RLock()
+	defer fake.unlockMutex.RUnlock()
+	return fake.unlockArgsForCall[i].handle, fake.unlockArgsForCall[i].holder
+}
+
+func (fake *FakeConnection) UnlockReturns(result1 error) {
+	fake.UnlockStub = nil
+	fake.unlockReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeConnection) ForceUnlock(handle string) error {
+	fake.forceUnlockMutex.Lock()
+	fake.forceUnlockArgsForCall = append(fake.forceUnlockArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.recordInvocation("ForceUnlock", []interface{}{handle})
+	fake.forceUnlockMutex.Unlock()
+	if fake.ForceUnlockStub != nil {
+		return fake.ForceUnlockStub(handle)
+	} else {
+		return fake.forceUnlockReturns.result1
+	}
+}
+
+func (fake *FakeConnection) ForceUnlockCallCount() int {
+	fake.forceUnlockMutex.RLock()
+	defer fake.forceUnlockMutex.RUnlock()
+	return len(fake.forceUnlockArgsForCall)
+}
+
+func (fake *FakeConnection) ForceUnlockArgsForCall(i int) string {
+	fake.forceUnlockMutex.RLock()
+	defer fake.forceUnlockMutex.RUnlock()
+	return fake.forceUnlockArgsForCall[i].handle
+}
+
+func (fake *FakeConnection) ForceUnlockReturns(result1 error) {
+	fake.ForceUnlockStub = nil
+	fake.forceUnlockReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeConnection) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -1149,6 +2122,50 @@ func (fake *FakeConnection) Invocations() map[string][][]interface{} {
 	defer fake.metricsMutex.RUnlock()
 	fake.removePropertyMutex.RLock()
 	defer fake.removePropertyMutex.RUnlock()
+	fake.destroyMatchingMutex.RLock()
+	defer fake.destroyMatchingMutex.RUnlock()
+	fake.createWithInfoMutex.RLock()
+	defer fake.createWithInfoMutex.RUnlock()
+	fake.createWithEffectiveSpecMutex.RLock()
+	defer fake.createWithEffectiveSpecMutex.RUnlock()
+	fake.connectedMutex.RLock()
+	defer fake.connectedMutex.RUnlock()
+	fake.lastErrorMutex.RLock()
+	defer fake.lastErrorMutex.RUnlock()
+	fake.tombstoneMutex.RLock()
+	defer fake.tombstoneMutex.RUnlock()
+	fake.readFileMutex.RLock()
+	defer fake.readFileMutex.RUnlock()
+	fake.writeFileMutex.RLock()
+	defer fake.writeFileMutex.RUnlock()
+	fake.listPageMutex.RLock()
+	defer fake.listPageMutex.RUnlock()
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	fake.metricsHistoryMutex.RLock()
+	defer fake.metricsHistoryMutex.RUnlock()
+	fake.destroyAsyncMutex.RLock()
+	defer fake.destroyAsyncMutex.RUnlock()
+	fake.forceDestroyMutex.RLock()
+	defer fake.forceDestroyMutex.RUnlock()
+	fake.compareAndSetPropertyMutex.RLock()
+	defer fake.compareAndSetPropertyMutex.RUnlock()
+	fake.setPropertiesMutex.RLock()
+	defer fake.setPropertiesMutex.RUnlock()
+	fake.removePropertiesMutex.RLock()
+	defer fake.removePropertiesMutex.RUnlock()
+	fake.listFilteredMutex.RLock()
+	defer fake.listFilteredMutex.RUnlock()
+	fake.capturedOutputMutex.RLock()
+	defer fake.capturedOutputMutex.RUnlock()
+	fake.destroyAllMutex.RLock()
+	defer fake.destroyAllMutex.RUnlock()
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	fake.unlockMutex.RLock()
+	defer fake.unlockMutex.RUnlock()
+	fake.forceUnlockMutex.RLock()
+	defer fake.forceUnlockMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -44,10 +44,34 @@ func (p *process) SetTTY(tty garden.TTYSpec) error {
 	return p.processInputStream.SetTTY(tty)
 }
 
+// Signal sends the signal to the process. Once the server has reported that
+// the process exited, there is nothing left to signal and it does nothing.
 func (p *process) Signal(signal garden.Signal) error {
+	if p.hasExited() {
+		return nil
+	}
+
 	return p.processInputStream.Signal(signal)
 }
 
+// hasExited reports whether the server reported the process's exit, as
+// opposed to the stream ending while it may still be running.
+func (p *process) hasExited() bool {
+	p.doneL.L.Lock()
+	defer p.doneL.L.Unlock()
+
+	if !p.done {
+		return false
+	}
+
+	switch p.exitErr.(type) {
+	case nil, garden.ProcessTimeoutError, garden.ContainerDestroyedError:
+		return true
+	}
+
+	return false
+}
+
 func (p *process) exited(exitStatus int, err error) {
 	p.doneL.L.Lock()
 	p.exitStatus = exitStatus
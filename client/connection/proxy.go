@@ -0,0 +1,152 @@
+package connection
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"golang.org/x/net/proxy"
+)
+
+const proxyDialTimeout = 2 * time.Second
+
+// ProxyConfig describes a proxy through which to reach the garden server.
+type ProxyConfig struct {
+	// URL is the proxy's address. The http scheme tunnels through HTTP
+	// CONNECT and the socks5 scheme through SOCKS5. Credentials may be given
+	// as its user info.
+	URL *url.URL
+
+	// NoProxy lists the hosts, domains and networks to reach directly, in the
+	// form of the NO_PROXY environment variable.
+	NoProxy string
+}
+
+// NewWithProxy creates a connection that reaches the server through a proxy.
+// Ordinary requests and process streams are tunneled alike, so that the
+// server sees one end-to-end connection for each.
+func NewWithProxy(network, address string, proxyConfig ProxyConfig, logger lager.Logger) (Connection, error) {
+	dialer, err := ProxyDialer(network, address, proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithDialerAndLogger(dialer, logger), nil
+}
+
+// ProxyDialer returns a DialerFunc that reaches the address through the
+// proxy, unless NoProxy excludes it. Only tcp addresses are proxied.
+func ProxyDialer(network, address string, proxyConfig ProxyConfig) (DialerFunc, error) {
+	direct := &net.Dialer{Timeout: proxyDialTimeout}
+
+	if network != "tcp" {
+		return func(string, string) (net.Conn, error) {
+			return direct.Dial(network, address)
+		}, nil
+	}
+
+	if proxyConfig.URL == nil {
+		return nil, fmt.Errorf("no proxy url given")
+	}
+
+	var via proxy.Dialer
+
+	switch proxyConfig.URL.Scheme {
+	case "http":
+		via = &connectDialer{
+			proxyAddress: proxyConfig.URL.Host,
+			user:         proxyConfig.URL.User,
+			forward:      direct,
+		}
+
+	case "socks5":
+		var auth *proxy.Auth
+		if user := proxyConfig.URL.User; user != nil {
+			password, _ := user.Password()
+			auth = &proxy.Auth{User: user.Username(), Password: password}
+		}
+
+		var err error
+		via, err = proxy.SOCKS5("tcp", proxyConfig.URL.Host, auth, direct)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyConfig.URL.Scheme)
+	}
+
+	perHost := proxy.NewPerHost(via, direct)
+	perHost.AddFromString(proxyConfig.NoProxy)
+
+	return func(string, string) (net.Conn, error) {
+		return perHost.Dial(network, address)
+	}, nil
+}
+
+// connectDialer opens tunnels through an HTTP proxy with the CONNECT method.
+type connectDialer struct {
+	proxyAddress string
+	user         *url.Userinfo
+	forward      proxy.Dialer
+}
+
+func (d *connectDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := d.forward.Dial(network, d.proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(proxyDialTimeout))
+
+	request := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+
+	if d.user != nil {
+		password, _ := d.user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.user.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused to connect to %s: %s", address, response.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn reads through the reader used for the proxy's response, in
+// case the server's first bytes arrived with it.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
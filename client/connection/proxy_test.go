@@ -0,0 +1,287 @@
+package connection_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"code.cloudfoundry.org/garden"
+	. "code.cloudfoundry.org/garden/client/connection"
+	"code.cloudfoundry.org/garden/transport"
+)
+
+var _ = Describe("Connecting through a proxy", func() {
+	var (
+		server  *ghttp.Server
+		address string
+
+		proxyListener net.Listener
+		tunnels       int32
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		address = server.HTTPTestServer.Listener.Addr().String()
+
+		server.RouteToHandler("GET", "/ping", ghttp.RespondWith(200, "{}"))
+		server.RouteToHandler("GET", "/containers", ghttp.RespondWithJSONEncoded(200, &transport.ListResponse{
+			Handles: []string{"some-handle"},
+		}))
+
+		atomic.StoreInt32(&tunnels, 0)
+	})
+
+	AfterEach(func() {
+		server.Close()
+
+		if proxyListener != nil {
+			proxyListener.Close()
+			proxyListener = nil
+		}
+	})
+
+	connectThrough := func(proxyURL string, noProxy string) (Connection, error) {
+		parsed, err := url.Parse(proxyURL)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return NewWithProxy("tcp", address, ProxyConfig{URL: parsed, NoProxy: noProxy}, lagertest.NewTestLogger("test-connection"))
+	}
+
+	Describe("with HTTP CONNECT", func() {
+		BeforeEach(func() {
+			proxyListener = startConnectProxy("user:secret", &tunnels)
+		})
+
+		It("tunnels requests to the server", func() {
+			conn, err := connectThrough("http://user:secret@"+proxyListener.Addr().String(), "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(conn.Ping()).Should(Succeed())
+
+			handles, err := conn.List(nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(handles).Should(Equal([]string{"some-handle"}))
+
+			Ω(atomic.LoadInt32(&tunnels)).Should(BeNumerically(">=", 2))
+		})
+
+		It("tunnels process streams to the server", func() {
+			server.RouteToHandler("POST", "/containers/some-handle/processes", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+
+				conn, _, err := w.(http.Hijacker).Hijack()
+				Ω(err).ShouldNot(HaveOccurred())
+				defer conn.Close()
+
+				transport.WriteMessage(conn, map[string]interface{}{"process_id": "process-handle"})
+				transport.WriteMessage(conn, map[string]interface{}{"process_id": "process-handle", "exit_status": 3})
+			})
+
+			conn, err := connectThrough("http://user:secret@"+proxyListener.Addr().String(), "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			process, err := conn.Run("some-handle", garden.ProcessSpec{Path: "some-path"}, garden.ProcessIO{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(process.Wait()).Should(Equal(3))
+			Ω(atomic.LoadInt32(&tunnels)).Should(Equal(int32(1)))
+		})
+
+		It("fails if the proxy refuses the credentials", func() {
+			conn, err := connectThrough("http://user:wrong@"+proxyListener.Addr().String(), "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(conn.Ping()).Should(MatchError(ContainSubstring("407")))
+			Ω(server.ReceivedRequests()).Should(BeEmpty())
+		})
+
+		It("reaches hosts excluded by NoProxy directly", func() {
+			conn, err := connectThrough("http://user:secret@"+proxyListener.Addr().String(), "example.com, 127.0.0.1")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(conn.Ping()).Should(Succeed())
+			Ω(atomic.LoadInt32(&tunnels)).Should(BeZero())
+		})
+	})
+
+	Describe("with SOCKS5", func() {
+		BeforeEach(func() {
+			proxyListener = startSOCKS5Proxy("user", "secret", &tunnels)
+		})
+
+		It("tunnels requests to the server", func() {
+			conn, err := connectThrough("socks5://user:secret@"+proxyListener.Addr().String(), "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(conn.Ping()).Should(Succeed())
+			Ω(atomic.LoadInt32(&tunnels)).Should(Equal(int32(1)))
+		})
+
+		It("fails if the proxy refuses the credentials", func() {
+			conn, err := connectThrough("socks5://user:wrong@"+proxyListener.Addr().String(), "")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(conn.Ping()).ShouldNot(Succeed())
+			Ω(server.ReceivedRequests()).Should(BeEmpty())
+		})
+	})
+
+	It("rejects unsupported proxy schemes", func() {
+		_, err := connectThrough("ftp://proxy.example.com", "")
+		Ω(err).Should(MatchError("unsupported proxy scheme: ftp"))
+	})
+})
+
+func startConnectProxy(credentials string, tunnels *int32) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Ω(err).ShouldNot(HaveOccurred())
+
+	expectedAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				request, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+
+				if request.Method != "CONNECT" || request.Header.Get("Proxy-Authorization") != expectedAuth {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+
+				target, err := net.Dial("tcp", request.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+
+				atomic.AddInt32(tunnels, 1)
+				io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+
+				pipe(conn, target)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func startSOCKS5Proxy(user, password string, tunnels *int32) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Ω(err).ShouldNot(HaveOccurred())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				// greeting: version, methods; choose username/password
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 2})
+
+				// username/password subnegotiation
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				gotUser := make([]byte, header[1])
+				io.ReadFull(conn, gotUser)
+				length := make([]byte, 1)
+				io.ReadFull(conn, length)
+				gotPassword := make([]byte, length[0])
+				io.ReadFull(conn, gotPassword)
+
+				if string(gotUser) != user || string(gotPassword) != password {
+					conn.Write([]byte{1, 1})
+					return
+				}
+				conn.Write([]byte{1, 0})
+
+				// connect request: version, command, reserved, address type
+				request := make([]byte, 4)
+				if _, err := io.ReadFull(conn, request); err != nil {
+					return
+				}
+
+				var host string
+				switch request[3] {
+				case 1:
+					ip := make([]byte, 4)
+					io.ReadFull(conn, ip)
+					host = net.IP(ip).String()
+				case 3:
+					io.ReadFull(conn, length)
+					name := make([]byte, length[0])
+					io.ReadFull(conn, name)
+					host = string(name)
+				default:
+					return
+				}
+
+				port := make([]byte, 2)
+				io.ReadFull(conn, port)
+
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+				if err != nil {
+					conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+
+				atomic.AddInt32(tunnels, 1)
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+				pipe(conn, target)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+
+	<-done
+}
@@ -0,0 +1,73 @@
+package connection_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/garden/routes"
+	"code.cloudfoundry.org/garden/server"
+	"code.cloudfoundry.org/garden/transport"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The route table of a live server", func() {
+	var (
+		tmpdir     string
+		apiServer  *server.GardenServer
+		advertised []routes.Description
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "route-table")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		socketPath := filepath.Join(tmpdir, "api.sock")
+
+		apiServer = server.New("unix", socketPath, time.Minute, new(gardenfakes.FakeBackend), lagertest.NewTestLogger("test"))
+		Ω(apiServer.Start()).Should(Succeed())
+
+		httpClient := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+				},
+			},
+		}
+
+		response, err := httpClient.Get("http://api/routes")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer response.Body.Close()
+
+		Ω(response.StatusCode).Should(Equal(http.StatusOK))
+		Ω(json.NewDecoder(response.Body).Decode(&advertised)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		apiServer.Stop()
+		os.RemoveAll(tmpdir)
+	})
+
+	It("advertises every route the client requests, with the same method and path", func() {
+		Ω(advertised).Should(HaveLen(len(routes.Routes)))
+
+		for i, route := range routes.Routes {
+			Ω(advertised[i].Name).Should(Equal(route.Name))
+			Ω(advertised[i].Method).Should(Equal(route.Method), route.Name)
+			Ω(advertised[i].Path).Should(Equal(route.Path), route.Name)
+		}
+	})
+
+	It("advertises the wire messages the client exchanges", func() {
+		Ω(advertised).Should(Equal(transport.DescribeRoutes()))
+	})
+})
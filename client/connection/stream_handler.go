@@ -56,6 +56,17 @@ func (sh *streamHandler) wait(decoder *json.Decoder) (int, error) {
 			return 0, fmt.Errorf("connection: decode failed: %s", err)
 		}
 
+		if payload.ErrorType != nil {
+			sh.wg.Wait()
+
+			status := 0
+			if payload.ExitStatus != nil {
+				status = *payload.ExitStatus
+			}
+
+			return status, payload.ErrorType.Err
+		}
+
 		if payload.Error != nil {
 			sh.wg.Wait()
 			return 0, fmt.Errorf("connection: process error: %s", *payload.Error)
@@ -1,13 +1,120 @@
 package client
 
 import (
+	"encoding/json"
 	"io"
+	"os"
 	"time"
 
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/client/connection"
 )
 
+// Container is a garden.Container with conveniences that only the client
+// offers. Every container returned by this package's Client implements it.
+type Container interface {
+	garden.Container
+
+	// ReadFile returns the contents of a single file in the container,
+	// without parsing a tar stream from StreamOut. The path is subject to the
+	// server's path policy.
+	//
+	// Errors:
+	// * FileTooLargeError, if the file is larger than the server allows; use
+	//   StreamOut instead.
+	// * FileNotFoundError, if nothing is at the path.
+	// * InvalidRequestError, if the path is not a regular file.
+	ReadFile(path string) ([]byte, error)
+
+	// WriteFile creates or replaces a single file in the container, without
+	// building a tar stream for StreamIn. It is owned as if streamed in, as
+	// mapped by the container's user namespace. A mode of 0 writes it with
+	// 0644.
+	//
+	// Errors:
+	// * FileTooLargeError, if data is larger than the server allows; use
+	//   StreamIn instead.
+	// * InvalidRequestError, if the path is empty, the root or a directory.
+	WriteFile(path string, data []byte, mode os.FileMode) error
+
+	// MetricsHistory returns the metrics sampled after the given time, oldest
+	// first, for a container created with RecordMetrics. The server keeps a
+	// bounded number of samples per container, so older ones may have been
+	// dropped. A container without a recording has no history.
+	MetricsHistory(since time.Time) ([]garden.MetricsSample, error)
+
+	// ResumableStreamOut is StreamOut for large trees over unreliable
+	// connections. It streams a Resumable archive, and if the connection
+	// breaks off, reconnects and resumes from the last byte received, giving
+	// up after five attempts in a row that receive nothing. The spec's Offset
+	// and Generation are managed for the caller.
+	//
+	// Errors:
+	// * StreamOutChangedError, from Read, if the files changed before the
+	//   stream could be resumed.
+	ResumableStreamOut(spec garden.StreamOutSpec) (io.ReadCloser, error)
+
+	// Lock takes a maintenance lock on the container for the ttl in the
+	// holder's name, renewing it if the holder has it already. Until it
+	// expires or is unlocked, destroying the container fails with a
+	// ContainerLockedError, other than by ForceDestroy, and it is not
+	// destroyed when its grace time runs out. Everything else carries on as
+	// usual. Info reports the lock. A lock held by another holder fails with
+	// a ContainerLockedError.
+	Lock(holder string, reason string, ttl time.Duration) error
+
+	// Unlock releases the container's maintenance lock if the holder has it,
+	// failing with a ContainerLockedError if another holder does.
+	Unlock(holder string) error
+
+	// ForceUnlock releases the container's maintenance lock, whoever holds
+	// it.
+	ForceUnlock() error
+
+	// CompareAndSetProperty sets the named property to newValue only if it
+	// currently holds oldValue, and reports whether it did. A property that
+	// is not set matches an empty oldValue. The server applies it atomically
+	// with respect to other property changes made through it, so callers
+	// racing to claim a container can tell which of them won.
+	//
+	// Errors:
+	// * None, if the property holds some other value.
+	CompareAndSetProperty(name string, oldValue string, newValue string) (bool, error)
+
+	// SetProperties sets all of the given properties in one request. Either
+	// all of them are set or, if one cannot be, none are.
+	SetProperties(properties garden.Properties) error
+
+	// RemoveProperties removes all of the named properties in one request.
+	// Either all of them are removed or, if one cannot be, none are. Names
+	// that are not set are ignored.
+	RemoveProperties(names []string) error
+
+	// CapturedOutput reads the output captured of one stream of a process
+	// run with an OutputCapture: garden.CapturedStdout or
+	// garden.CapturedStderr. It holds the output so far, up to the capture's
+	// limit, and can be read once the process has exited, or after the
+	// container is destroyed if the capture was kept.
+	//
+	// Errors:
+	// * ProcessNotFoundError, if no output of the process was captured.
+	// * InvalidRequestError, if the stream is neither of those.
+	CapturedOutput(processID string, stream string) (io.ReadCloser, error)
+
+	// GetJSON decodes the JSON value of the named property into out, as
+	// json.Unmarshal does.
+	GetJSON(name string, out interface{}) error
+
+	// SetJSON sets the named property to the JSON encoding of value. If the
+	// server has a schema registered for the property, the value must be
+	// accepted by it.
+	//
+	// Errors:
+	// * InvalidPropertyValueError, if the property's schema does not accept
+	//   the value.
+	SetJSON(name string, value interface{}) error
+}
+
 type container struct {
 	handle string
 
@@ -42,6 +149,37 @@ func (container *container) StreamOut(spec garden.StreamOutSpec) (io.ReadCloser,
 	return container.connection.StreamOut(container.handle, spec)
 }
 
+func (container *container) ResumableStreamOut(spec garden.StreamOutSpec) (io.ReadCloser, error) {
+	reader, err := newResumingReader(spec, func(spec garden.StreamOutSpec) (io.ReadCloser, error) {
+		return container.connection.StreamOut(container.handle, spec)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+func (container *container) Lock(holder string, reason string, ttl time.Duration) error {
+	return container.connection.Lock(container.handle, holder, reason, ttl)
+}
+
+func (container *container) Unlock(holder string) error {
+	return container.connection.Unlock(container.handle, holder)
+}
+
+func (container *container) ForceUnlock() error {
+	return container.connection.ForceUnlock(container.handle)
+}
+
+func (container *container) ReadFile(path string) ([]byte, error) {
+	return container.connection.ReadFile(container.handle, path)
+}
+
+func (container *container) WriteFile(path string, data []byte, mode os.FileMode) error {
+	return container.connection.WriteFile(container.handle, path, data, mode)
+}
+
 func (container *container) CurrentBandwidthLimits() (garden.BandwidthLimits, error) {
 	return container.connection.CurrentBandwidthLimits(container.handle)
 }
@@ -66,6 +204,28 @@ func (container *container) Attach(processID string, io garden.ProcessIO) (garde
 	return container.connection.Attach(container.handle, processID, io)
 }
 
+func (container *container) GetJSON(name string, out interface{}) error {
+	value, err := container.connection.Property(container.handle, name)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(value), out)
+}
+
+func (container *container) SetJSON(name string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return container.connection.SetProperty(container.handle, name, string(encoded))
+}
+
+func (container *container) CapturedOutput(processID string, stream string) (io.ReadCloser, error) {
+	return container.connection.CapturedOutput(container.handle, processID, stream)
+}
+
 func (container *container) NetIn(hostPort, containerPort uint32) (uint32, uint32, error) {
 	return container.connection.NetIn(container.handle, hostPort, containerPort)
 }
@@ -74,10 +234,18 @@ func (container *container) NetOut(netOutRule garden.NetOutRule) error {
 	return container.connection.NetOut(container.handle, netOutRule)
 }
 
+func (container *container) BulkNetOut(netOutRules []garden.NetOutRule) error {
+	return container.connection.BulkNetOut(container.handle, netOutRules)
+}
+
 func (container *container) Metrics() (garden.Metrics, error) {
 	return container.connection.Metrics(container.handle)
 }
 
+func (container *container) MetricsHistory(since time.Time) ([]garden.MetricsSample, error) {
+	return container.connection.MetricsHistory(container.handle, since)
+}
+
 func (container *container) SetGraceTime(graceTime time.Duration) error {
 	return container.connection.SetGraceTime(container.handle, graceTime)
 }
@@ -97,3 +265,15 @@ func (container *container) SetProperty(name string, value string) error {
 func (container *container) RemoveProperty(name string) error {
 	return container.connection.RemoveProperty(container.handle, name)
 }
+
+func (container *container) SetProperties(properties garden.Properties) error {
+	return container.connection.SetProperties(container.handle, properties)
+}
+
+func (container *container) RemoveProperties(names []string) error {
+	return container.connection.RemoveProperties(container.handle, names)
+}
+
+func (container *container) CompareAndSetProperty(name string, oldValue string, newValue string) (bool, error) {
+	return container.connection.CompareAndSetProperty(container.handle, name, oldValue, newValue)
+}
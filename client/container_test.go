@@ -2,10 +2,14 @@ package client_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
+	"os"
 	"strings"
 	"time"
 
@@ -126,6 +130,44 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("SetProperties", func() {
+		It("sends a bulk set request", func() {
+			err := container.(Container).SetProperties(garden.Properties{"a": "1"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle, properties := fakeConnection.SetPropertiesArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(properties).Should(Equal(garden.Properties{"a": "1"}))
+		})
+	})
+
+	Describe("RemoveProperties", func() {
+		It("sends a bulk remove request", func() {
+			err := container.(Container).RemoveProperties([]string{"a"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle, names := fakeConnection.RemovePropertiesArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(names).Should(Equal([]string{"a"}))
+		})
+	})
+
+	Describe("CompareAndSetProperty", func() {
+		It("sends a compare and set request", func() {
+			fakeConnection.CompareAndSetPropertyReturns(true, nil)
+
+			swapped, err := container.(Container).CompareAndSetProperty("owner", "", "some-scheduler")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(swapped).Should(BeTrue())
+
+			handle, name, oldValue, newValue := fakeConnection.CompareAndSetPropertyArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(name).Should(Equal("owner"))
+			Ω(oldValue).Should(BeEmpty())
+			Ω(newValue).Should(Equal("some-scheduler"))
+		})
+	})
+
 	Describe("Property", func() {
 
 		propertyName := "propertyName"
@@ -229,6 +271,227 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("ResumableStreamOut", func() {
+		brokenOff := errors.New("connection reset")
+
+		var restoreBackoff func()
+
+		BeforeEach(func() {
+			restoreBackoff = SetResumeBackoff(time.Millisecond)
+		})
+
+		AfterEach(func() {
+			restoreBackoff()
+		})
+
+		breaksOffAfter := func(data string) io.ReadCloser {
+			return ioutil.NopCloser(io.MultiReader(strings.NewReader(data), failingReader{brokenOff}))
+		}
+
+		type streamOutResult struct {
+			stream io.ReadCloser
+			err    error
+		}
+
+		streamsOutInTurn := func(results ...streamOutResult) {
+			fakeConnection.StreamOutStub = func(string, garden.StreamOutSpec) (io.ReadCloser, error) {
+				result := results[fakeConnection.StreamOutCallCount()-1]
+				return result.stream, result.err
+			}
+		}
+
+		generationOf := func(data string) string {
+			digest := sha256.Sum256([]byte(data))
+			return hex.EncodeToString(digest[:])
+		}
+
+		It("streams out a resumable archive", func() {
+			fakeConnection.StreamOutReturns(ioutil.NopCloser(strings.NewReader("kewl")), nil)
+
+			reader, err := container.(Container).ResumableStreamOut(garden.StreamOutSpec{
+				User: "deandra",
+				Path: "from",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(ioutil.ReadAll(reader)).Should(Equal([]byte("kewl")))
+			Ω(reader.Close()).Should(Succeed())
+
+			handle, spec := fakeConnection.StreamOutArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(spec).Should(Equal(garden.StreamOutSpec{User: "deandra", Path: "from", Resumable: true}))
+		})
+
+		It("resumes from the last byte received when the stream breaks off", func() {
+			streamsOutInTurn(
+				streamOutResult{stream: breaksOffAfter("hello-")},
+				streamOutResult{stream: ioutil.NopCloser(strings.NewReader("world"))},
+			)
+
+			reader, err := container.(Container).ResumableStreamOut(garden.StreamOutSpec{Path: "from"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(ioutil.ReadAll(reader)).Should(Equal([]byte("hello-world")))
+
+			Ω(fakeConnection.StreamOutCallCount()).Should(Equal(2))
+			_, spec := fakeConnection.StreamOutArgsForCall(1)
+			Ω(spec).Should(Equal(garden.StreamOutSpec{
+				Path:       "from",
+				Resumable:  true,
+				Offset:     6,
+				Generation: generationOf("hello-"),
+			}))
+		})
+
+		It("hashes everything received across resumes", func() {
+			streamsOutInTurn(
+				streamOutResult{stream: breaksOffAfter("hello-")},
+				streamOutResult{stream: breaksOffAfter("wor")},
+				streamOutResult{stream: ioutil.NopCloser(strings.NewReader("ld"))},
+			)
+
+			reader, err := container.(Container).ResumableStreamOut(garden.StreamOutSpec{Path: "from"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(ioutil.ReadAll(reader)).Should(Equal([]byte("hello-world")))
+
+			_, spec := fakeConnection.StreamOutArgsForCall(2)
+			Ω(spec.Offset).Should(Equal(int64(9)))
+			Ω(spec.Generation).Should(Equal(generationOf("hello-wor")))
+		})
+
+		It("retries reconnecting while the server cannot be reached", func() {
+			streamsOutInTurn(
+				streamOutResult{stream: breaksOffAfter("hello-")},
+				streamOutResult{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}},
+				streamOutResult{stream: ioutil.NopCloser(strings.NewReader("world"))},
+			)
+
+			reader, err := container.(Container).ResumableStreamOut(garden.StreamOutSpec{Path: "from"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(ioutil.ReadAll(reader)).Should(Equal([]byte("hello-world")))
+			Ω(fakeConnection.StreamOutCallCount()).Should(Equal(3))
+		})
+
+		It("gives up after five attempts in a row that receive nothing", func() {
+			fakeConnection.StreamOutStub = func(string, garden.StreamOutSpec) (io.ReadCloser, error) {
+				return breaksOffAfter(""), nil
+			}
+
+			reader, err := container.(Container).ResumableStreamOut(garden.StreamOutSpec{Path: "from"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = ioutil.ReadAll(reader)
+			Ω(err).Should(Equal(brokenOff))
+			Ω(fakeConnection.StreamOutCallCount()).Should(Equal(6))
+		})
+
+		Context("when the files changed before the stream could be resumed", func() {
+			changed := garden.StreamOutChangedError{Handle: "some-handle", Path: "from"}
+
+			BeforeEach(func() {
+				streamsOutInTurn(
+					streamOutResult{stream: breaksOffAfter("hello-")},
+					streamOutResult{err: changed},
+				)
+			})
+
+			It("fails without retrying", func() {
+				reader, err := container.(Container).ResumableStreamOut(garden.StreamOutSpec{Path: "from"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				_, err = ioutil.ReadAll(reader)
+				Ω(err).Should(Equal(changed))
+				Ω(fakeConnection.StreamOutCallCount()).Should(Equal(2))
+			})
+		})
+
+		Context("when streaming out fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeConnection.StreamOutReturns(nil, disaster)
+			})
+
+			It("returns the error, and no reader", func() {
+				reader, err := container.(Container).ResumableStreamOut(garden.StreamOutSpec{Path: "from"})
+				Ω(err).Should(Equal(disaster))
+				Ω(reader).Should(BeNil())
+			})
+		})
+	})
+
+	Describe("ReadFile", func() {
+		It("reads the file through the connection", func() {
+			fakeConnection.ReadFileReturns([]byte("contents"), nil)
+
+			data, err := container.(Container).ReadFile("/some/file")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(data).Should(Equal([]byte("contents")))
+
+			handle, path := fakeConnection.ReadFileArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(path).Should(Equal("/some/file"))
+		})
+	})
+
+	Describe("WriteFile", func() {
+		It("writes the file through the connection", func() {
+			err := container.(Container).WriteFile("/some/file", []byte("contents"), 0644)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle, path, data, mode := fakeConnection.WriteFileArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(path).Should(Equal("/some/file"))
+			Ω(data).Should(Equal([]byte("contents")))
+			Ω(mode).Should(Equal(os.FileMode(0644)))
+		})
+
+		Context("when the file is too large", func() {
+			It("returns the error", func() {
+				tooLarge := garden.FileTooLargeError{Handle: "some-handle", Path: "/some/file", Limit: 1}
+				fakeConnection.WriteFileReturns(tooLarge)
+
+				err := container.(Container).WriteFile("/some/file", []byte("contents"), 0644)
+				Ω(err).Should(Equal(tooLarge))
+			})
+		})
+	})
+
+	Describe("Lock", func() {
+		It("locks the container through the connection", func() {
+			err := container.(Container).Lock("frank", "debugging", time.Hour)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle, holder, reason, ttl := fakeConnection.LockArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(holder).Should(Equal("frank"))
+			Ω(reason).Should(Equal("debugging"))
+			Ω(ttl).Should(Equal(time.Hour))
+		})
+	})
+
+	Describe("Unlock", func() {
+		It("unlocks the container through the connection", func() {
+			err := container.(Container).Unlock("frank")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle, holder := fakeConnection.UnlockArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(holder).Should(Equal("frank"))
+		})
+	})
+
+	Describe("ForceUnlock", func() {
+		It("force unlocks the container through the connection", func() {
+			err := container.(Container).ForceUnlock()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(fakeConnection.ForceUnlockArgsForCall(0)).Should(Equal("some-handle"))
+		})
+	})
+
 	Describe("CurrentBandwidthLimits", func() {
 		It("sends an empty limit request and returns its response", func() {
 			limitsToReturn := garden.BandwidthLimits{
@@ -446,6 +709,63 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("CapturedOutput", func() {
+		It("reads the captured output through the connection", func() {
+			fakeConnection.CapturedOutputReturns(ioutil.NopCloser(strings.NewReader("output")), nil)
+
+			reader, err := container.(Container).CapturedOutput("some-process", garden.CapturedStderr)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ioutil.ReadAll(reader)).Should(Equal([]byte("output")))
+
+			handle, processID, stream := fakeConnection.CapturedOutputArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(processID).Should(Equal("some-process"))
+			Ω(stream).Should(Equal("stderr"))
+		})
+	})
+
+	Describe("GetJSON", func() {
+		It("decodes the property's value", func() {
+			fakeConnection.PropertyReturns(`{"version":3}`, nil)
+
+			var deploy struct{ Version int }
+			Ω(container.(Container).GetJSON("deploy", &deploy)).Should(Succeed())
+			Ω(deploy.Version).Should(Equal(3))
+
+			handle, name := fakeConnection.PropertyArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(name).Should(Equal("deploy"))
+		})
+
+		It("fails for a value that is not JSON", func() {
+			fakeConnection.PropertyReturns("plain", nil)
+
+			var deploy struct{ Version int }
+			Ω(container.(Container).GetJSON("deploy", &deploy)).ShouldNot(Succeed())
+		})
+
+		Context("when getting the property fails", func() {
+			It("returns the error", func() {
+				disaster := errors.New("oh no!")
+				fakeConnection.PropertyReturns("", disaster)
+
+				var deploy struct{ Version int }
+				Ω(container.(Container).GetJSON("deploy", &deploy)).Should(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("SetJSON", func() {
+		It("sets the property to the value's JSON encoding", func() {
+			Ω(container.(Container).SetJSON("deploy", map[string]int{"version": 3})).Should(Succeed())
+
+			handle, name, value := fakeConnection.SetPropertyArgsForCall(0)
+			Ω(handle).Should(Equal("some-handle"))
+			Ω(name).Should(Equal("deploy"))
+			Ω(value).Should(MatchJSON(`{"version":3}`))
+		})
+	})
+
 	Describe("NetIn", func() {
 		It("sends a net in request", func() {
 			fakeConnection.NetInReturns(111, 222, nil)
@@ -498,6 +818,37 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("BulkNetOut", func() {
+		It("sends BulkNetOut requests over the connection", func() {
+			rules := []garden.NetOutRule{
+				{Ports: []garden.PortRange{{Start: 12, End: 24}}},
+				{Protocol: garden.ProtocolUDP, Log: true},
+			}
+
+			Ω(container.BulkNetOut(rules)).Should(Succeed())
+
+			h, sent := fakeConnection.BulkNetOutArgsForCall(0)
+			Ω(h).Should(Equal("some-handle"))
+			Ω(sent).Should(Equal(rules))
+		})
+	})
+
+	Describe("MetricsHistory", func() {
+		It("sends MetricsHistory requests over the connection", func() {
+			since := time.Now()
+			samples := []garden.MetricsSample{{SampledAt: since.Add(time.Second)}}
+			fakeConnection.MetricsHistoryReturns(samples, nil)
+
+			history, err := container.(Container).MetricsHistory(since)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(history).Should(Equal(samples))
+
+			h, s := fakeConnection.MetricsHistoryArgsForCall(0)
+			Ω(h).Should(Equal("some-handle"))
+			Ω(s).Should(Equal(since))
+		})
+	})
+
 	Describe(("GraceTime"), func() {
 		It("send the set grace time request", func() {
 			graceTime := time.Second * 5
@@ -537,3 +888,11 @@ var _ = Describe("Container", func() {
 		})
 	})
 })
+
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// DispatcherConfig configures a Dispatcher.
+type DispatcherConfig struct {
+	// MaxConcurrency bounds how many operations run at once across all
+	// handles. Zero means no bound.
+	MaxConcurrency int
+
+	// Retries is how many more times an operation is attempted after failing
+	// with an error that Retryable accepts.
+	Retries int
+
+	// RetryBackoff is how long the first retry waits; each one after it waits
+	// twice as long as the last.
+	RetryBackoff time.Duration
+
+	// Retryable decides whether a failed operation is attempted again. By
+	// default, only failures to reach the server, which are net.Errors, are.
+	Retryable func(error) bool
+}
+
+// Dispatcher runs operations on containers so that those on the same handle
+// run one at a time, in the order they were dispatched, while those on
+// different handles run in parallel. Bursts of operations on a container then
+// reach the server in the order they were issued.
+//
+// An operation is retried in its place in the queue, so the operations after
+// it wait for its last attempt. Each operation's outcome is its own: one
+// failing does not fail those queued after it.
+//
+// It is a client-side composition only; other clients' operations on the
+// same containers are not ordered with its own.
+type Dispatcher struct {
+	config DispatcherConfig
+	slots  chan struct{}
+
+	queues map[string]*dispatchQueue
+	mu     sync.Mutex
+}
+
+// dispatchQueue holds a handle's operations while its worker runs them. It is
+// dropped once drained, so each handle with operations has a worker.
+type dispatchQueue struct {
+	pending []*dispatchedOperation
+
+	// true while the worker is running an operation
+	started bool
+
+	// closed once the queue has drained
+	drained chan struct{}
+}
+
+type dispatchedOperation struct {
+	ctx    context.Context
+	op     func() error
+	result chan error
+}
+
+// NewDispatcher creates a Dispatcher with the given config.
+func NewDispatcher(config DispatcherConfig) *Dispatcher {
+	if config.Retryable == nil {
+		config.Retryable = isNetError
+	}
+
+	d := &Dispatcher{
+		config: config,
+		queues: make(map[string]*dispatchQueue),
+	}
+
+	if config.MaxConcurrency > 0 {
+		d.slots = make(chan struct{}, config.MaxConcurrency)
+	}
+
+	return d
+}
+
+// Dispatch queues the operation behind those already dispatched for the
+// handle, returning a channel that receives its outcome once it has run.
+//
+// If ctx is done before the operation starts, it is dropped without running
+// and its outcome is ctx.Err(). An operation that has started runs to
+// completion, but is not retried once ctx is done.
+func (d *Dispatcher) Dispatch(ctx context.Context, handle string, op func() error) <-chan error {
+	operation := &dispatchedOperation{
+		ctx:    ctx,
+		op:     op,
+		result: make(chan error, 1),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue, found := d.queues[handle]
+	if !found {
+		queue = &dispatchQueue{drained: make(chan struct{})}
+		d.queues[handle] = queue
+
+		go d.work(handle, queue)
+	}
+
+	queue.pending = append(queue.pending, operation)
+
+	return operation.result
+}
+
+// Do dispatches the operation and waits for its outcome.
+func (d *Dispatcher) Do(ctx context.Context, handle string, op func() error) error {
+	return <-d.Dispatch(ctx, handle, op)
+}
+
+// Flush waits until every operation dispatched for the handle so far, and
+// any dispatched while waiting, has finished. It returns ctx.Err() if ctx is
+// done first.
+func (d *Dispatcher) Flush(ctx context.Context, handle string) error {
+	d.mu.Lock()
+	queue, found := d.queues[handle]
+	d.mu.Unlock()
+
+	if !found {
+		return nil
+	}
+
+	select {
+	case <-queue.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// QueueDepths returns how many operations are queued or running for each
+// handle that has any.
+func (d *Dispatcher) QueueDepths() map[string]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	depths := make(map[string]int, len(d.queues))
+	for handle, queue := range d.queues {
+		depths[handle] = len(queue.pending)
+		if queue.started {
+			depths[handle]++
+		}
+	}
+
+	return depths
+}
+
+func (d *Dispatcher) work(handle string, queue *dispatchQueue) {
+	for {
+		d.mu.Lock()
+		if len(queue.pending) == 0 {
+			delete(d.queues, handle)
+			close(queue.drained)
+			d.mu.Unlock()
+			return
+		}
+
+		operation := queue.pending[0]
+		queue.pending = queue.pending[1:]
+		queue.started = true
+		d.mu.Unlock()
+
+		err := d.run(operation)
+
+		d.mu.Lock()
+		queue.started = false
+		d.mu.Unlock()
+
+		operation.result <- err
+	}
+}
+
+func (d *Dispatcher) run(operation *dispatchedOperation) error {
+	if err := operation.ctx.Err(); err != nil {
+		return err
+	}
+
+	if d.slots != nil {
+		select {
+		case d.slots <- struct{}{}:
+		case <-operation.ctx.Done():
+			return operation.ctx.Err()
+		}
+
+		defer func() { <-d.slots }()
+	}
+
+	backoff := d.config.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := operation.op()
+		if err == nil || attempt >= d.config.Retries || !d.config.Retryable(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-operation.ctx.Done():
+			return err
+		}
+
+		backoff *= 2
+	}
+}
+
+func isNetError(err error) bool {
+	_, ok := err.(net.Error)
+	return ok
+}
@@ -0,0 +1,349 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/garden/client"
+)
+
+var _ = Describe("Dispatcher", func() {
+	var (
+		config     DispatcherConfig
+		dispatcher *Dispatcher
+
+		ctx context.Context
+
+		events   *eventLog
+		record   func(string)
+		recorded func() []string
+	)
+
+	// blocking returns an operation that records its name and then waits to
+	// be released
+	blocking := func(name string) (func() error, chan struct{}) {
+		record := record
+		release := make(chan struct{})
+
+		return func() error {
+			record(name)
+			<-release
+			return nil
+		}, release
+	}
+
+	recording := func(name string) func() error {
+		record := record
+
+		return func() error {
+			record(name)
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		config = DispatcherConfig{}
+		ctx = context.Background()
+
+		// operations left blocked by a spec record into its own log
+		events = new(eventLog)
+		record = events.record
+		recorded = events.recorded
+	})
+
+	JustBeforeEach(func() {
+		dispatcher = NewDispatcher(config)
+	})
+
+	It("runs the operations on a handle one at a time, in order", func() {
+		first, releaseFirst := blocking("first")
+
+		firstDone := dispatcher.Dispatch(ctx, "some-handle", first)
+		secondDone := dispatcher.Dispatch(ctx, "some-handle", recording("second"))
+		thirdDone := dispatcher.Dispatch(ctx, "some-handle", recording("third"))
+
+		Eventually(recorded).Should(Equal([]string{"first"}))
+		Consistently(recorded).Should(Equal([]string{"first"}))
+
+		close(releaseFirst)
+
+		Ω(<-firstDone).Should(Succeed())
+		Ω(<-secondDone).Should(Succeed())
+		Ω(<-thirdDone).Should(Succeed())
+
+		Ω(recorded()).Should(Equal([]string{"first", "second", "third"}))
+	})
+
+	It("runs the operations on different handles in parallel", func() {
+		first, releaseFirst := blocking("first")
+		second, releaseSecond := blocking("second")
+
+		dispatcher.Dispatch(ctx, "some-handle", first)
+		dispatcher.Dispatch(ctx, "another-handle", second)
+
+		Eventually(recorded).Should(ConsistOf("first", "second"))
+
+		close(releaseFirst)
+		close(releaseSecond)
+	})
+
+	It("returns each operation's own outcome", func() {
+		disaster := errors.New("oh no!")
+
+		failedDone := dispatcher.Dispatch(ctx, "some-handle", func() error { return disaster })
+		nextDone := dispatcher.Dispatch(ctx, "some-handle", recording("next"))
+
+		Ω(<-failedDone).Should(Equal(disaster))
+		Ω(<-nextDone).Should(Succeed())
+	})
+
+	Describe("Do", func() {
+		It("waits for the operation's outcome", func() {
+			disaster := errors.New("oh no!")
+
+			Ω(dispatcher.Do(ctx, "some-handle", func() error { return disaster })).Should(Equal(disaster))
+		})
+	})
+
+	Context("with a concurrency limit", func() {
+		BeforeEach(func() {
+			config.MaxConcurrency = 1
+		})
+
+		It("runs no more operations at once than the limit", func() {
+			first, releaseFirst := blocking("first")
+
+			dispatcher.Dispatch(ctx, "some-handle", first)
+			Eventually(recorded).Should(Equal([]string{"first"}))
+
+			secondDone := dispatcher.Dispatch(ctx, "another-handle", recording("second"))
+			Consistently(recorded).Should(Equal([]string{"first"}))
+
+			close(releaseFirst)
+
+			Ω(<-secondDone).Should(Succeed())
+			Ω(recorded()).Should(Equal([]string{"first", "second"}))
+		})
+
+		Context("when an operation is cancelled while waiting for its turn", func() {
+			It("is dropped without running", func() {
+				first, releaseFirst := blocking("first")
+				defer close(releaseFirst)
+
+				dispatcher.Dispatch(ctx, "some-handle", first)
+				Eventually(recorded).Should(Equal([]string{"first"}))
+
+				cancelled, cancel := context.WithCancel(ctx)
+				secondDone := dispatcher.Dispatch(cancelled, "another-handle", recording("second"))
+
+				cancel()
+
+				Eventually(secondDone).Should(Receive(Equal(context.Canceled)))
+				Ω(recorded()).Should(Equal([]string{"first"}))
+			})
+		})
+	})
+
+	Context("when a queued operation is cancelled", func() {
+		It("is dropped without running, and those after it run", func() {
+			first, releaseFirst := blocking("first")
+
+			cancelled, cancel := context.WithCancel(ctx)
+
+			dispatcher.Dispatch(ctx, "some-handle", first)
+			secondDone := dispatcher.Dispatch(cancelled, "some-handle", recording("second"))
+			thirdDone := dispatcher.Dispatch(ctx, "some-handle", recording("third"))
+
+			cancel()
+			close(releaseFirst)
+
+			Ω(<-secondDone).Should(Equal(context.Canceled))
+			Ω(<-thirdDone).Should(Succeed())
+
+			Ω(recorded()).Should(Equal([]string{"first", "third"}))
+		})
+	})
+
+	Describe("retrying", func() {
+		var unreachable error
+
+		BeforeEach(func() {
+			unreachable = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+			config.Retries = 2
+			config.RetryBackoff = 10 * time.Millisecond
+		})
+
+		It("retries an operation in its place in the queue", func() {
+			attempts := 0
+			firstDone := dispatcher.Dispatch(ctx, "some-handle", func() error {
+				attempts++
+				record("first")
+
+				if attempts == 1 {
+					return unreachable
+				}
+
+				return nil
+			})
+
+			secondDone := dispatcher.Dispatch(ctx, "some-handle", recording("second"))
+
+			Ω(<-firstDone).Should(Succeed())
+			Ω(<-secondDone).Should(Succeed())
+
+			Ω(recorded()).Should(Equal([]string{"first", "first", "second"}))
+		})
+
+		It("gives up after the configured number of retries", func() {
+			err := dispatcher.Do(ctx, "some-handle", func() error {
+				record("attempt")
+				return unreachable
+			})
+			Ω(err).Should(Equal(unreachable))
+
+			Ω(recorded()).Should(HaveLen(3))
+		})
+
+		It("does not retry errors returned by the server", func() {
+			disaster := errors.New("oh no!")
+
+			err := dispatcher.Do(ctx, "some-handle", func() error {
+				record("attempt")
+				return disaster
+			})
+			Ω(err).Should(Equal(disaster))
+
+			Ω(recorded()).Should(HaveLen(1))
+		})
+
+		Context("with a Retryable", func() {
+			BeforeEach(func() {
+				config.Retryable = func(error) bool { return true }
+			})
+
+			It("retries the errors it accepts", func() {
+				err := dispatcher.Do(ctx, "some-handle", func() error {
+					record("attempt")
+					return errors.New("oh no!")
+				})
+				Ω(err).Should(HaveOccurred())
+
+				Ω(recorded()).Should(HaveLen(3))
+			})
+		})
+
+		Context("when the operation is cancelled between attempts", func() {
+			BeforeEach(func() {
+				config.RetryBackoff = time.Hour
+			})
+
+			It("returns the last attempt's error", func() {
+				cancelled, cancel := context.WithCancel(ctx)
+
+				done := dispatcher.Dispatch(cancelled, "some-handle", func() error {
+					record("attempt")
+					return unreachable
+				})
+
+				Eventually(recorded).Should(HaveLen(1))
+				cancel()
+
+				Eventually(done).Should(Receive(Equal(unreachable)))
+				Ω(recorded()).Should(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("Flush", func() {
+		It("waits for the handle's operations to finish", func() {
+			first, releaseFirst := blocking("first")
+
+			dispatcher.Dispatch(ctx, "some-handle", first)
+			dispatcher.Dispatch(ctx, "some-handle", recording("second"))
+
+			flushed := make(chan error)
+			go func() { flushed <- dispatcher.Flush(ctx, "some-handle") }()
+
+			Consistently(flushed).ShouldNot(Receive())
+
+			close(releaseFirst)
+
+			Eventually(flushed).Should(Receive(BeNil()))
+			Ω(recorded()).Should(Equal([]string{"first", "second"}))
+		})
+
+		It("does not wait for other handles' operations", func() {
+			first, releaseFirst := blocking("first")
+			defer close(releaseFirst)
+
+			dispatcher.Dispatch(ctx, "another-handle", first)
+
+			Ω(dispatcher.Flush(ctx, "some-handle")).Should(Succeed())
+		})
+
+		Context("when ctx is done first", func() {
+			It("returns its error", func() {
+				first, releaseFirst := blocking("first")
+				defer close(releaseFirst)
+
+				dispatcher.Dispatch(ctx, "some-handle", first)
+
+				timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+				defer cancel()
+
+				Ω(dispatcher.Flush(timeout, "some-handle")).Should(Equal(context.DeadlineExceeded))
+			})
+		})
+	})
+
+	Describe("QueueDepths", func() {
+		It("counts the operations queued or running for each handle", func() {
+			first, releaseFirst := blocking("first")
+			another, releaseAnother := blocking("another")
+
+			dispatcher.Dispatch(ctx, "some-handle", first)
+			dispatcher.Dispatch(ctx, "some-handle", recording("second"))
+			dispatcher.Dispatch(ctx, "another-handle", another)
+
+			Eventually(recorded).Should(ConsistOf("first", "another"))
+			Ω(dispatcher.QueueDepths()).Should(Equal(map[string]int{
+				"some-handle":    2,
+				"another-handle": 1,
+			}))
+
+			close(releaseFirst)
+			close(releaseAnother)
+
+			Ω(dispatcher.Flush(ctx, "some-handle")).Should(Succeed())
+			Ω(dispatcher.Flush(ctx, "another-handle")).Should(Succeed())
+
+			Ω(dispatcher.QueueDepths()).Should(BeEmpty())
+		})
+	})
+})
+
+type eventLog struct {
+	events []string
+	mu     sync.Mutex
+}
+
+func (l *eventLog) record(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) recorded() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string{}, l.events...)
+}
@@ -0,0 +1,14 @@
+package client
+
+import "time"
+
+// SetResumeBackoff shortens the wait between reconnects of resumable
+// StreamOuts, returning a func that restores it.
+func SetResumeBackoff(backoff time.Duration) func() {
+	previous := resumeBackoff
+	resumeBackoff = backoff
+
+	return func() {
+		resumeBackoff = previous
+	}
+}
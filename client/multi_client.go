@@ -0,0 +1,516 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// HandleResolver finds the member of a MultiClient that owns a container.
+// Members are identified by their index in the MultiClient.
+type HandleResolver interface {
+	// Resolve returns the member owning the handle.
+	//
+	// Errors:
+	// * ContainerNotFoundError, if no member owns it.
+	Resolve(members []garden.Client, handle string) (int, error)
+
+	// Remember records that a member owns the handle, as when it has just
+	// created the container.
+	Remember(handle string, member int)
+
+	// Forget drops what is known about the handle, as when its container has
+	// been destroyed.
+	Forget(handle string)
+}
+
+// PlacementStrategy chooses the member of a MultiClient to create a container
+// on.
+type PlacementStrategy interface {
+	Place(members []garden.Client, spec garden.ContainerSpec) (int, error)
+}
+
+// PartialResultsError is returned along with the results gathered from the
+// members that answered, when others failed. Failures are keyed by member
+// index.
+type PartialResultsError struct {
+	Failures map[int]error
+}
+
+func (err *PartialResultsError) Error() string {
+	members := make([]int, 0, len(err.Failures))
+	for member := range err.Failures {
+		members = append(members, member)
+	}
+	sort.Ints(members)
+
+	messages := make([]string, 0, len(members))
+	for _, member := range members {
+		messages = append(messages, fmt.Sprintf("member %d: %s", member, err.Failures[member]))
+	}
+
+	return "partial results: " + strings.Join(messages, "; ")
+}
+
+// MultiClient spreads containers across several garden servers. Operations on
+// a handle go to the member owning it, listings and capacity are aggregated,
+// and creates are placed by a PlacementStrategy. Containers it returns belong
+// to their member's client, so that streaming and every other container
+// operation talk to the owning server directly.
+//
+// It is a client-side composition only; the servers know nothing of each
+// other.
+type MultiClient struct {
+	members   []garden.Client
+	resolver  HandleResolver
+	placement PlacementStrategy
+}
+
+var _ garden.Client = &MultiClient{}
+
+// NewMultiClient creates a MultiClient over the members. A nil resolver
+// defaults to a LookupResolver and a nil placement to RoundRobin.
+func NewMultiClient(members []garden.Client, resolver HandleResolver, placement PlacementStrategy) *MultiClient {
+	if resolver == nil {
+		resolver = NewLookupResolver()
+	}
+
+	if placement == nil {
+		placement = &RoundRobin{}
+	}
+
+	return &MultiClient{
+		members:   members,
+		resolver:  resolver,
+		placement: placement,
+	}
+}
+
+// Ping pings every member, failing with a PartialResultsError if any fails.
+func (client *MultiClient) Ping() error {
+	failures := client.fanOut(func(_ int, member garden.Client) error {
+		return member.Ping()
+	})
+
+	return partialResults(failures)
+}
+
+// Capacity sums the capacity of the members that answer. If some fail, the
+// sum is returned along with a PartialResultsError. The CPU and schedulable
+// memory totals are only reported if every member that answered reported
+// them.
+func (client *MultiClient) Capacity() (garden.Capacity, error) {
+	capacities := make([]garden.Capacity, len(client.members))
+
+	failures := client.fanOut(func(i int, member garden.Client) error {
+		capacity, err := member.Capacity()
+		capacities[i] = capacity
+		return err
+	})
+
+	var cpuCores, cpuShares, schedulableMemory []*uint64
+
+	total := garden.Capacity{}
+	for i, capacity := range capacities {
+		if _, failed := failures[i]; failed {
+			continue
+		}
+
+		total.MemoryInBytes += capacity.MemoryInBytes
+		total.DiskInBytes += capacity.DiskInBytes
+		total.MaxContainers += capacity.MaxContainers
+		cpuCores = append(cpuCores, capacity.CPUCores)
+		cpuShares = append(cpuShares, capacity.CPUShares)
+		schedulableMemory = append(schedulableMemory, capacity.SchedulableMemoryInBytes)
+		total.SubnetPool = addPoolUsage(total.SubnetPool, capacity.SubnetPool)
+		total.UIDPool = addPoolUsage(total.UIDPool, capacity.UIDPool)
+		total.PortPool = addPoolUsage(total.PortPool, capacity.PortPool)
+	}
+
+	total.CPUCores = sumReported(cpuCores)
+	total.CPUShares = sumReported(cpuShares)
+	total.SchedulableMemoryInBytes = sumReported(schedulableMemory)
+
+	return total, partialResults(failures)
+}
+
+// sumReported sums a value that members may not report. The sum is nil unless
+// every member reported the value, as a sum over only some of them would pass
+// for the whole.
+func sumReported(values []*uint64) *uint64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var sum uint64
+	for _, value := range values {
+		if value == nil {
+			return nil
+		}
+
+		sum += *value
+	}
+
+	return &sum
+}
+
+func addPoolUsage(total, usage *garden.PoolUsage) *garden.PoolUsage {
+	if usage == nil {
+		return total
+	}
+
+	if total == nil {
+		total = &garden.PoolUsage{}
+	}
+
+	return &garden.PoolUsage{Size: total.Size + usage.Size, InUse: total.InUse + usage.InUse}
+}
+
+// Create creates the container on the member chosen by the placement
+// strategy.
+func (client *MultiClient) Create(spec garden.ContainerSpec) (garden.Container, error) {
+	member, err := client.place(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := client.members[member].Create(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	client.resolver.Remember(container.Handle(), member)
+
+	return container, nil
+}
+
+func (client *MultiClient) place(spec garden.ContainerSpec) (int, error) {
+	if len(client.members) == 0 {
+		return 0, errors.New("no members to place the container on")
+	}
+
+	member, err := client.placement.Place(client.members, spec)
+	if err != nil {
+		return 0, err
+	}
+
+	if member < 0 || member >= len(client.members) {
+		return 0, fmt.Errorf("placement chose member %d of %d", member, len(client.members))
+	}
+
+	return member, nil
+}
+
+// Destroy destroys the container on the member owning it.
+func (client *MultiClient) Destroy(handle string) error {
+	member, err := client.resolver.Resolve(client.members, handle)
+	if err != nil {
+		return err
+	}
+
+	err = client.members[member].Destroy(handle)
+	if _, notFound := err.(garden.ContainerNotFoundError); err == nil || notFound {
+		client.resolver.Forget(handle)
+	}
+
+	return err
+}
+
+// Containers lists the containers of the members that answer, sorted by
+// handle. If some fail, the containers found are returned along with a
+// PartialResultsError.
+func (client *MultiClient) Containers(properties garden.Properties) ([]garden.Container, error) {
+	listed := make([][]garden.Container, len(client.members))
+
+	failures := client.fanOut(func(i int, member garden.Client) error {
+		containers, err := member.Containers(properties)
+		listed[i] = containers
+		return err
+	})
+
+	containers := []garden.Container{}
+	for i, memberContainers := range listed {
+		if _, failed := failures[i]; failed {
+			continue
+		}
+
+		for _, container := range memberContainers {
+			client.resolver.Remember(container.Handle(), i)
+			containers = append(containers, container)
+		}
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].Handle() < containers[j].Handle()
+	})
+
+	return containers, partialResults(failures)
+}
+
+// BulkInfo asks each member for the info of the handles it owns. Handles that
+// cannot be resolved, or whose member fails, get an entry holding the error.
+func (client *MultiClient) BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error) {
+	result := map[string]garden.ContainerInfoEntry{}
+
+	for member, memberHandles := range client.groupHandles(handles, func(handle string, err error) {
+		result[handle] = garden.ContainerInfoEntry{Err: &garden.Error{Err: err}}
+	}) {
+		entries, err := client.members[member].BulkInfo(memberHandles)
+		for _, handle := range memberHandles {
+			if err != nil {
+				result[handle] = garden.ContainerInfoEntry{Err: &garden.Error{Err: err}}
+			} else if entry, found := entries[handle]; found {
+				result[handle] = entry
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// BulkMetrics asks each member for the metrics of the handles it owns, in the
+// same way as BulkInfo.
+func (client *MultiClient) BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error) {
+	result := map[string]garden.ContainerMetricsEntry{}
+
+	for member, memberHandles := range client.groupHandles(handles, func(handle string, err error) {
+		result[handle] = garden.ContainerMetricsEntry{Err: &garden.Error{Err: err}}
+	}) {
+		entries, err := client.members[member].BulkMetrics(memberHandles)
+		for _, handle := range memberHandles {
+			if err != nil {
+				result[handle] = garden.ContainerMetricsEntry{Err: &garden.Error{Err: err}}
+			} else if entry, found := entries[handle]; found {
+				result[handle] = entry
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (client *MultiClient) groupHandles(handles []string, unresolved func(string, error)) map[int][]string {
+	groups := map[int][]string{}
+
+	for _, handle := range handles {
+		member, err := client.resolver.Resolve(client.members, handle)
+		if err != nil {
+			unresolved(handle, err)
+			continue
+		}
+
+		groups[member] = append(groups[member], handle)
+	}
+
+	return groups
+}
+
+// Lookup returns the container from the member owning it.
+func (client *MultiClient) Lookup(handle string) (garden.Container, error) {
+	member, err := client.resolver.Resolve(client.members, handle)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := client.members[member].Lookup(handle)
+	if _, notFound := err.(garden.ContainerNotFoundError); notFound {
+		client.resolver.Forget(handle)
+	}
+
+	return container, err
+}
+
+// fanOut calls each member concurrently and returns the failures by member.
+func (client *MultiClient) fanOut(call func(int, garden.Client) error) map[int]error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures = map[int]error{}
+	)
+
+	for i, member := range client.members {
+		wg.Add(1)
+
+		go func(i int, member garden.Client) {
+			defer wg.Done()
+
+			if err := call(i, member); err != nil {
+				mu.Lock()
+				failures[i] = err
+				mu.Unlock()
+			}
+		}(i, member)
+	}
+
+	wg.Wait()
+
+	return failures
+}
+
+func partialResults(failures map[int]error) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &PartialResultsError{Failures: failures}
+}
+
+// LookupResolver resolves a handle by asking every member to look it up, and
+// remembers the answer.
+type LookupResolver struct {
+	mu     sync.Mutex
+	owners map[string]int
+}
+
+func NewLookupResolver() *LookupResolver {
+	return &LookupResolver{owners: map[string]int{}}
+}
+
+// Resolve returns the remembered owner of the handle, or else the member
+// whose Lookup finds it. If no member finds it but some fail, the failures
+// are returned as a PartialResultsError.
+func (resolver *LookupResolver) Resolve(members []garden.Client, handle string) (int, error) {
+	resolver.mu.Lock()
+	member, known := resolver.owners[handle]
+	resolver.mu.Unlock()
+
+	if known && member < len(members) {
+		return member, nil
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		owner    = -1
+		failures = map[int]error{}
+	)
+
+	for i, member := range members {
+		wg.Add(1)
+
+		go func(i int, member garden.Client) {
+			defer wg.Done()
+
+			_, err := member.Lookup(handle)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch err.(type) {
+			case nil:
+				if owner == -1 || i < owner {
+					owner = i
+				}
+			case garden.ContainerNotFoundError:
+			default:
+				failures[i] = err
+			}
+		}(i, member)
+	}
+
+	wg.Wait()
+
+	if owner != -1 {
+		resolver.Remember(handle, owner)
+		return owner, nil
+	}
+
+	if len(failures) > 0 {
+		return 0, &PartialResultsError{Failures: failures}
+	}
+
+	return 0, garden.ContainerNotFoundError{Handle: handle}
+}
+
+func (resolver *LookupResolver) Remember(handle string, member int) {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	resolver.owners[handle] = member
+}
+
+func (resolver *LookupResolver) Forget(handle string) {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	delete(resolver.owners, handle)
+}
+
+// RoundRobin places containers on each member in turn.
+type RoundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (strategy *RoundRobin) Place(members []garden.Client, _ garden.ContainerSpec) (int, error) {
+	strategy.mu.Lock()
+	defer strategy.mu.Unlock()
+
+	member := strategy.next % len(members)
+	strategy.next = member + 1
+
+	return member, nil
+}
+
+// MostFreeCapacity places containers on the member with the most room left in
+// its allocation pools, as reported by Capacity. A container takes one entry
+// from every pool, so a member's room is that of its fullest pool; a member
+// reporting no pools is judged by MaxContainers. Members whose Capacity fails
+// are passed over, and the lowest index wins a tie.
+type MostFreeCapacity struct{}
+
+func (MostFreeCapacity) Place(members []garden.Client, _ garden.ContainerSpec) (int, error) {
+	rooms := make([]uint64, len(members))
+	failures := (&MultiClient{members: members}).fanOut(func(i int, member garden.Client) error {
+		capacity, err := member.Capacity()
+		rooms[i] = freeRoom(capacity)
+		return err
+	})
+
+	best := -1
+	for i, room := range rooms {
+		if _, failed := failures[i]; failed {
+			continue
+		}
+
+		if best == -1 || room > rooms[best] {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return 0, &PartialResultsError{Failures: failures}
+	}
+
+	return best, nil
+}
+
+func freeRoom(capacity garden.Capacity) uint64 {
+	pools := []*garden.PoolUsage{capacity.SubnetPool, capacity.UIDPool, capacity.PortPool}
+
+	room, reported := uint64(0), false
+	for _, pool := range pools {
+		if pool == nil {
+			continue
+		}
+
+		free := uint64(0)
+		if pool.Size > pool.InUse {
+			free = pool.Size - pool.InUse
+		}
+
+		if !reported || free < room {
+			room, reported = free, true
+		}
+	}
+
+	if !reported {
+		return capacity.MaxContainers
+	}
+
+	return room
+}
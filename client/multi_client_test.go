@@ -0,0 +1,411 @@
+package client_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/garden"
+	. "code.cloudfoundry.org/garden/client"
+	"code.cloudfoundry.org/garden/gardenfakes"
+)
+
+var _ = Describe("MultiClient", func() {
+	var (
+		members []*gardenfakes.FakeClient
+		multi   *MultiClient
+
+		resolver  HandleResolver
+		placement PlacementStrategy
+	)
+
+	fakeContainer := func(handle string) garden.Container {
+		container := new(gardenfakes.FakeContainer)
+		container.HandleReturns(handle)
+		return container
+	}
+
+	// owning makes a member that owns the given handles
+	owning := func(handles ...string) *gardenfakes.FakeClient {
+		member := new(gardenfakes.FakeClient)
+
+		member.LookupStub = func(handle string) (garden.Container, error) {
+			for _, h := range handles {
+				if h == handle {
+					return fakeContainer(handle), nil
+				}
+			}
+
+			return nil, garden.ContainerNotFoundError{Handle: handle}
+		}
+
+		containers := []garden.Container{}
+		for _, handle := range handles {
+			containers = append(containers, fakeContainer(handle))
+		}
+		member.ContainersReturns(containers, nil)
+
+		return member
+	}
+
+	handlesOf := func(containers []garden.Container) []string {
+		handles := []string{}
+		for _, container := range containers {
+			handles = append(handles, container.Handle())
+		}
+		return handles
+	}
+
+	BeforeEach(func() {
+		members = []*gardenfakes.FakeClient{
+			owning("a-1", "a-2"),
+			owning("b-1"),
+			owning("c-1"),
+		}
+
+		resolver = nil
+		placement = nil
+	})
+
+	JustBeforeEach(func() {
+		clients := []garden.Client{}
+		for _, member := range members {
+			clients = append(clients, member)
+		}
+
+		multi = NewMultiClient(clients, resolver, placement)
+	})
+
+	Describe("Ping", func() {
+		It("pings every member", func() {
+			Ω(multi.Ping()).Should(Succeed())
+
+			for _, member := range members {
+				Ω(member.PingCallCount()).Should(Equal(1))
+			}
+		})
+
+		It("reports the members that fail", func() {
+			members[1].PingReturns(errors.New("down"))
+
+			err := multi.Ping()
+			Ω(err).Should(BeAssignableToTypeOf(&PartialResultsError{}))
+			Ω(err.(*PartialResultsError).Failures).Should(Equal(map[int]error{1: errors.New("down")}))
+			Ω(err).Should(MatchError("partial results: member 1: down"))
+		})
+	})
+
+	Describe("Capacity", func() {
+		BeforeEach(func() {
+			members[0].CapacityReturns(garden.Capacity{
+				MemoryInBytes: 100,
+				DiskInBytes:   1000,
+				MaxContainers: 10,
+				SubnetPool:    &garden.PoolUsage{Size: 10, InUse: 4},
+				CPUCores:      uint64ptr(4),
+			}, nil)
+			members[1].CapacityReturns(garden.Capacity{
+				MemoryInBytes: 200,
+				DiskInBytes:   2000,
+				MaxContainers: 20,
+				SubnetPool:    &garden.PoolUsage{Size: 20, InUse: 5},
+				UIDPool:       &garden.PoolUsage{Size: 30, InUse: 1},
+				CPUCores:      uint64ptr(8),
+
+				SchedulableMemoryInBytes: uint64ptr(150),
+			}, nil)
+			members[2].CapacityReturns(garden.Capacity{MemoryInBytes: 400, CPUCores: uint64ptr(2)}, nil)
+		})
+
+		It("sums the capacity of the members", func() {
+			capacity, err := multi.Capacity()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(capacity).Should(Equal(garden.Capacity{
+				MemoryInBytes: 700,
+				DiskInBytes:   3000,
+				MaxContainers: 30,
+				SubnetPool:    &garden.PoolUsage{Size: 30, InUse: 9},
+				UIDPool:       &garden.PoolUsage{Size: 30, InUse: 1},
+				CPUCores:      uint64ptr(14),
+			}))
+		})
+
+		It("reports a total only if every member that answered reported it", func() {
+			members[0].CapacityReturns(garden.Capacity{}, errors.New("down"))
+			members[2].CapacityReturns(garden.Capacity{SchedulableMemoryInBytes: uint64ptr(50)}, nil)
+
+			capacity, err := multi.Capacity()
+			Ω(err).Should(MatchError("partial results: member 0: down"))
+			Ω(capacity.CPUCores).Should(BeNil())
+			Ω(capacity.SchedulableMemoryInBytes).Should(Equal(uint64ptr(200)))
+		})
+
+		It("skips members that fail and reports them", func() {
+			members[1].CapacityReturns(garden.Capacity{}, errors.New("down"))
+
+			capacity, err := multi.Capacity()
+			Ω(err).Should(MatchError("partial results: member 1: down"))
+			Ω(capacity.MemoryInBytes).Should(Equal(uint64(500)))
+		})
+	})
+
+	Describe("Containers", func() {
+		It("lists the containers of every member, sorted by handle", func() {
+			members[0].ContainersReturns([]garden.Container{fakeContainer("z"), fakeContainer("b")}, nil)
+			members[1].ContainersReturns([]garden.Container{fakeContainer("a")}, nil)
+			members[2].ContainersReturns([]garden.Container{}, nil)
+
+			containers, err := multi.Containers(garden.Properties{"owner": "me"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handlesOf(containers)).Should(Equal([]string{"a", "b", "z"}))
+			Ω(members[0].ContainersArgsForCall(0)).Should(Equal(garden.Properties{"owner": "me"}))
+		})
+
+		It("skips members that fail and reports them", func() {
+			members[2].ContainersReturns(nil, errors.New("down"))
+
+			containers, err := multi.Containers(nil)
+			Ω(err).Should(MatchError("partial results: member 2: down"))
+			Ω(handlesOf(containers)).Should(Equal([]string{"a-1", "a-2", "b-1"}))
+		})
+
+		It("remembers which member owns each container listed", func() {
+			_, err := multi.Containers(nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(multi.Destroy("b-1")).Should(Succeed())
+			Ω(members[1].DestroyCallCount()).Should(Equal(1))
+
+			for _, member := range members {
+				Ω(member.LookupCallCount()).Should(BeZero())
+			}
+		})
+	})
+
+	Describe("handle-addressed operations", func() {
+		It("looks the container up on the member owning it", func() {
+			container, err := multi.Lookup("b-1")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Handle()).Should(Equal("b-1"))
+
+			Ω(members[1].LookupCallCount()).Should(Equal(2))
+		})
+
+		It("destroys the container on the member owning it", func() {
+			Ω(multi.Destroy("c-1")).Should(Succeed())
+
+			Ω(members[0].DestroyCallCount()).Should(BeZero())
+			Ω(members[1].DestroyCallCount()).Should(BeZero())
+			Ω(members[2].DestroyArgsForCall(0)).Should(Equal("c-1"))
+		})
+
+		It("caches the owner of a handle", func() {
+			_, err := multi.Lookup("a-2")
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = multi.Lookup("a-2")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(members[1].LookupCallCount()).Should(Equal(1))
+			Ω(members[0].LookupCallCount()).Should(Equal(3))
+		})
+
+		It("forgets the owner once the container is destroyed", func() {
+			Ω(multi.Destroy("a-1")).Should(Succeed())
+			_, err := multi.Lookup("a-1")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(members[1].LookupCallCount()).Should(Equal(2))
+		})
+
+		It("forgets the owner once it no longer has the container", func() {
+			members[0].DestroyReturns(garden.ContainerNotFoundError{Handle: "a-1"})
+
+			Ω(multi.Destroy("a-1")).Should(Equal(garden.ContainerNotFoundError{Handle: "a-1"}))
+			_, err := multi.Lookup("a-1")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(members[1].LookupCallCount()).Should(Equal(2))
+		})
+
+		It("fails with ContainerNotFoundError when no member owns the handle", func() {
+			_, err := multi.Lookup("nowhere")
+			Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "nowhere"}))
+
+			Ω(multi.Destroy("nowhere")).Should(Equal(garden.ContainerNotFoundError{Handle: "nowhere"}))
+		})
+
+		It("reports failing members when no other member owns the handle", func() {
+			members[2].LookupReturns(nil, errors.New("down"))
+
+			_, err := multi.Lookup("c-1")
+			Ω(err).Should(MatchError("partial results: member 2: down"))
+		})
+
+		It("still finds a handle owned by a live member when another fails", func() {
+			members[2].LookupReturns(nil, errors.New("down"))
+
+			container, err := multi.Lookup("a-1")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Handle()).Should(Equal("a-1"))
+		})
+
+		Context("with a custom resolver", func() {
+			BeforeEach(func() {
+				resolver = &prefixResolver{}
+			})
+
+			It("routes by it", func() {
+				Ω(multi.Destroy("c-anything")).Should(Succeed())
+				Ω(members[2].DestroyArgsForCall(0)).Should(Equal("c-anything"))
+			})
+		})
+	})
+
+	Describe("BulkInfo", func() {
+		BeforeEach(func() {
+			members[0].BulkInfoReturns(map[string]garden.ContainerInfoEntry{
+				"a-1": {Info: garden.ContainerInfo{State: "active"}},
+			}, nil)
+			members[1].BulkInfoReturns(nil, errors.New("down"))
+		})
+
+		It("asks each member about the handles it owns", func() {
+			entries, err := multi.BulkInfo([]string{"a-1", "b-1", "nowhere"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(members[0].BulkInfoArgsForCall(0)).Should(Equal([]string{"a-1"}))
+			Ω(members[1].BulkInfoArgsForCall(0)).Should(Equal([]string{"b-1"}))
+			Ω(members[2].BulkInfoCallCount()).Should(BeZero())
+
+			Ω(entries["a-1"]).Should(Equal(garden.ContainerInfoEntry{Info: garden.ContainerInfo{State: "active"}}))
+			Ω(entries["b-1"].Err).Should(MatchError("down"))
+			Ω(entries["nowhere"].Err.Err).Should(Equal(garden.ContainerNotFoundError{Handle: "nowhere"}))
+		})
+	})
+
+	Describe("BulkMetrics", func() {
+		It("asks each member about the handles it owns", func() {
+			members[2].BulkMetricsReturns(map[string]garden.ContainerMetricsEntry{
+				"c-1": {Metrics: garden.Metrics{}},
+			}, nil)
+
+			entries, err := multi.BulkMetrics([]string{"c-1"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(members[2].BulkMetricsArgsForCall(0)).Should(Equal([]string{"c-1"}))
+			Ω(entries).Should(HaveKey("c-1"))
+			Ω(entries["c-1"].Err).Should(BeNil())
+		})
+	})
+
+	Describe("Create", func() {
+		BeforeEach(func() {
+			for i, member := range members {
+				member.CreateReturns(fakeContainer([]string{"new-a", "new-b", "new-c"}[i]), nil)
+			}
+		})
+
+		It("places containers on each member in turn by default", func() {
+			for i := 0; i < 4; i++ {
+				_, err := multi.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+
+			Ω(members[0].CreateCallCount()).Should(Equal(2))
+			Ω(members[1].CreateCallCount()).Should(Equal(1))
+			Ω(members[2].CreateCallCount()).Should(Equal(1))
+		})
+
+		It("remembers the member owning the created container", func() {
+			container, err := multi.Create(garden.ContainerSpec{Handle: "new-a"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Handle()).Should(Equal("new-a"))
+			Ω(members[0].CreateArgsForCall(0).Handle).Should(Equal("new-a"))
+
+			Ω(multi.Destroy("new-a")).Should(Succeed())
+			Ω(members[0].DestroyArgsForCall(0)).Should(Equal("new-a"))
+			Ω(members[0].LookupCallCount()).Should(BeZero())
+		})
+
+		It("returns the member's error", func() {
+			members[0].CreateReturns(nil, errors.New("no room"))
+
+			_, err := multi.Create(garden.ContainerSpec{})
+			Ω(err).Should(MatchError("no room"))
+		})
+
+		Context("when placing by most free capacity", func() {
+			BeforeEach(func() {
+				placement = MostFreeCapacity{}
+
+				members[0].CapacityReturns(garden.Capacity{
+					SubnetPool: &garden.PoolUsage{Size: 100, InUse: 10},
+					UIDPool:    &garden.PoolUsage{Size: 100, InUse: 95},
+				}, nil)
+				members[1].CapacityReturns(garden.Capacity{
+					SubnetPool: &garden.PoolUsage{Size: 50, InUse: 30},
+				}, nil)
+				members[2].CapacityReturns(garden.Capacity{MaxContainers: 10}, nil)
+			})
+
+			It("places on the member whose fullest pool has the most room", func() {
+				_, err := multi.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(members[1].CreateCallCount()).Should(Equal(1))
+			})
+
+			It("passes over members whose capacity cannot be had", func() {
+				members[1].CapacityReturns(garden.Capacity{}, errors.New("down"))
+
+				_, err := multi.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(members[2].CreateCallCount()).Should(Equal(1))
+			})
+
+			It("fails when no member reports its capacity", func() {
+				for _, member := range members {
+					member.CapacityReturns(garden.Capacity{}, errors.New("down"))
+				}
+
+				_, err := multi.Create(garden.ContainerSpec{})
+				Ω(err).Should(BeAssignableToTypeOf(&PartialResultsError{}))
+
+				for _, member := range members {
+					Ω(member.CreateCallCount()).Should(BeZero())
+				}
+			})
+		})
+
+		Context("when the placement chooses a member that does not exist", func() {
+			BeforeEach(func() {
+				placement = fixedPlacement(7)
+			})
+
+			It("fails without creating", func() {
+				_, err := multi.Create(garden.ContainerSpec{})
+				Ω(err).Should(MatchError("placement chose member 7 of 3"))
+			})
+		})
+	})
+})
+
+// prefixResolver routes handles by their first letter
+type prefixResolver struct{}
+
+func (prefixResolver) Resolve(members []garden.Client, handle string) (int, error) {
+	return int(handle[0] - 'a'), nil
+}
+
+func (prefixResolver) Remember(string, int) {}
+func (prefixResolver) Forget(string)        {}
+
+type fixedPlacement int
+
+func (p fixedPlacement) Place([]garden.Client, garden.ContainerSpec) (int, error) {
+	return int(p), nil
+}
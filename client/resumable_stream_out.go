@@ -0,0 +1,129 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// resumeAttempts bounds how often in a row a resumable StreamOut reconnects
+// without receiving anything before giving up.
+const resumeAttempts = 5
+
+// resumeBackoff is how long the first reconnect waits; each one after it waits
+// twice as long as the last. Tests shorten it.
+var resumeBackoff = 100 * time.Millisecond
+
+// resumingReader reads a resumable StreamOut, reopening it from where it broke
+// off. It hashes what it has read, so that the server can tell whether the
+// archive still starts with it.
+type resumingReader struct {
+	spec garden.StreamOutSpec
+	open func(garden.StreamOutSpec) (io.ReadCloser, error)
+
+	stream io.ReadCloser
+	offset int64
+	hash   hash.Hash
+
+	backoff  time.Duration
+	failures int
+	lastErr  error
+}
+
+func newResumingReader(spec garden.StreamOutSpec, open func(garden.StreamOutSpec) (io.ReadCloser, error)) (*resumingReader, error) {
+	spec.Resumable = true
+	spec.Offset = 0
+	spec.Generation = ""
+
+	r := &resumingReader{
+		spec: spec,
+		open: open,
+		hash: sha256.New(),
+
+		backoff: resumeBackoff,
+	}
+
+	if err := r.resume(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	for {
+		if r.stream == nil {
+			if err := r.resume(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.stream.Read(p)
+		if n > 0 {
+			r.hash.Write(p[:n])
+			r.offset += int64(n)
+			r.failures = 0
+		}
+
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+
+		r.stream.Close()
+		r.stream = nil
+
+		r.failures++
+		r.lastErr = err
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *resumingReader) Close() error {
+	if r.stream == nil {
+		return nil
+	}
+
+	return r.stream.Close()
+}
+
+// resume opens the stream from the current offset, retrying connection
+// failures. Errors the server returns, such as a StreamOutChangedError, are
+// not retried.
+func (r *resumingReader) resume() error {
+	for {
+		if r.failures > resumeAttempts {
+			return r.lastErr
+		}
+
+		if r.failures > 0 {
+			time.Sleep(r.backoff << uint(r.failures-1))
+		}
+
+		spec := r.spec
+		if r.offset > 0 {
+			spec.Offset = r.offset
+			spec.Generation = hex.EncodeToString(r.hash.Sum(nil))
+		}
+
+		stream, err := r.open(spec)
+		if err == nil {
+			r.stream = stream
+			return nil
+		}
+
+		if _, ok := err.(net.Error); !ok {
+			return err
+		}
+
+		r.failures++
+		r.lastErr = err
+	}
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// OutOfScopeError is returned by a ScopedClient for a container that does not
+// carry its scope's properties, and for a spec that would create one.
+type OutOfScopeError struct {
+	Handle string
+}
+
+func (err OutOfScopeError) Error() string {
+	if err.Handle == "" {
+		return "container spec conflicts with the client's scope"
+	}
+
+	return fmt.Sprintf("container %s is outside the client's scope", err.Handle)
+}
+
+// ScopedClient confines a client to the containers carrying a set of
+// properties, such as those identifying a tenant. They are added to every
+// container it creates and every filter it lists with, and operations on a
+// handle check the container's properties first.
+//
+// It is a client-side composition only, so it keeps honest callers apart
+// rather than enforcing anything against others sharing the server. Scoped
+// clients may be nested, the inner scope applying as well. Containers it
+// returns belong to the underlying client, so changes to their properties are
+// not checked.
+type ScopedClient struct {
+	inner garden.Client
+	scope garden.Properties
+}
+
+var _ garden.Client = &ScopedClient{}
+
+// NewScopedClient creates a ScopedClient over inner for the given scope.
+func NewScopedClient(inner garden.Client, scope garden.Properties) *ScopedClient {
+	copied := garden.Properties{}
+	for name, value := range scope {
+		copied[name] = value
+	}
+
+	return &ScopedClient{
+		inner: inner,
+		scope: copied,
+	}
+}
+
+func (client *ScopedClient) Ping() error {
+	return client.inner.Ping()
+}
+
+func (client *ScopedClient) Capacity() (garden.Capacity, error) {
+	return client.inner.Capacity()
+}
+
+// Create creates a container carrying the scope's properties, failing with an
+// OutOfScopeError if the spec gives any of them another value.
+func (client *ScopedClient) Create(spec garden.ContainerSpec) (garden.Container, error) {
+	properties, ok := client.merge(spec.Properties)
+	if !ok {
+		return nil, OutOfScopeError{Handle: spec.Handle}
+	}
+
+	spec.Properties = properties
+
+	return client.inner.Create(spec)
+}
+
+func (client *ScopedClient) Destroy(handle string) error {
+	if _, err := client.Lookup(handle); err != nil {
+		return err
+	}
+
+	return client.inner.Destroy(handle)
+}
+
+// Containers lists the containers in scope matching the filter. A filter
+// giving a scope property another value matches nothing.
+func (client *ScopedClient) Containers(filter garden.Properties) ([]garden.Container, error) {
+	properties, ok := client.merge(filter)
+	if !ok {
+		return []garden.Container{}, nil
+	}
+
+	return client.inner.Containers(properties)
+}
+
+// BulkInfo reports the info of the containers in scope. The entries of the
+// others hold an OutOfScopeError.
+func (client *ScopedClient) BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error) {
+	infos, err := client.inner.BulkInfo(handles)
+	if err != nil {
+		return nil, err
+	}
+
+	for handle, entry := range infos {
+		if entry.Err == nil && !client.inScope(entry.Info.Properties) {
+			infos[handle] = garden.ContainerInfoEntry{
+				Err: &garden.Error{Err: OutOfScopeError{Handle: handle}},
+			}
+		}
+	}
+
+	return infos, nil
+}
+
+// BulkMetrics reports the metrics of the containers in scope. The entries of
+// the others hold the error that kept them out.
+func (client *ScopedClient) BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error) {
+	infos, err := client.BulkInfo(handles)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := []string{}
+	refused := map[string]garden.ContainerMetricsEntry{}
+	for _, handle := range handles {
+		if entry, found := infos[handle]; found && entry.Err != nil {
+			refused[handle] = garden.ContainerMetricsEntry{Err: entry.Err}
+			continue
+		}
+
+		allowed = append(allowed, handle)
+	}
+
+	metrics := map[string]garden.ContainerMetricsEntry{}
+	if len(allowed) > 0 {
+		metrics, err = client.inner.BulkMetrics(allowed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for handle, entry := range refused {
+		metrics[handle] = entry
+	}
+
+	return metrics, nil
+}
+
+// Lookup returns the container if it carries the scope's properties, failing
+// with an OutOfScopeError if it does not.
+func (client *ScopedClient) Lookup(handle string) (garden.Container, error) {
+	container, err := client.inner.Lookup(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, err := container.Properties()
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.inScope(properties) {
+		return nil, OutOfScopeError{Handle: handle}
+	}
+
+	return container, nil
+}
+
+// merge adds the scope to the properties, reporting false if they give any of
+// the scope's properties another value.
+func (client *ScopedClient) merge(properties garden.Properties) (garden.Properties, bool) {
+	merged := garden.Properties{}
+	for name, value := range properties {
+		merged[name] = value
+	}
+
+	for name, value := range client.scope {
+		if existing, found := merged[name]; found && existing != value {
+			return nil, false
+		}
+
+		merged[name] = value
+	}
+
+	return merged, true
+}
+
+func (client *ScopedClient) inScope(properties garden.Properties) bool {
+	for name, value := range client.scope {
+		if existing, found := properties[name]; !found || existing != value {
+			return false
+		}
+	}
+
+	return true
+}
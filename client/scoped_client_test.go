@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/garden"
+	. "code.cloudfoundry.org/garden/client"
+	"code.cloudfoundry.org/garden/gardenfakes"
+)
+
+var _ = Describe("ScopedClient", func() {
+	var (
+		shared     *gardenfakes.FakeClient
+		properties map[string]garden.Properties
+
+		tenantA *ScopedClient
+		tenantB *ScopedClient
+	)
+
+	matches := func(props, filter garden.Properties) bool {
+		for name, value := range filter {
+			if existing, found := props[name]; !found || existing != value {
+				return false
+			}
+		}
+		return true
+	}
+
+	containerFor := func(handle string) garden.Container {
+		container := new(gardenfakes.FakeContainer)
+		container.HandleReturns(handle)
+		container.PropertiesReturns(properties[handle], nil)
+		return container
+	}
+
+	handlesOf := func(containers []garden.Container) []string {
+		handles := []string{}
+		for _, container := range containers {
+			handles = append(handles, container.Handle())
+		}
+		sort.Strings(handles)
+		return handles
+	}
+
+	BeforeEach(func() {
+		properties = map[string]garden.Properties{}
+
+		// a client over one server, remembering the properties of what it
+		// creates
+		shared = new(gardenfakes.FakeClient)
+		shared.CreateStub = func(spec garden.ContainerSpec) (garden.Container, error) {
+			properties[spec.Handle] = spec.Properties
+			return containerFor(spec.Handle), nil
+		}
+		shared.LookupStub = func(handle string) (garden.Container, error) {
+			if _, found := properties[handle]; !found {
+				return nil, garden.ContainerNotFoundError{Handle: handle}
+			}
+			return containerFor(handle), nil
+		}
+		shared.ContainersStub = func(filter garden.Properties) ([]garden.Container, error) {
+			containers := []garden.Container{}
+			for handle, props := range properties {
+				if matches(props, filter) {
+					containers = append(containers, containerFor(handle))
+				}
+			}
+			return containers, nil
+		}
+		shared.DestroyStub = func(handle string) error {
+			delete(properties, handle)
+			return nil
+		}
+		shared.BulkInfoStub = func(handles []string) (map[string]garden.ContainerInfoEntry, error) {
+			infos := map[string]garden.ContainerInfoEntry{}
+			for _, handle := range handles {
+				infos[handle] = garden.ContainerInfoEntry{Info: garden.ContainerInfo{Properties: properties[handle]}}
+			}
+			return infos, nil
+		}
+		shared.BulkMetricsStub = func(handles []string) (map[string]garden.ContainerMetricsEntry, error) {
+			metrics := map[string]garden.ContainerMetricsEntry{}
+			for _, handle := range handles {
+				metrics[handle] = garden.ContainerMetricsEntry{}
+			}
+			return metrics, nil
+		}
+
+		tenantA = NewScopedClient(shared, garden.Properties{"tenant": "a"})
+		tenantB = NewScopedClient(shared, garden.Properties{"tenant": "b"})
+
+		_, err := tenantA.Create(garden.ContainerSpec{Handle: "a-1", Properties: garden.Properties{"app": "web"}})
+		Ω(err).ShouldNot(HaveOccurred())
+		_, err = tenantB.Create(garden.ContainerSpec{Handle: "b-1"})
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	Describe("Create", func() {
+		It("adds the scope's properties to the spec", func() {
+			Ω(properties["a-1"]).Should(Equal(garden.Properties{"tenant": "a", "app": "web"}))
+			Ω(properties["b-1"]).Should(Equal(garden.Properties{"tenant": "b"}))
+		})
+
+		It("refuses a spec giving a scope property another value", func() {
+			_, err := tenantA.Create(garden.ContainerSpec{Handle: "sneaky", Properties: garden.Properties{"tenant": "b"}})
+			Ω(err).Should(Equal(OutOfScopeError{Handle: "sneaky"}))
+
+			Ω(properties).ShouldNot(HaveKey("sneaky"))
+		})
+	})
+
+	Describe("Containers", func() {
+		It("lists only the containers in scope", func() {
+			Ω(tenantA.Containers(nil)).Should(WithTransform(handlesOf, Equal([]string{"a-1"})))
+			Ω(tenantB.Containers(nil)).Should(WithTransform(handlesOf, Equal([]string{"b-1"})))
+		})
+
+		It("applies the caller's filter as well", func() {
+			Ω(tenantA.Containers(garden.Properties{"app": "web"})).Should(WithTransform(handlesOf, Equal([]string{"a-1"})))
+			Ω(tenantA.Containers(garden.Properties{"app": "db"})).Should(BeEmpty())
+		})
+
+		It("matches nothing for a filter giving a scope property another value", func() {
+			Ω(tenantA.Containers(garden.Properties{"tenant": "b"})).Should(BeEmpty())
+		})
+	})
+
+	Describe("operations on a handle", func() {
+		It("refuses containers outside the scope", func() {
+			_, err := tenantA.Lookup("b-1")
+			Ω(err).Should(Equal(OutOfScopeError{Handle: "b-1"}))
+
+			Ω(tenantA.Destroy("b-1")).Should(Equal(OutOfScopeError{Handle: "b-1"}))
+			Ω(properties).Should(HaveKey("b-1"))
+		})
+
+		It("operates on containers in scope", func() {
+			container, err := tenantB.Lookup("b-1")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Handle()).Should(Equal("b-1"))
+
+			Ω(tenantB.Destroy("b-1")).Should(Succeed())
+			Ω(properties).ShouldNot(HaveKey("b-1"))
+		})
+
+		It("passes on errors looking the container up", func() {
+			_, err := tenantA.Lookup("missing")
+			Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "missing"}))
+		})
+	})
+
+	Describe("bulk operations", func() {
+		It("reports info for containers in scope only", func() {
+			infos, err := tenantA.BulkInfo([]string{"a-1", "b-1"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(infos["a-1"].Err).Should(BeNil())
+			Ω(infos["b-1"].Err.Err).Should(Equal(OutOfScopeError{Handle: "b-1"}))
+		})
+
+		It("reports metrics for containers in scope only", func() {
+			metrics, err := tenantA.BulkMetrics([]string{"a-1", "b-1"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(metrics["a-1"].Err).Should(BeNil())
+			Ω(metrics["b-1"].Err.Err).Should(Equal(OutOfScopeError{Handle: "b-1"}))
+
+			Ω(shared.BulkMetricsArgsForCall(0)).Should(Equal([]string{"a-1"}))
+		})
+	})
+
+	Context("when nested", func() {
+		var nested *ScopedClient
+
+		BeforeEach(func() {
+			nested = NewScopedClient(tenantA, garden.Properties{"app": "web"})
+		})
+
+		It("applies both scopes", func() {
+			_, err := nested.Create(garden.ContainerSpec{Handle: "a-2"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(properties["a-2"]).Should(Equal(garden.Properties{"tenant": "a", "app": "web"}))
+
+			Ω(nested.Containers(nil)).Should(WithTransform(handlesOf, Equal([]string{"a-1", "a-2"})))
+
+			_, err = nested.Lookup("b-1")
+			Ω(err).Should(Equal(OutOfScopeError{Handle: "b-1"}))
+		})
+
+		It("refuses specs conflicting with the inner scope", func() {
+			_, err := nested.Create(garden.ContainerSpec{Handle: "sneaky", Properties: garden.Properties{"tenant": "b"}})
+			Ω(err).Should(Equal(OutOfScopeError{Handle: "sneaky"}))
+		})
+	})
+})
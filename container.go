@@ -19,6 +19,8 @@ type Container interface {
 	//
 	// If kill is true, garden stops a container by sending the processing running inside it a SIGKILL signal.
 	//
+	// Stopping a container that is already stopped is a no-op.
+	//
 	// It is possible to copy files in to and out of a stopped container.
 	// It is only when a container is destroyed that its filesystem is cleaned up.
 	//
@@ -31,12 +33,31 @@ type Container interface {
 
 	// StreamIn streams data into a file in a container.
 	//
+	// The tar stream is extracted under the spec's Path, which is created along
+	// with any missing parents. Backends should extract it as it is read rather
+	// than buffering it, as it may be many gigabytes.
+	//
+	// If reading the tar stream fails, anything extracted by this call must be
+	// removed before returning, so that each call is all or nothing. This is
+	// up to the backend: the server aborts the stream, but only sees the tar
+	// stream, not the files extracted from it.
+	//
 	// Errors:
-	// *  TODO.
+	// * QuotaExceededError, if the stream would not fit in the container's
+	//   disk quota.
+	// * StreamSizeMismatchError, if ExpectedBytes was given and the stream is
+	//   larger or smaller.
+	// * ChecksumMismatchError, if a checksum was given and the stream does not
+	//   match it.
 	StreamIn(spec StreamInSpec) error
 
 	// StreamOut streams a file out of a container.
 	//
+	// Following tar conventions, a Path with a trailing slash streams the
+	// contents of the directory, and one without streams the directory itself.
+	// The server streams the archive as the backend produces it, and closes the
+	// backend's reader once it is done, including when the client disconnects.
+	//
 	// Errors:
 	// * TODO.
 	StreamOut(spec StreamOutSpec) (io.ReadCloser, error)
@@ -80,27 +101,44 @@ type Container interface {
 	// * An error is returned if the NetOut call fails.
 	NetOut(netOutRule NetOutRule) error
 
+	// Whitelist outbound network traffic for a batch of rules at once, as if
+	// by calling NetOut for each in order.
+	//
+	// Errors:
+	// * An InvalidNetOutRuleError if any rule is malformed, in which case none
+	//   of them are applied.
+	// * An error is returned if the BulkNetOut call fails.
+	BulkNetOut(netOutRules []NetOutRule) error
+
 	// Run a script inside a container.
 	//
 	// The root user will be mapped to a non-root UID in the host unless the container (not this process) was created with 'privileged' true.
 	//
 	// Errors:
-	// * TODO.
+	// * When the spec's ProcessLimits exceed the container's limits.
+	// * UnsupportedOperationError, if the spec has ProcessLimits and the
+	//   backend cannot enforce limits on individual processes; see
+	//   ProcessLimiter.
 	Run(ProcessSpec, ProcessIO) (Process, error)
 
 	// Attach starts streaming the output back to the client from a specified process.
 	//
 	// Errors:
-	// * processID does not refer to a running process.
+	// * ProcessNotFoundError, if processID does not refer to a process of the
+	//   container.
 	Attach(processID string, io ProcessIO) (Process, error)
 
 	// Metrics returns the current set of metrics for a container
 	Metrics() (Metrics, error)
 
-	// Sets the grace time.
+	// SetGraceTime changes how long the container may go unreferenced before
+	// it is destroyed, restarting the countdown from now. Zero means it is
+	// never destroyed for going unreferenced. Requests in flight still hold
+	// the countdown until they finish.
 	SetGraceTime(graceTime time.Duration) error
 
-	// Properties returns the current set of properties
+	// Properties returns the current set of properties. Backends return a
+	// copy, so callers may modify it without affecting the container.
 	Properties() (Properties, error)
 
 	// Property returns the value of the property with the specified name.
@@ -144,8 +182,57 @@ type ProcessSpec struct {
 
 	// Execute with a TTY for stdio.
 	TTY *TTYSpec `json:"tty,omitempty"`
+
+	// MaxDuration limits how long the process may run. Once it is exceeded,
+	// the process is sent SignalTerminate, and SignalKill if it has not exited
+	// shortly after. Wait then returns the exit status along with a
+	// ProcessTimeoutError. Zero means no limit.
+	MaxDuration time.Duration `json:"max_duration,omitempty"`
+
+	// ProcessLimits optionally gives the process a budget of its own within
+	// the container's limits, so that it cannot starve the container's other
+	// processes.
+	ProcessLimits *ProcessLimits `json:"process_limits,omitempty"`
+
+	// OutputCapture optionally has the server keep the process's stdout and
+	// stderr in files of its own, so that the output of a process nobody is
+	// attached to can be read later. The output is still streamed to any
+	// attached client.
+	OutputCapture *OutputCapture `json:"output_capture,omitempty"`
 }
 
+// ProcessLimits is a sub-budget of a container's limits for a single
+// process. Zero fields are not limited beyond the container's own limits.
+type ProcessLimits struct {
+	// MemoryInBytes may not exceed the container's memory limit. A process
+	// exceeding it is killed without affecting the rest of the container.
+	MemoryInBytes uint64 `json:"memory_in_bytes,omitempty"`
+
+	// CPUShares may not exceed the container's CPU shares.
+	CPUShares uint64 `json:"cpu_shares,omitempty"`
+}
+
+// OutputCapture configures how a process's output is captured.
+type OutputCapture struct {
+	// MaxBytes caps the output kept of each stream. Once a stream's capture
+	// file would exceed it, the file is rotated, and the capture holds its
+	// previous file and the new one; older output is discarded. A single write
+	// larger than MaxBytes keeps only its last MaxBytes. Zero means the
+	// server's default of 1MiB.
+	MaxBytes uint64 `json:"max_bytes,omitempty"`
+
+	// KeepCapturedLogs keeps the captured output once the container is
+	// destroyed, for as long as its tombstone is kept. Otherwise it is
+	// removed along with the container.
+	KeepCapturedLogs bool `json:"keep_captured_logs,omitempty"`
+}
+
+// The streams of a process's captured output.
+const (
+	CapturedStdout = "stdout"
+	CapturedStderr = "stderr"
+)
+
 type TTYSpec struct {
 	WindowSize *WindowSize `json:"window_size,omitempty"`
 }
@@ -167,6 +254,9 @@ type Process interface {
 	ID() string
 	Wait() (int, error)
 	SetTTY(TTYSpec) error
+
+	// Signal sends a signal to the process. Signalling a process that has
+	// already exited does nothing.
 	Signal(Signal) error
 }
 
@@ -186,24 +276,91 @@ type StreamInSpec struct {
 	Path      string
 	User      string
 	TarStream io.Reader
+
+	// ExpectedBytes is an optional hint of the size of TarStream. When set, it
+	// is checked against the container's remaining disk quota before anything
+	// is streamed, and the stream is aborted with a StreamSizeMismatchError if
+	// it turns out to be larger, or fails at its end if smaller. Whether or not it is set, a stream that
+	// reaches the disk quota is aborted with a QuotaExceededError. Both are
+	// measured in bytes of TarStream rather than of the files it extracts to.
+	ExpectedBytes uint64
+
+	// Checksum is an optional hex-encoded SHA-256 digest of TarStream. When
+	// set, the stream is hashed as it is read, and the server checks the
+	// digest of the whole stream, including anything after the end of the
+	// archive, before the backend reads the end of the archive.
+	Checksum string
 }
 
 type StreamOutSpec struct {
 	Path string
 	User string
+
+	// ChangedSince, if set, limits the archive to files modified in or after
+	// its second, as archives only keep whole seconds. Directories are always
+	// included so that changed files keep their parents. Symlinks are judged by their own modification time, and hard
+	// links are only included along with their target. Backends may use it to
+	// skip unchanged files; the server filters the archive regardless.
+	ChangedSince time.Time
+
+	// Resumable has the server encode the archive canonically, so that
+	// streaming the same unchanged files again yields the same bytes. Each
+	// entry carries its numeric ownership, mode, whole-second modification
+	// time and extended attributes; access and change times and user and
+	// group names are dropped. Entries keep the order the backend walks the
+	// tree in, so the backend is passed Resumable to walk it depth first, with
+	// the entries of each directory sorted by name. A backend that walks it
+	// out of order fails the stream with an UnsupportedOperationError.
+	Resumable bool
+
+	// Offset resumes a Resumable stream from that many bytes into the
+	// archive. Generation must then be the hex-encoded SHA-256 digest of the
+	// bytes already received, and the stream fails with a
+	// StreamOutChangedError if the start of the archive no longer matches it.
+	// The server reads and hashes the skipped bytes before sending any.
+	Offset     int64
+	Generation string
 }
 
+// StateDestroying is the State of a container whose destroy has begun but
+// not finished.
+const StateDestroying = "destroying"
+
 // ContainerInfo holds information about a container.
+//
+// Like every slice and map on the wire, the list fields are omitted when
+// empty rather than sent as null.
 type ContainerInfo struct {
-	State         string        // Either "active" or "stopped".
-	Events        []string      // List of events that occurred for the container. It currently includes only "oom" (Out Of Memory) event if it occurred.
+	State         string        // Either "active" or "stopped", or StateDestroying.
+	Events        []string      `json:"Events,omitempty"` // List of events that occurred for the container. It currently includes only "oom" (Out Of Memory) event if it occurred.
 	HostIP        string        // The IP address of the gateway which controls the host side of the container's virtual ethernet pair.
 	ContainerIP   string        // The IP address of the container side of the container's virtual ethernet pair.
 	ExternalIP    string        //
 	ContainerPath string        // The path to the directory holding the container's files (both its control scripts and filesystem).
-	ProcessIDs    []string      // List of running processes.
-	Properties    Properties    // List of properties defined for the container.
-	MappedPorts   []PortMapping //
+	ProcessIDs    []string      `json:"ProcessIDs,omitempty"`  // List of running processes.
+	Properties    Properties    `json:"Properties,omitempty"`  // List of properties defined for the container.
+	MappedPorts   []PortMapping `json:"MappedPorts,omitempty"` //
+	BindMounts    []BindMount   `json:"BindMounts,omitempty"`  // The container's bind mounts, in the order they were applied.
+
+	IsolateIntraSubnet bool `json:"IsolateIntraSubnet,omitempty"` // Whether traffic from subnet peers is filtered; see ContainerSpec.IsolateIntraSubnet.
+	Privileged         bool `json:"Privileged,omitempty"`         // Whether the container was created privileged; see ContainerSpec.Privileged.
+	RecordingMetrics   bool `json:"RecordingMetrics,omitempty"`   // Whether the server is recording the container's metrics; see ContainerSpec.RecordMetrics.
+
+	Network string `json:"Network,omitempty"` // The address of the subnet the container was created on, where the server knows it; see ContainerSpec.Network and NetworkFrom.
+
+	Lock *ContainerLock `json:"Lock,omitempty"` // The maintenance lock held on the container, if any.
+
+	Warnings []string `json:"Warnings,omitempty"` // Warnings raised when creating the container; only set on the info returned with the created handle.
+}
+
+// ContainerLock is a maintenance lock on a container. Until it expires, the
+// container is only destroyed by force; everything else carries on as usual.
+// Holder names whoever took the lock, as they gave it; only they can renew or
+// release it, other than by force.
+type ContainerLock struct {
+	Holder    string    `json:"holder"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type ContainerInfoEntry struct {
@@ -218,6 +375,12 @@ type Metrics struct {
 	NetworkStat ContainerNetworkStat
 }
 
+// MetricsSample is a container's metrics as sampled at a point in time.
+type MetricsSample struct {
+	SampledAt time.Time `json:"sampled_at"`
+	Metrics   Metrics   `json:"metrics"`
+}
+
 type ContainerMetricsEntry struct {
 	Metrics Metrics
 	Err     *Error
@@ -268,6 +431,14 @@ type ContainerDiskStat struct {
 	TotalInodesUsed     uint64
 	ExclusiveBytesUsed  uint64
 	ExclusiveInodesUsed uint64
+
+	// Usage of each scratch space, which is not included in the totals above.
+	ScratchSpaces []ScratchSpaceStat `json:"ScratchSpaces,omitempty"`
+}
+
+type ScratchSpaceStat struct {
+	Path      string
+	BytesUsed uint64
 }
 
 type ContainerBandwidthStat struct {
@@ -5,14 +5,42 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 )
 
 type errType string
 
 const (
-	unrecoverableErrType      = "UnrecoverableError"
-	serviceUnavailableErrType = "ServiceUnavailableError"
-	containerNotFoundErrType  = "ContainerNotFoundError"
+	unrecoverableErrType         = "UnrecoverableError"
+	serviceUnavailableErrType    = "ServiceUnavailableError"
+	containerNotFoundErrType     = "ContainerNotFoundError"
+	quotaExceededErrType         = "QuotaExceededError"
+	checksumMismatchErrType      = "ChecksumMismatchError"
+	containerDestroyedErrType    = "ContainerDestroyedError"
+	subnetPoolExhaustedErrType   = "SubnetPoolExhaustedError"
+	uidPoolExhaustedErrType      = "UIDPoolExhaustedError"
+	portPoolExhaustedErrType     = "PortPoolExhaustedError"
+	processTimeoutErrType        = "ProcessTimeoutError"
+	unsupportedOperationErrType  = "UnsupportedOperationError"
+	streamLifetimeErrType        = "StreamLifetimeExceededError"
+	fileTooLargeErrType          = "FileTooLargeError"
+	fileNotFoundErrType          = "FileNotFoundError"
+	requestLimitErrType          = "RequestLimitExceededError"
+	processNotFoundErrType       = "ProcessNotFoundError"
+	idempotencyConflictErrType   = "IdempotencyConflictError"
+	handleStillDestroyingErrType = "HandleStillDestroyingError"
+	streamOutChangedErrType      = "StreamOutChangedError"
+	containerLockedErrType       = "ContainerLockedError"
+	invalidPropertyValueErrType  = "InvalidPropertyValueError"
+	invalidPathErrType           = "InvalidPathError"
+	invalidRequestErrType        = "InvalidRequestError"
+	streamSizeMismatchErrType    = "StreamSizeMismatchError"
+	invalidNetOutRuleErrType     = "InvalidNetOutRuleError"
+	privilegedDisabledErrType    = "PrivilegedContainersDisabledError"
+	invalidPropertyFilterErrType = "InvalidPropertyFilterError"
 )
 
 type Error struct {
@@ -27,6 +55,35 @@ type marshalledError struct {
 	Type    errType
 	Message string
 	Handle  string
+
+	PoolSize uint64 `json:",omitempty"`
+	InUse    uint64 `json:",omitempty"`
+
+	Path      string `json:",omitempty"`
+	ProcessID string `json:",omitempty"`
+	Key       string `json:",omitempty"`
+	Field     string `json:",omitempty"`
+
+	// Limit also carries a StreamSizeMismatchError's ExpectedBytes.
+	Limit uint64 `json:",omitempty"`
+
+	EstimatedCompletion *time.Time `json:",omitempty"`
+
+	Lock *ContainerLock `json:",omitempty"`
+
+	Property string `json:",omitempty"`
+
+	Match *PropertyMatch `json:",omitempty"`
+
+	// Reason also carries the Rule of an InvalidPathError or an
+	// InvalidNetOutRuleError.
+	Reason string `json:",omitempty"`
+}
+
+// ErrorWireType is the type an Error is encoded as in JSON, for deriving its
+// schema.
+func ErrorWireType() reflect.Type {
+	return reflect.TypeOf(marshalledError{})
 }
 
 func (m Error) Error() string {
@@ -37,6 +94,44 @@ func (m Error) StatusCode() int {
 	switch m.Err.(type) {
 	case ContainerNotFoundError:
 		return http.StatusNotFound
+	case ProcessNotFoundError:
+		return http.StatusNotFound
+	case FileNotFoundError:
+		return http.StatusNotFound
+	case QuotaExceededError:
+		return http.StatusRequestEntityTooLarge
+	case FileTooLargeError:
+		return http.StatusRequestEntityTooLarge
+	case RequestLimitExceededError:
+		return http.StatusRequestEntityTooLarge
+	case ChecksumMismatchError:
+		return http.StatusBadRequest
+	case InvalidPropertyValueError:
+		return http.StatusBadRequest
+	case InvalidPathError:
+		return http.StatusBadRequest
+	case InvalidPropertyFilterError:
+		return http.StatusBadRequest
+	case InvalidRequestError:
+		return http.StatusBadRequest
+	case StreamSizeMismatchError:
+		return http.StatusBadRequest
+	case InvalidNetOutRuleError:
+		return http.StatusBadRequest
+	case ContainerDestroyedError:
+		return http.StatusGone
+	case UnsupportedOperationError:
+		return http.StatusNotImplemented
+	case PrivilegedContainersDisabledError:
+		return http.StatusForbidden
+	case IdempotencyConflictError:
+		return http.StatusConflict
+	case HandleStillDestroyingError:
+		return http.StatusConflict
+	case StreamOutChangedError:
+		return http.StatusConflict
+	case ContainerLockedError:
+		return http.StatusLocked
 	}
 
 	return http.StatusInternalServerError
@@ -45,17 +140,124 @@ func (m Error) StatusCode() int {
 func (m Error) MarshalJSON() ([]byte, error) {
 	var errorType errType
 	handle := ""
+	path := ""
+	processID := ""
+	key := ""
+	field := ""
+	var limit uint64
+	var pool PoolUsage
+	var estimatedCompletion *time.Time
+	var lock *ContainerLock
+	var match *PropertyMatch
+	var property, reason string
 	switch err := m.Err.(type) {
 	case ContainerNotFoundError:
 		errorType = containerNotFoundErrType
 		handle = err.Handle
+	case ProcessNotFoundError:
+		errorType = processNotFoundErrType
+		handle = err.Handle
+		processID = err.ProcessID
 	case ServiceUnavailableError:
 		errorType = serviceUnavailableErrType
 	case UnrecoverableError:
 		errorType = unrecoverableErrType
+	case QuotaExceededError:
+		errorType = quotaExceededErrType
+		handle = err.Handle
+	case ChecksumMismatchError:
+		errorType = checksumMismatchErrType
+		handle = err.Handle
+	case ContainerDestroyedError:
+		errorType = containerDestroyedErrType
+		handle = err.Handle
+	case SubnetPoolExhaustedError:
+		errorType = subnetPoolExhaustedErrType
+		pool = err.Pool
+	case UIDPoolExhaustedError:
+		errorType = uidPoolExhaustedErrType
+		pool = err.Pool
+	case PortPoolExhaustedError:
+		errorType = portPoolExhaustedErrType
+		pool = err.Pool
+	case ProcessTimeoutError:
+		errorType = processTimeoutErrType
+	case UnsupportedOperationError:
+		errorType = unsupportedOperationErrType
+	case InvalidRequestError:
+		errorType = invalidRequestErrType
+	case StreamSizeMismatchError:
+		errorType = streamSizeMismatchErrType
+		handle = err.Handle
+		limit = err.ExpectedBytes
+	case InvalidNetOutRuleError:
+		errorType = invalidNetOutRuleErrType
+		reason = err.Rule
+	case StreamLifetimeExceededError:
+		errorType = streamLifetimeErrType
+	case FileTooLargeError:
+		errorType = fileTooLargeErrType
+		handle = err.Handle
+		path = err.Path
+		limit = err.Limit
+	case FileNotFoundError:
+		errorType = fileNotFoundErrType
+		handle = err.Handle
+		path = err.Path
+	case RequestLimitExceededError:
+		errorType = requestLimitErrType
+		field = err.Field
+		limit = err.Limit
+	case IdempotencyConflictError:
+		errorType = idempotencyConflictErrType
+		key = err.Key
+	case HandleStillDestroyingError:
+		errorType = handleStillDestroyingErrType
+		handle = err.Handle
+		estimatedCompletion = &err.EstimatedCompletion
+	case StreamOutChangedError:
+		errorType = streamOutChangedErrType
+		handle = err.Handle
+		path = err.Path
+	case ContainerLockedError:
+		errorType = containerLockedErrType
+		handle = err.Handle
+		lock = &err.Lock
+	case InvalidPropertyValueError:
+		errorType = invalidPropertyValueErrType
+		property = err.Property
+		reason = err.Reason
+	case InvalidPathError:
+		errorType = invalidPathErrType
+		path = err.Path
+		reason = err.Rule
+	case PrivilegedContainersDisabledError:
+		errorType = privilegedDisabledErrType
+	case InvalidPropertyFilterError:
+		errorType = invalidPropertyFilterErrType
+		match = &err.Match
+		reason = err.Reason
 	}
 
-	return json.Marshal(marshalledError{errorType, m.Err.Error(), handle})
+	return json.Marshal(marshalledError{
+		Type:      errorType,
+		Message:   m.Err.Error(),
+		Handle:    handle,
+		PoolSize:  pool.Size,
+		InUse:     pool.InUse,
+		Path:      path,
+		ProcessID: processID,
+		Key:       key,
+		Field:     field,
+		Limit:     limit,
+
+		EstimatedCompletion: estimatedCompletion,
+		Lock:                lock,
+
+		Property: property,
+		Match:    match,
+		Reason:   reason,
+	})
 }
 
 func (m *Error) UnmarshalJSON(data []byte) error {
@@ -72,6 +274,66 @@ func (m *Error) UnmarshalJSON(data []byte) error {
 		m.Err = ServiceUnavailableError{result.Message}
 	case containerNotFoundErrType:
 		m.Err = ContainerNotFoundError{result.Handle}
+	case processNotFoundErrType:
+		m.Err = ProcessNotFoundError{Handle: result.Handle, ProcessID: result.ProcessID}
+	case quotaExceededErrType:
+		m.Err = QuotaExceededError{result.Handle}
+	case checksumMismatchErrType:
+		m.Err = ChecksumMismatchError{result.Handle}
+	case containerDestroyedErrType:
+		m.Err = ContainerDestroyedError{result.Handle}
+	case subnetPoolExhaustedErrType:
+		m.Err = SubnetPoolExhaustedError{PoolUsage{Size: result.PoolSize, InUse: result.InUse}}
+	case uidPoolExhaustedErrType:
+		m.Err = UIDPoolExhaustedError{PoolUsage{Size: result.PoolSize, InUse: result.InUse}}
+	case portPoolExhaustedErrType:
+		m.Err = PortPoolExhaustedError{PoolUsage{Size: result.PoolSize, InUse: result.InUse}}
+	case processTimeoutErrType:
+		m.Err = ProcessTimeoutError{}
+	case unsupportedOperationErrType:
+		m.Err = UnsupportedOperationError{result.Message}
+	case invalidRequestErrType:
+		m.Err = InvalidRequestError{result.Message}
+	case streamSizeMismatchErrType:
+		m.Err = StreamSizeMismatchError{Handle: result.Handle, ExpectedBytes: result.Limit}
+	case invalidNetOutRuleErrType:
+		m.Err = InvalidNetOutRuleError{Rule: result.Reason}
+	case streamLifetimeErrType:
+		m.Err = StreamLifetimeExceededError{}
+	case fileTooLargeErrType:
+		m.Err = FileTooLargeError{Handle: result.Handle, Path: result.Path, Limit: result.Limit}
+	case fileNotFoundErrType:
+		m.Err = FileNotFoundError{Handle: result.Handle, Path: result.Path}
+	case requestLimitErrType:
+		m.Err = RequestLimitExceededError{Field: result.Field, Limit: result.Limit}
+	case idempotencyConflictErrType:
+		m.Err = IdempotencyConflictError{Key: result.Key}
+	case handleStillDestroyingErrType:
+		err := HandleStillDestroyingError{Handle: result.Handle}
+		if result.EstimatedCompletion != nil {
+			err.EstimatedCompletion = *result.EstimatedCompletion
+		}
+		m.Err = err
+	case streamOutChangedErrType:
+		m.Err = StreamOutChangedError{Handle: result.Handle, Path: result.Path}
+	case containerLockedErrType:
+		err := ContainerLockedError{Handle: result.Handle}
+		if result.Lock != nil {
+			err.Lock = *result.Lock
+		}
+		m.Err = err
+	case invalidPropertyValueErrType:
+		m.Err = InvalidPropertyValueError{Property: result.Property, Reason: result.Reason}
+	case invalidPathErrType:
+		m.Err = InvalidPathError{Path: result.Path, Rule: result.Reason}
+	case privilegedDisabledErrType:
+		m.Err = PrivilegedContainersDisabledError{}
+	case invalidPropertyFilterErrType:
+		err := InvalidPropertyFilterError{Reason: result.Reason}
+		if result.Match != nil {
+			err.Match = *result.Match
+		}
+		m.Err = err
 	default:
 		m.Err = errors.New(result.Message)
 	}
@@ -101,6 +363,241 @@ func (err ContainerNotFoundError) Error() string {
 	return fmt.Sprintf("unknown handle: %s", err.Handle)
 }
 
+// ProcessNotFoundError is returned by Attach when the container has no
+// process with the given ID, or it has already exited and been forgotten.
+type ProcessNotFoundError struct {
+	Handle    string
+	ProcessID string
+}
+
+func (err ProcessNotFoundError) Error() string {
+	return fmt.Sprintf("unknown process %s in container %s", err.ProcessID, err.Handle)
+}
+
+type QuotaExceededError struct {
+	Handle string
+}
+
+func (err QuotaExceededError) Error() string {
+	return fmt.Sprintf("disk quota exceeded: %s", err.Handle)
+}
+
+// StreamSizeMismatchError is returned when a stream turns out to be larger
+// or smaller than the ExpectedBytes it was streamed in with.
+type StreamSizeMismatchError struct {
+	Handle        string
+	ExpectedBytes uint64
+}
+
+func (err StreamSizeMismatchError) Error() string {
+	return fmt.Sprintf("stream into %s does not match its expected %d bytes", err.Handle, err.ExpectedBytes)
+}
+
+type ChecksumMismatchError struct {
+	Handle string
+}
+
+func (err ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch: %s", err.Handle)
+}
+
+// ContainerDestroyedError is returned by Process.Wait when the container was
+// destroyed while the process was being waited on.
+type ContainerDestroyedError struct {
+	Handle string
+}
+
+func (err ContainerDestroyedError) Error() string {
+	return fmt.Sprintf("container destroyed: %s", err.Handle)
+}
+
+// SubnetPoolExhaustedError is returned by Create when no subnet could be
+// allocated for the container.
+type SubnetPoolExhaustedError struct {
+	Pool PoolUsage
+}
+
+func (err SubnetPoolExhaustedError) Error() string {
+	return fmt.Sprintf("subnet pool exhausted: %d of %d in use", err.Pool.InUse, err.Pool.Size)
+}
+
+// UIDPoolExhaustedError is returned by Create when no user ID range could be
+// allocated for the container.
+type UIDPoolExhaustedError struct {
+	Pool PoolUsage
+}
+
+func (err UIDPoolExhaustedError) Error() string {
+	return fmt.Sprintf("uid pool exhausted: %d of %d in use", err.Pool.InUse, err.Pool.Size)
+}
+
+// PortPoolExhaustedError is returned by Create and NetIn when no host port
+// could be allocated.
+type PortPoolExhaustedError struct {
+	Pool PoolUsage
+}
+
+func (err PortPoolExhaustedError) Error() string {
+	return fmt.Sprintf("port pool exhausted: %d of %d in use", err.Pool.InUse, err.Pool.Size)
+}
+
+// ProcessTimeoutError is returned by Process.Wait, along with the exit status,
+// when the process was stopped for exceeding ProcessSpec.MaxDuration.
+type ProcessTimeoutError struct{}
+
+func (err ProcessTimeoutError) Error() string {
+	return "process exceeded its maximum duration"
+}
+
+// StreamLifetimeExceededError is returned by Process.Wait when the server
+// closed the process's stream for being open longer than it allows. The
+// process keeps running and can be attached to again.
+type StreamLifetimeExceededError struct{}
+
+func (err StreamLifetimeExceededError) Error() string {
+	return "stream closed: max-lifetime-exceeded"
+}
+
+// FileTooLargeError is returned by ReadFile and WriteFile for a file larger
+// than the server allows them to transfer. StreamOut and StreamIn have no such
+// limit.
+type FileTooLargeError struct {
+	Handle string
+	Path   string
+	Limit  uint64
+}
+
+func (err FileTooLargeError) Error() string {
+	return fmt.Sprintf("file %s in container %s is larger than %d bytes; use StreamOut or StreamIn instead", err.Path, err.Handle, err.Limit)
+}
+
+// FileNotFoundError is returned by ReadFile for a path that names nothing in
+// the container.
+type FileNotFoundError struct {
+	Handle string
+	Path   string
+}
+
+func (err FileNotFoundError) Error() string {
+	return fmt.Sprintf("file %s not found in container %s", err.Path, err.Handle)
+}
+
+// RequestLimitExceededError is returned when a request holds more entries in
+// one of its lists or maps than the server accepts, or, with the Field
+// RequestBodyField, when its body holds more bytes than the server accepts.
+type RequestLimitExceededError struct {
+	Field string
+	Limit uint64
+}
+
+// RequestBodyField is the Field of a RequestLimitExceededError for a request
+// body that is too large.
+const RequestBodyField = "body"
+
+func (err RequestLimitExceededError) Error() string {
+	if err.Field == RequestBodyField {
+		return fmt.Sprintf("request body is larger than %d bytes", err.Limit)
+	}
+
+	return fmt.Sprintf("request has more than %d entries in %s", err.Limit, err.Field)
+}
+
+// IdempotencyConflictError is returned when an idempotency key is reused for a
+// request other than the one it was first used for.
+type IdempotencyConflictError struct {
+	Key string
+}
+
+func (err IdempotencyConflictError) Error() string {
+	return fmt.Sprintf("idempotency key %s was used for a different request", err.Key)
+}
+
+// HandleStillDestroyingError is returned when creating a container with the
+// handle of one whose destruction has not finished yet. EstimatedCompletion
+// is when the server expects it to, judging by how long destroys have taken.
+type HandleStillDestroyingError struct {
+	Handle              string
+	EstimatedCompletion time.Time
+}
+
+func (err HandleStillDestroyingError) Error() string {
+	return fmt.Sprintf("container %s is still being destroyed", err.Handle)
+}
+
+// StreamOutChangedError is returned when resuming a StreamOut whose files
+// changed since it began, so that the bytes already received no longer match
+// the start of the archive.
+type StreamOutChangedError struct {
+	Handle string
+	Path   string
+}
+
+func (err StreamOutChangedError) Error() string {
+	return fmt.Sprintf("files at %s in container %s changed since the stream being resumed began", err.Path, err.Handle)
+}
+
+// ContainerLockedError is returned when destroying a container that holds a
+// maintenance lock, other than by force, and when locking or unlocking it
+// under another holder's name.
+type ContainerLockedError struct {
+	Handle string
+	Lock   ContainerLock
+}
+
+func (err ContainerLockedError) Error() string {
+	return fmt.Sprintf("container %s is locked by %s until %s: %s", err.Handle, err.Lock.Holder, err.Lock.ExpiresAt.Format(time.RFC3339), err.Lock.Reason)
+}
+
+// UnsupportedOperationError is returned when a request asks for something the
+// backend cannot do, rather than the backend silently ignoring it.
+type UnsupportedOperationError struct {
+	Message string
+}
+
+func (err UnsupportedOperationError) Error() string {
+	return err.Message
+}
+
+// PrivilegedContainersDisabledError is returned when a privileged container is
+// asked for of a server that does not allow them. The server could create it,
+// but will not.
+type PrivilegedContainersDisabledError struct{}
+
+func (err PrivilegedContainersDisabledError) Error() string {
+	return "privileged containers are disabled on this server"
+}
+
+// InvalidRequestError is returned when a request is malformed or asks for
+// something that makes no sense, so that retrying it unchanged cannot succeed.
+type InvalidRequestError struct {
+	Message string
+}
+
+func (err InvalidRequestError) Error() string {
+	return err.Message
+}
+
+// MultiError holds the per-handle failures of an operation applied to several
+// containers.
+type MultiError struct {
+	Errors map[string]*Error `json:"errors,omitempty"`
+}
+
+func (err *MultiError) Error() string {
+	handles := make([]string, 0, len(err.Errors))
+	for handle := range err.Errors {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+
+	messages := make([]string, 0, len(handles))
+	for _, handle := range handles {
+		messages = append(messages, fmt.Sprintf("%s: %s", handle, err.Errors[handle]))
+	}
+
+	return strings.Join(messages, "; ")
+}
+
 func NewServiceUnavailableError(cause string) error {
 	return ServiceUnavailableError{
 		Cause: cause,
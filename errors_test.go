@@ -0,0 +1,168 @@
+package garden_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Error", func() {
+	roundTrip := func(err error) error {
+		encoded, marshalErr := json.Marshal(garden.Error{Err: err})
+		Ω(marshalErr).ShouldNot(HaveOccurred())
+
+		var decoded garden.Error
+		Ω(json.Unmarshal(encoded, &decoded)).Should(Succeed())
+
+		return decoded.Err
+	}
+
+	It("reconstructs pool exhaustion errors with their pool usage", func() {
+		pool := garden.PoolUsage{Size: 256, InUse: 256}
+
+		Ω(roundTrip(garden.SubnetPoolExhaustedError{Pool: pool})).Should(Equal(garden.SubnetPoolExhaustedError{Pool: pool}))
+		Ω(roundTrip(garden.UIDPoolExhaustedError{Pool: pool})).Should(Equal(garden.UIDPoolExhaustedError{Pool: pool}))
+		Ω(roundTrip(garden.PortPoolExhaustedError{Pool: pool})).Should(Equal(garden.PortPoolExhaustedError{Pool: pool}))
+	})
+
+	It("reconstructs errors carrying a handle", func() {
+		Ω(roundTrip(garden.ContainerNotFoundError{Handle: "some-handle"})).Should(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+		Ω(roundTrip(garden.QuotaExceededError{Handle: "some-handle"})).Should(Equal(garden.QuotaExceededError{Handle: "some-handle"}))
+		Ω(roundTrip(garden.ChecksumMismatchError{Handle: "some-handle"})).Should(Equal(garden.ChecksumMismatchError{Handle: "some-handle"}))
+		Ω(roundTrip(garden.ContainerDestroyedError{Handle: "some-handle"})).Should(Equal(garden.ContainerDestroyedError{Handle: "some-handle"}))
+	})
+
+	It("reconstructs process not found errors with their handle and process ID", func() {
+		err := garden.ProcessNotFoundError{Handle: "some-handle", ProcessID: "some-pid"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusNotFound))
+	})
+
+	It("reconstructs process timeout errors", func() {
+		Ω(roundTrip(garden.ProcessTimeoutError{})).Should(Equal(garden.ProcessTimeoutError{}))
+	})
+
+	It("reconstructs stream lifetime errors", func() {
+		Ω(roundTrip(garden.StreamLifetimeExceededError{})).Should(Equal(garden.StreamLifetimeExceededError{}))
+	})
+
+	It("reconstructs file too large errors with their path and limit", func() {
+		err := garden.FileTooLargeError{Handle: "some-handle", Path: "/some/file", Limit: 1024}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusRequestEntityTooLarge))
+	})
+
+	It("reconstructs file not found errors with their path", func() {
+		err := garden.FileNotFoundError{Handle: "some-handle", Path: "/some/file"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusNotFound))
+	})
+
+	It("reconstructs request limit errors with their field and limit", func() {
+		err := garden.RequestLimitExceededError{Field: "properties", Limit: 10}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusRequestEntityTooLarge))
+	})
+
+	It("describes a request body that is too large", func() {
+		err := garden.RequestLimitExceededError{Field: garden.RequestBodyField, Limit: 1024}
+		Ω(err).Should(MatchError("request body is larger than 1024 bytes"))
+		Ω(roundTrip(err)).Should(Equal(err))
+	})
+
+	It("reconstructs idempotency conflicts with their key", func() {
+		err := garden.IdempotencyConflictError{Key: "some-key"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusConflict))
+	})
+
+	It("reconstructs handle still destroying errors with their estimated completion", func() {
+		err := garden.HandleStillDestroyingError{
+			Handle:              "some-handle",
+			EstimatedCompletion: time.Date(2016, 1, 2, 3, 4, 5, 6, time.UTC),
+		}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusConflict))
+	})
+
+	It("reconstructs stream out changed errors with their path", func() {
+		err := garden.StreamOutChangedError{Handle: "some-handle", Path: "/some/dir"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusConflict))
+	})
+
+	It("reconstructs container locked errors with their lock", func() {
+		err := garden.ContainerLockedError{
+			Handle: "some-handle",
+			Lock: garden.ContainerLock{
+				Holder:    "10.0.0.1:1234",
+				Reason:    "debugging",
+				ExpiresAt: time.Date(2016, 1, 2, 3, 4, 5, 6, time.UTC),
+			},
+		}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusLocked))
+	})
+
+	It("reconstructs invalid property value errors with their property and reason", func() {
+		err := garden.InvalidPropertyValueError{Property: "deploy", Reason: "not valid JSON"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("reconstructs invalid path errors with their path and rule", func() {
+		err := garden.InvalidPathError{Path: "/proc/1", Rule: "under denied prefix /proc"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("reconstructs stream size mismatches with their handle and expected bytes", func() {
+		err := garden.StreamSizeMismatchError{Handle: "some-handle", ExpectedBytes: 5}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("reconstructs invalid net out rule errors with the rule they break", func() {
+		err := garden.InvalidNetOutRuleError{Rule: "port range 81-80 ends before it starts"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("reconstructs invalid request errors with their message", func() {
+		err := garden.InvalidRequestError{Message: "an empty filter matches every container"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("reconstructs unsupported operation errors with their message", func() {
+		err := garden.UnsupportedOperationError{Message: "per-process limits are not supported"}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusNotImplemented))
+	})
+
+	It("reconstructs invalid property filter errors with the match and reason", func() {
+		err := garden.InvalidPropertyFilterError{
+			Match:  garden.PropertyMatch{Name: "owner", Op: "sounds_like", Value: "bob"},
+			Reason: `unknown op "sounds_like"`,
+		}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("reconstructs privileged containers disabled errors", func() {
+		err := garden.PrivilegedContainersDisabledError{}
+		Ω(roundTrip(err)).Should(Equal(err))
+		Ω(garden.Error{Err: err}.StatusCode()).Should(Equal(http.StatusForbidden))
+	})
+
+	It("does not add pool fields to other errors", func() {
+		encoded, err := json.Marshal(garden.Error{Err: garden.ContainerNotFoundError{Handle: "some-handle"}})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(encoded).Should(MatchJSON(`{"Type":"ContainerNotFoundError","Message":"unknown handle: some-handle","Handle":"some-handle"}`))
+	})
+})
@@ -163,6 +163,14 @@ type FakeContainer struct {
 	removePropertyReturns struct {
 		result1 error
 	}
+	BulkNetOutStub        func(netOutRules []garden.NetOutRule) error
+	bulkNetOutMutex       sync.RWMutex
+	bulkNetOutArgsForCall []struct {
+		netOutRules []garden.NetOutRule
+	}
+	bulkNetOutReturns struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -747,6 +755,44 @@ func (fake *FakeContainer) RemovePropertyReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainer) BulkNetOut(netOutRules []garden.NetOutRule) error {
+	var netOutRulesCopy []garden.NetOutRule
+	if netOutRules != nil {
+		netOutRulesCopy = make([]garden.NetOutRule, len(netOutRules))
+		copy(netOutRulesCopy, netOutRules)
+	}
+	fake.bulkNetOutMutex.Lock()
+	fake.bulkNetOutArgsForCall = append(fake.bulkNetOutArgsForCall, struct {
+		netOutRules []garden.NetOutRule
+	}{netOutRulesCopy})
+	fake.recordInvocation("BulkNetOut", []interface{}{netOutRulesCopy})
+	fake.bulkNetOutMutex.Unlock()
+	if fake.BulkNetOutStub != nil {
+		return fake.BulkNetOutStub(netOutRules)
+	} else {
+		return fake.bulkNetOutReturns.result1
+	}
+}
+
+func (fake *FakeContainer) BulkNetOutCallCount() int {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return len(fake.bulkNetOutArgsForCall)
+}
+
+func (fake *FakeContainer) BulkNetOutArgsForCall(i int) []garden.NetOutRule {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return fake.bulkNetOutArgsForCall[i].netOutRules
+}
+
+func (fake *FakeContainer) BulkNetOutReturns(result1 error) {
+	fake.BulkNetOutStub = nil
+	fake.bulkNetOutReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeContainer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -788,6 +834,8 @@ func (fake *FakeContainer) Invocations() map[string][][]interface{} {
 	defer fake.setPropertyMutex.RUnlock()
 	fake.removePropertyMutex.RLock()
 	defer fake.removePropertyMutex.RUnlock()
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
 	return fake.invocations
 }
 
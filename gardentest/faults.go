@@ -0,0 +1,145 @@
+package gardentest
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden/client/connection"
+)
+
+// ErrConnectionRefused is returned when dialing while connections are refused.
+var ErrConnectionRefused = errors.New("gardentest: connection refused")
+
+// Faults disturb the connections between a client and the server. The zero
+// state disturbs nothing; faults can be changed at any time.
+type Faults struct {
+	mu          sync.Mutex
+	delay       time.Duration
+	refuse      bool
+	corruptNext bool
+	conns       map[*faultyConn]struct{}
+}
+
+func NewFaults() *Faults {
+	return &Faults{conns: map[*faultyConn]struct{}{}}
+}
+
+// DelayResponses holds up everything read from the server by d, until set
+// back to zero.
+func (f *Faults) DelayResponses(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.delay = d
+}
+
+// RefuseConnections makes new connections fail with ErrConnectionRefused
+// while refuse is true.
+func (f *Faults) RefuseConnections(refuse bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refuse = refuse
+}
+
+// DropConnections closes every open connection, including hijacked process
+// streams, as if the network had failed.
+func (f *Faults) DropConnections() {
+	f.mu.Lock()
+	conns := f.conns
+	f.conns = map[*faultyConn]struct{}{}
+	f.mu.Unlock()
+
+	for conn := range conns {
+		conn.Conn.Close()
+	}
+}
+
+// CorruptNextRead inverts the first byte of the next read from the server on
+// any connection.
+func (f *Faults) CorruptNextRead() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.corruptNext = true
+}
+
+// OpenConnections is the number of connections not yet closed.
+func (f *Faults) OpenConnections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.conns)
+}
+
+// Dialer wraps dial so that its connections are subject to the faults.
+func (f *Faults) Dialer(dial connection.DialerFunc) connection.DialerFunc {
+	return func(network, address string) (net.Conn, error) {
+		f.mu.Lock()
+		refuse := f.refuse
+		f.mu.Unlock()
+
+		if refuse {
+			return nil, ErrConnectionRefused
+		}
+
+		conn, err := dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		faulty := &faultyConn{Conn: conn, faults: f}
+
+		f.mu.Lock()
+		f.conns[faulty] = struct{}{}
+		f.mu.Unlock()
+
+		return faulty, nil
+	}
+}
+
+func (f *Faults) readDelay() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delay
+}
+
+// claimCorruption reports whether a read is to be corrupted, at most once for
+// each call to CorruptNextRead.
+func (f *Faults) claimCorruption() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	corrupt := f.corruptNext
+	f.corruptNext = false
+
+	return corrupt
+}
+
+type faultyConn struct {
+	net.Conn
+	faults *Faults
+}
+
+func (c *faultyConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+
+	time.Sleep(c.faults.readDelay())
+
+	if n > 0 && c.faults.claimCorruption() {
+		p[0] = ^p[0]
+	}
+
+	return n, err
+}
+
+func (c *faultyConn) Close() error {
+	c.faults.mu.Lock()
+	delete(c.faults.conns, c)
+	c.faults.mu.Unlock()
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,83 @@
+// Package gardentest runs a real garden server in-process, so that client code
+// can be tested over real HTTP, hijacked streams included, without root or a
+// container backend.
+package gardentest
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/client"
+	"code.cloudfoundry.org/garden/client/connection"
+	"code.cloudfoundry.org/garden/server"
+	"code.cloudfoundry.org/lager"
+)
+
+// Harness is a garden server listening on a private unix socket, with a
+// client connected to it through Faults.
+type Harness struct {
+	// Backend serves the server's requests, typically a
+	// gardenfakes.FakeBackend.
+	Backend garden.Backend
+
+	// Server may be configured further once started.
+	Server *server.GardenServer
+
+	// Client is connected to Server over HTTP, through Faults.
+	Client client.Client
+
+	// Faults are injected into every connection of the harness's clients.
+	Faults *Faults
+
+	dir        string
+	socketPath string
+	logger     lager.Logger
+}
+
+// Start starts a server backed by backend. Containers are never reaped for
+// their grace time unless the backend gives them one. Stop must be called to
+// remove the socket.
+func Start(backend garden.Backend, logger lager.Logger) (*Harness, error) {
+	dir, err := ioutil.TempDir("", "gardentest")
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Harness{
+		Backend:    backend,
+		Faults:     NewFaults(),
+		dir:        dir,
+		socketPath: filepath.Join(dir, "garden.sock"),
+		logger:     logger,
+	}
+
+	h.Server = server.New("unix", h.socketPath, 0, backend, logger)
+
+	if err := h.Server.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	h.Client = h.NewClient()
+
+	return h, nil
+}
+
+// NewClient connects another client to the server, through the same Faults.
+func (h *Harness) NewClient() client.Client {
+	dial := h.Faults.Dialer(func(string, string) (net.Conn, error) {
+		return net.Dial("unix", h.socketPath)
+	})
+
+	return client.New(connection.NewWithDialerAndLogger(dial, h.logger.Session("client")))
+}
+
+// Stop stops the server and removes its socket.
+func (h *Harness) Stop() {
+	h.Faults.DropConnections()
+	h.Server.Stop()
+	os.RemoveAll(h.dir)
+}
@@ -0,0 +1,197 @@
+package gardentest_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/garden/gardentest"
+)
+
+var _ = Describe("Harness", func() {
+	var (
+		backend       *gardenfakes.FakeBackend
+		fakeContainer *gardenfakes.FakeContainer
+		harness       *gardentest.Harness
+	)
+
+	BeforeEach(func() {
+		backend = new(gardenfakes.FakeBackend)
+
+		fakeContainer = new(gardenfakes.FakeContainer)
+		fakeContainer.HandleReturns("some-handle")
+
+		backend.CreateReturns(fakeContainer, nil)
+		backend.LookupReturns(fakeContainer, nil)
+		backend.ContainersReturns([]garden.Container{fakeContainer}, nil)
+
+		var err error
+		harness, err = gardentest.Start(backend, lagertest.NewTestLogger("harness"))
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		harness.Stop()
+	})
+
+	Describe("core operations", func() {
+		It("pings the backend", func() {
+			Ω(harness.Client.Ping()).Should(Succeed())
+
+			backend.PingReturns(garden.UnrecoverableError{Symptom: "broken"})
+			Ω(harness.Client.Ping()).Should(Equal(garden.UnrecoverableError{Symptom: "broken"}))
+		})
+
+		It("reports the backend's capacity", func() {
+			capacity := garden.Capacity{
+				MemoryInBytes: 1024,
+				MaxContainers: 10,
+				SubnetPool:    &garden.PoolUsage{Size: 4, InUse: 1},
+			}
+			backend.CapacityReturns(capacity, nil)
+
+			Ω(harness.Client.Capacity()).Should(Equal(capacity))
+		})
+
+		It("creates containers with the spec given", func() {
+			container, err := harness.Client.Create(garden.ContainerSpec{
+				Handle:     "some-handle",
+				Properties: garden.Properties{"a": "b"},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Handle()).Should(Equal("some-handle"))
+
+			Ω(backend.CreateArgsForCall(0).Properties).Should(Equal(garden.Properties{"a": "b"}))
+		})
+
+		It("lists and looks up the backend's containers", func() {
+			containers, err := harness.Client.Containers(nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(containers).Should(HaveLen(1))
+			Ω(containers[0].Handle()).Should(Equal("some-handle"))
+
+			container, err := harness.Client.Lookup("some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Handle()).Should(Equal("some-handle"))
+
+			_, err = harness.Client.Lookup("missing")
+			Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "missing"}))
+		})
+
+		It("carries the backend's typed errors", func() {
+			backend.DestroyReturns(garden.ContainerNotFoundError{Handle: "missing"})
+
+			Ω(harness.Client.Destroy("missing")).Should(Equal(garden.ContainerNotFoundError{Handle: "missing"}))
+		})
+
+		It("reports container info, singly and in bulk", func() {
+			info := garden.ContainerInfo{State: "active", ContainerIP: "10.0.0.2"}
+			fakeContainer.InfoReturns(info, nil)
+
+			container, err := harness.Client.Lookup("some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(container.Info()).Should(Equal(info))
+
+			backend.BulkInfoReturns(map[string]garden.ContainerInfoEntry{"some-handle": {Info: info}}, nil)
+			Ω(harness.Client.BulkInfo([]string{"some-handle"})).Should(Equal(map[string]garden.ContainerInfoEntry{
+				"some-handle": {Info: info},
+			}))
+		})
+
+		It("streams process output over hijacked connections", func() {
+			fakeContainer.RunStub = func(spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error) {
+				fmt.Fprintf(io.Stdout, "hello\n")
+
+				process := new(gardenfakes.FakeProcess)
+				process.IDReturns("process-id")
+				return process, nil
+			}
+
+			container, err := harness.Client.Lookup("some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			stdout := gbytes.NewBuffer()
+			process, err := container.Run(garden.ProcessSpec{Path: "/bin/echo", Args: []string{"hello"}}, garden.ProcessIO{
+				Stdout: stdout,
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(process.ID()).Should(Equal("process-id"))
+			Ω(process.Wait()).Should(Equal(0))
+			Eventually(stdout).Should(gbytes.Say("hello"))
+		})
+
+		It("connects further clients to the same server", func() {
+			Ω(harness.NewClient().Ping()).Should(Succeed())
+			Ω(backend.PingCallCount()).Should(Equal(1))
+		})
+	})
+
+	Describe("fault injection", func() {
+		It("delays responses", func() {
+			harness.Faults.DelayResponses(50 * time.Millisecond)
+
+			started := time.Now()
+			Ω(harness.Client.Ping()).Should(Succeed())
+			Ω(time.Since(started)).Should(BeNumerically(">=", 50*time.Millisecond))
+
+			harness.Faults.DelayResponses(0)
+		})
+
+		It("refuses connections until told otherwise", func() {
+			harness.Faults.RefuseConnections(true)
+			Ω(harness.Client.Ping()).Should(MatchError(ContainSubstring(gardentest.ErrConnectionRefused.Error())))
+
+			harness.Faults.RefuseConnections(false)
+			Ω(harness.Client.Ping()).Should(Succeed())
+		})
+
+		It("corrupts the next read from the server", func() {
+			harness.Faults.CorruptNextRead()
+			Ω(harness.Client.Ping()).ShouldNot(Succeed())
+
+			Ω(harness.Client.Ping()).Should(Succeed())
+		})
+
+		It("drops open connections, including process streams", func() {
+			running := make(chan struct{})
+			exit := make(chan struct{})
+			defer close(exit)
+
+			fakeContainer.RunStub = func(spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error) {
+				close(running)
+
+				process := new(gardenfakes.FakeProcess)
+				process.IDReturns("process-id")
+				process.WaitStub = func() (int, error) {
+					<-exit
+					return 0, errors.New("exited")
+				}
+				return process, nil
+			}
+
+			container, err := harness.Client.Lookup("some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			process, err := container.Run(garden.ProcessSpec{}, garden.ProcessIO{Stdout: ioutil.Discard})
+			Ω(err).ShouldNot(HaveOccurred())
+			Eventually(running).Should(BeClosed())
+
+			Ω(harness.Faults.OpenConnections()).Should(BeNumerically(">", 0))
+			harness.Faults.DropConnections()
+
+			_, err = process.Wait()
+			Ω(err).Should(HaveOccurred())
+
+			Ω(harness.Client.Ping()).Should(Succeed())
+		})
+	})
+})
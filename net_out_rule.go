@@ -1,6 +1,10 @@
 package garden
 
-import "net"
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
 
 type NetOutRule struct {
 	// the protocol to be whitelisted
@@ -46,6 +50,47 @@ type ICMPControl struct {
 	Code *ICMPCode `json:"code,omitempty"`
 }
 
+// InvalidNetOutRuleError is returned by ValidateNetOutRule, naming the rule
+// it breaks.
+type InvalidNetOutRuleError struct {
+	Rule string
+}
+
+func (err InvalidNetOutRuleError) Error() string {
+	return "invalid net out rule: " + err.Rule
+}
+
+// ValidateNetOutRule checks that a rule names a known protocol and that its
+// ranges are well formed, running from Start to End within one IP family.
+func ValidateNetOutRule(rule NetOutRule) error {
+	if rule.Protocol > ProtocolICMP {
+		return InvalidNetOutRuleError{Rule: fmt.Sprintf("unknown protocol %d", rule.Protocol)}
+	}
+
+	for _, network := range rule.Networks {
+		start, end := network.Start.To16(), network.End.To16()
+		if start == nil || end == nil {
+			return InvalidNetOutRuleError{Rule: "network range missing start or end"}
+		}
+
+		if (network.Start.To4() == nil) != (network.End.To4() == nil) {
+			return InvalidNetOutRuleError{Rule: fmt.Sprintf("network range %s-%s mixes IP families", network.Start, network.End)}
+		}
+
+		if bytes.Compare(start, end) > 0 {
+			return InvalidNetOutRuleError{Rule: fmt.Sprintf("network range %s-%s ends before it starts", network.Start, network.End)}
+		}
+	}
+
+	for _, ports := range rule.Ports {
+		if ports.Start > ports.End {
+			return InvalidNetOutRuleError{Rule: fmt.Sprintf("port range %d-%d ends before it starts", ports.Start, ports.End)}
+		}
+	}
+
+	return nil
+}
+
 // IPRangeFromIP creates an IPRange containing a single IP
 func IPRangeFromIP(ip net.IP) IPRange {
 	return IPRange{Start: ip, End: ip}
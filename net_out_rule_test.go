@@ -44,4 +44,51 @@ var _ = Describe("NetOutRule helper functions", func() {
 			Ω(*code).Should(BeNumerically("==", 2))
 		})
 	})
+
+	Describe("ValidateNetOutRule", func() {
+		It("accepts a rule with well formed ranges", func() {
+			Ω(garden.ValidateNetOutRule(garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{
+					{Start: net.ParseIP("1.2.3.4"), End: net.ParseIP("1.2.3.10")},
+					garden.IPRangeFromIP(net.ParseIP("::1")),
+				},
+				Ports: []garden.PortRange{{Start: 80, End: 8080}},
+			})).Should(Succeed())
+		})
+
+		It("accepts the empty rule", func() {
+			Ω(garden.ValidateNetOutRule(garden.NetOutRule{})).Should(Succeed())
+		})
+
+		It("rejects an unknown protocol", func() {
+			Ω(garden.ValidateNetOutRule(garden.NetOutRule{Protocol: 42})).Should(Equal(
+				garden.InvalidNetOutRuleError{Rule: "unknown protocol 42"},
+			))
+		})
+
+		It("rejects a network range missing an end", func() {
+			Ω(garden.ValidateNetOutRule(garden.NetOutRule{
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4")}},
+			})).Should(Equal(garden.InvalidNetOutRuleError{Rule: "network range missing start or end"}))
+		})
+
+		It("rejects a network range mixing IP families", func() {
+			Ω(garden.ValidateNetOutRule(garden.NetOutRule{
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4"), End: net.ParseIP("::1")}},
+			})).Should(BeAssignableToTypeOf(garden.InvalidNetOutRuleError{}))
+		})
+
+		It("rejects a network range that ends before it starts", func() {
+			Ω(garden.ValidateNetOutRule(garden.NetOutRule{
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4"), End: net.ParseIP("1.2.3.3")}},
+			})).Should(Equal(garden.InvalidNetOutRuleError{Rule: "network range 1.2.3.4-1.2.3.3 ends before it starts"}))
+		})
+
+		It("rejects a port range that ends before it starts", func() {
+			Ω(garden.ValidateNetOutRule(garden.NetOutRule{
+				Ports: []garden.PortRange{{Start: 81, End: 80}},
+			})).Should(Equal(garden.InvalidNetOutRuleError{Rule: "port range 81-80 ends before it starts"}))
+		})
+	})
 })
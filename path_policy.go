@@ -0,0 +1,87 @@
+package garden
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PathPolicy decides which paths in a container may be targeted by bind
+// mounts, scratch spaces and file streaming.
+type PathPolicy struct {
+	// AllowedPrefixes exempts paths under them from DeniedPrefixes.
+	AllowedPrefixes []string `json:"allowed_prefixes,omitempty"`
+
+	// DeniedPrefixes rejects paths at or under any of them.
+	DeniedPrefixes []string `json:"denied_prefixes,omitempty"`
+
+	// AllowRelative permits paths that are not absolute. They are resolved by
+	// the backend against the user's home directory, so one climbing out of
+	// it is checked as if that were the root, the shallowest it can be.
+	AllowRelative bool `json:"allow_relative,omitempty"`
+
+	// AllowRoot permits the root directory itself, as file streams may
+	// target the whole filesystem.
+	AllowRoot bool `json:"allow_root,omitempty"`
+}
+
+// DefaultPathPolicy keeps targets out of the container's kernel and device
+// filesystems.
+var DefaultPathPolicy = PathPolicy{
+	DeniedPrefixes: []string{"/proc", "/sys", "/dev"},
+}
+
+// InvalidPathError is returned by ValidateTargetPath, naming the rule the
+// path breaks.
+type InvalidPathError struct {
+	Path string
+	Rule string
+}
+
+func (err InvalidPathError) Error() string {
+	return fmt.Sprintf("invalid path %s: %s", err.Path, err.Rule)
+}
+
+// ValidateTargetPath checks a target path in a container against the policy.
+// The root directory is not a valid target unless the policy allows it.
+func ValidateTargetPath(p string, policy PathPolicy) error {
+	clean := filepath.Clean(p)
+
+	if !filepath.IsAbs(p) {
+		if !policy.AllowRelative {
+			return InvalidPathError{Path: p, Rule: "not absolute"}
+		}
+
+		// a path within the home directory cannot reach a denied prefix
+		// outside it, nor the root
+		if clean != ".." && !strings.HasPrefix(clean, "../") {
+			return nil
+		}
+
+		clean = filepath.Join("/", clean)
+	}
+
+	if clean == "/" && !policy.AllowRoot {
+		return InvalidPathError{Path: p, Rule: "is the root directory"}
+	}
+
+	for _, allowed := range policy.AllowedPrefixes {
+		if hasPathPrefix(clean, allowed) {
+			return nil
+		}
+	}
+
+	for _, denied := range policy.DeniedPrefixes {
+		if hasPathPrefix(clean, denied) {
+			return InvalidPathError{Path: p, Rule: "under denied prefix " + denied}
+		}
+	}
+
+	return nil
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	prefix = filepath.Clean(prefix)
+
+	return prefix == "/" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
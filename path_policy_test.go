@@ -0,0 +1,72 @@
+package garden_test
+
+import (
+	"code.cloudfoundry.org/garden"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateTargetPath", func() {
+	itAccepts := func(path string, policy garden.PathPolicy) {
+		It("accepts "+path, func() {
+			Ω(garden.ValidateTargetPath(path, policy)).Should(Succeed())
+		})
+	}
+
+	itRejects := func(path string, policy garden.PathPolicy, rule string) {
+		It("rejects "+path+" as "+rule, func() {
+			Ω(garden.ValidateTargetPath(path, policy)).Should(Equal(garden.InvalidPathError{Path: path, Rule: rule}))
+		})
+	}
+
+	Context("with the default policy", func() {
+		policy := garden.DefaultPathPolicy
+
+		itAccepts("/var/data", policy)
+		itAccepts("/proceeds", policy)
+
+		itRejects("data", policy, "not absolute")
+		itRejects("/", policy, "is the root directory")
+		itRejects("/var/..", policy, "is the root directory")
+		itRejects("/proc", policy, "under denied prefix /proc")
+		itRejects("/sys/fs/cgroup", policy, "under denied prefix /sys")
+		itRejects("/var/../dev/null", policy, "under denied prefix /dev")
+	})
+
+	Context("when a prefix is explicitly allowed", func() {
+		policy := garden.PathPolicy{
+			AllowedPrefixes: []string{"/dev/shm"},
+			DeniedPrefixes:  []string{"/dev"},
+		}
+
+		itAccepts("/dev/shm/data", policy)
+		itRejects("/dev/null", policy, "under denied prefix /dev")
+	})
+
+	Context("when relative paths are allowed", func() {
+		policy := garden.PathPolicy{AllowRelative: true}
+
+		itAccepts("data", policy)
+		itAccepts("proc/data", policy)
+		itRejects("/", policy, "is the root directory")
+		itRejects("../..", policy, "is the root directory")
+	})
+
+	Context("when relative paths are allowed with denied prefixes", func() {
+		policy := garden.DefaultPathPolicy
+		policy.AllowRelative = true
+
+		itAccepts("../data", policy)
+		itRejects("../../proc/x", policy, "under denied prefix /proc")
+		itRejects("data/../../../dev/null", policy, "under denied prefix /dev")
+	})
+
+	Context("when the root directory is allowed", func() {
+		policy := garden.DefaultPathPolicy
+		policy.AllowRoot = true
+
+		itAccepts("/", policy)
+		itAccepts("/var/..", policy)
+		itRejects("/proc", policy, "under denied prefix /proc")
+	})
+})
@@ -0,0 +1,229 @@
+package garden
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PropertyFilter matches containers whose properties satisfy all of its
+// matches.
+type PropertyFilter []PropertyMatch
+
+// PropertyMatch matches the property with the given name.
+type PropertyMatch struct {
+	Name string          `json:"name"`
+	Op   PropertyMatchOp `json:"op"`
+
+	// Value is the value to equal, or the pattern to match. It is ignored
+	// when testing for existence.
+	Value string `json:"value,omitempty"`
+}
+
+type PropertyMatchOp string
+
+const (
+	// PropertyOpEquals matches a property set to exactly Value.
+	PropertyOpEquals PropertyMatchOp = "equals"
+
+	// PropertyOpExists matches a property that is set, to any value.
+	PropertyOpExists PropertyMatchOp = "exists"
+
+	// PropertyOpGlob matches a property whose value matches the pattern in
+	// Value. In a pattern, * matches any run of characters, and \* and \\
+	// match a literal star and backslash.
+	PropertyOpGlob PropertyMatchOp = "glob"
+
+	// The negated ops match whatever their positive op does not, including a
+	// property that is not set at all. Servers that predate them reject them
+	// as unknown, rather than ignoring the negation.
+	PropertyOpNotEquals PropertyMatchOp = "not_equals"
+	PropertyOpNotExists PropertyMatchOp = "not_exists"
+	PropertyOpNotGlob   PropertyMatchOp = "not_glob"
+)
+
+// PropertyEquals matches a property set to exactly the value.
+func PropertyEquals(name, value string) PropertyMatch {
+	return PropertyMatch{Name: name, Op: PropertyOpEquals, Value: value}
+}
+
+// PropertyExists matches a property that is set, to any value.
+func PropertyExists(name string) PropertyMatch {
+	return PropertyMatch{Name: name, Op: PropertyOpExists}
+}
+
+// PropertyGlob matches a property whose value matches the pattern.
+func PropertyGlob(name, pattern string) PropertyMatch {
+	return PropertyMatch{Name: name, Op: PropertyOpGlob, Value: pattern}
+}
+
+// PropertyNotEquals matches a property that is not set to the value,
+// including one that is not set at all.
+func PropertyNotEquals(name, value string) PropertyMatch {
+	return PropertyMatch{Name: name, Op: PropertyOpNotEquals, Value: value}
+}
+
+// PropertyNotExists matches a property that is not set.
+func PropertyNotExists(name string) PropertyMatch {
+	return PropertyMatch{Name: name, Op: PropertyOpNotExists}
+}
+
+// PropertyNotGlob matches a property whose value does not match the pattern,
+// including one that is not set at all.
+func PropertyNotGlob(name, pattern string) PropertyMatch {
+	return PropertyMatch{Name: name, Op: PropertyOpNotGlob, Value: pattern}
+}
+
+// PropertyHasPrefix matches a property whose value starts with the prefix,
+// taken literally.
+func PropertyHasPrefix(name, prefix string) PropertyMatch {
+	return PropertyGlob(name, EscapeGlob(prefix)+"*")
+}
+
+// EscapeGlob escapes a value to be matched literally in a glob pattern.
+func EscapeGlob(literal string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`).Replace(literal)
+}
+
+// InvalidPropertyFilterError is returned for a filter holding a match that
+// is not well formed.
+type InvalidPropertyFilterError struct {
+	Match  PropertyMatch
+	Reason string
+}
+
+func (err InvalidPropertyFilterError) Error() string {
+	return fmt.Sprintf("invalid match on property %q: %s", err.Match.Name, err.Reason)
+}
+
+// Validate checks that every match of the filter is well formed.
+func (filter PropertyFilter) Validate() error {
+	for _, match := range filter {
+		op, _ := match.Op.positive()
+
+		switch op {
+		case PropertyOpEquals, PropertyOpExists:
+		case PropertyOpGlob:
+			if _, ok := globSegments(match.Value); !ok {
+				return InvalidPropertyFilterError{Match: match, Reason: "pattern ends in an unfinished escape"}
+			}
+		default:
+			return InvalidPropertyFilterError{Match: match, Reason: fmt.Sprintf("unknown op %q", match.Op)}
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true if the properties satisfy every match of the filter.
+// A match that is not well formed matches nothing.
+func (filter PropertyFilter) Matches(properties Properties) bool {
+	for _, match := range filter {
+		op, negated := match.Op.positive()
+
+		matched, ok := matchProperty(op, match.Name, match.Value, properties)
+		if !ok || matched == negated {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Equalities returns the properties the filter requires to equal a value.
+func (filter PropertyFilter) Equalities() Properties {
+	equalities := Properties{}
+	for _, match := range filter {
+		if match.Op == PropertyOpEquals {
+			equalities[match.Name] = match.Value
+		}
+	}
+
+	return equalities
+}
+
+// positive returns the op that a negated op negates, and whether it is
+// negated. Other ops are returned as they are.
+func (op PropertyMatchOp) positive() (PropertyMatchOp, bool) {
+	switch op {
+	case PropertyOpNotEquals:
+		return PropertyOpEquals, true
+	case PropertyOpNotExists:
+		return PropertyOpExists, true
+	case PropertyOpNotGlob:
+		return PropertyOpGlob, true
+	default:
+		return op, false
+	}
+}
+
+// matchProperty tests the named property against a positive op, reporting
+// false if the op is unknown or its pattern is malformed.
+func matchProperty(op PropertyMatchOp, name, value string, properties Properties) (matched bool, ok bool) {
+	actual, found := properties[name]
+
+	switch op {
+	case PropertyOpEquals:
+		return found && actual == value, true
+	case PropertyOpExists:
+		return found, true
+	case PropertyOpGlob:
+		segments, ok := globSegments(value)
+		if !ok {
+			return false, false
+		}
+
+		return found && globMatch(segments, actual), true
+	default:
+		return false, false
+	}
+}
+
+func globMatch(segments []string, value string) bool {
+	// the segments lie between the stars, so the first must start the value
+	// and the last must end it
+	if len(segments) == 1 {
+		return value == segments[0]
+	}
+
+	first, last := segments[0], segments[len(segments)-1]
+	if !strings.HasPrefix(value, first) {
+		return false
+	}
+	value = value[len(first):]
+
+	for _, segment := range segments[1 : len(segments)-1] {
+		i := strings.Index(value, segment)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(segment):]
+	}
+
+	return strings.HasSuffix(value, last)
+}
+
+// globSegments splits a pattern into the literal runs between its stars,
+// unescaped.
+func globSegments(pattern string) ([]string, bool) {
+	segments := []string{}
+	current := new(strings.Builder)
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+			if i == len(pattern) {
+				return nil, false
+			}
+
+			current.WriteByte(pattern[i])
+		case '*':
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(pattern[i])
+		}
+	}
+
+	return append(segments, current.String()), true
+}
@@ -0,0 +1,147 @@
+package garden_test
+
+import (
+	"code.cloudfoundry.org/garden"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PropertyFilter", func() {
+	properties := garden.Properties{
+		"task-id":  "",
+		"app-guid": "abc-123",
+		"pattern":  `lit*eral\path`,
+	}
+
+	itMatches := func(description string, filter garden.PropertyFilter) {
+		It("matches "+description, func() {
+			Ω(filter.Validate()).Should(Succeed())
+			Ω(filter.Matches(properties)).Should(BeTrue())
+		})
+	}
+
+	itDoesNotMatch := func(description string, filter garden.PropertyFilter) {
+		It("does not match "+description, func() {
+			Ω(filter.Validate()).Should(Succeed())
+			Ω(filter.Matches(properties)).Should(BeFalse())
+		})
+	}
+
+	itMatches("an empty filter", garden.PropertyFilter{})
+
+	itMatches("an equal value", garden.PropertyFilter{garden.PropertyEquals("app-guid", "abc-123")})
+	itDoesNotMatch("a different value", garden.PropertyFilter{garden.PropertyEquals("app-guid", "abc")})
+
+	itMatches("a property set to empty as existing", garden.PropertyFilter{garden.PropertyExists("task-id")})
+	itDoesNotMatch("a missing property as existing", garden.PropertyFilter{garden.PropertyExists("instance-id")})
+
+	itMatches("a prefix", garden.PropertyFilter{garden.PropertyHasPrefix("app-guid", "abc-")})
+	itDoesNotMatch("another prefix", garden.PropertyFilter{garden.PropertyHasPrefix("app-guid", "abd")})
+	itDoesNotMatch("a prefix of a missing property", garden.PropertyFilter{garden.PropertyHasPrefix("instance-id", "")})
+
+	itMatches("a glob with stars around and between", garden.PropertyFilter{garden.PropertyGlob("app-guid", "*c*2*")})
+	itMatches("a glob ending in a literal", garden.PropertyFilter{garden.PropertyGlob("app-guid", "a*123")})
+	itDoesNotMatch("a glob needing more than the value holds", garden.PropertyFilter{garden.PropertyGlob("app-guid", "abc*c-123")})
+
+	itMatches("all of several matches", garden.PropertyFilter{
+		garden.PropertyExists("task-id"),
+		garden.PropertyHasPrefix("app-guid", "abc"),
+	})
+	itDoesNotMatch("only some of several matches", garden.PropertyFilter{
+		garden.PropertyExists("task-id"),
+		garden.PropertyHasPrefix("app-guid", "xyz"),
+	})
+
+	Describe("negation", func() {
+		itMatches("a different value as not equal", garden.PropertyFilter{garden.PropertyNotEquals("app-guid", "abc")})
+		itDoesNotMatch("an equal value as not equal", garden.PropertyFilter{garden.PropertyNotEquals("app-guid", "abc-123")})
+		itMatches("a missing property as not equal", garden.PropertyFilter{garden.PropertyNotEquals("instance-id", "abc-123")})
+
+		itMatches("a missing property as not existing", garden.PropertyFilter{garden.PropertyNotExists("instance-id")})
+		itDoesNotMatch("a property set to empty as not existing", garden.PropertyFilter{garden.PropertyNotExists("task-id")})
+
+		itMatches("a value not matching a glob", garden.PropertyFilter{garden.PropertyNotGlob("app-guid", "xyz*")})
+		itDoesNotMatch("a value matching a glob", garden.PropertyFilter{garden.PropertyNotGlob("app-guid", "abc*")})
+		itMatches("a missing property as not matching a glob", garden.PropertyFilter{garden.PropertyNotGlob("instance-id", "*")})
+
+		itMatches("negated matches alongside positive ones", garden.PropertyFilter{
+			garden.PropertyExists("task-id"),
+			garden.PropertyNotEquals("app-guid", "xyz"),
+		})
+		itDoesNotMatch("a positive match failing alongside a negated one", garden.PropertyFilter{
+			garden.PropertyExists("instance-id"),
+			garden.PropertyNotEquals("app-guid", "xyz"),
+		})
+	})
+
+	Describe("escaping", func() {
+		itMatches("an escaped star literally", garden.PropertyFilter{garden.PropertyGlob("pattern", `lit\*eral*`)})
+		itDoesNotMatch("an escaped star as a wildcard", garden.PropertyFilter{garden.PropertyGlob("app-guid", `abc\*`)})
+		itMatches("an escaped backslash literally", garden.PropertyFilter{garden.PropertyGlob("pattern", `*\\path`)})
+
+		itMatches("a prefix holding a star literally", garden.PropertyFilter{garden.PropertyHasPrefix("pattern", "lit*e")})
+		itDoesNotMatch("a prefix holding a star as a wildcard", garden.PropertyFilter{garden.PropertyHasPrefix("pattern", "l*eral")})
+		itMatches("a prefix holding a backslash literally", garden.PropertyFilter{garden.PropertyHasPrefix("pattern", `lit*eral\p`)})
+
+		It("escapes stars and backslashes", func() {
+			Ω(garden.EscapeGlob(`a*b\c`)).Should(Equal(`a\*b\\c`))
+		})
+	})
+
+	Describe("a malformed pattern", func() {
+		It("matches nothing", func() {
+			filter := garden.PropertyFilter{garden.PropertyGlob("app-guid", `abc\`)}
+			Ω(filter.Matches(properties)).Should(BeFalse())
+		})
+
+		It("matches nothing when negated", func() {
+			filter := garden.PropertyFilter{garden.PropertyNotGlob("app-guid", `abc\`)}
+			Ω(filter.Matches(properties)).Should(BeFalse())
+
+			filter = garden.PropertyFilter{garden.PropertyNotGlob("instance-id", `abc\`)}
+			Ω(filter.Matches(properties)).Should(BeFalse())
+		})
+	})
+
+	Describe("Validate", func() {
+		It("rejects an unknown op", func() {
+			match := garden.PropertyMatch{Name: "app-guid", Op: "like", Value: "abc%"}
+
+			Ω(garden.PropertyFilter{match}.Validate()).Should(Equal(garden.InvalidPropertyFilterError{
+				Match:  match,
+				Reason: `unknown op "like"`,
+			}))
+		})
+
+		It("rejects a negated pattern ending in an unfinished escape", func() {
+			match := garden.PropertyNotGlob("app-guid", `abc\`)
+
+			Ω(garden.PropertyFilter{match}.Validate()).Should(Equal(garden.InvalidPropertyFilterError{
+				Match:  match,
+				Reason: "pattern ends in an unfinished escape",
+			}))
+		})
+
+		It("rejects a pattern ending in an unfinished escape", func() {
+			match := garden.PropertyGlob("app-guid", `abc\`)
+
+			Ω(garden.PropertyFilter{match}.Validate()).Should(Equal(garden.InvalidPropertyFilterError{
+				Match:  match,
+				Reason: "pattern ends in an unfinished escape",
+			}))
+		})
+	})
+
+	Describe("Equalities", func() {
+		It("returns the properties required to equal a value", func() {
+			filter := garden.PropertyFilter{
+				garden.PropertyEquals("app-guid", "abc-123"),
+				garden.PropertyExists("task-id"),
+				garden.PropertyHasPrefix("pattern", "lit"),
+				garden.PropertyNotEquals("owner", "someone"),
+			}
+
+			Ω(filter.Equalities()).Should(Equal(garden.Properties{"app-guid": "abc-123"}))
+		})
+	})
+})
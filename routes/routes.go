@@ -7,17 +7,27 @@ const (
 	Capacity = "Capacity"
 
 	List        = "List"
+	ListPage    = "ListPage"
 	Create      = "Create"
 	Info        = "Info"
 	BulkInfo    = "BulkInfo"
 	BulkMetrics = "BulkMetrics"
 	Destroy     = "Destroy"
 
+	DestroyMatching = "DestroyMatching"
+	Tombstone       = "Tombstone"
+
 	Stop = "Stop"
 
+	Lock   = "Lock"
+	Unlock = "Unlock"
+
 	StreamIn  = "StreamIn"
 	StreamOut = "StreamOut"
 
+	ReadFile  = "ReadFile"
+	WriteFile = "WriteFile"
+
 	Stdout = "Stdout"
 	Stderr = "Stderr"
 
@@ -26,21 +36,32 @@ const (
 	CurrentDiskLimits      = "CurrentDiskLimits"
 	CurrentMemoryLimits    = "CurrentMemoryLimits"
 
-	NetIn  = "NetIn"
-	NetOut = "NetOut"
+	NetIn      = "NetIn"
+	NetOut     = "NetOut"
+	BulkNetOut = "BulkNetOut"
 
 	Run    = "Run"
 	Attach = "Attach"
 
+	CapturedOutput = "CapturedOutput"
+
 	SetGraceTime = "SetGraceTime"
 
 	Properties  = "Properties"
 	Property    = "Property"
 	SetProperty = "SetProperty"
 
-	Metrics = "Metrics"
+	Metrics        = "Metrics"
+	MetricsHistory = "MetricsHistory"
 
 	RemoveProperty = "RemoveProperty"
+
+	CompareAndSetProperty = "CompareAndSetProperty"
+	SetProperties         = "SetProperties"
+	RemoveProperties      = "RemoveProperties"
+
+	RouteTable = "RouteTable"
+	APISpec    = "APISpec"
 )
 
 var Routes = rata.Routes{
@@ -48,6 +69,7 @@ var Routes = rata.Routes{
 	{Path: "/capacity", Method: "GET", Name: Capacity},
 
 	{Path: "/containers", Method: "GET", Name: List},
+	{Path: "/containers/list_page", Method: "POST", Name: ListPage},
 	{Path: "/containers", Method: "POST", Name: Create},
 
 	{Path: "/containers/:handle/info", Method: "GET", Name: Info},
@@ -55,10 +77,16 @@ var Routes = rata.Routes{
 	{Path: "/containers/bulk_metrics", Method: "GET", Name: BulkMetrics},
 
 	{Path: "/containers/:handle", Method: "DELETE", Name: Destroy},
+	{Path: "/containers/destroy_matching", Method: "POST", Name: DestroyMatching},
+	{Path: "/containers/:handle/tombstone", Method: "GET", Name: Tombstone},
 	{Path: "/containers/:handle/stop", Method: "PUT", Name: Stop},
+	{Path: "/containers/:handle/lock", Method: "PUT", Name: Lock},
+	{Path: "/containers/:handle/lock", Method: "DELETE", Name: Unlock},
 
 	{Path: "/containers/:handle/files", Method: "PUT", Name: StreamIn},
 	{Path: "/containers/:handle/files", Method: "GET", Name: StreamOut},
+	{Path: "/containers/:handle/file", Method: "GET", Name: ReadFile},
+	{Path: "/containers/:handle/file", Method: "PUT", Name: WriteFile},
 
 	{Path: "/containers/:handle/limits/bandwidth", Method: "GET", Name: CurrentBandwidthLimits},
 	{Path: "/containers/:handle/limits/cpu", Method: "GET", Name: CurrentCPULimits},
@@ -67,11 +95,13 @@ var Routes = rata.Routes{
 
 	{Path: "/containers/:handle/net/in", Method: "POST", Name: NetIn},
 	{Path: "/containers/:handle/net/out", Method: "POST", Name: NetOut},
+	{Path: "/containers/:handle/net/out/bulk", Method: "POST", Name: BulkNetOut},
 
 	{Path: "/containers/:handle/processes/:pid/attaches/:streamid/stdout", Method: "GET", Name: Stdout},
 	{Path: "/containers/:handle/processes/:pid/attaches/:streamid/stderr", Method: "GET", Name: Stderr},
 	{Path: "/containers/:handle/processes", Method: "POST", Name: Run},
 	{Path: "/containers/:handle/processes/:pid", Method: "GET", Name: Attach},
+	{Path: "/containers/:handle/processes/:pid/captured/:stream", Method: "GET", Name: CapturedOutput},
 
 	{Path: "/containers/:handle/grace_time", Method: "PUT", Name: SetGraceTime},
 
@@ -79,6 +109,32 @@ var Routes = rata.Routes{
 	{Path: "/containers/:handle/properties/:key", Method: "GET", Name: Property},
 	{Path: "/containers/:handle/properties/:key", Method: "PUT", Name: SetProperty},
 	{Path: "/containers/:handle/properties/:key", Method: "DELETE", Name: RemoveProperty},
+	{Path: "/containers/:handle/properties/:key/compare_and_set", Method: "POST", Name: CompareAndSetProperty},
+	{Path: "/containers/:handle/properties", Method: "PUT", Name: SetProperties},
+	{Path: "/containers/:handle/properties/remove", Method: "POST", Name: RemoveProperties},
 
 	{Path: "/containers/:handle/metrics", Method: "GET", Name: Metrics},
+	{Path: "/containers/:handle/metrics/history", Method: "GET", Name: MetricsHistory},
+
+	{Path: "/routes", Method: "GET", Name: RouteTable},
+	{Path: "/api-spec", Method: "GET", Name: APISpec},
+}
+
+// Description is the machine-readable form of a route, as served by the
+// RouteTable route.
+type Description struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+
+	// Request and Response name the Go type exchanged as JSON, or the media
+	// type of a raw body. They are empty when no body is exchanged.
+	Request  string `json:"request,omitempty"`
+	Response string `json:"response,omitempty"`
+
+	// Hijacks is true if the route takes over the underlying connection, and
+	// so cannot be served over HTTP/2. Stdout and Stderr take it over when
+	// they can, but otherwise stream their response as a chunked body, so
+	// they are not marked.
+	Hijacks bool `json:"hijacks,omitempty"`
 }
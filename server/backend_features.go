@@ -0,0 +1,21 @@
+package server
+
+import "code.cloudfoundry.org/garden"
+
+// checkBackendSupports refuses a spec that asks for something the backend
+// does not enforce, rather than create a container without it.
+func checkBackendSupports(backend garden.Backend, spec garden.ContainerSpec) error {
+	if spec.IsolateIntraSubnet {
+		if isolator, ok := backend.(garden.IntraSubnetIsolator); !ok || !isolator.IsolatesIntraSubnet() {
+			return garden.UnsupportedOperationError{Message: "the backend does not support isolate_intra_subnet"}
+		}
+	}
+
+	if len(spec.ScratchSpaces) > 0 {
+		if provider, ok := backend.(garden.ScratchSpaceProvider); !ok || !provider.ProvidesScratchSpaces() {
+			return garden.UnsupportedOperationError{Message: "the backend does not support scratch_spaces"}
+		}
+	}
+
+	return nil
+}
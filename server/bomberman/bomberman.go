@@ -18,6 +18,13 @@ type bomb struct {
 	DefuseHandle   string
 }
 
+// detonation identifies a bomb that went off, so that it is only forgotten if
+// no other has been strapped to the container since.
+type detonation struct {
+	handle string
+	bomb   *timebomb.TimeBomb
+}
+
 type Bomberman struct {
 	backend garden.Backend
 
@@ -25,7 +32,7 @@ type Bomberman struct {
 
 	pause   chan string
 	unpause chan string
-	cleanup chan string
+	cleanup chan detonation
 	bomb    chan bomb
 }
 
@@ -37,7 +44,7 @@ func New(backend garden.Backend, detonate func(garden.Container)) *Bomberman {
 		bomb:    make(chan bomb),
 		pause:   make(chan string),
 		unpause: make(chan string),
-		cleanup: make(chan string),
+		cleanup: make(chan detonation),
 	}
 
 	go b.manageBombs()
@@ -45,6 +52,9 @@ func New(backend garden.Backend, detonate func(garden.Container)) *Bomberman {
 	return b
 }
 
+// Strap starts the container's countdown with its current grace time,
+// replacing any countdown already running. Pauses held by in-flight requests
+// carry over to the new countdown.
 func (b *Bomberman) Strap(container garden.Container) {
 	b.bomb <- bomb{Action: strap, StrapContainer: container}
 }
@@ -64,6 +74,10 @@ func (b *Bomberman) Defuse(name string) {
 func (b *Bomberman) manageBombs() {
 	timeBombs := map[string]*timebomb.TimeBomb{}
 
+	// pauses outlive the bombs, so that a bomb strapped while requests are
+	// in flight stays paused until they finish
+	pauses := map[string]int{}
+
 	for {
 		select {
 		case bombSignal := <-b.bomb:
@@ -71,18 +85,28 @@ func (b *Bomberman) manageBombs() {
 			case strap:
 				container := bombSignal.StrapContainer
 
+				if bomb, found := timeBombs[container.Handle()]; found {
+					bomb.Defuse()
+					delete(timeBombs, container.Handle())
+				}
+
 				if b.backend.GraceTime(container) == 0 {
 					continue
 				}
 
-				bomb := timebomb.New(
+				var bomb *timebomb.TimeBomb
+				bomb = timebomb.New(
 					b.backend.GraceTime(container),
 					func() {
 						b.detonate(container)
-						b.cleanup <- container.Handle()
+						b.cleanup <- detonation{handle: container.Handle(), bomb: bomb}
 					},
 				)
 
+				for i := 0; i < pauses[container.Handle()]; i++ {
+					bomb.Pause()
+				}
+
 				timeBombs[container.Handle()] = bomb
 				bomb.Strap()
 
@@ -97,6 +121,8 @@ func (b *Bomberman) manageBombs() {
 				delete(timeBombs, bombSignal.DefuseHandle)
 			}
 		case handle := <-b.pause:
+			pauses[handle]++
+
 			bomb, found := timeBombs[handle]
 			if !found {
 				continue
@@ -105,6 +131,10 @@ func (b *Bomberman) manageBombs() {
 			bomb.Pause()
 
 		case handle := <-b.unpause:
+			if pauses[handle]--; pauses[handle] <= 0 {
+				delete(pauses, handle)
+			}
+
 			bomb, found := timeBombs[handle]
 			if !found {
 				continue
@@ -112,8 +142,10 @@ func (b *Bomberman) manageBombs() {
 
 			bomb.Unpause()
 
-		case handle := <-b.cleanup:
-			delete(timeBombs, handle)
+		case detonated := <-b.cleanup:
+			if timeBombs[detonated.handle] == detonated.bomb {
+				delete(timeBombs, detonated.handle)
+			}
 		}
 	}
 }
@@ -132,6 +132,91 @@ var _ = Describe("Bomberman", func() {
 		})
 	})
 
+	Describe("strapping a container that already has a timebomb", func() {
+		It("restarts the countdown", func() {
+			detonated := make(chan garden.Container)
+
+			backend := new(fakes.FakeBackend)
+			backend.GraceTimeReturns(100 * time.Millisecond)
+
+			bomberman := bomberman.New(backend, func(container garden.Container) {
+				detonated <- container
+			})
+
+			container := new(fakes.FakeContainer)
+			container.HandleReturns("doomed")
+
+			bomberman.Strap(container)
+			time.Sleep(50 * time.Millisecond)
+
+			before := time.Now()
+			bomberman.Strap(container)
+
+			select {
+			case <-detonated:
+				Ω(time.Since(before)).Should(BeNumerically(">=", 100*time.Millisecond))
+			case <-time.After(backend.GraceTime(container) + 50*time.Millisecond):
+				Fail("did not detonate!")
+			}
+
+			Consistently(detonated, 200*time.Millisecond).ShouldNot(Receive())
+		})
+
+		Context("when its timebomb is paused", func() {
+			It("does not detonate until it is unpaused", func() {
+				detonated := make(chan garden.Container)
+
+				backend := new(fakes.FakeBackend)
+				backend.GraceTimeReturns(100 * time.Millisecond)
+
+				bomberman := bomberman.New(backend, func(container garden.Container) {
+					detonated <- container
+				})
+
+				container := new(fakes.FakeContainer)
+				container.HandleReturns("doomed")
+
+				bomberman.Strap(container)
+				bomberman.Pause("doomed")
+				bomberman.Strap(container)
+
+				Consistently(detonated, 200*time.Millisecond).ShouldNot(Receive())
+
+				bomberman.Unpause("doomed")
+
+				Eventually(detonated, 200*time.Millisecond).Should(Receive())
+			})
+		})
+
+		Context("when it had no timebomb while paused", func() {
+			It("does not detonate until it is unpaused", func() {
+				detonated := make(chan garden.Container)
+
+				backend := new(fakes.FakeBackend)
+				backend.GraceTimeReturns(0)
+
+				bomberman := bomberman.New(backend, func(container garden.Container) {
+					detonated <- container
+				})
+
+				container := new(fakes.FakeContainer)
+				container.HandleReturns("doomed")
+
+				bomberman.Strap(container)
+				bomberman.Pause("doomed")
+
+				backend.GraceTimeReturns(100 * time.Millisecond)
+				bomberman.Strap(container)
+
+				Consistently(detonated, 200*time.Millisecond).ShouldNot(Receive())
+
+				bomberman.Unpause("doomed")
+
+				Eventually(detonated, 200*time.Millisecond).Should(Receive())
+			})
+		})
+	})
+
 	Describe("defusing a container's timebomb", func() {
 		It("prevents it from detonating", func() {
 			detonated := make(chan garden.Container)
@@ -156,6 +241,34 @@ var _ = Describe("Bomberman", func() {
 			}
 		})
 
+		Context("when it was strapped again as it detonated", func() {
+			It("prevents the new one from detonating", func() {
+				detonated := make(chan garden.Container)
+
+				backend := new(fakes.FakeBackend)
+				backend.GraceTimeReturns(100 * time.Millisecond)
+
+				var b *bomberman.Bomberman
+				b = bomberman.New(backend, func(container garden.Container) {
+					detonated <- container
+					b.Strap(container)
+				})
+
+				container := new(fakes.FakeContainer)
+				container.HandleReturns("doomed")
+
+				b.Strap(container)
+
+				Eventually(detonated, 200*time.Millisecond).Should(Receive())
+
+				// let the first bomb's cleanup run before defusing
+				time.Sleep(10 * time.Millisecond)
+				b.Defuse("doomed")
+
+				Consistently(detonated, 200*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+
 		Context("when the handle is invalid", func() {
 			It("doesn't launch any missiles or anything like that", func() {
 				bomberman := bomberman.New(new(fakes.FakeBackend), func(container garden.Container) {
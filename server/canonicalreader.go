@@ -0,0 +1,153 @@
+package server
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// xattrPAXPrefix is the prefix of the PAX records holding extended
+// attributes, which are the only records kept.
+const xattrPAXPrefix = "SCHILY.xattr."
+
+// resumableLookahead is how much of a resumable archive is read before any of
+// it is sent, so that a stream that fails early is refused rather than broken
+// off.
+const resumableLookahead = 1024 * 1024
+
+// canonicalReader re-encodes a tar stream so that the same entries always
+// yield the same bytes, whatever tool wrote them and whenever they were read.
+// This lets a resumed StreamOut continue where an earlier one stopped.
+//
+// Each header keeps the entry's type, name, link target, size, mode, numeric
+// ownership, device numbers and extended attributes, and its modification
+// time truncated to the second. Access and change times, which reading the
+// files may itself update, and user and group names are dropped.
+//
+// The entries must come in the order StreamOutSpec.Resumable asks backends
+// for: depth first, with the entries of each directory sorted by name. An
+// archive in any other order could not be resumed reliably, so the stream
+// fails at the first entry out of order with an UnsupportedOperationError.
+type canonicalReader struct {
+	*io.PipeReader
+	source io.ReadCloser
+}
+
+func newCanonicalReader(source io.ReadCloser) *canonicalReader {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(canonicalize(tar.NewReader(source), tar.NewWriter(pw)))
+	}()
+
+	return &canonicalReader{PipeReader: pr, source: source}
+}
+
+func (r *canonicalReader) Close() error {
+	r.PipeReader.Close()
+	return r.source.Close()
+}
+
+func canonicalize(tr *tar.Reader, tw *tar.Writer) error {
+	var previous string
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if previous != "" && !entryBefore(previous, hdr.Name) {
+			return garden.UnsupportedOperationError{
+				Message: fmt.Sprintf("stream out entries are out of order: %q after %q; the backend cannot stream resumably", hdr.Name, previous),
+			}
+		}
+
+		previous = hdr.Name
+
+		if err := tw.WriteHeader(canonicalHeader(hdr)); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// entryBefore tells whether an entry named a comes before one named b in a
+// depth first walk that visits the entries of each directory sorted by name.
+func entryBefore(a, b string) bool {
+	as := strings.Split(strings.TrimSuffix(a, "/"), "/")
+	bs := strings.Split(strings.TrimSuffix(b, "/"), "/")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+
+	return len(as) < len(bs)
+}
+
+func canonicalHeader(hdr *tar.Header) *tar.Header {
+	typeflag := hdr.Typeflag
+	if typeflag == tar.TypeRegA {
+		typeflag = tar.TypeReg
+	}
+
+	canonical := &tar.Header{
+		Typeflag: typeflag,
+		Name:     hdr.Name,
+		Linkname: hdr.Linkname,
+		Size:     hdr.Size,
+		Mode:     hdr.Mode,
+		Uid:      hdr.Uid,
+		Gid:      hdr.Gid,
+		ModTime:  hdr.ModTime.Truncate(time.Second),
+		Devmajor: hdr.Devmajor,
+		Devminor: hdr.Devminor,
+	}
+
+	for key, value := range hdr.PAXRecords {
+		if !strings.HasPrefix(key, xattrPAXPrefix) {
+			continue
+		}
+
+		if canonical.PAXRecords == nil {
+			canonical.PAXRecords = make(map[string]string)
+		}
+
+		canonical.PAXRecords[key] = value
+	}
+
+	return canonical
+}
+
+// skipResumed reads the first offset bytes of a canonical stream, checking
+// that they hash to the generation the client holds.
+func skipResumed(reader io.Reader, offset int64, generation string) (bool, error) {
+	hash := sha256.New()
+
+	_, err := io.CopyN(hash, reader, offset)
+	if err == io.EOF {
+		// the archive got shorter than what was already received
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)) == generation, nil
+}
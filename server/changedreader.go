@@ -0,0 +1,74 @@
+package server
+
+import (
+	"archive/tar"
+	"io"
+	"time"
+)
+
+// changedReader filters a tar stream down to the entries modified in or after
+// the second of a marker, so that repeated StreamOuts of a mostly unchanged tree only transfer
+// what changed.
+//
+// Directories are always kept, so that changed files keep their parents'
+// ownership and modes. Symlinks are judged by their own modification time and
+// kept as links. Hard links share their target's modification time, and are
+// dropped with it, as they could not be extracted without it.
+type changedReader struct {
+	*io.PipeReader
+	source io.ReadCloser
+}
+
+func newChangedReader(source io.ReadCloser, since time.Time) *changedReader {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(filterChanged(tar.NewReader(source), tar.NewWriter(pw), since))
+	}()
+
+	return &changedReader{PipeReader: pr, source: source}
+}
+
+func (r *changedReader) Close() error {
+	r.PipeReader.Close()
+	return r.source.Close()
+}
+
+func filterChanged(tr *tar.Reader, tw *tar.Writer, since time.Time) error {
+	kept := make(map[string]bool)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+		case tar.TypeLink:
+			if !kept[hdr.Linkname] {
+				continue
+			}
+		default:
+			// tar keeps whole seconds, so a file changed within the marker's
+			// second may carry a time before it
+			if hdr.ModTime.Before(since.Truncate(time.Second)) {
+				continue
+			}
+		}
+
+		kept[hdr.Name] = true
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// checksumReader hashes a stream as it is read and turns its EOF into a
+// ChecksumMismatchError if the digest is not the expected one.
+//
+// Backends may stop reading at the end of the archive, so it follows the
+// archive as it goes. The read that reaches the end of the archive first reads
+// and hashes whatever follows it, and is failed instead of handed on if the
+// whole stream does not match: a backend never sees the end of an archive
+// that fails its check.
+type checksumReader struct {
+	r          io.Reader
+	handle     string
+	expected   string
+	hash       hash.Hash
+	mismatched bool
+	drained    bool
+
+	// the archive is followed by a tar reader of its own, fed each read in
+	// turn; it asks for the next on wants, and closes ended once it has
+	// reached the end of the archive, or given up on a stream that is not one
+	wants      chan struct{}
+	chunks     chan []byte
+	ended      chan struct{}
+	reachedEnd bool
+	asked      bool
+	followed   bool
+
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+func newChecksumReader(r io.Reader, handle, expected string) *checksumReader {
+	c := &checksumReader{
+		r:        r,
+		handle:   handle,
+		expected: strings.ToLower(expected),
+		hash:     sha256.New(),
+
+		wants:  make(chan struct{}),
+		chunks: make(chan []byte),
+		ended:  make(chan struct{}),
+
+		stop:     make(chan struct{}),
+		stopOnce: new(sync.Once),
+	}
+
+	go c.followArchive()
+
+	return c
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	if c.drained {
+		return 0, io.EOF
+	}
+
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+
+	if n > 0 && c.follow(p[:n]) {
+		if _, err := io.Copy(c.hash, c.r); err != nil {
+			return 0, err
+		}
+
+		c.drained = true
+
+		if !c.matches() {
+			return 0, garden.ChecksumMismatchError{Handle: c.handle}
+		}
+
+		return n, nil
+	}
+
+	if err != nil {
+		c.release()
+	}
+
+	if err == io.EOF && !c.matches() {
+		return n, garden.ChecksumMismatchError{Handle: c.handle}
+	}
+
+	return n, err
+}
+
+// verify reads whatever the backend left of the stream, so that a backend
+// that stops reading early cannot skip the check, and fails with a
+// ChecksumMismatchError if the digest of the whole stream is not the
+// expected one.
+func (c *checksumReader) verify() error {
+	_, err := io.Copy(ioutil.Discard, c)
+	if c.mismatched {
+		return garden.ChecksumMismatchError{Handle: c.handle}
+	}
+
+	return err
+}
+
+// release stops following the archive.
+func (c *checksumReader) release() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+func (c *checksumReader) matches() bool {
+	if hex.EncodeToString(c.hash.Sum(nil)) != c.expected {
+		c.mismatched = true
+	}
+
+	return !c.mismatched
+}
+
+// follow feeds a read to the archive's follower, reporting whether the
+// archive ends within it.
+func (c *checksumReader) follow(chunk []byte) bool {
+	if c.followed {
+		return false
+	}
+
+	if !c.asked {
+		select {
+		case <-c.wants:
+		case <-c.ended:
+			c.followed = true
+			return false
+		}
+	}
+
+	select {
+	case c.chunks <- chunk:
+	case <-c.ended:
+		c.followed = true
+		return false
+	}
+
+	select {
+	case <-c.wants:
+		c.asked = true
+		return false
+	case <-c.ended:
+		c.followed = true
+		return c.reachedEnd
+	}
+}
+
+func (c *checksumReader) followArchive() {
+	defer close(c.ended)
+
+	archive := tar.NewReader(&fedReader{wants: c.wants, chunks: c.chunks, stop: c.stop})
+	for {
+		_, err := archive.Next()
+		if err == io.EOF {
+			c.reachedEnd = true
+			return
+		}
+
+		if err != nil {
+			return
+		}
+
+		if _, err := io.Copy(ioutil.Discard, archive); err != nil {
+			return
+		}
+	}
+}
+
+// fedReader reads the chunks it is fed, asking for each one.
+type fedReader struct {
+	wants  chan<- struct{}
+	chunks <-chan []byte
+	stop   <-chan struct{}
+	chunk  []byte
+}
+
+func (f *fedReader) Read(p []byte) (int, error) {
+	for len(f.chunk) == 0 {
+		select {
+		case f.wants <- struct{}{}:
+		case <-f.stop:
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		select {
+		case f.chunk = <-f.chunks:
+		case <-f.stop:
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+
+	n := copy(p, f.chunk)
+	f.chunk = f.chunk[n:]
+
+	return n, nil
+}
+
+func validChecksum(checksum string) bool {
+	decoded, err := hex.DecodeString(checksum)
+	return err == nil && len(decoded) == sha256.Size
+}
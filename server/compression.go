@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/transport"
+)
+
+// maxDecompressedBodyBytes bounds how large a gzipped request body may grow,
+// so that a small compressed body cannot exhaust the server's memory.
+const maxDecompressedBodyBytes = 64 * 1024 * 1024
+
+var ErrCompressionDisabled = errors.New("gzip request bodies are not accepted by this server")
+
+// decompressRequest replaces a gzipped request body with its decompressed
+// contents. It writes an error and returns false if the body's encoding is
+// not accepted.
+func (s *GardenServer) decompressRequest(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+		return true
+	case "gzip":
+		if !s.compressionEnabled() {
+			s.writeUnsupportedEncoding(w, ErrCompressionDisabled)
+			return false
+		}
+	default:
+		s.writeUnsupportedEncoding(w, fmt.Errorf("unsupported content encoding: %s", r.Header.Get("Content-Encoding")))
+		return false
+	}
+
+	gzipReader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		s.writeError(w, err, s.logger)
+		return false
+	}
+
+	r.Body = &limitedBody{
+		Reader: io.LimitReader(gzipReader, maxDecompressedBodyBytes+1),
+		Closer: r.Body,
+	}
+	r.Header.Del("Content-Encoding")
+
+	return true
+}
+
+func (s *GardenServer) writeUnsupportedEncoding(w http.ResponseWriter, err error) {
+	s.logger.Error("unsupported-content-encoding", err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+	json.NewEncoder(w).Encode(&garden.Error{Err: err})
+}
+
+// limitedBody fails reads once more than maxDecompressedBodyBytes have been
+// read, rather than silently truncating the body.
+type limitedBody struct {
+	io.Reader
+	io.Closer
+
+	read int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += int64(n)
+
+	if b.read > maxDecompressedBodyBytes {
+		return n, fmt.Errorf("decompressed request body exceeds %d bytes", maxDecompressedBodyBytes)
+	}
+
+	return n, err
+}
+
+// writeCompressed writes msg gzipped if the client accepts it and it is large
+// enough to be worth it.
+func writeCompressed(w http.ResponseWriter, r *http.Request, msg interface{}) {
+	buf := new(bytes.Buffer)
+	if err := transport.WriteMessage(buf, msg); err != nil {
+		return
+	}
+
+	writeCompressedBody(w, r, http.StatusOK, buf.Bytes())
+}
+
+// writeCompressedBody is writeCompressed for a response that is already
+// encoded as JSON.
+func writeCompressedBody(w http.ResponseWriter, r *http.Request, statusCode int, body []byte) {
+	if len(body) < transport.CompressionThreshold || !acceptsGzip(r) {
+		w.WriteHeader(statusCode)
+		w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(statusCode)
+
+	gzipWriter := gzip.NewWriter(w)
+	gzipWriter.Write(body)
+	gzipWriter.Close()
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(encoding, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+
+	return false
+}
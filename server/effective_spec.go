@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// effectiveSpecFields are the indexes of the fields of an EffectiveSpec by
+// their JSON names, which is how a request masks them.
+var effectiveSpecFields = jsonFieldIndexes(reflect.TypeOf(garden.EffectiveSpec{}))
+
+func jsonFieldIndexes(message reflect.Type) map[string][]int {
+	indexes := map[string][]int{}
+
+	for i := 0; i < message.NumField(); i++ {
+		field := message.Field(i)
+
+		if field.Anonymous {
+			for name, index := range jsonFieldIndexes(field.Type) {
+				indexes[name] = append([]int{i}, index...)
+			}
+
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		indexes[name] = field.Index
+	}
+
+	return indexes
+}
+
+// parseEffectiveSpecFields parses the comma-separated fields a request masks
+// its effective spec to.
+func parseEffectiveSpecFields(query string) ([]string, error) {
+	if query == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(query, ",")
+	for _, field := range fields {
+		if _, found := effectiveSpecFields[field]; !found {
+			return nil, garden.InvalidRequestError{Message: fmt.Sprintf("effective spec has no field %q", field)}
+		}
+	}
+
+	return fields, nil
+}
+
+// effectiveSpec is the spec a container was created with, as resolved by
+// handleCreate, taking what Info reports over what was asked for, so that the
+// two agree.
+func effectiveSpec(spec garden.ContainerSpec, handle string, info garden.ContainerInfo) garden.EffectiveSpec {
+	spec.Handle = handle
+	spec.Network = info.Network
+	spec.BindMounts = info.BindMounts
+	spec.Properties = info.Properties
+	spec.Privileged = info.Privileged
+	spec.IsolateIntraSubnet = info.IsolateIntraSubnet
+	spec.RecordMetrics = info.RecordingMetrics
+
+	return garden.EffectiveSpec{
+		ContainerSpec: spec,
+		HostIP:        info.HostIP,
+		ContainerIP:   info.ContainerIP,
+		ExternalIP:    info.ExternalIP,
+		MappedPorts:   info.MappedPorts,
+	}
+}
+
+// maskEffectiveSpec keeps only the named fields of an effective spec, or all
+// of them if none are named.
+func maskEffectiveSpec(spec garden.EffectiveSpec, fields []string) garden.EffectiveSpec {
+	if len(fields) == 0 {
+		return spec
+	}
+
+	var masked garden.EffectiveSpec
+
+	from := reflect.ValueOf(spec)
+	to := reflect.ValueOf(&masked).Elem()
+
+	for _, field := range fields {
+		index := effectiveSpecFields[field]
+		to.FieldByIndex(index).Set(from.FieldByIndex(index))
+	}
+
+	return masked
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// HandleGenerator chooses the handle of a container created without one.
+type HandleGenerator interface {
+	Generate(spec garden.ContainerSpec) (string, error)
+}
+
+// maxHandleAttempts bounds how many handles are generated for one create
+// before giving up, should they all be taken.
+const maxHandleAttempts = 5
+
+// RandomHandles generates random URL-safe handles of 128 bits.
+type RandomHandles struct{}
+
+func (RandomHandles) Generate(garden.ContainerSpec) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SequentialHandles generates handles of the form <prefix><n>, counting up
+// from 1. The count is not persisted, so the prefix should tell servers and
+// restarts apart if handles must stay unique across them.
+type SequentialHandles struct {
+	Prefix string
+
+	mu   sync.Mutex
+	next uint64
+}
+
+func (g *SequentialHandles) Generate(garden.ContainerSpec) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.next++
+	return fmt.Sprintf("%s%d", g.Prefix, g.next), nil
+}
+
+// SetHandleGenerator sets how handles are chosen for containers created
+// without one. If it is nil, as by default, the backend chooses them. The
+// backend's Lookup must fail with a ContainerNotFoundError for a handle that
+// is free.
+func (s *GardenServer) SetHandleGenerator(generator HandleGenerator) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.handleGenerator = generator
+}
+
+func (s *GardenServer) getHandleGenerator() HandleGenerator {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.handleGenerator
+}
+
+// generateHandle asks the generator for a handle that is valid and not yet
+// taken, trying again a bounded number of times. A handle is only taken to be
+// free if the backend says there is no such container; any other failure to
+// look it up fails the create.
+func (s *GardenServer) generateHandle(generator HandleGenerator, spec garden.ContainerSpec) (string, error) {
+	for attempt := 0; attempt < maxHandleAttempts; attempt++ {
+		handle, err := generator.Generate(spec)
+		if err != nil {
+			return "", err
+		}
+
+		if err := validateGeneratedHandle(handle); err != nil {
+			return "", err
+		}
+
+		_, err = s.backend.Lookup(handle)
+		if _, notFound := err.(garden.ContainerNotFoundError); notFound {
+			return handle, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("no unused handle generated in %d attempts", maxHandleAttempts)
+}
+
+// validateGeneratedHandle checks that a handle can be used as a single path
+// segment of the API's routes without escaping.
+func validateGeneratedHandle(handle string) error {
+	if handle == "" {
+		return fmt.Errorf("generated handle is empty")
+	}
+
+	if strings.IndexFunc(handle, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.~", r))
+	}) != -1 || handle == "." || handle == ".." {
+		return fmt.Errorf("generated handle %q is not URL-safe", handle)
+	}
+
+	return nil
+}
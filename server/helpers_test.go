@@ -1,5 +1,23 @@
 package server_test
 
+import fakes "code.cloudfoundry.org/garden/gardenfakes"
+
 func uint64ptr(n uint64) *uint64 {
 	return &n
 }
+
+// capableBackend is a fake backend implementing the optional backend
+// interfaces, reporting support as set
+type capableBackend struct {
+	*fakes.FakeBackend
+
+	isolatesIntraSubnet   bool
+	limitsProcesses       bool
+	providesScratchSpaces bool
+}
+
+func (b *capableBackend) IsolatesIntraSubnet() bool { return b.isolatesIntraSubnet }
+
+func (b *capableBackend) LimitsProcesses() bool { return b.limitsProcesses }
+
+func (b *capableBackend) ProvidesScratchSpaces() bool { return b.providesScratchSpaces }
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/transport"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	defaultIdempotencyWindow = 10 * time.Minute
+	maxIdempotencyKeys       = 1000
+)
+
+// idempotentOutcome is the response to a request made with an idempotency
+// key, replayed to retries of it.
+type idempotentOutcome struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+type idempotentRequest struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	recordedAt  time.Time
+
+	// closed once outcome is set, or the request is forgotten
+	done    chan struct{}
+	outcome *idempotentOutcome
+}
+
+// idempotencyKeys remembers the outcomes of mutating requests by their
+// idempotency key. Entries are dropped once they are older than the window,
+// or, oldest first, once there are more than maxIdempotencyKeys of them.
+type idempotencyKeys struct {
+	window time.Duration
+
+	entries map[string]*idempotentRequest
+	order   []*idempotentRequest
+	mu      sync.Mutex
+}
+
+func newIdempotencyKeys(window time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		window:  window,
+		entries: make(map[string]*idempotentRequest),
+	}
+}
+
+func (k *idempotencyKeys) setWindow(window time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.window = window
+}
+
+// begin returns the request already made with the key, or, if there is none,
+// records and returns a new one for the caller to complete. Reusing a key for
+// a different request fails with an IdempotencyConflictError.
+func (k *idempotencyKeys) begin(key string, fingerprint [sha256.Size]byte) (*idempotentRequest, bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.prune()
+
+	if req, found := k.entries[key]; found {
+		if req.fingerprint != fingerprint {
+			return nil, false, garden.IdempotencyConflictError{Key: key}
+		}
+
+		return req, false, nil
+	}
+
+	req := &idempotentRequest{
+		key:         key,
+		fingerprint: fingerprint,
+		recordedAt:  time.Now(),
+		done:        make(chan struct{}),
+	}
+
+	k.entries[key] = req
+	k.order = append(k.order, req)
+
+	return req, true, nil
+}
+
+// complete records the outcome of a request, or forgets it if outcome is nil
+// so that a retry is executed again.
+func (k *idempotencyKeys) complete(req *idempotentRequest, outcome *idempotentOutcome) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	req.outcome = outcome
+	close(req.done)
+
+	if outcome == nil && k.entries[req.key] == req {
+		delete(k.entries, req.key)
+	}
+}
+
+// prune drops entries older than the window, then the oldest beyond
+// maxIdempotencyKeys. Requests still in flight are never dropped, so that a
+// retry waits for them rather than executing again.
+func (k *idempotencyKeys) prune() {
+	cutoff := time.Now().Add(-k.window)
+	excess := len(k.order) - maxIdempotencyKeys
+
+	kept := k.order[:0]
+	for i, req := range k.order {
+		if req.inFlight() {
+			kept = append(kept, req)
+			continue
+		}
+
+		if excess <= 0 && !req.recordedAt.Before(cutoff) {
+			// the rest are newer still
+			kept = append(kept, k.order[i:]...)
+			break
+		}
+
+		excess--
+
+		// the key may have been forgotten and reused since
+		if k.entries[req.key] == req {
+			delete(k.entries, req.key)
+		}
+	}
+
+	k.order = kept
+}
+
+func (req *idempotentRequest) inFlight() bool {
+	select {
+	case <-req.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// SetIdempotencyWindow sets how long the outcome of a mutating request made
+// with an idempotency key is replayed to retries of it. It defaults to ten
+// minutes.
+func (s *GardenServer) SetIdempotencyWindow(window time.Duration) {
+	s.idempotencyKeys.setWindow(window)
+}
+
+// idempotent makes a mutating handler honour the idempotency key header: a
+// retry of a request that was already made with the key gets the original
+// response rather than being executed again. Server errors are not
+// remembered, so a request that failed with one is executed again.
+func (s *GardenServer) idempotent(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(transport.IdempotencyKeyHeader)
+		if key == "" {
+			handler(w, r)
+			return
+		}
+
+		hLog := s.logger.Session("idempotency", lager.Data{
+			"key": key,
+		})
+
+		body, err := readIdempotentBody(r.Body, s.getRequestLimits().MaxIdempotentBodyBytes)
+		if err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		for {
+			req, owner, err := s.idempotencyKeys.begin(key, requestFingerprint(r, body))
+			if err != nil {
+				s.writeError(w, err, hLog)
+				return
+			}
+
+			if owner {
+				s.executeIdempotent(req, handler, w, r)
+				return
+			}
+
+			<-req.done
+
+			if req.outcome != nil {
+				hLog.Info("replaying")
+
+				s.writeOutcome(w, r, req.outcome)
+				return
+			}
+
+			// the original request was forgotten; try to make it ourselves
+		}
+	})
+}
+
+// executeIdempotent handles a request for the first time, recording its
+// outcome for retries. The outcome is recorded unencoded, and encoded for
+// each response as the request it answers accepts.
+func (s *GardenServer) executeIdempotent(req *idempotentRequest, handler http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	recorder := &outcomeRecorder{header: make(http.Header), statusCode: http.StatusOK}
+
+	var outcome *idempotentOutcome
+	defer func() {
+		s.idempotencyKeys.complete(req, outcome)
+	}()
+
+	unencoded := r.WithContext(r.Context())
+	unencoded.Header = r.Header.Clone()
+	unencoded.Header.Del("Accept-Encoding")
+
+	handler(recorder, unencoded)
+
+	recorded := &idempotentOutcome{
+		statusCode: recorder.statusCode,
+		header:     recorder.header,
+		body:       recorder.body.Bytes(),
+	}
+
+	s.writeOutcome(w, r, recorded)
+
+	if recorded.statusCode < http.StatusInternalServerError {
+		outcome = recorded
+	}
+}
+
+// writeOutcome writes a recorded response, gzipped as writeResponse would
+// for the request it answers.
+func (s *GardenServer) writeOutcome(w http.ResponseWriter, r *http.Request, outcome *idempotentOutcome) {
+	for name, values := range outcome.header {
+		w.Header()[name] = values
+	}
+
+	if outcome.header.Get("Content-Type") == "application/json" && s.compressionEnabled() {
+		writeCompressedBody(w, r, outcome.statusCode, outcome.body)
+		return
+	}
+
+	w.WriteHeader(outcome.statusCode)
+	w.Write(outcome.body)
+}
+
+// readIdempotentBody reads the body of a request made with an idempotency key,
+// failing as soon as it holds more than limit bytes.
+func readIdempotentBody(body io.Reader, limit int) ([]byte, error) {
+	if limit <= 0 {
+		return ioutil.ReadAll(body)
+	}
+
+	read, err := ioutil.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(read) > limit {
+		return nil, garden.RequestLimitExceededError{Field: garden.RequestBodyField, Limit: uint64(limit)}
+	}
+
+	return read, nil
+}
+
+// requestFingerprint tells requests reusing an idempotency key apart.
+func requestFingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+	h.Write(body)
+
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], h.Sum(nil))
+	return fingerprint
+}
+
+// outcomeRecorder keeps a response to write once the handler is done.
+type outcomeRecorder struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *outcomeRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *outcomeRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+}
+
+func (r *outcomeRecorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
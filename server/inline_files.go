@@ -0,0 +1,231 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/transport"
+	"code.cloudfoundry.org/lager"
+)
+
+// defaultInlineFileLimit bounds the files ReadFile and WriteFile transfer.
+const defaultInlineFileLimit = 1024 * 1024
+
+// writeFileEnvelopeBytes is the room a WriteFile request may take beyond its
+// base64 encoded data, for its path, mode and JSON punctuation.
+const writeFileEnvelopeBytes = 64 * 1024
+
+// defaultWriteFileMode is the mode of a file written without one.
+const defaultWriteFileMode os.FileMode = 0644
+
+// SetInlineFileLimit sets the largest file, in bytes, that ReadFile and
+// WriteFile transfer. Larger files fail with garden.FileTooLargeError. It
+// defaults to 1 MiB.
+func (s *GardenServer) SetInlineFileLimit(limit uint64) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.inlineFileLimit = limit
+}
+
+func (s *GardenServer) getInlineFileLimit() uint64 {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.inlineFileLimit
+}
+
+func (s *GardenServer) handleReadFile(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+	filePath := r.URL.Query().Get("path")
+
+	hLog := s.logger.Session("read-file", lager.Data{
+		"handle": handle,
+		"path":   filePath,
+	})
+
+	if err := garden.ValidateTargetPath(filePath, s.streamPathPolicy()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	hLog.Debug("reading")
+
+	tarStream, err := container.StreamOut(garden.StreamOutSpec{Path: filePath})
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+	defer tarStream.Close()
+
+	data, err := readSingleFile(tarStream, handle, filePath, s.getInlineFileLimit())
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	hLog.Info("read", lager.Data{"bytes": len(data)})
+
+	s.writeResponse(w, r, &transport.ReadFileResponse{Data: data})
+}
+
+func (s *GardenServer) handleWriteFile(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	// the data is checked against the limit once decoded, but the body must
+	// be bounded before it is held in memory
+	limit := s.getInlineFileLimit()
+	body := &bodyLimitReader{r: r.Body, limit: (limit+2)/3*4 + writeFileEnvelopeBytes}
+	r.Body = ioutil.NopCloser(body)
+
+	var request transport.WriteFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.writeError(w, body.wrap(err), s.logger)
+		return
+	}
+
+	hLog := s.logger.Session("write-file", lager.Data{
+		"handle": handle,
+		"path":   request.Path,
+		"mode":   request.Mode,
+	})
+
+	name, ok := fileName(request.Path)
+	if !ok {
+		s.writeError(w, garden.InvalidRequestError{Message: fmt.Sprintf("%q does not name a file", request.Path)}, hLog)
+		return
+	}
+
+	if err := garden.ValidateTargetPath(request.Path, s.streamPathPolicy()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	if uint64(len(request.Data)) > limit {
+		s.writeError(w, garden.FileTooLargeError{Handle: handle, Path: request.Path, Limit: limit}, hLog)
+		return
+	}
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	mode := request.Mode
+	if mode == 0 {
+		mode = defaultWriteFileMode
+	}
+
+	tarStream, err := singleFileTar(name, request.Data, mode)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	hLog.Debug("writing")
+
+	err = container.StreamIn(garden.StreamInSpec{
+		Path:      path.Dir(request.Path),
+		TarStream: tarStream,
+	})
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	hLog.Info("written")
+
+	s.writeSuccess(w)
+}
+
+// fileName returns the last element of a path naming a file, or false for an
+// empty path, the root or a path naming a directory.
+func fileName(filePath string) (string, bool) {
+	if filePath == "" || strings.HasSuffix(filePath, "/") {
+		return "", false
+	}
+
+	name := path.Base(filePath)
+	if name == "/" || name == "." || name == ".." {
+		return "", false
+	}
+
+	return name, true
+}
+
+// singleFileTar archives data as one file. Ownership is left to the backend,
+// which applies the container's user mapping as for any StreamIn.
+func singleFileTar(name string, data []byte, mode os.FileMode) (io.Reader, error) {
+	buf := new(bytes.Buffer)
+
+	tarWriter := tar.NewWriter(buf)
+
+	err := tarWriter.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tarWriter.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// readSingleFile returns the contents of the first entry of a StreamOut tar
+// stream, which must be a regular file no larger than limit.
+func readSingleFile(tarStream io.Reader, handle, filePath string, limit uint64) ([]byte, error) {
+	tarReader := tar.NewReader(tarStream)
+
+	header, err := tarReader.Next()
+	if err == io.EOF {
+		return nil, garden.FileNotFoundError{Handle: handle, Path: filePath}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Typeflag != tar.TypeReg {
+		return nil, garden.InvalidRequestError{Message: fmt.Sprintf("%s is not a regular file", filePath)}
+	}
+
+	if header.Size < 0 || uint64(header.Size) > limit {
+		return nil, garden.FileTooLargeError{Handle: handle, Path: filePath, Limit: limit}
+	}
+
+	return ioutil.ReadAll(tarReader)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// containerLocks holds the maintenance locks on containers. Expired locks are
+// dropped as they are looked up.
+type containerLocks struct {
+	entries map[string]garden.ContainerLock
+	mu      sync.Mutex
+}
+
+func newContainerLocks() *containerLocks {
+	return &containerLocks{
+		entries: make(map[string]garden.ContainerLock),
+	}
+}
+
+// lock takes the container's lock, renewing it if its holder has it already.
+// It fails with a ContainerLockedError if another holder has it.
+func (l *containerLocks) lock(handle string, lock garden.ContainerLock) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if held, locked := l.live(handle); locked && held.Holder != lock.Holder {
+		return garden.ContainerLockedError{Handle: handle, Lock: held}
+	}
+
+	l.entries[handle] = lock
+
+	return nil
+}
+
+// unlock releases the container's lock if the holder has it, or whoever has
+// it if forced. It fails with a ContainerLockedError if another holder has
+// it.
+func (l *containerLocks) unlock(handle, holder string, force bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if held, locked := l.live(handle); locked && !force && held.Holder != holder {
+		return garden.ContainerLockedError{Handle: handle, Lock: held}
+	}
+
+	delete(l.entries, handle)
+
+	return nil
+}
+
+// release drops the container's lock as it is destroyed.
+func (l *containerLocks) release(handle string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, handle)
+}
+
+func (l *containerLocks) lookup(handle string) (garden.ContainerLock, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.live(handle)
+}
+
+// live must be called with mu held.
+func (l *containerLocks) live(handle string) (garden.ContainerLock, bool) {
+	lock, found := l.entries[handle]
+	if !found {
+		return garden.ContainerLock{}, false
+	}
+
+	if !time.Now().Before(lock.ExpiresAt) {
+		delete(l.entries, handle)
+		return garden.ContainerLock{}, false
+	}
+
+	return lock, true
+}
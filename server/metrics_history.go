@@ -0,0 +1,290 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+// MetricsRecording configures the metrics history the server keeps for
+// containers created with RecordMetrics.
+//
+// Each recorded container costs one backend Metrics call per Interval, which
+// dwarfs storing the sample; BenchmarkMetricsSample measures the two together
+// against a backend that answers at once. Each sample held costs about 350
+// bytes, so the defaults bound the history of all containers to about 12 MiB.
+type MetricsRecording struct {
+	// Interval is how often a recorded container's metrics are sampled.
+	Interval time.Duration
+
+	// Samples bounds the history kept per container, the oldest samples
+	// being dropped first.
+	Samples int
+
+	// MaxSamples bounds the samples kept across all containers. A container
+	// is only recorded if its Samples fit alongside those of the containers
+	// already recording; the others report RecordingMetrics false in their
+	// info. Zero means no limit.
+	MaxSamples int
+
+	// TombstoneSamples is how many of the latest samples are kept in the
+	// tombstone of a destroyed container.
+	TombstoneSamples int
+}
+
+// DefaultMetricsRecording samples every ten seconds, keeping an hour of
+// history for up to 100 containers.
+var DefaultMetricsRecording = MetricsRecording{
+	Interval:         10 * time.Second,
+	Samples:          360,
+	MaxSamples:       36000,
+	TombstoneSamples: 30,
+}
+
+// metricsRecorder samples the metrics of the containers recording them.
+type metricsRecorder struct {
+	config     MetricsRecording
+	reserved   int
+	recordings map[string]*metricsRecording
+	mu         sync.Mutex
+}
+
+func newMetricsRecorder(config MetricsRecording) *metricsRecorder {
+	return &metricsRecorder{
+		config:     config,
+		recordings: make(map[string]*metricsRecording),
+	}
+}
+
+func (m *metricsRecorder) setConfig(config MetricsRecording) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = config
+}
+
+// start begins recording the container's metrics, reporting false if there
+// is no room for its samples. Recordings keep the configuration they were
+// started with.
+func (m *metricsRecorder) start(container garden.Container, logger lager.Logger) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	handle := container.Handle()
+	if _, found := m.recordings[handle]; found {
+		return true
+	}
+
+	config := m.config
+	if config.Interval <= 0 || config.Samples <= 0 {
+		return false
+	}
+
+	if config.MaxSamples > 0 && m.reserved+config.Samples > config.MaxSamples {
+		return false
+	}
+
+	recording := &metricsRecording{
+		config:  config,
+		samples: make([]garden.MetricsSample, 0, config.Samples),
+		stop:    make(chan struct{}),
+	}
+
+	m.reserved += config.Samples
+	m.recordings[handle] = recording
+
+	go m.sample(container, recording, logger.Session("recording-metrics", lager.Data{
+		"handle": handle,
+	}))
+
+	return true
+}
+
+// stop ends the container's recording, returning its latest samples for the
+// tombstone.
+func (m *metricsRecorder) stop(handle string) []garden.MetricsSample {
+	m.mu.Lock()
+	recording, found := m.recordings[handle]
+	if found {
+		m.release(handle, recording)
+	}
+	m.mu.Unlock()
+
+	if !found {
+		return nil
+	}
+
+	samples := recording.history(time.Time{})
+	if keep := recording.config.TombstoneSamples; len(samples) > keep {
+		samples = samples[len(samples)-keep:]
+	}
+
+	if len(samples) == 0 {
+		return nil
+	}
+
+	return samples
+}
+
+// stopAll ends every recording, as the server stops.
+func (m *metricsRecorder) stopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for handle, recording := range m.recordings {
+		m.release(handle, recording)
+	}
+}
+
+// release must be called with mu held.
+func (m *metricsRecorder) release(handle string, recording *metricsRecording) {
+	close(recording.stop)
+	delete(m.recordings, handle)
+	m.reserved -= recording.config.Samples
+}
+
+// recording tells whether the container's metrics are being recorded.
+func (m *metricsRecorder) recording(handle string) bool {
+	_, found := m.lookup(handle)
+	return found
+}
+
+func (m *metricsRecorder) lookup(handle string) (*metricsRecording, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recording, found := m.recordings[handle]
+	return recording, found
+}
+
+// history returns the container's samples taken after since, oldest first.
+func (m *metricsRecorder) history(handle string, since time.Time) []garden.MetricsSample {
+	recording, found := m.lookup(handle)
+	if !found {
+		return []garden.MetricsSample{}
+	}
+
+	return recording.history(since)
+}
+
+// latest returns the container's latest sample if it was taken within the
+// last interval, so that it can stand in for collecting the metrics afresh.
+func (m *metricsRecorder) latest(handle string) (garden.MetricsSample, bool) {
+	recording, found := m.lookup(handle)
+	if !found {
+		return garden.MetricsSample{}, false
+	}
+
+	sample, found := recording.latest()
+	if !found || time.Since(sample.SampledAt) > recording.config.Interval {
+		return garden.MetricsSample{}, false
+	}
+
+	return sample, true
+}
+
+func (m *metricsRecorder) sample(container garden.Container, recording *metricsRecording, logger lager.Logger) {
+	ticker := time.NewTicker(recording.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if !m.sampleOnce(container, recording, logger) {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-recording.stop:
+			return
+		}
+	}
+}
+
+// sampleOnce takes one sample of the container's metrics, reporting false if
+// the container has gone.
+func (m *metricsRecorder) sampleOnce(container garden.Container, recording *metricsRecording, logger lager.Logger) bool {
+	metrics, err := container.Metrics()
+	switch err.(type) {
+	case nil:
+		recording.record(garden.MetricsSample{
+			SampledAt: time.Now(),
+			Metrics:   metrics,
+		})
+
+	case garden.ContainerNotFoundError, garden.ContainerDestroyedError:
+		// destroyed behind the server's back; give up the room
+		logger.Info("container-gone")
+
+		m.mu.Lock()
+		if m.recordings[container.Handle()] == recording {
+			m.release(container.Handle(), recording)
+		}
+		m.mu.Unlock()
+
+		return false
+
+	default:
+		logger.Error("failed-to-sample", err)
+	}
+
+	return true
+}
+
+// metricsRecording holds a container's samples in a ring of the configured
+// size.
+type metricsRecording struct {
+	config MetricsRecording
+	stop   chan struct{}
+
+	samples []garden.MetricsSample
+	next    int
+	mu      sync.Mutex
+}
+
+func (r *metricsRecording) record(sample garden.MetricsSample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.samples) < cap(r.samples) {
+		r.samples = append(r.samples, sample)
+		return
+	}
+
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+func (r *metricsRecording) history(since time.Time) []garden.MetricsSample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := []garden.MetricsSample{}
+	for i := range r.samples {
+		sample := r.samples[(r.next+i)%len(r.samples)]
+		if sample.SampledAt.After(since) {
+			history = append(history, sample)
+		}
+	}
+
+	return history
+}
+
+func (r *metricsRecording) latest() (garden.MetricsSample, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.samples) == 0 {
+		return garden.MetricsSample{}, false
+	}
+
+	return r.samples[(r.next+len(r.samples)-1)%len(r.samples)], true
+}
+
+// SetMetricsRecording configures the metrics history kept for containers
+// created with RecordMetrics from then on. It defaults to
+// DefaultMetricsRecording.
+func (s *GardenServer) SetMetricsRecording(config MetricsRecording) {
+	s.metricsRecorder.setConfig(config)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager"
+)
+
+// BenchmarkMetricsSample measures a recording's work each interval: the
+// backend Metrics call, here to a backend that answers at once, and storing
+// its sample.
+func BenchmarkMetricsSample(b *testing.B) {
+	recorder := newMetricsRecorder(DefaultMetricsRecording)
+	recording := &metricsRecording{
+		config:  DefaultMetricsRecording,
+		samples: make([]garden.MetricsSample, 0, DefaultMetricsRecording.Samples),
+	}
+
+	container := new(gardenfakes.FakeContainer)
+	container.HandleReturns("some-handle")
+	container.MetricsReturns(garden.Metrics{MemoryStat: garden.ContainerMemoryStat{Cache: 1}}, nil)
+
+	logger := lager.NewLogger("benchmark")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder.sampleOnce(container, recording, logger)
+	}
+}
+
+func BenchmarkMetricsRecord(b *testing.B) {
+	recording := &metricsRecording{
+		samples: make([]garden.MetricsSample, 0, DefaultMetricsRecording.Samples),
+	}
+
+	sample := garden.MetricsSample{SampledAt: time.Now()}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recording.record(sample)
+	}
+}
+
+func BenchmarkMetricsHistory(b *testing.B) {
+	recording := &metricsRecording{
+		samples: make([]garden.MetricsSample, 0, DefaultMetricsRecording.Samples),
+	}
+
+	start := time.Now()
+	for i := 0; i < DefaultMetricsRecording.Samples; i++ {
+		recording.record(garden.MetricsSample{SampledAt: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recording.history(time.Time{})
+	}
+}
@@ -0,0 +1,394 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	defaultCaptureMaxBytes      = 1 << 20
+	defaultCaptureMaxBytesLimit = 64 << 20
+)
+
+// outputCaptures keeps the output of processes run with an OutputCapture, in
+// a directory per container under dir. A container's captures are removed
+// when it is destroyed, unless one of its processes asked for them to be
+// kept, in which case they are kept for as long as its tombstone.
+type outputCaptures struct {
+	dir       string
+	retention time.Duration
+
+	// the largest MaxBytes a process may ask for
+	maxBytesLimit uint64
+
+	// numbers the capture files of a container, as processes have no ID
+	// until they have started
+	seq uint64
+
+	live map[string]*containerCaptures
+	kept map[string]*containerCaptures
+
+	// kept captures, oldest first
+	keptOrder []*containerCaptures
+
+	mu sync.Mutex
+}
+
+type containerCaptures struct {
+	dir       string
+	processes map[string]*processCapture
+
+	keep        bool
+	destroyedAt time.Time
+}
+
+type processCapture struct {
+	container *containerCaptures
+
+	stdout *captureFile
+	stderr *captureFile
+}
+
+func newOutputCaptures(retention time.Duration) *outputCaptures {
+	return &outputCaptures{
+		retention:     retention,
+		maxBytesLimit: defaultCaptureMaxBytesLimit,
+		live:          make(map[string]*containerCaptures),
+		kept:          make(map[string]*containerCaptures),
+	}
+}
+
+func (c *outputCaptures) setDir(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dir = dir
+}
+
+func (c *outputCaptures) setRetention(retention time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.retention = retention
+}
+
+func (c *outputCaptures) setMaxBytesLimit(limit uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytesLimit = limit
+}
+
+// begin creates the capture files for a process about to be run in the
+// container. Once the process has started, the capture is registered under
+// its ID; if it fails to start, the capture is discarded.
+func (c *outputCaptures) begin(handle string, spec garden.OutputCapture, logger lager.Logger) (*processCapture, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil, garden.UnsupportedOperationError{Message: "output capture is not enabled on this server"}
+	}
+
+	container, found := c.live[handle]
+	if !found {
+		dir, err := ioutil.TempDir(c.dir, "container-")
+		if err != nil {
+			return nil, err
+		}
+
+		container = &containerCaptures{
+			dir:       dir,
+			processes: make(map[string]*processCapture),
+		}
+
+		c.live[handle] = container
+	}
+
+	if spec.KeepCapturedLogs {
+		container.keep = true
+	}
+
+	maxBytes := spec.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = defaultCaptureMaxBytes
+	}
+
+	if maxBytes > c.maxBytesLimit {
+		logger.Info("limiting-captured-output", lager.Data{"max-bytes": maxBytes, "limit": c.maxBytesLimit})
+		maxBytes = c.maxBytesLimit
+	}
+
+	c.seq++
+	name := filepath.Join(container.dir, strconv.FormatUint(c.seq, 10))
+
+	stdout, err := createCaptureFile(name+".stdout", maxBytes, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	stderr, err := createCaptureFile(name+".stderr", maxBytes, logger)
+	if err != nil {
+		stdout.remove()
+		return nil, err
+	}
+
+	return &processCapture{
+		container: container,
+		stdout:    stdout,
+		stderr:    stderr,
+	}, nil
+}
+
+func (c *outputCaptures) register(capture *processCapture, processID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	capture.container.processes[processID] = capture
+}
+
+// lookup finds the capture of a process, in a live container or in one
+// whose captures were kept.
+func (c *outputCaptures) lookup(handle, processID string) (*processCapture, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune()
+
+	for _, containers := range []map[string]*containerCaptures{c.live, c.kept} {
+		if container, found := containers[handle]; found {
+			if capture, found := container.processes[processID]; found {
+				return capture, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// release removes a destroyed container's captures, or keeps them for the
+// retention if asked to.
+func (c *outputCaptures) release(handle string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	container, found := c.live[handle]
+	if !found {
+		return
+	}
+
+	delete(c.live, handle)
+
+	if !container.keep {
+		os.RemoveAll(container.dir)
+		return
+	}
+
+	container.destroyedAt = time.Now()
+
+	// the handle may have been destroyed before with its captures kept
+	if previous, found := c.kept[handle]; found {
+		os.RemoveAll(previous.dir)
+	}
+
+	c.kept[handle] = container
+	c.keptOrder = append(c.keptOrder, container)
+
+	c.prune()
+}
+
+func (c *outputCaptures) prune() {
+	cutoff := time.Now().Add(-c.retention)
+
+	for len(c.keptOrder) > 0 && c.keptOrder[0].destroyedAt.Before(cutoff) {
+		oldest := c.keptOrder[0]
+		c.keptOrder = c.keptOrder[1:]
+
+		os.RemoveAll(oldest.dir)
+
+		for handle, container := range c.kept {
+			if container == oldest {
+				delete(c.kept, handle)
+			}
+		}
+	}
+}
+
+func (p *processCapture) stream(name string) (*captureFile, bool) {
+	switch name {
+	case garden.CapturedStdout:
+		return p.stdout, true
+	case garden.CapturedStderr:
+		return p.stderr, true
+	default:
+		return nil, false
+	}
+}
+
+// close stops capturing once the process has exited.
+func (p *processCapture) close() {
+	p.stdout.close()
+	p.stderr.close()
+}
+
+// discard removes the capture of a process that failed to start.
+func (p *processCapture) discard() {
+	p.stdout.remove()
+	p.stderr.remove()
+}
+
+// captureFile captures one stream of a process's output. Once the file would
+// exceed maxBytes it is rotated to path.1, replacing the file rotated before,
+// so that neither file is ever larger than maxBytes.
+//
+// Writes never fail, so that capturing cannot disturb the process or the
+// streaming of its output; output that cannot be written is lost.
+type captureFile struct {
+	path     string
+	maxBytes uint64
+	logger   lager.Logger
+
+	// nil once closed
+	file *os.File
+	size uint64
+
+	mu sync.Mutex
+}
+
+func createCaptureFile(path string, maxBytes uint64, logger lager.Logger) (*captureFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &captureFile{
+		path:     path,
+		maxBytes: maxBytes,
+		logger:   logger,
+		file:     file,
+	}, nil
+}
+
+func (f *captureFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil && f.size > 0 && f.size+uint64(len(p)) > f.maxBytes {
+		f.rotate()
+	}
+
+	if f.file == nil {
+		return len(p), nil
+	}
+
+	written := len(p)
+
+	// a write larger than the cap keeps only its most recent output, as
+	// rotating would
+	if space := f.maxBytes - f.size; uint64(len(p)) > space {
+		p = p[uint64(len(p))-space:]
+	}
+
+	n, err := f.file.Write(p)
+	f.size += uint64(n)
+
+	if err != nil {
+		f.logger.Error("failed-to-capture-output", err, lager.Data{"path": f.path})
+	}
+
+	return written, nil
+}
+
+func (f *captureFile) rotate() {
+	f.logger.Info("rotating-captured-output", lager.Data{"path": f.path, "size": f.size})
+
+	f.file.Close()
+	f.file = nil
+	f.size = 0
+
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		f.logger.Error("failed-to-rotate-captured-output", err, lager.Data{"path": f.path})
+		return
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		f.logger.Error("failed-to-rotate-captured-output", err, lager.Data{"path": f.path})
+		return
+	}
+
+	f.file = file
+}
+
+// open reads the captured output: the rotated file's, then the current
+// file's.
+func (f *captureFile) open() (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	files := []*os.File{}
+	for _, path := range []string{f.path + ".1", f.path} {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			for _, file := range files {
+				file.Close()
+			}
+
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	readers := make([]io.Reader, len(files))
+	for i, file := range files {
+		readers[i] = file
+	}
+
+	return captureReader{Reader: io.MultiReader(readers...), files: files}, nil
+}
+
+func (f *captureFile) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+func (f *captureFile) remove() {
+	f.close()
+
+	os.Remove(f.path)
+	os.Remove(f.path + ".1")
+}
+
+type captureReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (r captureReader) Close() error {
+	var err error
+	for _, file := range r.files {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/garden"
+)
+
+func validateProcessLimits(backend garden.Backend, container garden.Container, limits *garden.ProcessLimits) error {
+	if limits == nil {
+		return nil
+	}
+
+	if limiter, ok := backend.(garden.ProcessLimiter); !ok || !limiter.LimitsProcesses() {
+		return garden.UnsupportedOperationError{Message: "the backend does not support process_limits"}
+	}
+
+	if limits.MemoryInBytes > 0 {
+		memoryLimits, err := container.CurrentMemoryLimits()
+		if err != nil {
+			return err
+		}
+
+		if memoryLimits.LimitInBytes > 0 && limits.MemoryInBytes > memoryLimits.LimitInBytes {
+			return garden.InvalidRequestError{Message: fmt.Sprintf("process memory limit %d exceeds container memory limit %d", limits.MemoryInBytes, memoryLimits.LimitInBytes)}
+		}
+	}
+
+	if limits.CPUShares > 0 {
+		cpuLimits, err := container.CurrentCPULimits()
+		if err != nil {
+			return err
+		}
+
+		if cpuLimits.LimitInShares > 0 && limits.CPUShares > cpuLimits.LimitInShares {
+			return garden.InvalidRequestError{Message: fmt.Sprintf("process cpu shares %d exceed container cpu shares %d", limits.CPUShares, cpuLimits.LimitInShares)}
+		}
+	}
+
+	return nil
+}
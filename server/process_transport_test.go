@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Running and attaching without a hijackable connection", func() {
+	var (
+		server        *GardenServer
+		fakeBackend   *gardenfakes.FakeBackend
+		fakeContainer *gardenfakes.FakeContainer
+		recorder      *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		fakeContainer = new(gardenfakes.FakeContainer)
+		fakeContainer.HandleReturns("some-handle")
+
+		fakeBackend = new(gardenfakes.FakeBackend)
+		fakeBackend.LookupReturns(fakeContainer, nil)
+
+		server = New("tcp", "127.0.0.1:0", time.Minute, fakeBackend, lagertest.NewTestLogger("test"))
+
+		recorder = httptest.NewRecorder()
+	})
+
+	serve := func(method, path, body string) {
+		request, err := http.NewRequest(method, path, strings.NewReader(body))
+		Ω(err).ShouldNot(HaveOccurred())
+		request.Header.Set("Content-Type", "application/json")
+
+		// an HTTP/2 server's writer can be flushed but not hijacked
+		server.server.Handler.ServeHTTP(flushOnly{recorder}, request)
+	}
+
+	expectUnsupported := func() {
+		Ω(recorder.Code).Should(Equal(http.StatusNotImplemented))
+
+		var gardenErr garden.Error
+		Ω(json.Unmarshal(recorder.Body.Bytes(), &gardenErr)).Should(Succeed())
+		Ω(gardenErr.Err).Should(Equal(errProcessTransportUnsupported))
+	}
+
+	Describe("Run", func() {
+		It("responds with a single typed error and runs nothing", func() {
+			serve("POST", "/containers/some-handle/processes", `{"path":"some-path"}`)
+
+			expectUnsupported()
+			Ω(fakeContainer.RunCallCount()).Should(BeZero())
+		})
+	})
+
+	Describe("Attach", func() {
+		It("responds with a single typed error and attaches to nothing", func() {
+			serve("GET", "/containers/some-handle/processes/some-pid", "")
+
+			expectUnsupported()
+			Ω(fakeContainer.AttachCallCount()).Should(BeZero())
+		})
+	})
+})
+
+// flushOnly hides every optional interface of the wrapped writer but Flusher
+type flushOnly struct {
+	w *httptest.ResponseRecorder
+}
+
+func (f flushOnly) Header() http.Header         { return f.w.Header() }
+func (f flushOnly) Write(b []byte) (int, error) { return f.w.Write(b) }
+func (f flushOnly) WriteHeader(code int)        { f.w.WriteHeader(code) }
+func (f flushOnly) Flush()                      { f.w.Flush() }
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+// propertyMutations serializes the property mutations made on each container,
+// so that a compare-and-set sees no other change between its read and its
+// write. Containers with nothing in flight hold no entry.
+type propertyMutations struct {
+	entries map[string]*propertyMutation
+	mu      sync.Mutex
+}
+
+type propertyMutation struct {
+	sync.Mutex
+	waiters int
+}
+
+func newPropertyMutations() *propertyMutations {
+	return &propertyMutations{
+		entries: make(map[string]*propertyMutation),
+	}
+}
+
+// begin waits for the container's other property mutations to finish. The
+// returned func must be called once the mutation is done.
+func (m *propertyMutations) begin(handle string) func() {
+	m.mu.Lock()
+	entry, found := m.entries[handle]
+	if !found {
+		entry = &propertyMutation{}
+		m.entries[handle] = entry
+	}
+	entry.waiters++
+	m.mu.Unlock()
+
+	entry.Lock()
+
+	return func() {
+		entry.Unlock()
+
+		m.mu.Lock()
+		if entry.waiters--; entry.waiters == 0 {
+			delete(m.entries, handle)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// compareAndSet sets the container's property to newValue if it currently
+// holds oldValue. A property that is not set matches an empty oldValue.
+func (m *propertyMutations) compareAndSet(container garden.Container, name, oldValue, newValue string) (bool, error) {
+	done := m.begin(container.Handle())
+	defer done()
+
+	properties, err := container.Properties()
+	if err != nil {
+		return false, err
+	}
+
+	if properties[name] != oldValue {
+		return false, nil
+	}
+
+	if err := container.SetProperty(name, newValue); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setMultiple sets all of the properties or, if one cannot be set, none of
+// them: those already set are restored to what they were.
+func (m *propertyMutations) setMultiple(container garden.Container, properties garden.Properties, logger lager.Logger) error {
+	done := m.begin(container.Handle())
+	defer done()
+
+	before, err := container.Properties()
+	if err != nil {
+		return err
+	}
+
+	var applied []string
+	for _, name := range sortedNames(properties) {
+		if err := container.SetProperty(name, properties[name]); err != nil {
+			restoreProperties(container, before, applied, logger)
+			return err
+		}
+
+		applied = append(applied, name)
+	}
+
+	return nil
+}
+
+// removeMultiple removes all of the named properties or, if one cannot be
+// removed, none of them: those already removed are restored.
+func (m *propertyMutations) removeMultiple(container garden.Container, names []string, logger lager.Logger) error {
+	done := m.begin(container.Handle())
+	defer done()
+
+	before, err := container.Properties()
+	if err != nil {
+		return err
+	}
+
+	var applied []string
+	for _, name := range names {
+		if _, found := before[name]; !found {
+			continue
+		}
+
+		if err := container.RemoveProperty(name); err != nil {
+			restoreProperties(container, before, applied, logger)
+			return err
+		}
+
+		applied = append(applied, name)
+	}
+
+	return nil
+}
+
+// restoreProperties puts the named properties back to the values they had
+// before, removing those that were not set.
+func restoreProperties(container garden.Container, before garden.Properties, names []string, logger lager.Logger) {
+	for _, name := range names {
+		var err error
+		if value, found := before[name]; found {
+			err = container.SetProperty(name, value)
+		} else {
+			err = container.RemoveProperty(name)
+		}
+
+		if err != nil {
+			logger.Error("failed-to-restore-property", err)
+		}
+	}
+}
+
+func sortedNames(properties garden.Properties) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"io"
+	"math"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// quotaReader fails the read that would take it past limit bytes with err, so
+// that a stream is aborted the moment it exceeds the container's disk quota,
+// or the size it claimed if that is smaller. A stream that claimed its size
+// must also reach it: one that ends short fails its EOF with err. It counts
+// the bytes of the tar stream, headers and padding included, not what they
+// extract to. Removing what was extracted before the abort is left to the
+// backend, which alone knows what that is.
+type quotaReader struct {
+	r      io.Reader
+	limit  uint64
+	err    error
+	exact  bool
+	failed bool
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	if q.failed {
+		return 0, q.err
+	}
+
+	n, err := q.r.Read(p)
+	if uint64(n) > q.limit {
+		q.failed = true
+		n = int(q.limit)
+		q.limit = 0
+		return n, q.err
+	}
+
+	q.limit -= uint64(n)
+
+	if err == io.EOF && q.exact && q.limit > 0 {
+		q.failed = true
+		return n, q.err
+	}
+
+	return n, err
+}
+
+// remainingDiskQuota returns how many more bytes the container may use, and
+// whether it has a disk quota at all. It reports no quota along with any
+// error.
+func remainingDiskQuota(container garden.Container) (uint64, bool, error) {
+	limits, err := container.CurrentDiskLimits()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if limits.ByteHard == 0 {
+		return math.MaxUint64, false, nil
+	}
+
+	metrics, err := container.Metrics()
+	if err != nil {
+		return 0, false, err
+	}
+
+	used := metrics.DiskStat.TotalBytesUsed
+	if limits.Scope == garden.DiskLimitScopeExclusive {
+		used = metrics.DiskStat.ExclusiveBytesUsed
+	}
+
+	if used >= limits.ByteHard {
+		return 0, true, nil
+	}
+
+	return limits.ByteHard - used, true, nil
+}
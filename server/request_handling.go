@@ -1,15 +1,23 @@
 package server
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/server/streamer"
 	"code.cloudfoundry.org/garden/transport"
 	"code.cloudfoundry.org/lager"
 )
@@ -20,6 +28,9 @@ type processDebugInfo struct {
 	User   string
 	Limits garden.ResourceLimits
 	TTY    *garden.TTYSpec
+
+	ProcessLimits *garden.ProcessLimits
+	OutputCapture *garden.OutputCapture
 }
 
 type containerDebugInfo struct {
@@ -30,10 +41,21 @@ type containerDebugInfo struct {
 	Network    string
 	Privileged bool
 	Limits     garden.Limits
+
+	NetworkFrom        string
+	IsolateIntraSubnet bool
 }
 
 var ErrConcurrentDestroy = errors.New("container already being destroyed")
 
+// errProcessTransportUnsupported is returned by Run and Attach when the
+// connection cannot be hijacked, such as behind an HTTP/2 proxy. Unlike the
+// output streams they have no chunked fallback, as stdin flows over the
+// connection too.
+var errProcessTransportUnsupported = garden.UnsupportedOperationError{
+	Message: "running and attaching to processes needs a connection that can be hijacked, which HTTP/2 does not allow",
+}
+
 func (s *GardenServer) handlePing(w http.ResponseWriter, r *http.Request) {
 	hLog := s.logger.Session("ping")
 
@@ -55,12 +77,25 @@ func (s *GardenServer) handleCapacity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeResponse(w, capacity)
+	s.writeResponse(w, r, capacity)
+}
+
+func (s *GardenServer) handleRouteTable(w http.ResponseWriter, r *http.Request) {
+	s.writeResponse(w, r, transport.DescribeRoutes())
+}
+
+func (s *GardenServer) handleAPISpec(w http.ResponseWriter, r *http.Request) {
+	s.writeResponse(w, r, transport.APISpecResponse{
+		Routes:  transport.DescribeRoutes(),
+		Schemas: transport.Schemas(s.getRequestLimits().schemaLimits()),
+
+		PropertySchemas: s.getPropertySchemas(),
+	})
 }
 
 func (s *GardenServer) handleCreate(w http.ResponseWriter, r *http.Request) {
 	var spec garden.ContainerSpec
-	if !s.readRequest(&spec, w, r) {
+	if !s.readLimitedRequest(&spec, w, r) {
 		return
 	}
 
@@ -73,13 +108,105 @@ func (s *GardenServer) handleCreate(w http.ResponseWriter, r *http.Request) {
 			Network:    spec.Network,
 			Privileged: spec.Privileged,
 			Limits:     spec.Limits,
+
+			NetworkFrom:        spec.NetworkFrom,
+			IsolateIntraSubnet: spec.IsolateIntraSubnet,
 		},
 	})
 
+	query := r.URL.Query()
+
+	echoInfo := query.Get("echo_info") == "true"
+	echoSpec := query.Get("echo_effective_spec") == "true"
+
+	specFields, err := parseEffectiveSpecFields(query.Get("effective_spec_fields"))
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	if spec.Privileged && !s.privilegedAllowed() {
+		s.writeError(w, garden.PrivilegedContainersDisabledError{}, hLog)
+		return
+	}
+
+	if err := validatePropertyValues(spec.Properties, s.getPropertySchemas()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	pathPolicy := s.getPathPolicy()
+
+	for _, bindMount := range spec.BindMounts {
+		if err := garden.ValidateTargetPath(bindMount.DstPath, pathPolicy); err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+	}
+
+	if err := validateScratchSpaces(spec, pathPolicy); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	if err := checkBackendSupports(s.backend, spec); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	if spec.Handle != "" {
+		if err := s.checkNotDestroying(spec.Handle); err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+	}
+
+	sharedSubnet, endShare, err := s.shareNetworkFrom(spec)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	defer endShare()
+
+	if sharedSubnet != "" {
+		hLog.Info("sharing-subnet", lager.Data{"network-from": spec.NetworkFrom, "subnet": sharedSubnet})
+	}
+
+	var warnings []string
+
+	if isolationWithoutPeers(spec) {
+		hLog.Info("isolation-has-no-effect", lager.Data{
+			"network": spec.Network,
+			"reason":  "the subnet has no room for peers",
+		})
+
+		warnings = append(warnings, fmt.Sprintf("isolate_intra_subnet has no effect: network %s has no room for peers", spec.Network))
+	}
+
+	if generator := s.getHandleGenerator(); spec.Handle == "" && generator != nil {
+		handle, err := s.generateHandle(generator, spec)
+		if err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+
+		spec.Handle = handle
+	}
+
 	if spec.GraceTime == 0 {
 		spec.GraceTime = s.containerGraceTime
 	}
 
+	if sharedSubnet != "" {
+		spec.Network = sharedSubnet
+		spec.NetworkFrom = ""
+	}
+
+	if spec.RecordMetrics {
+		spec.Properties = withProperty(spec.Properties, garden.RecordMetricsProperty, "true")
+	}
+
 	hLog.Debug("creating")
 
 	container, err := s.backend.Create(spec)
@@ -90,11 +217,54 @@ func (s *GardenServer) handleCreate(w http.ResponseWriter, r *http.Request) {
 
 	hLog.Info("created")
 
+	if subnet := subnetAddress(spec.Network); subnet != "" {
+		s.destroysL.Lock()
+		s.subnets[container.Handle()] = subnet
+		s.destroysL.Unlock()
+	}
+
 	s.bomberman.Strap(container)
 
-	s.writeResponse(w, &struct{ Handle string }{
-		Handle: container.Handle(),
-	})
+	if spec.RecordMetrics && !s.metricsRecorder.start(container, hLog) {
+		hLog.Info("not-recording-metrics", lager.Data{
+			"reason": "no room for its samples",
+		})
+
+		warnings = append(warnings, "record_metrics has no effect: the server has no room for its samples")
+	}
+
+	response := &transport.CreateResponse{
+		Handle:   container.Handle(),
+		Warnings: warnings,
+	}
+
+	if echoInfo || echoSpec {
+		info, err := container.Info()
+		if err != nil {
+			// the caller never learns the handle, so don't leave it behind
+			if destroyErr := s.destroy(container.Handle(), garden.DestroyReasonCreateRollback, hLog); destroyErr != nil {
+				hLog.Error("failed-to-destroy", destroyErr)
+			}
+
+			s.writeError(w, err, hLog)
+			return
+		}
+
+		info.RecordingMetrics = s.metricsRecorder.recording(container.Handle())
+		info.Network = s.subnet(container.Handle())
+		info.Warnings = warnings
+
+		if echoInfo {
+			response.Info = &info
+		}
+
+		if echoSpec {
+			effective := maskEffectiveSpec(effectiveSpec(spec, container.Handle(), info), specFields)
+			response.EffectiveSpec = &effective
+		}
+	}
+
+	s.writeResponse(w, r, response)
 }
 
 func (s *GardenServer) handleList(w http.ResponseWriter, r *http.Request) {
@@ -114,15 +284,123 @@ func (s *GardenServer) handleList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handles := []string{}
+	handles := sortedHandles(containers)
+
+	hLog.Debug("ending", lager.Data{"handles": handles})
+
+	s.writeResponse(w, r, &transport.ListResponse{Handles: handles})
+}
+
+func (s *GardenServer) handleListPage(w http.ResponseWriter, r *http.Request) {
+	var request transport.ListPageRequest
+	if !s.readLimitedRequest(&request, w, r) {
+		return
+	}
 
+	hLog := s.logger.Session("list-page", lager.Data{
+		"limit": request.Limit,
+		"token": request.Token,
+	})
+	hLog.Debug("started")
+
+	after, err := decodeListToken(request.Token)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	if err := request.Filter.Validate(); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	containers, err := s.listFiltered(request.Properties, request.Filter)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	page := pageHandles(sortedHandles(containers), after, request.Limit)
+
+	hLog.Debug("ending", lager.Data{"handles": page.Handles})
+
+	s.writeResponse(w, r, &page)
+}
+
+// listFiltered lists the containers with the properties that the filter
+// matches. The backend narrows them down by the properties and the filter's
+// equalities, and the server matches the rest.
+func (s *GardenServer) listFiltered(properties garden.Properties, filter garden.PropertyFilter) ([]garden.Container, error) {
+	if len(filter) == 0 {
+		return s.backend.Containers(properties)
+	}
+
+	schemas := s.getPropertySchemas()
+
+	narrowed := filter.Equalities()
+	for name := range narrowed {
+		// the backend knows nothing of the paths into structured properties
+		if isStructuredPath(name, schemas) {
+			delete(narrowed, name)
+		}
+	}
+
+	for name, value := range properties {
+		narrowed[name] = value
+	}
+
+	containers, err := s.backend.Containers(narrowed)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []garden.Container{}
 	for _, container := range containers {
-		handles = append(handles, container.Handle())
+		containerProperties, err := container.Properties()
+		if err != nil {
+			return nil, err
+		}
+
+		if filter.Matches(withStructuredPaths(containerProperties, schemas)) {
+			matched = append(matched, container)
+		}
 	}
 
-	hLog.Debug("ending", lager.Data{"handles": handles})
+	return matched, nil
+}
+
+// pageHandles returns up to limit of the sorted handles that follow after.
+// Continuing from the last handle rather than an offset keeps pages stable
+// while containers come and go.
+func pageHandles(handles []string, after string, limit uint64) garden.ContainerPage {
+	start := 0
+	if after != "" {
+		start = sort.Search(len(handles), func(i int) bool { return handles[i] > after })
+	}
+
+	handles = handles[start:]
+
+	page := garden.ContainerPage{Handles: handles}
+	if limit > 0 && uint64(len(handles)) > limit {
+		page.Handles = handles[:limit]
+		page.NextToken = encodeListToken(page.Handles[limit-1])
+	}
+
+	return page
+}
+
+// list tokens are opaque to clients; they hold the last handle of the page
+func encodeListToken(lastHandle string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastHandle))
+}
+
+func decodeListToken(token string) (string, error) {
+	lastHandle, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || (token != "" && len(lastHandle) == 0) {
+		return "", garden.InvalidRequestError{Message: fmt.Sprintf("invalid list token: %q", token)}
+	}
 
-	s.writeResponse(w, &struct{ Handles []string }{handles})
+	return string(lastHandle), nil
 }
 
 func (s *GardenServer) handleDestroy(w http.ResponseWriter, r *http.Request) {
@@ -132,53 +410,515 @@ func (s *GardenServer) handleDestroy(w http.ResponseWriter, r *http.Request) {
 		"handle": handle,
 	})
 
+	// forcing is for administrators to destroy a container despite its lock
+	force := r.URL.Query().Get("force") == "true"
+	async := r.URL.Query().Get("async") == "true"
+
+	// an async destroy cannot report the backend's failure to find the
+	// container, so it looks it up first
+	if async {
+		if _, err := s.backend.Lookup(handle); err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+	}
+
+	requestedAt, err := s.beginAPIDestroy(handle, force, hLog)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	if async {
+		s.asyncDestroys.Add(1)
+		go func() {
+			defer s.asyncDestroys.Done()
+
+			if err := s.teardown(handle, garden.DestroyReasonAPI, requestedAt, hLog); err != nil {
+				hLog.Error("failed", err)
+				s.tombstones.recordFailure(handle, garden.DestroyReasonAPI, requestedAt, err)
+			}
+		}()
+
+		s.writeSuccess(w)
+		return
+	}
+
+	err = s.teardown(handle, garden.DestroyReasonAPI, requestedAt, hLog)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.writeSuccess(w)
+}
+
+func (s *GardenServer) handleTombstone(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := s.logger.Session("tombstone", lager.Data{
+		"handle": handle,
+	})
+
+	tombstone, found := s.tombstones.lookup(handle)
+	if !found {
+		s.writeError(w, garden.ContainerNotFoundError{Handle: handle}, hLog)
+		return
+	}
+
+	s.writeResponse(w, r, tombstone)
+}
+
+func (s *GardenServer) handleDestroyMatching(w http.ResponseWriter, r *http.Request) {
+	var request transport.DestroyMatchingRequest
+	if !s.readLimitedRequest(&request, w, r) {
+		return
+	}
+
+	hLog := s.logger.Session("destroy-matching", lager.Data{
+		"dry-run": request.DryRun,
+	})
+
+	if len(request.Properties) == 0 && !request.All {
+		s.writeError(w, garden.InvalidRequestError{Message: "an empty filter matches every container; set all to destroy them all"}, hLog)
+		return
+	}
+
+	containers, err := s.backend.Containers(request.Properties)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	matching := sortedHandles(containers)
+
+	if request.DryRun {
+		hLog.Info("matched", lager.Data{"handles": matching})
+
+		s.writeResponse(w, r, &transport.DestroyMatchingResponse{Handles: matching})
+		return
+	}
+
+	destroyed := []string{}
+	failures := map[string]*garden.Error{}
+
+	for _, handle := range matching {
+		dLog := hLog.Session("destroy", lager.Data{"handle": handle})
+
+		requestedAt, err := s.beginAPIDestroy(handle, false, dLog)
+		if err == nil {
+			err = s.teardown(handle, garden.DestroyReasonAPI, requestedAt, dLog)
+		}
+
+		if err != nil {
+			failures[handle] = &garden.Error{Err: err}
+			continue
+		}
+
+		destroyed = append(destroyed, handle)
+	}
+
+	hLog.Info("destroyed", lager.Data{"handles": destroyed})
+
+	s.writeResponse(w, r, &transport.DestroyMatchingResponse{
+		Handles: destroyed,
+		Errors:  failures,
+	})
+}
+
+func (s *GardenServer) destroy(handle, reason string, hLog lager.Logger) error {
+	requestedAt, ok := s.beginDestroy(handle)
+	if !ok {
+		return ErrConcurrentDestroy
+	}
+
+	return s.teardown(handle, reason, requestedAt, hLog)
+}
+
+// beginAPIDestroy marks the handle as being destroyed on behalf of a client,
+// failing if it already is, or if the container is locked and the destroy is
+// not forced.
+func (s *GardenServer) beginAPIDestroy(handle string, force bool, hLog lager.Logger) (time.Time, error) {
+	requestedAt, ok := s.beginDestroy(handle)
+	if !ok {
+		return time.Time{}, ErrConcurrentDestroy
+	}
+
+	lock, locked := s.locks.lookup(handle)
+	if !locked {
+		return requestedAt, nil
+	}
+
+	if !force {
+		s.finishDestroy(handle, requestedAt, false)
+		return time.Time{}, garden.ContainerLockedError{Handle: handle, Lock: lock}
+	}
+
+	hLog.Info("forcing-past-lock", lager.Data{
+		"holder": lock.Holder,
+		"reason": lock.Reason,
+	})
+
+	return requestedAt, nil
+}
+
+// beginDestroy marks the handle as being destroyed, reporting false if it
+// already is.
+func (s *GardenServer) beginDestroy(handle string) (time.Time, bool) {
+	s.destroysL.Lock()
+	defer s.destroysL.Unlock()
+
+	if _, alreadyDestroying := s.destroys[handle]; alreadyDestroying {
+		return time.Time{}, false
+	}
+
+	requestedAt := time.Now()
+	s.destroys[handle] = requestedAt
+
+	return requestedAt, true
+}
+
+// finishDestroy unmarks the handle, folding how long a successful destroy
+// took into the estimate given to creates that have to wait for one.
+func (s *GardenServer) finishDestroy(handle string, requestedAt time.Time, succeeded bool) {
+	s.destroysL.Lock()
+	defer s.destroysL.Unlock()
+
+	delete(s.destroys, handle)
+
+	if !succeeded {
+		return
+	}
+
+	took := time.Since(requestedAt)
+	if s.destroyDuration == 0 {
+		s.destroyDuration = took
+	} else {
+		s.destroyDuration = (7*s.destroyDuration + took) / 8
+	}
+}
+
+// checkNotDestroying fails with a HandleStillDestroyingError if a container
+// with the handle is being destroyed.
+func (s *GardenServer) checkNotDestroying(handle string) error {
+	s.destroysL.Lock()
+	defer s.destroysL.Unlock()
+
+	requestedAt, destroying := s.destroys[handle]
+	if !destroying {
+		return nil
+	}
+
+	return garden.HandleStillDestroyingError{
+		Handle:              handle,
+		EstimatedCompletion: requestedAt.Add(s.destroyDuration),
+	}
+}
+
+func (s *GardenServer) isDestroying(handle string) bool {
+	s.destroysL.Lock()
+	defer s.destroysL.Unlock()
+
+	_, destroying := s.destroys[handle]
+	return destroying
+}
+
+// teardown destroys a container marked by beginDestroy, recording its
+// tombstone once the backend has released everything. The handle stays
+// marked until everything the server kept for the container is released, so
+// that a create reusing it cannot race the cleanup.
+func (s *GardenServer) teardown(handle, reason string, requestedAt time.Time, hLog lager.Logger) error {
+	s.awaitSharers(handle)
+
+	hLog.Debug("destroying")
+
+	err := s.backend.Destroy(handle)
+	if err != nil {
+		s.finishDestroy(handle, requestedAt, false)
+		return err
+	}
+
+	hLog.Info("destroyed")
+
+	s.forget(handle, reason, requestedAt)
+
+	s.finishDestroy(handle, requestedAt, true)
+
+	return nil
+}
+
+// forget records the tombstone of a container the backend has destroyed, and
+// releases everything the server kept for it.
+func (s *GardenServer) forget(handle, reason string, requestedAt time.Time) {
+	s.tombstones.record(handle, reason, requestedAt, s.metricsRecorder.stop(handle))
+
+	s.outputCaptures.release(handle)
+
+	s.locks.release(handle)
+
+	s.bomberman.Defuse(handle)
+
+	s.destroysL.Lock()
+	for watcher := range s.destroyWatchers[handle] {
+		close(watcher)
+	}
+	delete(s.destroyWatchers, handle)
+	delete(s.subnets, handle)
+	s.destroysL.Unlock()
+}
+
+// shareNetworkFrom checks that the container whose subnet is to be shared
+// exists and is not on its way out, before the backend allocates anything,
+// and returns the subnet it is on. The subnet is empty if the server does not
+// know the container's, as when the backend allocated it from its pool; the
+// backend resolves it then.
+//
+// The container is held until endShare is called: a destroy of it waits for
+// that before the backend releases its subnet, so that the create cannot race
+// it.
+func (s *GardenServer) shareNetworkFrom(spec garden.ContainerSpec) (string, func(), error) {
+	if spec.NetworkFrom == "" {
+		return "", func() {}, nil
+	}
+
+	if spec.Network != "" {
+		return "", nil, garden.InvalidRequestError{Message: "network and network_from cannot both be specified"}
+	}
+
+	handle := spec.NetworkFrom
+
+	s.destroysL.Lock()
+	if _, destroying := s.destroys[handle]; destroying {
+		s.destroysL.Unlock()
+		return "", nil, garden.ContainerNotFoundError{Handle: handle}
+	}
+	s.subnetSharers[handle]++
+	subnet := s.subnets[handle]
+	s.destroysL.Unlock()
+
+	endShare := func() {
+		s.destroysL.Lock()
+		defer s.destroysL.Unlock()
+
+		s.subnetSharers[handle]--
+		if s.subnetSharers[handle] == 0 {
+			delete(s.subnetSharers, handle)
+		}
+
+		s.sharesEnded.Broadcast()
+	}
+
+	if _, err := s.backend.Lookup(handle); err != nil {
+		endShare()
+		return "", nil, err
+	}
+
+	return subnet, endShare, nil
+}
+
+// awaitSharers waits for the creates sharing a container's subnet to finish.
+func (s *GardenServer) awaitSharers(handle string) {
 	s.destroysL.Lock()
+	defer s.destroysL.Unlock()
+
+	for s.subnetSharers[handle] > 0 {
+		s.sharesEnded.Wait()
+	}
+}
+
+// subnet returns the address of the subnet a container was created on, or
+// nothing if the server does not know it.
+func (s *GardenServer) subnet(handle string) string {
+	s.destroysL.Lock()
+	defer s.destroysL.Unlock()
+
+	return s.subnets[handle]
+}
+
+// subnetAddress returns the address of the subnet a Network is on, as in
+// "10.0.0.0/30" for "10.0.0.2/30", or nothing if it is not a CIDR.
+func subnetAddress(network string) string {
+	_, subnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return ""
+	}
+
+	return subnet.String()
+}
+
+// withProperty returns a copy of the properties with one more set, leaving
+// the caller's untouched.
+func withProperty(properties garden.Properties, name, value string) garden.Properties {
+	copied := garden.Properties{}
+	for n, v := range properties {
+		copied[n] = v
+	}
+
+	copied[name] = value
+	return copied
+}
+
+// isolationWithoutPeers reports whether intra-subnet isolation is asked for
+// on an explicit Network that can hold only the one container. A subnet the
+// backend allocates may be larger, and others may join it with NetworkFrom,
+// so it is not warned about.
+func isolationWithoutPeers(spec garden.ContainerSpec) bool {
+	if !spec.IsolateIntraSubnet || spec.Network == "" {
+		return false
+	}
+
+	_, subnet, err := net.ParseCIDR(spec.Network)
+	if err != nil {
+		return false
+	}
+
+	ones, bits := subnet.Mask.Size()
+	return bits-ones <= 2
+}
+
+// watchDestroy returns a channel that is closed once the container is
+// destroyed, so that processes streaming from it can be told. It fails if the
+// container is being destroyed already.
+func (s *GardenServer) watchDestroy(handle string) (<-chan struct{}, func(), error) {
+	s.destroysL.Lock()
+	defer s.destroysL.Unlock()
+
+	if _, destroying := s.destroys[handle]; destroying {
+		return nil, nil, garden.ContainerDestroyedError{Handle: handle}
+	}
+
+	watcher := make(chan struct{})
+	if s.destroyWatchers[handle] == nil {
+		s.destroyWatchers[handle] = map[chan struct{}]struct{}{}
+	}
+	s.destroyWatchers[handle][watcher] = struct{}{}
+
+	unwatch := func() {
+		s.destroysL.Lock()
+		defer s.destroysL.Unlock()
+
+		delete(s.destroyWatchers[handle], watcher)
+		if len(s.destroyWatchers[handle]) == 0 {
+			delete(s.destroyWatchers, handle)
+		}
+	}
+
+	return watcher, unwatch, nil
+}
+
+func (s *GardenServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := s.logger.Session("stop", lager.Data{
+		"handle": handle,
+	})
 
-	_, alreadyDestroying := s.destroys[handle]
-	if !alreadyDestroying {
-		s.destroys[handle] = struct{}{}
+	var request transport.StopRequest
+	if !s.readRequest(&request, w, r) {
+		return
 	}
 
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	hLog.Debug("stopping")
+
+	err = container.Stop(request.Kill)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	hLog.Info("stopped")
+
+	s.writeSuccess(w)
+}
+
+func (s *GardenServer) handleLock(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := s.logger.Session("lock", lager.Data{
+		"handle": handle,
+	})
+
+	var request transport.LockRequest
+	if !s.readRequest(&request, w, r) {
+		return
+	}
+
+	if request.Holder == "" {
+		s.writeError(w, garden.InvalidRequestError{Message: "a lock must name its holder"}, hLog)
+		return
+	}
+
+	if request.TTL <= 0 {
+		s.writeError(w, garden.InvalidRequestError{Message: "a lock must have a positive ttl"}, hLog)
+		return
+	}
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	lock := garden.ContainerLock{
+		Holder:    request.Holder,
+		Reason:    request.Reason,
+		ExpiresAt: time.Now().Add(request.TTL),
+	}
+
+	// taken under destroysL, so that a destroy either sees the lock or has
+	// begun before it could be taken
+	s.destroysL.Lock()
+	requestedAt, destroying := s.destroys[container.Handle()]
+	if !destroying {
+		err = s.locks.lock(container.Handle(), lock)
+	}
+	estimate := s.destroyDuration
 	s.destroysL.Unlock()
 
-	if alreadyDestroying {
-		s.writeError(w, ErrConcurrentDestroy, hLog)
+	if destroying {
+		s.writeError(w, garden.HandleStillDestroyingError{
+			Handle:              handle,
+			EstimatedCompletion: requestedAt.Add(estimate),
+		}, hLog)
 		return
 	}
 
-	hLog.Debug("destroying")
-
-	err := s.backend.Destroy(handle)
-
-	if !alreadyDestroying {
-		s.destroysL.Lock()
-		delete(s.destroys, handle)
-		s.destroysL.Unlock()
-	}
-
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	hLog.Info("destroyed")
-
-	s.bomberman.Defuse(handle)
+	hLog.Info("locked", lager.Data{
+		"holder":     lock.Holder,
+		"reason":     lock.Reason,
+		"expires-at": lock.ExpiresAt,
+	})
 
 	s.writeSuccess(w)
 }
 
-func (s *GardenServer) handleStop(w http.ResponseWriter, r *http.Request) {
+func (s *GardenServer) handleUnlock(w http.ResponseWriter, r *http.Request) {
 	handle := r.FormValue(":handle")
+	holder := r.URL.Query().Get("holder")
+	force := r.URL.Query().Get("force") == "true"
 
-	hLog := s.logger.Session("stop", lager.Data{
+	hLog := s.logger.Session("unlock", lager.Data{
 		"handle": handle,
+		"holder": holder,
+		"force":  force,
 	})
 
-	var request struct {
-		Kill bool `json:"kill"`
-	}
-	if !s.readRequest(&request, w, r) {
+	if holder == "" && !force {
+		s.writeError(w, garden.InvalidRequestError{Message: "unlocking needs the lock's holder, or force"}, hLog)
 		return
 	}
 
@@ -188,18 +928,12 @@ func (s *GardenServer) handleStop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.bomberman.Pause(container.Handle())
-	defer s.bomberman.Unpause(container.Handle())
-
-	hLog.Debug("stopping")
-
-	err = container.Stop(request.Kill)
-	if err != nil {
+	if err := s.locks.unlock(container.Handle(), holder, force); err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	hLog.Info("stopped")
+	hLog.Info("unlocked")
 
 	s.writeSuccess(w)
 }
@@ -216,6 +950,27 @@ func (s *GardenServer) handleStreamIn(w http.ResponseWriter, r *http.Request) {
 		"destination": dstPath,
 	})
 
+	if err := garden.ValidateTargetPath(dstPath, s.streamPathPolicy()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	checksum := r.URL.Query().Get("checksum")
+	if checksum != "" && !validChecksum(checksum) {
+		s.writeError(w, garden.InvalidRequestError{Message: "checksum must be a hex-encoded sha256 digest"}, hLog)
+		return
+	}
+
+	var expectedBytes uint64
+	if expected := r.URL.Query().Get("expected_bytes"); expected != "" {
+		var err error
+		expectedBytes, err = strconv.ParseUint(expected, 10, 64)
+		if err != nil {
+			s.writeError(w, garden.InvalidRequestError{Message: fmt.Sprintf("invalid expected_bytes: %q", expected)}, hLog)
+			return
+		}
+	}
+
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
 		s.writeError(w, err, hLog)
@@ -225,13 +980,68 @@ func (s *GardenServer) handleStreamIn(w http.ResponseWriter, r *http.Request) {
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
+	var tarStream io.Reader = r.Body
+
+	remaining, hasQuota, err := remainingDiskQuota(container)
+	if err != nil {
+		if expectedBytes > 0 {
+			s.writeError(w, err, hLog)
+			return
+		}
+
+		// without a claimed size to check, the quota only guards the stream,
+		// so failing to look it up is no reason to refuse it
+		hLog.Error("failed-to-get-disk-quota", err)
+	}
+
+	if expectedBytes > remaining {
+		s.writeError(w, garden.QuotaExceededError{Handle: handle}, hLog)
+		return
+	}
+
+	var quota *quotaReader
+	if expectedBytes > 0 {
+		// within the quota, as checked above
+		quota = &quotaReader{r: r.Body, limit: expectedBytes, exact: true, err: garden.StreamSizeMismatchError{Handle: handle, ExpectedBytes: expectedBytes}}
+		tarStream = quota
+	} else if hasQuota {
+		quota = &quotaReader{r: r.Body, limit: remaining, err: garden.QuotaExceededError{Handle: handle}}
+		tarStream = quota
+	}
+
+	var verifier *checksumReader
+	if checksum != "" {
+		verifier = newChecksumReader(tarStream, handle, checksum)
+		defer verifier.release()
+
+		tarStream = verifier
+	}
+
 	hLog.Debug("streaming-in")
 
 	err = container.StreamIn(garden.StreamInSpec{
-		User:      user,
-		Path:      dstPath,
-		TarStream: r.Body,
+		User:          user,
+		Path:          dstPath,
+		TarStream:     tarStream,
+		ExpectedBytes: expectedBytes,
+		Checksum:      checksum,
 	})
+	if err == nil && verifier != nil {
+		err = verifier.verify()
+	}
+
+	if err == nil && quota != nil && quota.exact {
+		// the backend may have stopped reading before the end of the stream
+		_, err = io.Copy(ioutil.Discard, quota)
+	}
+
+	if quota != nil && quota.failed {
+		// backends may wrap the read error; report the overrun regardless
+		err = quota.err
+	} else if verifier != nil && verifier.mismatched {
+		err = garden.ChecksumMismatchError{Handle: handle}
+	}
+
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
@@ -243,7 +1053,9 @@ func (s *GardenServer) handleStreamIn(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *GardenServer) writeSuccess(w http.ResponseWriter) {
-	s.writeResponse(w, &struct{}{})
+	// too small to be worth compressing
+	w.Header().Set("Content-Type", "application/json")
+	transport.WriteMessage(w, &struct{}{})
 }
 
 func (s *GardenServer) handleStreamOut(w http.ResponseWriter, r *http.Request) {
@@ -258,6 +1070,40 @@ func (s *GardenServer) handleStreamOut(w http.ResponseWriter, r *http.Request) {
 		"source": srcPath,
 	})
 
+	if err := garden.ValidateTargetPath(srcPath, s.streamPathPolicy()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	var changedSince time.Time
+	if since := r.URL.Query().Get("changed_since"); since != "" {
+		var err error
+		changedSince, err = time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			s.writeError(w, garden.InvalidRequestError{Message: fmt.Sprintf("invalid changed_since: %q is not an RFC 3339 timestamp", since)}, hLog)
+			return
+		}
+	}
+
+	resumable := r.URL.Query().Get("resumable") == "true"
+
+	var offset int64
+	if o := r.URL.Query().Get("offset"); o != "" {
+		var err error
+		offset, err = strconv.ParseInt(o, 10, 64)
+		if err != nil || offset < 0 {
+			s.writeError(w, garden.InvalidRequestError{Message: fmt.Sprintf("invalid offset: %q", o)}, hLog)
+			return
+		}
+
+		if !resumable {
+			s.writeError(w, garden.InvalidRequestError{Message: "offset requires a resumable stream"}, hLog)
+			return
+		}
+	}
+
+	generation := r.URL.Query().Get("generation")
+
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
 		s.writeError(w, err, hLog)
@@ -267,25 +1113,84 @@ func (s *GardenServer) handleStreamOut(w http.ResponseWriter, r *http.Request) {
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
-	hLog.Debug("streaming-out")
+	hLog.Debug("streaming-out", lager.Data{"offset": offset})
 
-	reader, err := container.StreamOut(garden.StreamOutSpec{
-		User: user,
-		Path: srcPath,
+	var reader io.ReadCloser
+	reader, err = container.StreamOut(garden.StreamOutSpec{
+		User:         user,
+		Path:         srcPath,
+		ChangedSince: changedSince,
+		Resumable:    resumable,
 	})
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	n, err := io.Copy(w, reader)
-	if err != nil {
+	if !changedSince.IsZero() {
+		reader = newChangedReader(reader, changedSince)
+	}
+
+	if resumable {
+		reader = newCanonicalReader(reader)
+	}
+
+	// close the backend's stream however the copy ends, including when the
+	// client goes away mid-download
+	defer func() {
 		if err := reader.Close(); err != nil {
 			hLog.Error("failed-to-close", err)
 		}
+	}()
+
+	if offset > 0 {
+		matched, err := skipResumed(reader, offset, generation)
+		if err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+
+		if !matched {
+			s.writeError(w, garden.StreamOutChangedError{Handle: handle, Path: srcPath}, hLog)
+			return
+		}
+	}
+
+	if resumable {
+		// a backend that walks the tree out of order is usually found out
+		// within the first entries, so hold them back to refuse the stream
+		// outright rather than break it off for the client to retry
+		head := make([]byte, resumableLookahead)
+		n, err := io.ReadFull(reader, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			s.writeError(w, err, hLog)
+			return
+		}
+
+		reader = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head[:n]), reader), reader}
+	}
 
+	n, err := io.Copy(w, reader)
+	if err != nil {
 		if n == 0 {
 			s.writeError(w, err, hLog)
+			return
+		}
+
+		hLog.Error("failed-to-stream-out", err)
+
+		// a resumable stream that ends cleanly would pass for the whole
+		// archive, so cut the connection off for the client to resume from
+		// what it received
+		if resumable {
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+
+			panic(http.ErrAbortHandler)
 		}
 
 		return
@@ -322,7 +1227,7 @@ func (s *GardenServer) handleCurrentBandwidthLimits(w http.ResponseWriter, r *ht
 		"limits": limits,
 	})
 
-	s.writeResponse(w, limits)
+	s.writeResponse(w, r, limits)
 }
 
 func (s *GardenServer) handleCurrentMemoryLimits(w http.ResponseWriter, r *http.Request) {
@@ -353,7 +1258,7 @@ func (s *GardenServer) handleCurrentMemoryLimits(w http.ResponseWriter, r *http.
 		"limits": limits,
 	})
 
-	s.writeResponse(w, limits)
+	s.writeResponse(w, r, limits)
 }
 
 func (s *GardenServer) handleCurrentDiskLimits(w http.ResponseWriter, r *http.Request) {
@@ -384,7 +1289,7 @@ func (s *GardenServer) handleCurrentDiskLimits(w http.ResponseWriter, r *http.Re
 		"limits": limits,
 	})
 
-	s.writeResponse(w, limits)
+	s.writeResponse(w, r, limits)
 }
 
 func (s *GardenServer) handleCurrentCPULimits(w http.ResponseWriter, r *http.Request) {
@@ -415,7 +1320,7 @@ func (s *GardenServer) handleCurrentCPULimits(w http.ResponseWriter, r *http.Req
 		"limits": limits,
 	})
 
-	s.writeResponse(w, limits)
+	s.writeResponse(w, r, limits)
 }
 
 func (s *GardenServer) handleNetIn(w http.ResponseWriter, r *http.Request) {
@@ -458,7 +1363,7 @@ func (s *GardenServer) handleNetIn(w http.ResponseWriter, r *http.Request) {
 		"container-port": containerPort,
 	})
 
-	s.writeResponse(w, &transport.NetInResponse{
+	s.writeResponse(w, r, &transport.NetInResponse{
 		HostPort:      hostPort,
 		ContainerPort: containerPort,
 	})
@@ -503,12 +1408,185 @@ func (s *GardenServer) handleNetOut(w http.ResponseWriter, r *http.Request) {
 	s.writeSuccess(w)
 }
 
+func (s *GardenServer) handleBulkNetOut(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := s.logger.Session("bulk-net-out", lager.Data{
+		"handle": handle,
+	})
+
+	var request transport.BulkNetOutRequest
+	if !s.readLimitedRequest(&request, w, r) {
+		return
+	}
+
+	for _, rule := range request.Rules {
+		if err := garden.ValidateNetOutRule(rule); err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+	}
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	hLog.Debug("allowing-out", lager.Data{
+		"rules": len(request.Rules),
+	})
+
+	err = container.BulkNetOut(request.Rules)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	hLog.Debug("allowed", lager.Data{
+		"rules": len(request.Rules),
+	})
+
+	s.writeSuccess(w)
+}
+
 func (s *GardenServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	handle := r.FormValue(":handle")
 
-	hLog := s.logger.Session("get-metrics", lager.Data{
-		"handle": handle,
-	})
+	hLog := s.logger.Session("get-metrics", lager.Data{
+		"handle": handle,
+	})
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	metrics, err := container.Metrics()
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.writeResponse(w, r, metrics)
+}
+
+func (s *GardenServer) handleMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := s.logger.Session("get-metrics-history", lager.Data{
+		"handle": handle,
+	})
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			s.writeError(w, garden.InvalidRequestError{Message: fmt.Sprintf("invalid since: %q is not an RFC 3339 timestamp", value)}, hLog)
+			return
+		}
+	}
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	s.writeResponse(w, r, transport.MetricsHistoryResponse{
+		Samples: s.metricsRecorder.history(container.Handle(), since),
+	})
+}
+
+func (s *GardenServer) handleProperties(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := s.logger.Session("get-properties", lager.Data{
+		"handle": handle,
+	})
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	properties, err := container.Properties()
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	hLog.Info("got-properties")
+
+	s.writeResponse(w, r, properties)
+}
+
+func (s *GardenServer) handleProperty(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+	key := r.FormValue(":key")
+
+	hLog := s.logger.Session("get-property", lager.Data{
+		"handle": handle,
+	})
+
+	container, err := s.backend.Lookup(handle)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	s.bomberman.Pause(container.Handle())
+	defer s.bomberman.Unpause(container.Handle())
+
+	hLog.Debug("get-property", lager.Data{})
+
+	value, err := container.Property(key)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
+	hLog.Debug("got-property", lager.Data{})
+
+	s.writeResponse(w, r, &transport.PropertyResponse{
+		Value: value,
+	})
+}
+
+func (s *GardenServer) handleSetProperty(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+	key := r.FormValue(":key")
+
+	hLog := s.logger.Session("set-property", lager.Data{
+		"handle": handle,
+	})
+
+	var request transport.SetPropertyRequest
+	if !s.readRequest(&request, w, r) {
+		return
+	}
+
+	value := request.Value
+
+	if err := validatePropertyValues(garden.Properties{key: value}, s.getPropertySchemas()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
 
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
@@ -519,19 +1597,26 @@ func (s *GardenServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
-	metrics, err := container.Metrics()
+	hLog.Debug("set-property", lager.Data{})
+
+	done := s.propertyMutations.begin(container.Handle())
+	err = container.SetProperty(key, value)
+	done()
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	s.writeResponse(w, metrics)
+	hLog.Debug("set-property-complete", lager.Data{})
+
+	s.writeSuccess(w)
 }
 
-func (s *GardenServer) handleProperties(w http.ResponseWriter, r *http.Request) {
+func (s *GardenServer) handleRemoveProperty(w http.ResponseWriter, r *http.Request) {
 	handle := r.FormValue(":handle")
+	key := r.FormValue(":key")
 
-	hLog := s.logger.Session("get-properties", lager.Data{
+	hLog := s.logger.Session("remove-property", lager.Data{
 		"handle": handle,
 	})
 
@@ -544,25 +1629,38 @@ func (s *GardenServer) handleProperties(w http.ResponseWriter, r *http.Request)
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
-	properties, err := container.Properties()
+	hLog.Debug("remove-property", lager.Data{})
+
+	done := s.propertyMutations.begin(container.Handle())
+	err = container.RemoveProperty(key)
+	done()
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	hLog.Info("got-properties")
+	hLog.Info("removed-property", lager.Data{})
 
-	s.writeResponse(w, properties)
+	s.writeSuccess(w)
 }
 
-func (s *GardenServer) handleProperty(w http.ResponseWriter, r *http.Request) {
+func (s *GardenServer) handleSetProperties(w http.ResponseWriter, r *http.Request) {
 	handle := r.FormValue(":handle")
-	key := r.FormValue(":key")
 
-	hLog := s.logger.Session("get-property", lager.Data{
+	hLog := s.logger.Session("set-properties", lager.Data{
 		"handle": handle,
 	})
 
+	var request transport.SetPropertiesRequest
+	if !s.readLimitedRequest(&request, w, r) {
+		return
+	}
+
+	if err := validatePropertyValues(request.Properties, s.getPropertySchemas()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
 		s.writeError(w, err, hLog)
@@ -572,38 +1670,31 @@ func (s *GardenServer) handleProperty(w http.ResponseWriter, r *http.Request) {
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
-	hLog.Debug("get-property", lager.Data{})
-
-	value, err := container.Property(key)
+	err = s.propertyMutations.setMultiple(container, request.Properties, hLog)
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	hLog.Debug("got-property", lager.Data{})
-
-	s.writeResponse(w, map[string]string{
-		"value": value,
+	hLog.Debug("set-properties", lager.Data{
+		"count": len(request.Properties),
 	})
+
+	s.writeSuccess(w)
 }
 
-func (s *GardenServer) handleSetProperty(w http.ResponseWriter, r *http.Request) {
+func (s *GardenServer) handleRemoveProperties(w http.ResponseWriter, r *http.Request) {
 	handle := r.FormValue(":handle")
-	key := r.FormValue(":key")
 
-	hLog := s.logger.Session("set-property", lager.Data{
+	hLog := s.logger.Session("remove-properties", lager.Data{
 		"handle": handle,
 	})
 
-	var request struct {
-		Value string `json:"value"`
-	}
-	if !s.readRequest(&request, w, r) {
+	var request transport.RemovePropertiesRequest
+	if !s.readLimitedRequest(&request, w, r) {
 		return
 	}
 
-	value := request.Value
-
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
 		s.writeError(w, err, hLog)
@@ -613,27 +1704,37 @@ func (s *GardenServer) handleSetProperty(w http.ResponseWriter, r *http.Request)
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
-	hLog.Debug("set-property", lager.Data{})
-
-	err = container.SetProperty(key, value)
+	err = s.propertyMutations.removeMultiple(container, request.Names, hLog)
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	hLog.Debug("set-property-complete", lager.Data{})
+	hLog.Info("removed-properties", lager.Data{
+		"count": len(request.Names),
+	})
 
 	s.writeSuccess(w)
 }
 
-func (s *GardenServer) handleRemoveProperty(w http.ResponseWriter, r *http.Request) {
+func (s *GardenServer) handleCompareAndSetProperty(w http.ResponseWriter, r *http.Request) {
 	handle := r.FormValue(":handle")
 	key := r.FormValue(":key")
 
-	hLog := s.logger.Session("remove-property", lager.Data{
+	hLog := s.logger.Session("compare-and-set-property", lager.Data{
 		"handle": handle,
 	})
 
+	var request transport.CompareAndSetPropertyRequest
+	if !s.readRequest(&request, w, r) {
+		return
+	}
+
+	if err := validatePropertyValues(garden.Properties{key: request.New}, s.getPropertySchemas()); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
 		s.writeError(w, err, hLog)
@@ -643,17 +1744,19 @@ func (s *GardenServer) handleRemoveProperty(w http.ResponseWriter, r *http.Reque
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
-	hLog.Debug("remove-property", lager.Data{})
-
-	err = container.RemoveProperty(key)
+	swapped, err := s.propertyMutations.compareAndSet(container, key, request.Old, request.New)
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	hLog.Info("removed-property", lager.Data{})
+	hLog.Debug("compared-and-set-property", lager.Data{
+		"swapped": swapped,
+	})
 
-	s.writeSuccess(w)
+	s.writeResponse(w, r, &transport.CompareAndSetPropertyResponse{
+		Swapped: swapped,
+	})
 }
 
 func (s *GardenServer) handleSetGraceTime(w http.ResponseWriter, r *http.Request) {
@@ -668,17 +1771,29 @@ func (s *GardenServer) handleSetGraceTime(w http.ResponseWriter, r *http.Request
 		"handle": handle,
 	})
 
+	// a container being destroyed must not be strapped again
+	if err := s.checkNotDestroying(handle); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
 		s.writeError(w, err, hLog)
 		return
 	}
 
-	container.SetGraceTime(graceTime)
+	err = container.SetGraceTime(graceTime)
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
 
-	s.bomberman.Defuse(container.Handle())
+	// restarts the countdown, leaving it paused for requests in flight
 	s.bomberman.Strap(container)
 
+	hLog.Info("set", lager.Data{"grace-time": graceTime.String()})
+
 	s.writeSuccess(w)
 }
 
@@ -690,7 +1805,13 @@ func (s *GardenServer) handleRun(w http.ResponseWriter, r *http.Request) {
 	})
 
 	var request garden.ProcessSpec
-	if !s.readRequest(&request, w, r) {
+	if !s.readLimitedRequest(&request, w, r) {
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		s.writeError(w, errProcessTransportUnsupported, hLog)
 		return
 	}
 
@@ -700,6 +1821,9 @@ func (s *GardenServer) handleRun(w http.ResponseWriter, r *http.Request) {
 		User:   request.User,
 		Limits: request.Limits,
 		TTY:    request.TTY,
+
+		ProcessLimits: request.ProcessLimits,
+		OutputCapture: request.OutputCapture,
 	}
 
 	container, err := s.backend.Lookup(handle)
@@ -708,26 +1832,61 @@ func (s *GardenServer) handleRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateProcessLimits(s.backend, container, request.ProcessLimits); err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
+	destroyed, unwatch, err := s.watchDestroy(container.Handle())
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+	defer unwatch()
+
+	var capture *processCapture
+	if request.OutputCapture != nil {
+		capture, err = s.outputCaptures.begin(container.Handle(), *request.OutputCapture, hLog)
+		if err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+	}
+
 	hLog.Debug("running", lager.Data{
 		"spec": info,
 	})
 
-	stdout := make(chan []byte, 1000)
-	stderr := make(chan []byte, 1000)
+	streamID := s.streamer.NewStream()
+	defer s.streamer.Stop(streamID)
 
 	stdinR, stdinW := io.Pipe()
 
+	lifetime := newLifetimeTimer(s.getStreamLifetime())
+	defer lifetime.stop()
+
 	processIO := garden.ProcessIO{
-		Stdin:  stdinR,
-		Stdout: &chanWriter{stdout},
-		Stderr: &chanWriter{stderr},
+		Stdin:  lifetime.wrapReader(stdinR),
+		Stdout: lifetime.wrapWriter(&streamWriter{streamer: s.streamer, streamID: streamID, std: streamer.Stdout, logger: hLog}),
+		Stderr: lifetime.wrapWriter(&streamWriter{streamer: s.streamer, streamID: streamID, std: streamer.Stderr, logger: hLog}),
+	}
+
+	if capture != nil {
+		// capture first, as it never fails, while streaming stops once the
+		// stream's lifetime is exceeded
+		processIO.Stdout = io.MultiWriter(capture.stdout, processIO.Stdout)
+		processIO.Stderr = io.MultiWriter(capture.stderr, processIO.Stderr)
 	}
 
 	process, err := container.Run(request, processIO)
 	if err != nil {
+		if capture != nil {
+			capture.discard()
+		}
+
 		s.writeError(w, err, hLog)
 		return
 	}
@@ -736,15 +1895,29 @@ func (s *GardenServer) handleRun(w http.ResponseWriter, r *http.Request) {
 		"id":   process.ID(),
 	})
 
-	streamID := s.streamer.Stream(stdout, stderr)
-	defer s.streamer.Stop(streamID)
+	if capture != nil {
+		s.outputCaptures.register(capture, process.ID())
+
+		// the process outlives the request if the client goes away
+		go func() {
+			process.Wait()
+			capture.close()
+		}()
+	}
+
+	timedOut := neverTimedOut
+	if request.MaxDuration > 0 {
+		timedOut = s.enforceMaxDuration(hLog, process, request.MaxDuration)
+	}
 
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 
-	conn, br, err := w.(http.Hijacker).Hijack()
+	conn, br, err := hijacker.Hijack()
 	if err != nil {
-		s.writeError(w, err, hLog)
+		// the status has already been sent; all that is left is to end the
+		// response
+		hLog.Error("failed-to-hijack", err)
 		stdinW.Close()
 		return
 	}
@@ -760,7 +1933,50 @@ func (s *GardenServer) handleRun(w http.ResponseWriter, r *http.Request) {
 
 	go s.streamInput(json.NewDecoder(br), stdinW, process, connCloseCh)
 
-	s.streamProcess(hLog, conn, process, stdinW, connCloseCh)
+	s.streamProcess(hLog, conn, container.Handle(), process, stdinW, connCloseCh, destroyed, lifetime.expired, timedOut)
+}
+
+func (s *GardenServer) handleCapturedOutput(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+	processID := r.FormValue(":pid")
+	stream := r.FormValue(":stream")
+
+	hLog := s.logger.Session("captured-output", lager.Data{
+		"handle":  handle,
+		"process": processID,
+		"stream":  stream,
+	})
+
+	// captures kept past their container are found without it
+	capture, found := s.outputCaptures.lookup(handle, processID)
+	if !found {
+		if _, err := s.backend.Lookup(handle); err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
+
+		s.writeError(w, garden.ProcessNotFoundError{Handle: handle, ProcessID: processID}, hLog)
+		return
+	}
+
+	file, found := capture.stream(stream)
+	if !found {
+		s.writeError(w, garden.InvalidRequestError{Message: fmt.Sprintf("unknown stream: %s", stream)}, hLog)
+		return
+	}
+
+	reader, err := file.open()
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	if _, err := io.Copy(w, reader); err != nil {
+		hLog.Error("failed-to-copy", err)
+	}
 }
 
 func (s *GardenServer) handleAttach(w http.ResponseWriter, r *http.Request) {
@@ -772,6 +1988,12 @@ func (s *GardenServer) handleAttach(w http.ResponseWriter, r *http.Request) {
 
 	processID := r.FormValue(":pid")
 
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		s.writeError(w, errProcessTransportUnsupported, hLog)
+		return
+	}
+
 	container, err := s.backend.Lookup(handle)
 	if err != nil {
 		s.writeError(w, err, hLog)
@@ -781,15 +2003,25 @@ func (s *GardenServer) handleAttach(w http.ResponseWriter, r *http.Request) {
 	s.bomberman.Pause(container.Handle())
 	defer s.bomberman.Unpause(container.Handle())
 
-	stdout := make(chan []byte, 1000)
-	stderr := make(chan []byte, 1000)
+	destroyed, unwatch, err := s.watchDestroy(container.Handle())
+	if err != nil {
+		s.writeError(w, err, hLog)
+		return
+	}
+	defer unwatch()
+
+	streamID := s.streamer.NewStream()
+	defer s.streamer.Stop(streamID)
 
 	stdinR, stdinW := io.Pipe()
 
+	lifetime := newLifetimeTimer(s.getStreamLifetime())
+	defer lifetime.stop()
+
 	processIO := garden.ProcessIO{
-		Stdin:  stdinR,
-		Stdout: &chanWriter{stdout},
-		Stderr: &chanWriter{stderr},
+		Stdin:  lifetime.wrapReader(stdinR),
+		Stdout: lifetime.wrapWriter(&streamWriter{streamer: s.streamer, streamID: streamID, std: streamer.Stdout, logger: hLog}),
+		Stderr: lifetime.wrapWriter(&streamWriter{streamer: s.streamer, streamID: streamID, std: streamer.Stderr, logger: hLog}),
 	}
 
 	hLog.Debug("attaching", lager.Data{
@@ -807,15 +2039,14 @@ func (s *GardenServer) handleAttach(w http.ResponseWriter, r *http.Request) {
 		"id": process.ID(),
 	})
 
-	streamID := s.streamer.Stream(stdout, stderr)
-	defer s.streamer.Stop(streamID)
-
-	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 
-	conn, br, err := w.(http.Hijacker).Hijack()
+	conn, br, err := hijacker.Hijack()
 	if err != nil {
-		s.writeError(w, err, hLog)
+		// the status has already been sent; all that is left is to end the
+		// response
+		hLog.Error("failed-to-hijack", err)
 		stdinW.Close()
 		return
 	}
@@ -831,7 +2062,7 @@ func (s *GardenServer) handleAttach(w http.ResponseWriter, r *http.Request) {
 
 	go s.streamInput(json.NewDecoder(br), stdinW, process, connCloseCh)
 
-	s.streamProcess(hLog, conn, process, stdinW, connCloseCh)
+	s.streamProcess(hLog, conn, container.Handle(), process, stdinW, connCloseCh, destroyed, lifetime.expired, neverTimedOut)
 }
 
 func (s *GardenServer) handleInfo(w http.ResponseWriter, r *http.Request) {
@@ -858,13 +2089,28 @@ func (s *GardenServer) handleInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.isDestroying(container.Handle()) {
+		info.State = garden.StateDestroying
+	}
+
+	if lock, locked := s.locks.lookup(container.Handle()); locked {
+		info.Lock = &lock
+	}
+
+	info.RecordingMetrics = s.metricsRecorder.recording(container.Handle())
+	info.Network = s.subnet(container.Handle())
+
 	hLog.Info("got-info")
 
-	s.writeResponse(w, info)
+	s.writeResponse(w, r, info)
 }
 
 func (s *GardenServer) handleBulkInfo(w http.ResponseWriter, r *http.Request) {
-	handles := splitHandles(r.URL.Query()["handles"][0])
+	handles, err := splitLimitedHandles(r.URL.Query()["handles"][0], s.getRequestLimits().MaxBulkHandles)
+	if err != nil {
+		s.writeError(w, err, s.logger)
+		return
+	}
 
 	hLog := s.logger.Session("bulk_info", lager.Data{
 		"handles": handles,
@@ -879,26 +2125,78 @@ func (s *GardenServer) handleBulkInfo(w http.ResponseWriter, r *http.Request) {
 
 	hLog.Info("got-bulkinfo")
 
-	s.writeResponse(w, bulkInfo)
+	if bulkInfo == nil {
+		bulkInfo = map[string]garden.ContainerInfoEntry{}
+	}
+
+	for handle, entry := range bulkInfo {
+		if entry.Err != nil {
+			continue
+		}
+
+		if s.isDestroying(handle) {
+			entry.Info.State = garden.StateDestroying
+		}
+
+		if lock, locked := s.locks.lookup(handle); locked {
+			entry.Info.Lock = &lock
+		}
+
+		entry.Info.RecordingMetrics = s.metricsRecorder.recording(handle)
+		entry.Info.Network = s.subnet(handle)
+
+		bulkInfo[handle] = entry
+	}
+
+	s.writeResponse(w, r, bulkInfo)
 }
 
 func (s *GardenServer) handleBulkMetrics(w http.ResponseWriter, r *http.Request) {
-	handles := splitHandles(r.URL.Query()["handles"][0])
+	handles, err := splitLimitedHandles(r.URL.Query()["handles"][0], s.getRequestLimits().MaxBulkHandles)
+	if err != nil {
+		s.writeError(w, err, s.logger)
+		return
+	}
 
 	hLog := s.logger.Session("bulk_metrics", lager.Data{
 		"handles": handles,
 	})
 	hLog.Debug("getting-bulkmetrics")
 
-	bulkMetrics, err := s.backend.BulkMetrics(handles)
-	if err != nil {
-		s.writeError(w, err, hLog)
-		return
+	// containers recording their metrics are answered from their latest
+	// sample rather than collecting them afresh
+	recorded := map[string]garden.ContainerMetricsEntry{}
+	collect := []string{}
+	for _, handle := range handles {
+		if sample, found := s.metricsRecorder.latest(handle); found {
+			recorded[handle] = garden.ContainerMetricsEntry{Metrics: sample.Metrics}
+			continue
+		}
+
+		collect = append(collect, handle)
+	}
+
+	var bulkMetrics map[string]garden.ContainerMetricsEntry
+	if len(collect) > 0 || len(handles) == 0 {
+		var err error
+		bulkMetrics, err = s.backend.BulkMetrics(collect)
+		if err != nil {
+			s.writeError(w, err, hLog)
+			return
+		}
 	}
 
 	hLog.Info("got-bulkinfo")
 
-	s.writeResponse(w, bulkMetrics)
+	if bulkMetrics == nil {
+		bulkMetrics = map[string]garden.ContainerMetricsEntry{}
+	}
+
+	for handle, entry := range recorded {
+		bulkMetrics[handle] = entry
+	}
+
+	s.writeResponse(w, r, bulkMetrics)
 }
 
 func (s *GardenServer) writeError(w http.ResponseWriter, err error, logger lager.Logger) {
@@ -911,8 +2209,14 @@ func (s *GardenServer) writeError(w http.ResponseWriter, err error, logger lager
 	json.NewEncoder(w).Encode(merr)
 }
 
-func (s *GardenServer) writeResponse(w http.ResponseWriter, msg interface{}) {
+func (s *GardenServer) writeResponse(w http.ResponseWriter, r *http.Request, msg interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+
+	if s.compressionEnabled() {
+		writeCompressed(w, r, msg)
+		return
+	}
+
 	transport.WriteMessage(w, msg)
 }
 
@@ -979,7 +2283,9 @@ func (s *GardenServer) streamInput(decoder *json.Decoder, in *io.PipeWriter, pro
 	}
 }
 
-func (s *GardenServer) streamProcess(logger lager.Logger, conn net.Conn, process garden.Process, stdinPipe *io.PipeWriter, connCloseCh chan struct{}) {
+// streamProcess reports the process's exit to the client. timedOut tells
+// whether the process was stopped for exceeding its maximum duration.
+func (s *GardenServer) streamProcess(logger lager.Logger, conn net.Conn, handle string, process garden.Process, stdinPipe *io.PipeWriter, connCloseCh chan struct{}, destroyed, lifetimeExpired <-chan struct{}, timedOut func() bool) {
 	statusCh := make(chan int, 1)
 	errCh := make(chan error, 1)
 
@@ -1005,10 +2311,16 @@ func (s *GardenServer) streamProcess(logger lager.Logger, conn net.Conn, process
 		select {
 
 		case status := <-statusCh:
-			transport.WriteMessage(conn, &transport.ProcessPayload{
+			payload := &transport.ProcessPayload{
 				ProcessID:  process.ID(),
 				ExitStatus: &status,
-			})
+			}
+
+			if timedOut() {
+				payload.ErrorType = &garden.Error{Err: garden.ProcessTimeoutError{}}
+			}
+
+			transport.WriteMessage(conn, payload)
 
 			stdinPipe.Close()
 			return
@@ -1023,6 +2335,34 @@ func (s *GardenServer) streamProcess(logger lager.Logger, conn net.Conn, process
 			stdinPipe.Close()
 			return
 
+		case <-destroyed:
+			err := garden.ContainerDestroyedError{Handle: handle}
+			e := err.Error()
+			transport.WriteMessage(conn, &transport.ProcessPayload{
+				ProcessID: process.ID(),
+				Error:     &e,
+				ErrorType: &garden.Error{Err: err},
+			})
+
+			stdinPipe.Close()
+			return
+
+		case <-lifetimeExpired:
+			// the process keeps running, so that the client may attach again
+			logger.Info("stream-lifetime-exceeded", lager.Data{
+				"id": process.ID(),
+			})
+
+			err := garden.StreamLifetimeExceededError{}
+			e := err.Error()
+			transport.WriteMessage(conn, &transport.ProcessPayload{
+				ProcessID: process.ID(),
+				Error:     &e,
+				ErrorType: &garden.Error{Err: err},
+			})
+
+			return
+
 		case <-s.stopping:
 			logger.Debug("detaching", lager.Data{
 				"id": process.ID(),
@@ -1037,6 +2377,60 @@ func (s *GardenServer) streamProcess(logger lager.Logger, conn net.Conn, process
 	}
 }
 
+// processKillGracePeriod is how long a process that has exceeded its maximum
+// duration is given to exit after being terminated, before it is killed.
+var processKillGracePeriod = 10 * time.Second
+
+// neverTimedOut is the timedOut of a process streamed without a maximum
+// duration being enforced.
+func neverTimedOut() bool {
+	return false
+}
+
+// enforceMaxDuration stops the process once it has run for maxDuration,
+// whether or not a client is still attached, and returns a func reporting
+// whether it did.
+func (s *GardenServer) enforceMaxDuration(logger lager.Logger, process garden.Process, maxDuration time.Duration) func() bool {
+	var timedOut int32
+
+	exited := make(chan struct{})
+	go func() {
+		process.Wait()
+		close(exited)
+	}()
+
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-time.After(maxDuration):
+		}
+
+		atomic.StoreInt32(&timedOut, 1)
+
+		logger.Info("timed-out", lager.Data{
+			"id":           process.ID(),
+			"max-duration": maxDuration.String(),
+		})
+
+		if err := process.Signal(garden.SignalTerminate); err != nil {
+			logger.Error("failed-to-terminate", err)
+		}
+
+		select {
+		case <-exited:
+		case <-time.After(processKillGracePeriod):
+			if err := process.Signal(garden.SignalKill); err != nil {
+				logger.Error("failed-to-kill", err)
+			}
+		}
+	}()
+
+	return func() bool {
+		return atomic.LoadInt32(&timedOut) == 1
+	}
+}
+
 func splitHandles(queryHandles string) []string {
 	handles := []string{}
 	if queryHandles != "" {
@@ -1044,3 +2438,23 @@ func splitHandles(queryHandles string) []string {
 	}
 	return handles
 }
+
+// sortedHandles returns the handles of the containers in lexicographic order,
+// without duplicates, so that listings are stable across calls.
+func sortedHandles(containers []garden.Container) []string {
+	handles := make([]string, 0, len(containers))
+	for _, container := range containers {
+		handles = append(handles, container.Handle())
+	}
+
+	sort.Strings(handles)
+
+	deduped := handles[:0]
+	for _, handle := range handles {
+		if len(deduped) == 0 || handle != deduped[len(deduped)-1] {
+			deduped = append(deduped, handle)
+		}
+	}
+
+	return deduped
+}
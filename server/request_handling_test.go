@@ -1,14 +1,21 @@
 package server_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -23,7 +30,9 @@ import (
 	"code.cloudfoundry.org/garden/client"
 	"code.cloudfoundry.org/garden/client/connection"
 	fakes "code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/garden/routes"
 	"code.cloudfoundry.org/garden/server"
+	"code.cloudfoundry.org/garden/transport"
 	"code.cloudfoundry.org/localip"
 )
 
@@ -40,6 +49,11 @@ func createGardenListenArgs() (string, string) {
 	return "tcp", createTcpGardenListenAddr()
 }
 
+// newTCPClient is for specs that shadow the client package.
+func newTCPClient(address string) garden.Client {
+	return client.New(connection.New("tcp", address))
+}
+
 var _ = Describe("When connecting directly to the server", func() {
 	var (
 		gardenListenNetwork      string
@@ -96,12 +110,270 @@ var _ = Describe("When connecting directly to the server", func() {
 			Expect(response.StatusCode).To(Equal(http.StatusOK))
 		})
 	})
+
+	Describe("request limits", func() {
+		postCreate := func(body string) (int, string) {
+			// the server stops reading early, so don't reuse the connection
+			rawClient := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+			response, err := rawClient.Post(fmt.Sprintf("http://localhost:%d/containers", port), "application/json", strings.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+
+			responseBody, err := ioutil.ReadAll(response.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return response.StatusCode, string(responseBody)
+		}
+
+		BeforeEach(func() {
+			apiServer.SetRequestLimits(server.RequestLimits{MaxProperties: 2})
+		})
+
+		It("stops decoding as soon as a field exceeds its limit", func() {
+			// the body is malformed after the third property, so only an early
+			// abort reports the limit
+			status, body := postCreate(`{"properties":{"a":"1","b":"2","c":"3",!!!`)
+			Expect(status).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(body).To(ContainSubstring("RequestLimitExceededError"))
+
+			Expect(fakeBackend.CreateCallCount()).To(BeZero())
+		})
+
+		It("matches field names case-insensitively", func() {
+			status, _ := postCreate(`{"Properties":{"a":"1","b":"2","c":"3"}}`)
+			Expect(status).To(Equal(http.StatusRequestEntityTooLarge))
+		})
+
+		It("decodes a request within the limits as before", func() {
+			status, _ := postCreate(`{"handle":"some-handle","properties":{"a":"1","b":"2"},"env":["A=B"]}`)
+			Expect(status).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.CreateArgsForCall(0).Handle).To(Equal("some-handle"))
+			Expect(fakeBackend.CreateArgsForCall(0).Properties).To(Equal(garden.Properties{"a": "1", "b": "2"}))
+			Expect(fakeBackend.CreateArgsForCall(0).Env).To(Equal([]string{"A=B"}))
+		})
+
+		It("decodes a null body as an empty request", func() {
+			status, _ := postCreate(`null`)
+			Expect(status).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.CreateArgsForCall(0).Handle).To(BeEmpty())
+			Expect(fakeBackend.CreateArgsForCall(0).Properties).To(BeNil())
+		})
+
+		It("ignores unknown fields", func() {
+			status, _ := postCreate(`{"handle":"some-handle","unknown":{"a":[1,2,3]}}`)
+			Expect(status).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.CreateArgsForCall(0).Handle).To(Equal("some-handle"))
+		})
+	})
+
+	Describe("compression", func() {
+		var rawClient *http.Client
+
+		gzipped := func(data []byte) *bytes.Buffer {
+			buf := new(bytes.Buffer)
+			w := gzip.NewWriter(buf)
+			_, err := w.Write(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w.Close()).To(Succeed())
+			return buf
+		}
+
+		postGzipped := func(body *bytes.Buffer) *http.Response {
+			request, err := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d/containers", port), body)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set("Content-Encoding", "gzip")
+
+			response, err := rawClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			return response
+		}
+
+		BeforeEach(func() {
+			rawClient = &http.Client{
+				Transport: &http.Transport{DisableCompression: true},
+			}
+		})
+
+		It("advertises that it accepts gzipped request bodies", func() {
+			response, err := rawClient.Get(fmt.Sprintf("http://localhost:%d/ping", port))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Header.Get("Accept-Encoding")).To(Equal("gzip"))
+		})
+
+		It("accepts a gzipped request body", func() {
+			response := postGzipped(gzipped([]byte(`{"handle":"some-handle","env":["A=B"]}`)))
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.CreateArgsForCall(0).Env).To(Equal([]string{"A=B"}))
+		})
+
+		It("rejects a gzipped request body that decompresses past the limit", func() {
+			bomb := append([]byte(`{"handle":"`), bytes.Repeat([]byte("a"), 65*1024*1024)...)
+
+			response := postGzipped(gzipped(bomb))
+			Expect(response.StatusCode).NotTo(Equal(http.StatusOK))
+
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(ContainSubstring("decompressed request body exceeds"))
+
+			Expect(fakeBackend.CreateCallCount()).To(BeZero())
+		})
+
+		It("gzips large responses for clients that accept it", func() {
+			handles := []string{}
+			for i := 0; i < 1000; i++ {
+				handles = append(handles, fmt.Sprintf("handle-%04d", i))
+			}
+
+			containers := []garden.Container{}
+			for _, handle := range handles {
+				container := new(fakes.FakeContainer)
+				container.HandleReturns(handle)
+				containers = append(containers, container)
+			}
+			fakeBackend.ContainersReturns(containers, nil)
+
+			request, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/containers", port), nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Accept-Encoding", "gzip")
+
+			response, err := rawClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Header.Get("Content-Encoding")).To(Equal("gzip"))
+
+			gzipReader, err := gzip.NewReader(response.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			var listResponse struct{ Handles []string }
+			Expect(json.NewDecoder(gzipReader).Decode(&listResponse)).To(Succeed())
+			Expect(listResponse.Handles).To(Equal(handles))
+		})
+
+		It("gzips a replayed response as the retry accepts, not as the original did", func() {
+			containers := []garden.Container{}
+			for i := 0; i < 1000; i++ {
+				container := new(fakes.FakeContainer)
+				container.HandleReturns(fmt.Sprintf("handle-%04d", i))
+				containers = append(containers, container)
+			}
+			fakeBackend.ContainersReturns(containers, nil)
+
+			destroyMatching := func(acceptEncoding string) *http.Response {
+				request, err := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d/containers/destroy_matching", port), strings.NewReader(`{"all":true,"dry_run":true}`))
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("Content-Type", "application/json")
+				request.Header.Set(transport.IdempotencyKeyHeader, "some-key")
+				if acceptEncoding != "" {
+					request.Header.Set("Accept-Encoding", acceptEncoding)
+				}
+
+				response, err := rawClient.Do(request)
+				Expect(err).NotTo(HaveOccurred())
+				return response
+			}
+
+			original := destroyMatching("")
+			Expect(original.Header.Get("Content-Encoding")).To(BeEmpty())
+
+			var plain transport.DestroyMatchingResponse
+			Expect(json.NewDecoder(original.Body).Decode(&plain)).To(Succeed())
+
+			matched := fakeBackend.ContainersCallCount()
+
+			retry := destroyMatching("gzip")
+			Expect(retry.Header.Get("Content-Encoding")).To(Equal("gzip"))
+
+			gzipReader, err := gzip.NewReader(retry.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			var replayed transport.DestroyMatchingResponse
+			Expect(json.NewDecoder(gzipReader).Decode(&replayed)).To(Succeed())
+			Expect(replayed).To(Equal(plain))
+
+			Expect(destroyMatching("").Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(fakeBackend.ContainersCallCount()).To(Equal(matched))
+		})
+
+		It("does not gzip small responses", func() {
+			request, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/containers", port), nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Accept-Encoding", "gzip")
+
+			response, err := rawClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Header.Get("Content-Encoding")).To(BeEmpty())
+		})
+
+		Context("when compression is disabled", func() {
+			BeforeEach(func() {
+				apiServer.SetCompression(false)
+			})
+
+			It("does not advertise gzip", func() {
+				response, err := rawClient.Get(fmt.Sprintf("http://localhost:%d/ping", port))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(response.Header.Get("Accept-Encoding")).To(BeEmpty())
+			})
+
+			It("rejects a gzipped request body with 415 and a clear error", func() {
+				response := postGzipped(gzipped([]byte(`{"handle":"some-handle"}`)))
+				Expect(response.StatusCode).To(Equal(http.StatusUnsupportedMediaType))
+
+				body, err := ioutil.ReadAll(response.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("gzip request bodies are not accepted"))
+
+				Expect(fakeBackend.CreateCallCount()).To(BeZero())
+			})
+
+			It("serves large specs from a client that has seen it accept gzip before", func() {
+				apiServer.SetCompression(true)
+
+				apiClient := newTCPClient(fmt.Sprintf("localhost:%d", port))
+				Expect(apiClient.Ping()).To(Succeed())
+
+				apiServer.SetCompression(false)
+
+				env := []string{}
+				for i := 0; i < 1000; i++ {
+					env = append(env, fmt.Sprintf("VAR_%d=value", i))
+				}
+
+				_, err := apiClient.Create(garden.ContainerSpec{Env: env})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeBackend.CreateArgsForCall(0).Env).To(Equal(env))
+			})
+		})
+
+		It("round-trips large specs from the client compressed", func() {
+			apiClient := newTCPClient(fmt.Sprintf("localhost:%d", port))
+			Expect(apiClient.Ping()).To(Succeed())
+
+			env := []string{}
+			for i := 0; i < 1000; i++ {
+				env = append(env, fmt.Sprintf("VAR_%d=value", i))
+			}
+
+			_, err := apiClient.Create(garden.ContainerSpec{Env: env})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBackend.CreateArgsForCall(0).Env).To(Equal(env))
+			Expect(sink.Buffer()).NotTo(gbytes.Say("unsupported-content-encoding"))
+		})
+	})
 })
 
 var _ = Describe("When a client connects", func() {
 	var tmpdir string
 
 	var serverBackend *fakes.FakeBackend
+	var backendFeatures *capableBackend
 
 	var serverContainerGraceTime time.Duration
 
@@ -126,13 +398,14 @@ var _ = Describe("When a client connects", func() {
 		Ω(err).ShouldNot(HaveOccurred())
 
 		serverBackend = new(fakes.FakeBackend)
+		backendFeatures = &capableBackend{FakeBackend: serverBackend}
 		serverContainerGraceTime = 42 * time.Second
 
 		apiServer = server.New(
 			gardenListenNetwork,
 			gardenListenAddr,
 			serverContainerGraceTime,
-			serverBackend,
+			backendFeatures,
 			logger,
 		)
 
@@ -212,6 +485,54 @@ var _ = Describe("When a client connects", func() {
 			Ω(capacity.MaxContainers).Should(Equal(uint64(42)))
 		})
 
+		Context("when the backend reports its pools", func() {
+			BeforeEach(func() {
+				serverBackend.CapacityReturns(garden.Capacity{
+					SubnetPool: &garden.PoolUsage{Size: 64, InUse: 10},
+					UIDPool:    &garden.PoolUsage{Size: 128, InUse: 10},
+					PortPool:   &garden.PoolUsage{Size: 5000, InUse: 20},
+				}, nil)
+			})
+
+			It("returns the pool usage", func() {
+				capacity, err := apiClient.Capacity()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(capacity.SubnetPool).Should(Equal(&garden.PoolUsage{Size: 64, InUse: 10}))
+				Ω(capacity.UIDPool).Should(Equal(&garden.PoolUsage{Size: 128, InUse: 10}))
+				Ω(capacity.PortPool).Should(Equal(&garden.PoolUsage{Size: 5000, InUse: 20}))
+			})
+		})
+
+		Context("when the backend reports CPU and scheduling information", func() {
+			BeforeEach(func() {
+				serverBackend.CapacityReturns(garden.Capacity{
+					MemoryInBytes:            1111,
+					CPUCores:                 uint64ptr(8),
+					CPUShares:                uint64ptr(0),
+					SchedulableMemoryInBytes: uint64ptr(1000),
+				}, nil)
+			})
+
+			It("returns it, telling a reported zero apart", func() {
+				capacity, err := apiClient.Capacity()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(capacity.CPUCores).Should(Equal(uint64ptr(8)))
+				Ω(capacity.CPUShares).Should(Equal(uint64ptr(0)))
+				Ω(capacity.SchedulableMemoryInBytes).Should(Equal(uint64ptr(1000)))
+			})
+		})
+
+		It("reports no CPU or scheduling information when the backend does not", func() {
+			capacity, err := apiClient.Capacity()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(capacity.CPUCores).Should(BeNil())
+			Ω(capacity.CPUShares).Should(BeNil())
+			Ω(capacity.SchedulableMemoryInBytes).Should(BeNil())
+		})
+
 		Context("when getting the capacity fails", func() {
 			BeforeEach(func() {
 				serverBackend.CapacityReturns(garden.Capacity{}, errors.New("oh no!"))
@@ -224,6 +545,82 @@ var _ = Describe("When a client connects", func() {
 		})
 	})
 
+	Context("and the client requests the route table", func() {
+		var advertised []routes.Description
+
+		BeforeEach(func() {
+			response, err := http.Get(fmt.Sprintf("http://%s/routes", gardenListenAddr))
+			Ω(err).ShouldNot(HaveOccurred())
+			defer response.Body.Close()
+
+			Ω(response.StatusCode).Should(Equal(http.StatusOK))
+			Ω(json.NewDecoder(response.Body).Decode(&advertised)).Should(Succeed())
+		})
+
+		It("advertises exactly the routes the client uses", func() {
+			Ω(advertised).Should(HaveLen(len(routes.Routes)))
+
+			for i, route := range routes.Routes {
+				Ω(advertised[i].Name).Should(Equal(route.Name))
+				Ω(advertised[i].Method).Should(Equal(route.Method))
+				Ω(advertised[i].Path).Should(Equal(route.Path))
+			}
+		})
+
+		It("describes the wire messages of each route", func() {
+			Ω(advertised).Should(ContainElement(routes.Description{
+				Name:     routes.NetIn,
+				Method:   "POST",
+				Path:     "/containers/:handle/net/in",
+				Request:  "transport.NetInRequest",
+				Response: "transport.NetInResponse",
+			}))
+		})
+
+		It("marks the routes that need the connection to themselves as hijacking it", func() {
+			for _, description := range advertised {
+				switch description.Name {
+				case routes.Run, routes.Attach:
+					Ω(description.Hijacks).Should(BeTrue(), description.Name)
+				default:
+					Ω(description.Hijacks).Should(BeFalse(), description.Name)
+				}
+			}
+		})
+	})
+
+	Context("and the client requests the API spec", func() {
+		var spec transport.APISpecResponse
+
+		BeforeEach(func() {
+			apiServer.SetRequestLimits(server.RequestLimits{MaxEnv: 3, MaxNetOutRules: 4})
+			apiServer.SetPropertySchemas(map[string]garden.PropertySchema{"deploy": {Type: "object"}})
+
+			response, err := http.Get(fmt.Sprintf("http://%s/api-spec", gardenListenAddr))
+			Ω(err).ShouldNot(HaveOccurred())
+			defer response.Body.Close()
+
+			Ω(response.StatusCode).Should(Equal(http.StatusOK))
+			Ω(json.NewDecoder(response.Body).Decode(&spec)).Should(Succeed())
+		})
+
+		It("serves the route table", func() {
+			Ω(spec.Routes).Should(Equal(transport.DescribeRoutes()))
+		})
+
+		It("serves the schemas with the server's request limits", func() {
+			Ω(*spec.Schemas["garden.ContainerSpec"].Properties["env"].MaxItems).Should(Equal(3))
+			Ω(*spec.Schemas["garden.ProcessSpec"].Properties["env"].MaxItems).Should(Equal(3))
+			Ω(*spec.Schemas["transport.BulkNetOutRequest"].Properties["rules"].MaxItems).Should(Equal(4))
+
+			Ω(spec.Schemas["garden.ContainerSpec"].Properties["properties"].MaxProperties).Should(BeNil())
+		})
+
+		It("serves the registered property schemas", func() {
+			Ω(spec.PropertySchemas).Should(Equal(map[string]garden.PropertySchema{"deploy": {Type: "object"}}))
+		})
+	})
+
 	Context("and the client sends a CreateRequest", func() {
 		var fakeContainer *fakes.FakeContainer
 
@@ -243,6 +640,17 @@ var _ = Describe("When a client connects", func() {
 			Ω(container.Handle()).Should(Equal("some-handle"))
 		})
 
+		It("rejects a property its registered schema does not accept", func() {
+			apiServer.SetPropertySchemas(map[string]garden.PropertySchema{"deploy": {Type: "object"}})
+
+			_, err := apiClient.Create(garden.ContainerSpec{
+				Properties: garden.Properties{"deploy": "3"},
+			})
+			Ω(err).Should(Equal(garden.InvalidPropertyValueError{Property: "deploy", Reason: "must be of type object"}))
+
+			Ω(serverBackend.CreateCallCount()).Should(BeZero())
+		})
+
 		It("should not log any container spec properties", func() {
 			_, err := apiClient.Create(garden.ContainerSpec{
 				Handle:     "some-handle",
@@ -348,765 +756,3603 @@ var _ = Describe("When a client connects", func() {
 			}))
 		})
 
-		Context("when a grace time is given", func() {
-			var graceTime time.Duration
-
+		Context("when scratch spaces are given", func() {
 			BeforeEach(func() {
-				graceTime = time.Second
+				backendFeatures.providesScratchSpaces = true
+			})
 
-				fakeContainer = new(fakes.FakeContainer)
-				fakeContainer.HandleReturns("doomed-handle")
-				serverBackend.CreateReturns(fakeContainer, nil)
-				serverBackend.LookupReturns(fakeContainer, nil)
+			It("passes them to the backend", func() {
+				scratchSpaces := []garden.ScratchSpec{
+					{Path: "/tmp", ByteLimit: 1024},
+					{Path: "/var/scratch", ByteLimit: 2048},
+				}
+
+				_, err := apiClient.Create(garden.ContainerSpec{
+					Handle:        "some-handle",
+					BindMounts:    []garden.BindMount{{SrcPath: "/src", DstPath: "/var/data"}},
+					ScratchSpaces: scratchSpaces,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.CreateArgsForCall(0).ScratchSpaces).Should(Equal(scratchSpaces))
 			})
 
-			JustBeforeEach(func() {
-				serverBackend.GraceTimeReturns(graceTime)
+			itRejects := func(description string, spec garden.ContainerSpec, expected error) {
+				It("rejects "+description, func() {
+					_, err := apiClient.Create(spec)
+					Ω(err).Should(Equal(expected))
+
+					Ω(serverBackend.CreateCallCount()).Should(BeZero())
+				})
+			}
+
+			itRejects("a relative path",
+				garden.ContainerSpec{ScratchSpaces: []garden.ScratchSpec{{Path: "tmp"}}},
+				garden.InvalidPathError{Path: "tmp", Rule: "not absolute"},
+			)
+
+			itRejects("a path under /proc",
+				garden.ContainerSpec{ScratchSpaces: []garden.ScratchSpec{{Path: "/proc/scratch"}}},
+				garden.InvalidPathError{Path: "/proc/scratch", Rule: "under denied prefix /proc"},
+			)
+
+			itRejects("the same path twice",
+				garden.ContainerSpec{ScratchSpaces: []garden.ScratchSpec{{Path: "/tmp"}, {Path: "/tmp/"}}},
+				garden.InvalidRequestError{Message: "scratch space path /tmp/ overlaps /tmp"},
+			)
+
+			itRejects("a path inside a bind mount",
+				garden.ContainerSpec{
+					BindMounts:    []garden.BindMount{{SrcPath: "/src", DstPath: "/var"}},
+					ScratchSpaces: []garden.ScratchSpec{{Path: "/var/scratch"}},
+				},
+				garden.InvalidRequestError{Message: "scratch space path /var/scratch overlaps /var"},
+			)
+
+			itRejects("a path around a bind mount",
+				garden.ContainerSpec{
+					BindMounts:    []garden.BindMount{{SrcPath: "/src", DstPath: "/var/data"}},
+					ScratchSpaces: []garden.ScratchSpec{{Path: "/var"}},
+				},
+				garden.InvalidRequestError{Message: "scratch space path /var overlaps /var/data"},
+			)
+
+			Context("when the backend cannot mount them", func() {
+				BeforeEach(func() {
+					backendFeatures.providesScratchSpaces = false
+				})
+
+				itRejects("any of them",
+					garden.ContainerSpec{ScratchSpaces: []garden.ScratchSpec{{Path: "/tmp", ByteLimit: 1024}}},
+					garden.UnsupportedOperationError{Message: "the backend does not support scratch_spaces"},
+				)
 			})
+		})
 
-			It("destroys the container after it has been idle for the grace time", func() {
-				before := time.Now()
+		Context("when network_from is given", func() {
+			It("passes it to the backend once the named container is found, if the server does not know its subnet", func() {
+				serverBackend.LookupReturns(new(fakes.FakeContainer), nil)
 
-				_, err := apiClient.Create(garden.ContainerSpec{})
+				_, err := apiClient.Create(garden.ContainerSpec{NetworkFrom: "neighbour"})
 				Ω(err).ShouldNot(HaveOccurred())
 
-				Eventually(serverBackend.DestroyCallCount, 2*time.Second).Should(Equal(1))
-				Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("doomed-handle"))
+				Ω(serverBackend.LookupArgsForCall(0)).Should(Equal("neighbour"))
+				Ω(serverBackend.CreateArgsForCall(0).NetworkFrom).Should(Equal("neighbour"))
+			})
 
-				Ω(time.Since(before)).Should(BeNumerically(">", graceTime), "should not destroy before the grace time expires")
+			It("fails with ContainerNotFoundError without creating if the named container does not exist", func() {
+				serverBackend.LookupReturns(nil, garden.ContainerNotFoundError{Handle: "neighbour"})
+
+				_, err := apiClient.Create(garden.ContainerSpec{NetworkFrom: "neighbour"})
+				Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "neighbour"}))
+
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
 			})
 
-			Context("and a process is running", func() {
-				It("destroys the container after it has been idle for the grace time", func() {
-					fakeProcess := new(fakes.FakeProcess)
-					fakeProcess.IDReturns("doomed-handle")
-					fakeProcess.WaitStub = func() (int, error) {
-						select {}
-					}
-					fakeContainer.RunReturns(fakeProcess, nil)
+			It("fails with the backend's error without creating if it cannot look the named container up", func() {
+				serverBackend.LookupReturns(nil, garden.ServiceUnavailableError{Cause: "backend is restarting"})
 
-					before := time.Now()
+				_, err := apiClient.Create(garden.ContainerSpec{NetworkFrom: "neighbour"})
+				Ω(err).Should(Equal(garden.ServiceUnavailableError{Cause: "backend is restarting"}))
 
-					var clientConnection net.Conn
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
+			})
 
-					apiConnection := connection.NewWithDialerAndLogger(func(string, string) (net.Conn, error) {
-						var err error
-						clientConnection, err = net.DialTimeout(gardenListenNetwork, gardenListenAddr, 2*time.Second)
-						return clientConnection, err
-					}, lagertest.NewTestLogger("api-conn-dialer"))
+			It("fails with ContainerNotFoundError without creating if the named container is being destroyed", func() {
+				destroying := make(chan struct{})
+				finishDestroying := make(chan struct{})
 
-					apiClient = client.New(apiConnection)
+				serverBackend.DestroyStub = func(string) error {
+					close(destroying)
+					<-finishDestroying
+					return nil
+				}
 
-					container, err := apiClient.Create(garden.ContainerSpec{})
-					Ω(err).ShouldNot(HaveOccurred())
+				go apiClient.Destroy("neighbour")
+				defer close(finishDestroying)
 
-					_, err = container.Run(garden.ProcessSpec{}, garden.ProcessIO{})
-					Ω(err).ShouldNot(HaveOccurred())
+				<-destroying
 
-					clientConnection.Close()
+				_, err := apiClient.Create(garden.ContainerSpec{NetworkFrom: "neighbour"})
+				Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "neighbour"}))
 
-					Eventually(serverBackend.DestroyCallCount, 2*time.Second).Should(Equal(1))
-					Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("doomed-handle"))
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
+			})
 
-					Ω(time.Since(before)).Should(BeNumerically("~", graceTime, 100*time.Millisecond))
+			It("rejects a spec that also gives a network", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{
+					Network:     "10.0.0.0/30",
+					NetworkFrom: "neighbour",
 				})
+				Ω(err).Should(Equal(garden.InvalidRequestError{Message: "network and network_from cannot both be specified"}))
+
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
 			})
 
-			Context("but it expires during an API destroy request", func() {
-				BeforeEach(func() {
-					// increase the grace time so that we can API create/destroy before expiration
-					graceTime = time.Second * 3
+			It("passes the subnet of a named container it knows on as the network", func() {
+				serverBackend.LookupReturns(fakeContainer, nil)
 
-					serverBackend.DestroyStub = func(string) error {
-						// sleep for longer than the grace time
-						time.Sleep(graceTime * 2)
-						return nil
-					}
-				})
+				_, err := apiClient.Create(garden.ContainerSpec{Network: "10.0.0.6/30"})
+				Ω(err).ShouldNot(HaveOccurred())
 
-				It("does not reap the container", func() {
-					_, err := apiClient.Create(garden.ContainerSpec{})
-					Ω(err).ShouldNot(HaveOccurred())
-
-					err = apiClient.Destroy("doomed-handle")
-					Ω(err).ShouldNot(HaveOccurred())
+				container, err := apiClient.Create(garden.ContainerSpec{NetworkFrom: "some-handle"})
+				Ω(err).ShouldNot(HaveOccurred())
 
-					Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("doomed-handle"))
-					Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
-				})
-			})
-		})
+				spec := serverBackend.CreateArgsForCall(1)
+				Ω(spec.Network).Should(Equal("10.0.0.4/30"))
+				Ω(spec.NetworkFrom).Should(BeEmpty())
+				Ω(spec.Properties).Should(BeEmpty())
+				Ω(sink.Buffer()).Should(gbytes.Say(`sharing-subnet.*"subnet":"10.0.0.4/30"`))
 
-		Context("when a grace time is not given", func() {
-			It("defaults it to the server's grace time", func() {
-				_, err := apiClient.Create(garden.ContainerSpec{
-					Handle: "some-handle",
-				})
+				info, err := container.Info()
 				Ω(err).ShouldNot(HaveOccurred())
-
-				spec := serverBackend.CreateArgsForCall(0)
-				Ω(spec.GraceTime).Should(Equal(serverContainerGraceTime))
+				Ω(info.Network).Should(Equal("10.0.0.4/30"))
 			})
-		})
 
-		Context("when creating the container fails", func() {
-			BeforeEach(func() {
-				serverBackend.CreateReturns(nil, errors.New("oh no!"))
-			})
+			It("holds a destroy of the named container until the create finishes", func() {
+				serverBackend.LookupReturns(new(fakes.FakeContainer), nil)
 
-			It("returns an error", func() {
-				_, err := apiClient.Create(garden.ContainerSpec{
-					Handle: "some-handle",
-				})
-				Ω(err).Should(HaveOccurred())
-			})
-		})
+				creating := make(chan struct{})
+				finishCreating := make(chan struct{})
+				serverBackend.CreateStub = func(garden.ContainerSpec) (garden.Container, error) {
+					close(creating)
+					<-finishCreating
+					return fakeContainer, nil
+				}
 
-		Context("when creating the container fails with a ServiceUnavailableError", func() {
-			var err error
+				created := make(chan error)
+				go func() {
+					_, err := apiClient.Create(garden.ContainerSpec{NetworkFrom: "neighbour"})
+					created <- err
+				}()
 
-			BeforeEach(func() {
-				serverBackend.CreateReturns(nil, garden.NewServiceUnavailableError("special error"))
+				<-creating
 
-				_, err = apiClient.Create(garden.ContainerSpec{
-					Handle: "some-handle",
-				})
-			})
+				destroyed := make(chan error)
+				go func() {
+					destroyed <- apiClient.Destroy("neighbour")
+				}()
 
-			It("client returns an error with a well formed error msg", func() {
-				Ω(err).Should(MatchError("special error"))
-			})
+				Consistently(serverBackend.DestroyCallCount).Should(BeZero())
 
-			It("client returns an error of type ServiceUnavailableError", func() {
-				_, ok := err.(garden.ServiceUnavailableError)
-				Ω(ok).Should(BeTrue())
+				close(finishCreating)
+				Ω(<-created).Should(Succeed())
+
+				Ω(<-destroyed).Should(Succeed())
+				Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("neighbour"))
 			})
 		})
-	})
 
-	Context("and the client sends a destroy request", func() {
-		It("destroys the container", func() {
-			err := apiClient.Destroy("some-handle")
-			Ω(err).ShouldNot(HaveOccurred())
+		Context("when a network is given", func() {
+			It("leaves the properties alone, and reports the address of its subnet in info", func() {
+				serverBackend.LookupReturns(fakeContainer, nil)
 
-			Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("some-handle"))
-		})
+				properties := garden.Properties{"foo": "bar"}
 
-		Context("concurrent with other destroy requests", func() {
-			var destroying chan struct{}
+				container, err := apiClient.Create(garden.ContainerSpec{
+					Network:    "10.0.0.6/30",
+					Properties: properties,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
 
-			BeforeEach(func() {
-				destroying = make(chan struct{})
+				Ω(serverBackend.CreateArgsForCall(0).Properties).Should(Equal(properties))
 
-				serverBackend.DestroyStub = func(string) error {
-					close(destroying)
-					time.Sleep(time.Second)
-					return nil
-				}
+				info, err := container.Info()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(info.Network).Should(Equal("10.0.0.4/30"))
 			})
 
-			It("only destroys once", func() {
-				go apiClient.Destroy("some-handle")
+			It("forgets the subnet once the container is destroyed", func() {
+				serverBackend.LookupReturns(fakeContainer, nil)
 
-				<-destroying
+				_, err := apiClient.Create(garden.ContainerSpec{Network: "10.0.0.6/30"})
+				Ω(err).ShouldNot(HaveOccurred())
 
-				err := apiClient.Destroy("some-handle")
-				Ω(err).Should(HaveOccurred())
+				Ω(apiClient.Destroy("some-handle")).Should(Succeed())
 
-				Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+				_, err = apiClient.Create(garden.ContainerSpec{NetworkFrom: "some-handle"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.CreateArgsForCall(1).Network).Should(BeEmpty())
+				Ω(serverBackend.CreateArgsForCall(1).NetworkFrom).Should(Equal("some-handle"))
 			})
 		})
 
-		Context("when the container cannot be found", func() {
-			var theError = garden.ContainerNotFoundError{Handle: "some-handle"}
-
+		Context("when the server has a handle generator", func() {
 			BeforeEach(func() {
-				serverBackend.DestroyReturns(theError)
+				serverBackend.LookupStub = func(handle string) (garden.Container, error) {
+					return nil, garden.ContainerNotFoundError{Handle: handle}
+				}
 			})
 
-			It("returns an ContainerNotFoundError", func() {
-				err := apiClient.Destroy("some-handle")
-				Ω(err).Should(MatchError(garden.ContainerNotFoundError{Handle: "some-handle"}))
+			It("generates the handle of a container created without one", func() {
+				apiServer.SetHandleGenerator(&server.SequentialHandles{Prefix: "host-a-"})
+
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+				_, err = apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.CreateArgsForCall(0).Handle).Should(Equal("host-a-1"))
+				Ω(serverBackend.CreateArgsForCall(1).Handle).Should(Equal("host-a-2"))
 			})
-		})
 
-		Context("when destroying the container fails", func() {
-			var theError = errors.New("o no")
+			It("keeps a handle given in the spec", func() {
+				apiServer.SetHandleGenerator(&server.SequentialHandles{Prefix: "host-a-"})
 
-			BeforeEach(func() {
-				serverBackend.DestroyReturns(theError)
+				_, err := apiClient.Create(garden.ContainerSpec{Handle: "chosen"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.CreateArgsForCall(0).Handle).Should(Equal("chosen"))
 			})
 
-			It("returns an error with the same message", func() {
-				err := apiClient.Destroy("some-handle")
-				Ω(err).Should(MatchError("o no"))
+			It("generates random URL-safe handles", func() {
+				apiServer.SetHandleGenerator(server.RandomHandles{})
+
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+				_, err = apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				first := serverBackend.CreateArgsForCall(0).Handle
+				Ω(first).Should(MatchRegexp(`^[A-Za-z0-9_-]{22}$`))
+				Ω(serverBackend.CreateArgsForCall(1).Handle).ShouldNot(Equal(first))
 			})
 
-			Context("and destroying is attempted again", func() {
-				BeforeEach(func() {
-					err := apiClient.Destroy("some-handle")
-					Ω(err).Should(HaveOccurred())
+			It("tries again when the generated handle is taken", func() {
+				serverBackend.LookupStub = func(handle string) (garden.Container, error) {
+					if handle == "host-a-1" {
+						return new(fakes.FakeContainer), nil
+					}
 
-					serverBackend.DestroyReturns(nil)
-				})
+					return nil, garden.ContainerNotFoundError{Handle: handle}
+				}
 
-				It("tries to destroy again", func() {
-					err := apiClient.Destroy("some-handle")
-					Ω(err).ShouldNot(HaveOccurred())
+				apiServer.SetHandleGenerator(&server.SequentialHandles{Prefix: "host-a-"})
 
-					Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("some-handle"))
-				})
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.CreateArgsForCall(0).Handle).Should(Equal("host-a-2"))
 			})
-		})
-	})
 
-	Context("and the client sends a ListRequest", func() {
-		BeforeEach(func() {
-			c1 := new(fakes.FakeContainer)
-			c1.HandleReturns("some-handle")
+			It("fails without creating when the backend cannot tell whether a handle is taken", func() {
+				serverBackend.LookupStub = nil
+				serverBackend.LookupReturns(nil, errors.New("backend unavailable"))
+				apiServer.SetHandleGenerator(&server.SequentialHandles{Prefix: "host-a-"})
 
-			c2 := new(fakes.FakeContainer)
-			c2.HandleReturns("another-handle")
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).Should(MatchError("backend unavailable"))
 
-			c3 := new(fakes.FakeContainer)
-			c3.HandleReturns("super-handle")
+				Ω(serverBackend.LookupCallCount()).Should(Equal(1))
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
+			})
 
-			serverBackend.ContainersReturns([]garden.Container{c1, c2, c3}, nil)
-		})
+			It("gives up without creating when every generated handle is taken", func() {
+				serverBackend.LookupStub = nil
+				serverBackend.LookupReturns(new(fakes.FakeContainer), nil)
+				apiServer.SetHandleGenerator(&server.SequentialHandles{Prefix: "host-a-"})
 
-		It("returns the containers from the backend", func() {
-			containers, err := apiClient.Containers(nil)
-			Ω(err).ShouldNot(HaveOccurred())
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).Should(MatchError("no unused handle generated in 5 attempts"))
 
-			Ω(containers).Should(HaveLen(3))
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
+			})
 
-			handles := make([]string, 3)
-			for i, c := range containers {
-				handles[i] = c.Handle()
-			}
+			It("rejects generated handles that are not URL-safe", func() {
+				apiServer.SetHandleGenerator(&server.SequentialHandles{Prefix: "tenant/"})
 
-			Ω(handles).Should(ContainElement("some-handle"))
-			Ω(handles).Should(ContainElement("another-handle"))
-			Ω(handles).Should(ContainElement("super-handle"))
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).Should(MatchError(`generated handle "tenant/1" is not URL-safe`))
+
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
+			})
 		})
 
-		Context("when getting the containers fails", func() {
+		Context("when intra-subnet isolation is requested", func() {
 			BeforeEach(func() {
-				serverBackend.ContainersReturns(nil, errors.New("oh no!"))
+				backendFeatures.isolatesIntraSubnet = true
 			})
 
-			It("returns an error", func() {
-				_, err := apiClient.Containers(nil)
-				Ω(err).Should(HaveOccurred())
+			It("passes it to the backend", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{
+					Network:            "10.0.0.0/24",
+					IsolateIntraSubnet: true,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.CreateArgsForCall(0).IsolateIntraSubnet).Should(BeTrue())
+				Ω(sink.Buffer()).ShouldNot(gbytes.Say("isolation-has-no-effect"))
 			})
-		})
 
-		Context("and the client sends a ListRequest with a property filter", func() {
-			It("forwards the filter to the backend", func() {
-				_, err := apiClient.Containers(garden.Properties{
-					"foo": "bar",
+			It("warns, but creates the container, when the subnet is a /30", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{
+					Network:            "10.0.0.4/30",
+					IsolateIntraSubnet: true,
 				})
 				Ω(err).ShouldNot(HaveOccurred())
 
-				Ω(serverBackend.ContainersArgsForCall(serverBackend.ContainersCallCount() - 1)).Should(Equal(
-					garden.Properties{
-						"foo": "bar",
-					},
+				Ω(serverBackend.CreateCallCount()).Should(Equal(1))
+				Ω(sink.Buffer()).Should(gbytes.Say(`isolation-has-no-effect.*"network":"10.0.0.4/30"`))
+			})
+
+			It("returns the warning with the created container's info", func() {
+				_, info, err := client.New(connection.New(gardenListenNetwork, gardenListenAddr)).CreateWithInfo(garden.ContainerSpec{
+					Network:            "10.0.0.4/30",
+					IsolateIntraSubnet: true,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(info.Warnings).Should(ConsistOf(
+					"isolate_intra_subnet has no effect: network 10.0.0.4/30 has no room for peers",
 				))
 			})
 
-			It("should not log the properties", func() {
-				_, err := apiClient.Containers(garden.Properties{
-					"hello": "banana",
+			It("returns no warnings when the isolation has peers to isolate from", func() {
+				_, info, err := client.New(connection.New(gardenListenNetwork, gardenListenAddr)).CreateWithInfo(garden.ContainerSpec{
+					Network:            "10.0.0.0/24",
+					IsolateIntraSubnet: true,
 				})
 				Ω(err).ShouldNot(HaveOccurred())
 
-				buffer := sink.Buffer()
-				Expect(buffer).ToNot(gbytes.Say("hello"))
-				Expect(buffer).ToNot(gbytes.Say("banana"))
+				Ω(info.Warnings).Should(BeEmpty())
 			})
 
-			Context("when getting the containers fails", func() {
+			It("does not warn when the backend allocates the subnet, which others may join", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{IsolateIntraSubnet: true})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(sink.Buffer()).ShouldNot(gbytes.Say("isolation-has-no-effect"))
+			})
+
+			It("warns when the subnet is smaller than a /30", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{
+					Network:            "10.0.0.5/32",
+					IsolateIntraSubnet: true,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(sink.Buffer()).Should(gbytes.Say("isolation-has-no-effect"))
+			})
+
+			It("does not warn when the subnet is shared from another container", func() {
+				serverBackend.LookupReturns(new(fakes.FakeContainer), nil)
+
+				_, err := apiClient.Create(garden.ContainerSpec{
+					NetworkFrom:        "neighbour",
+					IsolateIntraSubnet: true,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(sink.Buffer()).ShouldNot(gbytes.Say("isolation-has-no-effect"))
+			})
+
+			Context("when the backend cannot enforce it", func() {
 				BeforeEach(func() {
-					serverBackend.ContainersReturns(nil, errors.New("oh no!"))
+					backendFeatures.isolatesIntraSubnet = false
 				})
 
-				It("should not log the properties", func() {
-					apiClient.Containers(garden.Properties{
-						"hello": "banana",
+				It("refuses the container without creating it", func() {
+					_, err := apiClient.Create(garden.ContainerSpec{
+						Network:            "10.0.0.0/24",
+						IsolateIntraSubnet: true,
 					})
+					Ω(err).Should(Equal(garden.UnsupportedOperationError{Message: "the backend does not support isolate_intra_subnet"}))
 
-					buffer := sink.Buffer()
-					Expect(buffer).ToNot(gbytes.Say("hello"))
-					Expect(buffer).ToNot(gbytes.Say("banana"))
+					Ω(serverBackend.CreateCallCount()).Should(BeZero())
 				})
 			})
-
 		})
-	})
 
-	Context("when a container has been created", func() {
-		var (
-			container garden.Container
-			graceTime time.Duration
+		Context("when privileged containers are disabled", func() {
+			BeforeEach(func() {
+				apiServer.SetAllowPrivileged(false)
+			})
 
-			fakeContainer *fakes.FakeContainer
-		)
+			It("rejects privileged containers without creating them", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{Privileged: true})
+				Ω(err).Should(Equal(garden.PrivilegedContainersDisabledError{}))
 
-		BeforeEach(func() {
-			fakeContainer = new(fakes.FakeContainer)
-			fakeContainer.HandleReturns("some-handle")
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
+			})
 
-			serverBackend.CreateReturns(fakeContainer, nil)
-			serverBackend.LookupReturns(fakeContainer, nil)
-		})
+			It("still creates unprivileged containers", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
 
-		JustBeforeEach(func() {
-			var err error
+				Ω(serverBackend.CreateCallCount()).Should(Equal(1))
+			})
+		})
 
-			container, err = apiClient.Create(garden.ContainerSpec{})
+		It("creates privileged containers by default", func() {
+			_, err := apiClient.Create(garden.ContainerSpec{Privileged: true})
 			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(serverBackend.CreateArgsForCall(0).Privileged).Should(BeTrue())
 		})
 
-		itResetsGraceTimeWhenHandling := func(fn func(time.Duration)) {
-			graceTime := 500 * time.Millisecond
+		It("rejects a bind mount into a denied path without creating", func() {
+			_, err := apiClient.Create(garden.ContainerSpec{
+				BindMounts: []garden.BindMount{{SrcPath: "/src", DstPath: "/sys/fs"}},
+			})
+			Ω(err).Should(Equal(garden.InvalidPathError{Path: "/sys/fs", Rule: "under denied prefix /sys"}))
+
+			Ω(serverBackend.CreateCallCount()).Should(BeZero())
+		})
 
+		Context("when the server's path policy allows a denied path", func() {
 			BeforeEach(func() {
-				serverBackend.GraceTimeReturns(graceTime)
+				apiServer.SetPathPolicy(garden.PathPolicy{
+					AllowedPrefixes: []string{"/dev/shm"},
+					DeniedPrefixes:  []string{"/dev"},
+				})
 			})
 
-			It("resets grace time when handling", func() {
-				fn(graceTime * 2)
-				Expect(serverBackend.DestroyCallCount()).To(Equal(0))
-				Eventually(serverBackend.DestroyCallCount, graceTime+(250*time.Millisecond)).Should(Equal(1))
+			It("accepts a bind mount into it", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{
+					BindMounts: []garden.BindMount{{SrcPath: "/src", DstPath: "/dev/shm/data"}},
+				})
+				Ω(err).ShouldNot(HaveOccurred())
 			})
-		}
+		})
 
-		itFailsWhenTheContainerIsNotFound := func(example func() error) {
-			Context("when the container is not found", func() {
-				It("fails", func() {
-					serverBackend.LookupReturns(nil, errors.New("not found"))
-					Ω(example()).Should(MatchError("not found"))
-				})
+		Context("when the spec holds more entries than the server accepts", func() {
+			BeforeEach(func() {
+				apiServer.SetRequestLimits(server.RequestLimits{MaxProperties: 1, MaxEnv: 1, MaxBindMounts: 1})
 			})
-		}
 
-		Describe("stopping", func() {
-			It("stops the container and sends a StopResponse", func() {
-				err := container.Stop(true)
-				Ω(err).ShouldNot(HaveOccurred())
+			itRejects := func(field string, spec garden.ContainerSpec) {
+				It("rejects too many "+field+" without creating", func() {
+					_, err := apiClient.Create(spec)
+					Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: field, Limit: 1}))
 
-				Ω(fakeContainer.StopArgsForCall(0)).Should(Equal(true))
+					Ω(serverBackend.CreateCallCount()).Should(BeZero())
+				})
+			}
+
+			itRejects("properties", garden.ContainerSpec{Properties: garden.Properties{"a": "1", "b": "2"}})
+			itRejects("env", garden.ContainerSpec{Env: []string{"A=1", "B=2"}})
+			itRejects("bind_mounts", garden.ContainerSpec{BindMounts: []garden.BindMount{
+				{SrcPath: "/a", DstPath: "/a"},
+				{SrcPath: "/b", DstPath: "/b"},
+			}})
+		})
+
+		It("passes the bind mounts to the backend in the order given", func() {
+			bindMounts := []garden.BindMount{
+				{SrcPath: "/src-z", DstPath: "/dst-z"},
+				{SrcPath: "/src-a", DstPath: "/dst-z/inner"},
+				{SrcPath: "/src-m", DstPath: "/dst-a"},
+			}
+
+			_, err := apiClient.Create(garden.ContainerSpec{
+				Handle:     "some-handle",
+				BindMounts: bindMounts,
 			})
+			Ω(err).ShouldNot(HaveOccurred())
 
-			itFailsWhenTheContainerIsNotFound(func() error {
-				return container.Stop(true)
+			Ω(serverBackend.CreateArgsForCall(0).BindMounts).Should(Equal(bindMounts))
+		})
+
+		Context("when the container's info is asked for", func() {
+			var infoClient client.Client
+
+			BeforeEach(func() {
+				infoClient = client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+				fakeContainer.InfoReturns(garden.ContainerInfo{
+					State:       "active",
+					ContainerIP: "10.0.0.2",
+					MappedPorts: []garden.PortMapping{{HostPort: 1234, ContainerPort: 8080}},
+				}, nil)
 			})
 
-			Context("when stopping the container fails", func() {
+			It("returns the info along with the container", func() {
+				container, info, err := infoClient.CreateWithInfo(garden.ContainerSpec{
+					Handle: "some-handle",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(container.Handle()).Should(Equal("some-handle"))
+				Ω(info).Should(Equal(garden.ContainerInfo{
+					State:       "active",
+					ContainerIP: "10.0.0.2",
+					MappedPorts: []garden.PortMapping{{HostPort: 1234, ContainerPort: 8080}},
+				}))
+			})
+
+			Context("and getting the info fails", func() {
 				BeforeEach(func() {
-					fakeContainer.StopReturns(errors.New("oh no!"))
+					fakeContainer.InfoReturns(garden.ContainerInfo{}, errors.New("oh no!"))
 				})
 
-				It("returns an error", func() {
-					err := container.Stop(true)
+				It("destroys the container and returns the error", func() {
+					_, _, err := infoClient.CreateWithInfo(garden.ContainerSpec{
+						Handle: "some-handle",
+					})
+					Ω(err).Should(MatchError("oh no!"))
+
+					Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+					Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("some-handle"))
+				})
+
+				It("records that the create was rolled back", func() {
+					_, _, err := infoClient.CreateWithInfo(garden.ContainerSpec{
+						Handle: "some-handle",
+					})
 					Ω(err).Should(HaveOccurred())
+
+					tombstone, err := infoClient.Tombstone("some-handle")
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(tombstone.Reason).Should(Equal(garden.DestroyReasonCreateRollback))
 				})
 			})
+		})
 
-			itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
-				fakeContainer.StopStub = func(_ bool) error { time.Sleep(timeToSleep); return nil }
-				container.Stop(false)
+		Context("when the container's effective spec is asked for", func() {
+			var specClient client.Client
+
+			BeforeEach(func() {
+				specClient = client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+				fakeContainer.InfoReturns(garden.ContainerInfo{
+					State:       "active",
+					ContainerIP: "10.0.0.2",
+					HostIP:      "10.0.0.1",
+					Properties:  garden.Properties{"a": "b"},
+					MappedPorts: []garden.PortMapping{{HostPort: 1234, ContainerPort: 8080}},
+				}, nil)
 			})
-		})
 
-		Describe("metrics", func() {
+			It("returns the spec as resolved, with what was allocated", func() {
+				_, effective, err := specClient.CreateWithEffectiveSpec(garden.ContainerSpec{
+					Network:    "10.0.0.0/24",
+					Env:        []string{"A=B"},
+					Properties: garden.Properties{"a": "b"},
+				})
+				Ω(err).ShouldNot(HaveOccurred())
 
-			containerMetrics := garden.Metrics{
-				MemoryStat: garden.ContainerMemoryStat{
-					Cache:                   1,
-					Rss:                     2,
-					MappedFile:              3,
-					Pgpgin:                  4,
-					Pgpgout:                 5,
-					Swap:                    6,
-					Pgfault:                 7,
-					Pgmajfault:              8,
-					InactiveAnon:            9,
-					ActiveAnon:              10,
-					InactiveFile:            11,
-					ActiveFile:              12,
-					Unevictable:             13,
-					HierarchicalMemoryLimit: 14,
-					HierarchicalMemswLimit:  15,
-					TotalCache:              16,
-					TotalRss:                17,
-					TotalMappedFile:         18,
-					TotalPgpgin:             19,
-					TotalPgpgout:            20,
-					TotalSwap:               21,
-					TotalPgfault:            22,
-					TotalPgmajfault:         23,
-					TotalInactiveAnon:       24,
-					TotalActiveAnon:         25,
-					TotalInactiveFile:       26,
-					TotalActiveFile:         27,
-					TotalUnevictable:        28,
-					TotalUsageTowardLimit:   7, // TotalRss+(TotalCache-TotalInactiveFile)
-				},
-				CPUStat: garden.ContainerCPUStat{
-					Usage:  1,
-					User:   2,
-					System: 3,
-				},
-				DiskStat: garden.ContainerDiskStat{
-					TotalBytesUsed:  1,
-					TotalInodesUsed: 2,
-				},
-			}
+				created := serverBackend.CreateArgsForCall(0)
+				Ω(created.GraceTime).ShouldNot(BeZero())
 
-			Context("when getting the metrics succeeds", func() {
+				Ω(effective).Should(Equal(garden.EffectiveSpec{
+					ContainerSpec: garden.ContainerSpec{
+						Handle:     "some-handle",
+						GraceTime:  created.GraceTime,
+						Network:    "10.0.0.0/24",
+						Env:        []string{"A=B"},
+						Properties: garden.Properties{"a": "b"},
+					},
+					ContainerIP: "10.0.0.2",
+					HostIP:      "10.0.0.1",
+					MappedPorts: []garden.PortMapping{{HostPort: 1234, ContainerPort: 8080}},
+				}))
+			})
+
+			It("agrees with the info reported right after", func() {
+				serverBackend.LookupReturns(fakeContainer, nil)
+
+				container, effective, err := specClient.CreateWithEffectiveSpec(garden.ContainerSpec{
+					Network: "10.0.0.0/24",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				info, err := container.Info()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(effective.Network).Should(Equal(info.Network))
+				Ω(effective.Properties).Should(Equal(info.Properties))
+				Ω(effective.ContainerIP).Should(Equal(info.ContainerIP))
+				Ω(effective.HostIP).Should(Equal(info.HostIP))
+				Ω(effective.MappedPorts).Should(Equal(info.MappedPorts))
+			})
+
+			It("returns only the fields asked for", func() {
+				_, effective, err := specClient.CreateWithEffectiveSpec(garden.ContainerSpec{
+					Env: []string{"A=B"},
+				}, "handle", "mapped_ports")
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(effective).Should(Equal(garden.EffectiveSpec{
+					ContainerSpec: garden.ContainerSpec{Handle: "some-handle"},
+					MappedPorts:   []garden.PortMapping{{HostPort: 1234, ContainerPort: 8080}},
+				}))
+			})
+
+			It("fails with an InvalidRequestError for an unknown field, without creating", func() {
+				_, _, err := specClient.CreateWithEffectiveSpec(garden.ContainerSpec{}, "handle", "bogus")
+				Ω(err).Should(Equal(garden.InvalidRequestError{Message: `effective spec has no field "bogus"`}))
+
+				Ω(serverBackend.CreateCallCount()).Should(BeZero())
+			})
+
+			Context("and getting the info fails", func() {
 				BeforeEach(func() {
-					fakeContainer.MetricsReturns(
-						containerMetrics,
-						nil,
-					)
+					fakeContainer.InfoReturns(garden.ContainerInfo{}, errors.New("oh no!"))
 				})
 
-				It("returns the metrics from the container", func() {
-					value, err := container.Metrics()
+				It("destroys the container and returns the error", func() {
+					_, _, err := specClient.CreateWithEffectiveSpec(garden.ContainerSpec{})
+					Ω(err).Should(MatchError("oh no!"))
+
+					Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+				})
+			})
+		})
+
+		Context("when a grace time is given", func() {
+			var graceTime time.Duration
+
+			BeforeEach(func() {
+				graceTime = time.Second
+
+				fakeContainer = new(fakes.FakeContainer)
+				fakeContainer.HandleReturns("doomed-handle")
+				serverBackend.CreateReturns(fakeContainer, nil)
+				serverBackend.LookupReturns(fakeContainer, nil)
+			})
+
+			JustBeforeEach(func() {
+				serverBackend.GraceTimeReturns(graceTime)
+			})
+
+			It("destroys the container after it has been idle for the grace time", func() {
+				before := time.Now()
+
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(serverBackend.DestroyCallCount, 2*time.Second).Should(Equal(1))
+				Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("doomed-handle"))
+
+				Ω(time.Since(before)).Should(BeNumerically(">", graceTime), "should not destroy before the grace time expires")
+			})
+
+			It("records that the grace time expired", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				tombstoneClient := client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+				Eventually(func() error {
+					_, err := tombstoneClient.Tombstone("doomed-handle")
+					return err
+				}, 2*time.Second).Should(Succeed())
+
+				tombstone, err := tombstoneClient.Tombstone("doomed-handle")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(tombstone.Reason).Should(Equal(garden.DestroyReasonGraceTime))
+			})
+
+			Context("and a process is running", func() {
+				It("destroys the container after it has been idle for the grace time", func() {
+					fakeProcess := new(fakes.FakeProcess)
+					fakeProcess.IDReturns("doomed-handle")
+					fakeProcess.WaitStub = func() (int, error) {
+						select {}
+					}
+					fakeContainer.RunReturns(fakeProcess, nil)
+
+					before := time.Now()
+
+					var clientConnection net.Conn
+
+					apiConnection := connection.NewWithDialerAndLogger(func(string, string) (net.Conn, error) {
+						var err error
+						clientConnection, err = net.DialTimeout(gardenListenNetwork, gardenListenAddr, 2*time.Second)
+						return clientConnection, err
+					}, lagertest.NewTestLogger("api-conn-dialer"))
+
+					apiClient = client.New(apiConnection)
+
+					container, err := apiClient.Create(garden.ContainerSpec{})
 					Ω(err).ShouldNot(HaveOccurred())
 
-					Ω(value).Should(Equal(containerMetrics))
+					_, err = container.Run(garden.ProcessSpec{}, garden.ProcessIO{})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					clientConnection.Close()
+
+					Eventually(serverBackend.DestroyCallCount, 2*time.Second).Should(Equal(1))
+					Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("doomed-handle"))
+
+					Ω(time.Since(before)).Should(BeNumerically("~", graceTime, 100*time.Millisecond))
 				})
+			})
 
-				itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
-					fakeContainer.MetricsStub = func() (garden.Metrics, error) { time.Sleep(timeToSleep); return garden.Metrics{}, nil }
-					_, err := container.Metrics()
+			Context("but it expires during an API destroy request", func() {
+				BeforeEach(func() {
+					// increase the grace time so that we can API create/destroy before expiration
+					graceTime = time.Second * 3
+
+					serverBackend.DestroyStub = func(string) error {
+						// sleep for longer than the grace time
+						time.Sleep(graceTime * 2)
+						return nil
+					}
+				})
+
+				It("does not reap the container", func() {
+					_, err := apiClient.Create(garden.ContainerSpec{})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					err = apiClient.Destroy("doomed-handle")
 					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("doomed-handle"))
+					Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
 				})
+			})
+		})
 
-				itFailsWhenTheContainerIsNotFound(func() error {
-					_, err := container.Metrics()
-					return err
+		Context("when a grace time is not given", func() {
+			It("defaults it to the server's grace time", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{
+					Handle: "some-handle",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				spec := serverBackend.CreateArgsForCall(0)
+				Ω(spec.GraceTime).Should(Equal(serverContainerGraceTime))
+			})
+		})
+
+		Context("when creating the container fails", func() {
+			BeforeEach(func() {
+				serverBackend.CreateReturns(nil, errors.New("oh no!"))
+			})
+
+			It("returns an error", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{
+					Handle: "some-handle",
 				})
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+
+		Context("when creating the container fails with a ServiceUnavailableError", func() {
+			var err error
+
+			BeforeEach(func() {
+				serverBackend.CreateReturns(nil, garden.NewServiceUnavailableError("special error"))
+
+				_, err = apiClient.Create(garden.ContainerSpec{
+					Handle: "some-handle",
+				})
+			})
+
+			It("client returns an error with a well formed error msg", func() {
+				Ω(err).Should(MatchError("special error"))
+			})
+
+			It("client returns an error of type ServiceUnavailableError", func() {
+				_, ok := err.(garden.ServiceUnavailableError)
+				Ω(ok).Should(BeTrue())
+			})
+		})
+
+		Context("when creating the container fails because a pool is exhausted", func() {
+			BeforeEach(func() {
+				serverBackend.CreateReturns(nil, garden.SubnetPoolExhaustedError{
+					Pool: garden.PoolUsage{Size: 64, InUse: 64},
+				})
+			})
+
+			It("client returns the typed error with the pool usage", func() {
+				_, err := apiClient.Create(garden.ContainerSpec{})
+				Ω(err).Should(Equal(garden.SubnetPoolExhaustedError{
+					Pool: garden.PoolUsage{Size: 64, InUse: 64},
+				}))
+			})
+		})
+	})
+
+	Context("and the client sends a destroy request", func() {
+		It("destroys the container", func() {
+			err := apiClient.Destroy("some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("some-handle"))
+		})
+
+		It("records a tombstone for the container", func() {
+			tombstoneClient := client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+			before := time.Now()
+
+			Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
+			tombstone, err := tombstoneClient.Tombstone("some-handle")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(tombstone.Handle).Should(Equal("some-handle"))
+			Ω(tombstone.Reason).Should(Equal(garden.DestroyReasonAPI))
+			Ω(tombstone.DestroyedAt).Should(BeTemporally(">=", before))
+		})
+
+		It("forgets tombstones older than the retention", func() {
+			apiServer.SetTombstoneRetention(10 * time.Millisecond)
+
+			Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
+			time.Sleep(20 * time.Millisecond)
+
+			_, err := client.New(connection.New(gardenListenNetwork, gardenListenAddr)).Tombstone("some-handle")
+			Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+		})
+
+		It("has no tombstone for a container it has not destroyed", func() {
+			tombstoneClient := client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+			_, err := tombstoneClient.Tombstone("some-handle")
+			Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+		})
+
+		Context("concurrent with other destroy requests", func() {
+			var destroying chan struct{}
+
+			BeforeEach(func() {
+				destroying = make(chan struct{})
+
+				serverBackend.DestroyStub = func(string) error {
+					close(destroying)
+					time.Sleep(time.Second)
+					return nil
+				}
+			})
+
+			It("only destroys once", func() {
+				go apiClient.Destroy("some-handle")
+
+				<-destroying
+
+				err := apiClient.Destroy("some-handle")
+				Ω(err).Should(HaveOccurred())
+
+				Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+			})
+		})
+
+		Context("rapidly followed by creating a container with the same handle", func() {
+			var (
+				destroyingClient client.Client
+				fakeContainer    *fakes.FakeContainer
+			)
+
+			BeforeEach(func() {
+				destroyingClient = client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+				fakeContainer = new(fakes.FakeContainer)
+				fakeContainer.HandleReturns("some-handle")
+				fakeContainer.InfoReturns(garden.ContainerInfo{State: "active"}, nil)
+
+				serverBackend.CreateReturns(fakeContainer, nil)
+				serverBackend.LookupReturns(fakeContainer, nil)
+			})
+
+			It("can reuse the handle as soon as a waiting destroy returns", func() {
+				for i := 0; i < 5; i++ {
+					Ω(destroyingClient.Destroy("some-handle")).Should(Succeed())
+
+					_, err := destroyingClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+					Ω(err).ShouldNot(HaveOccurred())
+				}
+
+				Ω(serverBackend.DestroyCallCount()).Should(Equal(5))
+				Ω(serverBackend.CreateCallCount()).Should(Equal(5))
+			})
+
+			Context("when destroying without waiting", func() {
+				var release chan struct{}
+
+				BeforeEach(func() {
+					release = make(chan struct{})
+
+					serverBackend.DestroyStub = func(string) error {
+						<-release
+						return nil
+					}
+				})
+
+				AfterEach(func() {
+					select {
+					case <-release:
+					default:
+						close(release)
+					}
+				})
+
+				It("returns before the container is destroyed", func() {
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+					Eventually(serverBackend.DestroyCallCount).Should(Equal(1))
+				})
+
+				It("rejects creating a container with the handle until the destroy finishes", func() {
+					before := time.Now()
+
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+					_, err := destroyingClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+					Ω(err).Should(BeAssignableToTypeOf(garden.HandleStillDestroyingError{}))
+					Ω(err.(garden.HandleStillDestroyingError).Handle).Should(Equal("some-handle"))
+					Ω(err.(garden.HandleStillDestroyingError).EstimatedCompletion).Should(BeTemporally(">=", before))
+					Ω(serverBackend.CreateCallCount()).Should(BeZero())
+
+					close(release)
+
+					Eventually(func() error {
+						_, err := destroyingClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+						return err
+					}).Should(Succeed())
+				})
+
+				It("estimates completion from how long destroys have taken", func() {
+					serverBackend.DestroyStub = func(string) error {
+						time.Sleep(100 * time.Millisecond)
+						return nil
+					}
+
+					Ω(destroyingClient.Destroy("some-handle")).Should(Succeed())
+
+					serverBackend.DestroyStub = func(string) error {
+						<-release
+						return nil
+					}
+
+					before := time.Now()
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+					_, err := destroyingClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+					Ω(err).Should(BeAssignableToTypeOf(garden.HandleStillDestroyingError{}))
+					Ω(err.(garden.HandleStillDestroyingError).EstimatedCompletion).Should(BeTemporally(">=", before.Add(100*time.Millisecond)))
+				})
+
+				It("reports the container as destroying in its info", func() {
+					container, err := destroyingClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+					info, err := container.Info()
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(info.State).Should(Equal(garden.StateDestroying))
+
+					close(release)
+
+					Eventually(func() string {
+						info, _ := container.Info()
+						return info.State
+					}).Should(Equal("active"))
+				})
+
+				It("rejects setting the grace time of the container while it is being destroyed", func() {
+					container, err := destroyingClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+					err = container.SetGraceTime(time.Hour)
+					Ω(err).Should(BeAssignableToTypeOf(garden.HandleStillDestroyingError{}))
+					Ω(fakeContainer.SetGraceTimeCallCount()).Should(BeZero())
+				})
+
+				It("fails to destroy the container again while it is being destroyed", func() {
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(MatchError(server.ErrConcurrentDestroy.Error()))
+					Ω(destroyingClient.Destroy("some-handle")).Should(MatchError(server.ErrConcurrentDestroy.Error()))
+				})
+
+				It("records when the destroy finished in the tombstone", func() {
+					before := time.Now()
+					Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+					time.Sleep(50 * time.Millisecond)
+					released := time.Now()
+					close(release)
+
+					var tombstone garden.Tombstone
+					Eventually(func() error {
+						var err error
+						tombstone, err = destroyingClient.Tombstone("some-handle")
+						return err
+					}).Should(Succeed())
+
+					Ω(tombstone.RequestedAt).Should(BeTemporally(">=", before))
+					Ω(tombstone.RequestedAt).Should(BeTemporally("<", released))
+					Ω(tombstone.DestroyedAt).Should(BeTemporally(">=", released))
+				})
+
+				It("can reuse the handle once each destroy finishes", func() {
+					close(release)
+
+					for i := 0; i < 5; i++ {
+						Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+						Eventually(func() error {
+							_, err := destroyingClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+							return err
+						}).Should(Succeed())
+					}
+
+					Ω(serverBackend.DestroyCallCount()).Should(Equal(5))
+				})
+			})
+		})
+
+		Context("when the container cannot be found", func() {
+			var theError = garden.ContainerNotFoundError{Handle: "some-handle"}
+
+			BeforeEach(func() {
+				serverBackend.DestroyReturns(theError)
+			})
+
+			It("returns an ContainerNotFoundError", func() {
+				err := apiClient.Destroy("some-handle")
+				Ω(err).Should(MatchError(garden.ContainerNotFoundError{Handle: "some-handle"}))
+			})
+
+			It("fails to destroy it without waiting, without destroying", func() {
+				serverBackend.LookupReturns(nil, theError)
+
+				err := client.New(connection.New(gardenListenNetwork, gardenListenAddr)).DestroyAsync("some-handle")
+				Ω(err).Should(Equal(theError))
+
+				Ω(serverBackend.DestroyCallCount()).Should(BeZero())
+			})
+		})
+
+		Context("when destroying the container fails", func() {
+			var theError = errors.New("o no")
+
+			BeforeEach(func() {
+				serverBackend.DestroyReturns(theError)
+			})
+
+			It("returns an error with the same message", func() {
+				err := apiClient.Destroy("some-handle")
+				Ω(err).Should(MatchError("o no"))
+			})
+
+			It("does not record a tombstone", func() {
+				apiClient.Destroy("some-handle")
+
+				_, err := client.New(connection.New(gardenListenNetwork, gardenListenAddr)).Tombstone("some-handle")
+				Ω(err).Should(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+			})
+
+			It("records the failure of a destroy that did not wait in the tombstone", func() {
+				destroyingClient := client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+				Ω(destroyingClient.DestroyAsync("some-handle")).Should(Succeed())
+
+				var tombstone garden.Tombstone
+				Eventually(func() error {
+					var err error
+					tombstone, err = destroyingClient.Tombstone("some-handle")
+					return err
+				}).Should(Succeed())
+
+				Ω(tombstone.Reason).Should(Equal(garden.DestroyReasonAPI))
+				Ω(tombstone.Error).Should(Equal("o no"))
+			})
+
+			Context("and destroying is attempted again", func() {
+				BeforeEach(func() {
+					err := apiClient.Destroy("some-handle")
+					Ω(err).Should(HaveOccurred())
+
+					serverBackend.DestroyReturns(nil)
+				})
+
+				It("tries to destroy again", func() {
+					err := apiClient.Destroy("some-handle")
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("some-handle"))
+				})
+			})
+		})
+	})
+
+	Context("and the client retries requests with an idempotency key", func() {
+		keyed := func(key string) garden.Client {
+			return client.New(connection.WithIdempotencyKey(connection.New(gardenListenNetwork, gardenListenAddr), key))
+		}
+
+		It("applies them once", func() {
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Succeed())
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Succeed())
+
+			Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+		})
+
+		It("replays the original response", func() {
+			fakeContainer := new(fakes.FakeContainer)
+			fakeContainer.HandleReturns("first-handle")
+			serverBackend.CreateReturns(fakeContainer, nil)
+
+			first, err := keyed("some-key").Create(garden.ContainerSpec{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			secondContainer := new(fakes.FakeContainer)
+			secondContainer.HandleReturns("second-handle")
+			serverBackend.CreateReturns(secondContainer, nil)
+
+			second, err := keyed("some-key").Create(garden.ContainerSpec{})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(second.Handle()).Should(Equal(first.Handle()))
+			Ω(serverBackend.CreateCallCount()).Should(Equal(1))
+		})
+
+		It("replays client errors", func() {
+			serverBackend.DestroyReturns(garden.ContainerNotFoundError{Handle: "some-handle"})
+
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+
+			Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+		})
+
+		It("executes them again after a server error", func() {
+			serverBackend.DestroyReturns(errors.New("oh no!"))
+
+			Ω(keyed("some-key").Destroy("some-handle")).ShouldNot(Succeed())
+
+			serverBackend.DestroyReturns(nil)
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Succeed())
+
+			Ω(serverBackend.DestroyCallCount()).Should(Equal(2))
+		})
+
+		It("rejects a key reused for a different request", func() {
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Succeed())
+
+			Ω(keyed("some-key").Destroy("other-handle")).Should(Equal(garden.IdempotencyConflictError{Key: "some-key"}))
+			Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+		})
+
+		It("executes them again once the window has passed", func() {
+			apiServer.SetIdempotencyWindow(10 * time.Millisecond)
+
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Succeed())
+			time.Sleep(20 * time.Millisecond)
+			Ω(keyed("some-key").Destroy("some-handle")).Should(Succeed())
+
+			Ω(serverBackend.DestroyCallCount()).Should(Equal(2))
+		})
+
+		It("keeps a request still in flight past the window, for retries to wait on", func() {
+			apiServer.SetIdempotencyWindow(10 * time.Millisecond)
+
+			created := make(chan struct{})
+			serverBackend.CreateStub = func(garden.ContainerSpec) (garden.Container, error) {
+				<-created
+
+				fakeContainer := new(fakes.FakeContainer)
+				fakeContainer.HandleReturns("some-handle")
+				return fakeContainer, nil
+			}
+
+			handles := make(chan string, 2)
+			create := func() {
+				defer GinkgoRecover()
+
+				container, err := keyed("some-key").Create(garden.ContainerSpec{})
+				Ω(err).ShouldNot(HaveOccurred())
+				handles <- container.Handle()
+			}
+
+			go create()
+			Eventually(serverBackend.CreateCallCount).Should(Equal(1))
+
+			time.Sleep(20 * time.Millisecond)
+
+			go create()
+			Consistently(serverBackend.CreateCallCount).Should(Equal(1))
+
+			close(created)
+
+			Eventually(handles).Should(Receive(Equal("some-handle")))
+			Eventually(handles).Should(Receive(Equal("some-handle")))
+			Ω(serverBackend.CreateCallCount()).Should(Equal(1))
+		})
+
+		It("rejects a body larger than the server reads for a key without executing it", func() {
+			apiServer.SetRequestLimits(server.RequestLimits{MaxIdempotentBodyBytes: 16})
+
+			_, err := keyed("some-key").Create(garden.ContainerSpec{Handle: "a-handle-too-long-to-fit"})
+			Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: garden.RequestBodyField, Limit: 16}))
+
+			Ω(serverBackend.CreateCallCount()).Should(BeZero())
+		})
+
+		It("executes requests without a key every time", func() {
+			Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+			Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
+			Ω(serverBackend.DestroyCallCount()).Should(Equal(2))
+		})
+	})
+
+	Context("and the client sends a destroy matching request", func() {
+		var destroyClient client.Client
+
+		BeforeEach(func() {
+			destroyClient = client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+			c1 := new(fakes.FakeContainer)
+			c1.HandleReturns("some-handle")
+
+			c2 := new(fakes.FakeContainer)
+			c2.HandleReturns("another-handle")
+
+			serverBackend.ContainersReturns([]garden.Container{c1, c2}, nil)
+		})
+
+		It("destroys the containers matching the filter", func() {
+			handles, multiErr, err := destroyClient.DestroyMatching(garden.Properties{"foo": "bar"}, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(multiErr).Should(BeNil())
+
+			Ω(handles).Should(Equal([]string{"another-handle", "some-handle"}))
+
+			Ω(serverBackend.ContainersArgsForCall(serverBackend.ContainersCallCount() - 1)).Should(Equal(garden.Properties{"foo": "bar"}))
+
+			Ω(serverBackend.DestroyCallCount()).Should(Equal(2))
+			Ω(serverBackend.DestroyArgsForCall(0)).Should(Equal("another-handle"))
+			Ω(serverBackend.DestroyArgsForCall(1)).Should(Equal("some-handle"))
+		})
+
+		Context("when it is a dry run", func() {
+			It("returns the matching handles without destroying them", func() {
+				handles, multiErr, err := destroyClient.DestroyMatching(garden.Properties{"foo": "bar"}, true)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(multiErr).Should(BeNil())
+
+				Ω(handles).Should(Equal([]string{"another-handle", "some-handle"}))
+
+				Ω(serverBackend.DestroyCallCount()).Should(BeZero())
+			})
+		})
+
+		Context("when destroying one of the containers fails", func() {
+			BeforeEach(func() {
+				serverBackend.DestroyStub = func(handle string) error {
+					if handle == "some-handle" {
+						return garden.ContainerNotFoundError{Handle: handle}
+					}
+
+					return nil
+				}
+			})
+
+			It("destroys the rest and reports the failure", func() {
+				handles, multiErr, err := destroyClient.DestroyAll(false)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(handles).Should(Equal([]string{"another-handle"}))
+
+				Ω(multiErr).ShouldNot(BeNil())
+				Ω(multiErr.Errors).Should(HaveLen(1))
+				Ω(multiErr.Errors["some-handle"].Err).Should(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+			})
+		})
+
+		Context("when the filter is empty", func() {
+			It("rejects it without listing", func() {
+				listed := serverBackend.ContainersCallCount()
+
+				_, _, err := destroyClient.DestroyMatching(nil, false)
+				Ω(err).Should(BeAssignableToTypeOf(garden.InvalidRequestError{}))
+
+				Ω(serverBackend.ContainersCallCount()).Should(Equal(listed))
+				Ω(serverBackend.DestroyCallCount()).Should(BeZero())
+			})
+
+			It("destroys every container when asked to destroy them all", func() {
+				handles, multiErr, err := destroyClient.DestroyAll(false)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(multiErr).Should(BeNil())
+
+				Ω(handles).Should(Equal([]string{"another-handle", "some-handle"}))
+				Ω(serverBackend.DestroyCallCount()).Should(Equal(2))
+			})
+		})
+
+		Context("when the filter holds more properties than the server accepts", func() {
+			BeforeEach(func() {
+				apiServer.SetRequestLimits(server.RequestLimits{MaxProperties: 1})
+			})
+
+			It("rejects it without listing", func() {
+				listed := serverBackend.ContainersCallCount()
+
+				_, _, err := destroyClient.DestroyMatching(garden.Properties{"a": "1", "b": "2"}, false)
+				Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: "properties", Limit: 1}))
+
+				Ω(serverBackend.ContainersCallCount()).Should(Equal(listed))
+			})
+		})
+
+		Context("when listing the containers fails", func() {
+			BeforeEach(func() {
+				serverBackend.ContainersReturns(nil, errors.New("oh no!"))
+			})
+
+			It("returns the error", func() {
+				_, _, err := destroyClient.DestroyMatching(garden.Properties{"foo": "bar"}, false)
+				Ω(err).Should(MatchError("oh no!"))
+
+				Ω(serverBackend.DestroyCallCount()).Should(BeZero())
+			})
+		})
+	})
+
+	Context("and the client sends a ListRequest", func() {
+		BeforeEach(func() {
+			c1 := new(fakes.FakeContainer)
+			c1.HandleReturns("some-handle")
+
+			c2 := new(fakes.FakeContainer)
+			c2.HandleReturns("another-handle")
+
+			c3 := new(fakes.FakeContainer)
+			c3.HandleReturns("super-handle")
+
+			serverBackend.ContainersReturns([]garden.Container{c1, c2, c3}, nil)
+		})
+
+		It("returns the containers from the backend", func() {
+			containers, err := apiClient.Containers(nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(containers).Should(HaveLen(3))
+
+			handles := make([]string, 3)
+			for i, c := range containers {
+				handles[i] = c.Handle()
+			}
+
+			Ω(handles).Should(ContainElement("some-handle"))
+			Ω(handles).Should(ContainElement("another-handle"))
+			Ω(handles).Should(ContainElement("super-handle"))
+		})
+
+		Context("when the backend returns the containers in any order, with duplicates", func() {
+			BeforeEach(func() {
+				names := []string{"some-handle", "another-handle", "super-handle", "another-handle"}
+
+				containers := []garden.Container{}
+				for _, i := range rand.Perm(len(names)) {
+					c := new(fakes.FakeContainer)
+					c.HandleReturns(names[i])
+					containers = append(containers, c)
+				}
+
+				serverBackend.ContainersReturns(containers, nil)
+			})
+
+			It("returns each handle once, sorted", func() {
+				containers, err := apiClient.Containers(nil)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				handles := []string{}
+				for _, c := range containers {
+					handles = append(handles, c.Handle())
+				}
+
+				Ω(handles).Should(Equal([]string{"another-handle", "some-handle", "super-handle"}))
+			})
+		})
+
+		Context("when getting the containers fails", func() {
+			BeforeEach(func() {
+				serverBackend.ContainersReturns(nil, errors.New("oh no!"))
+			})
+
+			It("returns an error", func() {
+				_, err := apiClient.Containers(nil)
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+
+		Context("and the client sends a ListRequest with a property filter", func() {
+			It("forwards the filter to the backend", func() {
+				_, err := apiClient.Containers(garden.Properties{
+					"foo": "bar",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.ContainersArgsForCall(serverBackend.ContainersCallCount() - 1)).Should(Equal(
+					garden.Properties{
+						"foo": "bar",
+					},
+				))
+			})
+
+			It("should not log the properties", func() {
+				_, err := apiClient.Containers(garden.Properties{
+					"hello": "banana",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				buffer := sink.Buffer()
+				Expect(buffer).ToNot(gbytes.Say("hello"))
+				Expect(buffer).ToNot(gbytes.Say("banana"))
+			})
+
+			Context("when getting the containers fails", func() {
+				BeforeEach(func() {
+					serverBackend.ContainersReturns(nil, errors.New("oh no!"))
+				})
+
+				It("should not log the properties", func() {
+					apiClient.Containers(garden.Properties{
+						"hello": "banana",
+					})
+
+					buffer := sink.Buffer()
+					Expect(buffer).ToNot(gbytes.Say("hello"))
+					Expect(buffer).ToNot(gbytes.Say("banana"))
+				})
+			})
+
+		})
+	})
+
+	Context("and the client asks for a page of the containers", func() {
+		var pageClient client.Client
+
+		BeforeEach(func() {
+			pageClient = client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+			containers := []garden.Container{}
+			for _, handle := range []string{"e", "b", "d", "a", "c", "b"} {
+				c := new(fakes.FakeContainer)
+				c.HandleReturns(handle)
+				containers = append(containers, c)
+			}
+
+			serverBackend.ContainersReturns(containers, nil)
+		})
+
+		It("pages through the handles in order, each once", func() {
+			page, err := pageClient.ContainersPage(garden.Properties{"foo": "bar"}, garden.ListOptions{Limit: 2})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page.Handles).Should(Equal([]string{"a", "b"}))
+			Ω(page.NextToken).ShouldNot(BeEmpty())
+
+			Ω(serverBackend.ContainersArgsForCall(serverBackend.ContainersCallCount() - 1)).Should(Equal(garden.Properties{"foo": "bar"}))
+
+			page, err = pageClient.ContainersPage(garden.Properties{"foo": "bar"}, garden.ListOptions{Limit: 2, Token: page.NextToken})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page.Handles).Should(Equal([]string{"c", "d"}))
+
+			page, err = pageClient.ContainersPage(garden.Properties{"foo": "bar"}, garden.ListOptions{Limit: 2, Token: page.NextToken})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page.Handles).Should(Equal([]string{"e"}))
+			Ω(page.NextToken).Should(BeEmpty())
+		})
+
+		It("returns everything in one page without a limit", func() {
+			page, err := pageClient.ContainersPage(nil, garden.ListOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page.Handles).Should(Equal([]string{"a", "b", "c", "d", "e"}))
+			Ω(page.NextToken).Should(BeEmpty())
+		})
+
+		It("neither skips nor repeats containers when one is destroyed between pages", func() {
+			page, err := pageClient.ContainersPage(nil, garden.ListOptions{Limit: 2})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			remaining := []garden.Container{}
+			for _, handle := range []string{"a", "c", "d", "e"} {
+				c := new(fakes.FakeContainer)
+				c.HandleReturns(handle)
+				remaining = append(remaining, c)
+			}
+			serverBackend.ContainersReturns(remaining, nil)
+
+			page, err = pageClient.ContainersPage(nil, garden.ListOptions{Limit: 2, Token: page.NextToken})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page.Handles).Should(Equal([]string{"c", "d"}))
+		})
+
+		It("rejects a token it did not issue with an InvalidRequestError", func() {
+			_, err := pageClient.ContainersPage(nil, garden.ListOptions{Token: "not a token!"})
+			Ω(err).Should(Equal(garden.InvalidRequestError{Message: `invalid list token: "not a token!"`}))
+		})
+
+		It("responds 400 to a tampered token", func() {
+			response, err := http.Post(
+				fmt.Sprintf("http://%s/containers/list_page", gardenListenAddr),
+				"application/json",
+				strings.NewReader(`{"token":"bWF0Y2gtMg=="}`),
+			)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer response.Body.Close()
+
+			Ω(response.StatusCode).Should(Equal(http.StatusBadRequest))
+		})
+
+		Context("when getting the containers fails", func() {
+			BeforeEach(func() {
+				serverBackend.ContainersReturns(nil, errors.New("oh no!"))
+			})
+
+			It("returns an error", func() {
+				_, err := pageClient.ContainersPage(nil, garden.ListOptions{Limit: 2})
+				Ω(err).Should(MatchError("oh no!"))
+			})
+		})
+	})
+
+	Context("and the client lists the containers with a filter", func() {
+		var (
+			filterClient client.Client
+			containers   map[string]*fakes.FakeContainer
+		)
+
+		BeforeEach(func() {
+			filterClient = client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+
+			containers = map[string]*fakes.FakeContainer{}
+			all := []garden.Container{}
+
+			for handle, properties := range map[string]garden.Properties{
+				"a": {"task-id": "1", "app-guid": "abc-123"},
+				"b": {"app-guid": "abc*def"},
+				"c": {"task-id": "", "app-guid": "abcXdef"},
+				"d": {"owner": "someone"},
+			} {
+				c := new(fakes.FakeContainer)
+				c.HandleReturns(handle)
+				c.PropertiesReturns(properties, nil)
+
+				containers[handle] = c
+				all = append(all, c)
+			}
+
+			serverBackend.ContainersReturns(all, nil)
+		})
+
+		handlesOf := func(containers []garden.Container) []string {
+			handles := []string{}
+			for _, container := range containers {
+				handles = append(handles, container.Handle())
+			}
+
+			return handles
+		}
+
+		It("lists the containers that merely have a property", func() {
+			listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyExists("task-id")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handlesOf(listed)).Should(Equal([]string{"a", "c"}))
+		})
+
+		It("lists the containers whose property has a prefix", func() {
+			listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyHasPrefix("app-guid", "abc")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handlesOf(listed)).Should(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("matches a star in a prefix literally", func() {
+			listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyHasPrefix("app-guid", "abc*")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handlesOf(listed)).Should(Equal([]string{"b"}))
+		})
+
+		It("matches an unescaped star in a glob as a wildcard", func() {
+			listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyGlob("app-guid", "abc*def")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handlesOf(listed)).Should(Equal([]string{"b", "c"}))
+		})
+
+		It("lists the containers that do not match a negated match, including those without the property", func() {
+			listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyNotEquals("app-guid", "abc-123")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handlesOf(listed)).Should(Equal([]string{"b", "c", "d"}))
+		})
+
+		It("combines negated matches with positive ones", func() {
+			listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{
+				garden.PropertyExists("task-id"),
+				garden.PropertyNotGlob("app-guid", "*-123"),
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handlesOf(listed)).Should(Equal([]string{"c"}))
+		})
+
+		It("does not narrow the backend's listing by negated equalities", func() {
+			_, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyNotEquals("app-guid", "abc-123")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(serverBackend.ContainersArgsForCall(serverBackend.ContainersCallCount() - 1)).Should(BeEmpty())
+		})
+
+		It("rejects a malformed filter sent without the client's check with an InvalidPropertyFilterError", func() {
+			match := garden.PropertyMatch{Name: "app-guid", Op: "sounds_like", Value: "abc"}
+
+			_, err := connection.New(gardenListenNetwork, gardenListenAddr).ListFiltered(garden.PropertyFilter{match})
+			Ω(err).Should(Equal(garden.InvalidPropertyFilterError{Match: match, Reason: `unknown op "sounds_like"`}))
+		})
+
+		Context("with structured properties registered", func() {
+			BeforeEach(func() {
+				apiServer.SetPropertySchemas(map[string]garden.PropertySchema{"deploy": {Type: "object"}})
+
+				containers["a"].PropertiesReturns(garden.Properties{"deploy": `{"version":3,"zones":["z1"]}`}, nil)
+				containers["b"].PropertiesReturns(garden.Properties{"deploy": `{"version":4}`}, nil)
+				containers["c"].PropertiesReturns(garden.Properties{"deploy": "not json"}, nil)
+			})
+
+			It("matches the fields inside them by dotted path", func() {
+				listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyEquals("deploy.version", "3")})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(handlesOf(listed)).Should(Equal([]string{"a"}))
+			})
+
+			It("matches array elements by index", func() {
+				listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyExists("deploy.zones.0")})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(handlesOf(listed)).Should(Equal([]string{"a"}))
+			})
+
+			It("does not narrow the backend's listing by paths", func() {
+				_, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyEquals("deploy.version", "3")})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(serverBackend.ContainersArgsForCall(serverBackend.ContainersCallCount() - 1)).Should(BeEmpty())
+			})
+
+			It("does not look inside unregistered properties", func() {
+				listed, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyExists("app-guid.version")})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(listed).Should(BeEmpty())
+			})
+		})
+
+		It("narrows the backend's listing by the filter's equalities", func() {
+			_, err := filterClient.ContainersWithFilter(garden.PropertyFilter{
+				garden.PropertyEquals("app-guid", "abc-123"),
+				garden.PropertyExists("task-id"),
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(serverBackend.ContainersArgsForCall(serverBackend.ContainersCallCount() - 1)).Should(Equal(garden.Properties{"app-guid": "abc-123"}))
+		})
+
+		It("rejects a match that is not well formed", func() {
+			_, err := connection.New(gardenListenNetwork, gardenListenAddr).ListFiltered(garden.PropertyFilter{
+				{Name: "app-guid", Op: "like", Value: "abc%"},
+			})
+			Ω(err).Should(MatchError(ContainSubstring(`unknown op "like"`)))
+		})
+
+		It("rejects more matches than the server accepts", func() {
+			apiServer.SetRequestLimits(server.RequestLimits{MaxProperties: 1})
+
+			_, err := filterClient.ContainersWithFilter(garden.PropertyFilter{
+				garden.PropertyExists("task-id"),
+				garden.PropertyExists("app-guid"),
+			})
+			Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: "filter", Limit: 1}))
+		})
+
+		Context("when getting a container's properties fails", func() {
+			BeforeEach(func() {
+				containers["c"].PropertiesReturns(nil, errors.New("oh no!"))
+			})
+
+			It("returns an error", func() {
+				_, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyExists("task-id")})
+				Ω(err).Should(MatchError("oh no!"))
+			})
+		})
+
+		It("should not log the filter", func() {
+			_, err := filterClient.ContainersWithFilter(garden.PropertyFilter{garden.PropertyHasPrefix("app-guid", "banana")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			buffer := sink.Buffer()
+			Expect(buffer).ToNot(gbytes.Say("app-guid"))
+			Expect(buffer).ToNot(gbytes.Say("banana"))
+		})
+	})
+
+	Context("when a container has been created", func() {
+		var (
+			container garden.Container
+			graceTime time.Duration
+
+			fakeContainer *fakes.FakeContainer
+		)
+
+		BeforeEach(func() {
+			fakeContainer = new(fakes.FakeContainer)
+			fakeContainer.HandleReturns("some-handle")
+
+			serverBackend.CreateReturns(fakeContainer, nil)
+			serverBackend.LookupReturns(fakeContainer, nil)
+		})
+
+		JustBeforeEach(func() {
+			var err error
+
+			container, err = apiClient.Create(garden.ContainerSpec{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		itResetsGraceTimeWhenHandling := func(fn func(time.Duration)) {
+			graceTime := 500 * time.Millisecond
+
+			BeforeEach(func() {
+				serverBackend.GraceTimeReturns(graceTime)
+			})
+
+			It("resets grace time when handling", func() {
+				fn(graceTime * 2)
+				Expect(serverBackend.DestroyCallCount()).To(Equal(0))
+				Eventually(serverBackend.DestroyCallCount, graceTime+(250*time.Millisecond)).Should(Equal(1))
+			})
+		}
+
+		itFailsWhenTheContainerIsNotFound := func(example func() error) {
+			Context("when the container is not found", func() {
+				It("fails", func() {
+					serverBackend.LookupReturns(nil, errors.New("not found"))
+					Ω(example()).Should(MatchError("not found"))
+				})
+			})
+		}
+
+		Describe("stopping", func() {
+			It("stops the container and sends a StopResponse", func() {
+				err := container.Stop(true)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(fakeContainer.StopArgsForCall(0)).Should(Equal(true))
+			})
+
+			itFailsWhenTheContainerIsNotFound(func() error {
+				return container.Stop(true)
+			})
+
+			Context("when stopping the container fails", func() {
+				BeforeEach(func() {
+					fakeContainer.StopReturns(errors.New("oh no!"))
+				})
+
+				It("returns an error", func() {
+					err := container.Stop(true)
+					Ω(err).Should(HaveOccurred())
+				})
+			})
+
+			itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+				fakeContainer.StopStub = func(_ bool) error { time.Sleep(timeToSleep); return nil }
+				container.Stop(false)
+			})
+		})
+
+		Describe("metrics", func() {
+
+			containerMetrics := garden.Metrics{
+				MemoryStat: garden.ContainerMemoryStat{
+					Cache:                   1,
+					Rss:                     2,
+					MappedFile:              3,
+					Pgpgin:                  4,
+					Pgpgout:                 5,
+					Swap:                    6,
+					Pgfault:                 7,
+					Pgmajfault:              8,
+					InactiveAnon:            9,
+					ActiveAnon:              10,
+					InactiveFile:            11,
+					ActiveFile:              12,
+					Unevictable:             13,
+					HierarchicalMemoryLimit: 14,
+					HierarchicalMemswLimit:  15,
+					TotalCache:              16,
+					TotalRss:                17,
+					TotalMappedFile:         18,
+					TotalPgpgin:             19,
+					TotalPgpgout:            20,
+					TotalSwap:               21,
+					TotalPgfault:            22,
+					TotalPgmajfault:         23,
+					TotalInactiveAnon:       24,
+					TotalActiveAnon:         25,
+					TotalInactiveFile:       26,
+					TotalActiveFile:         27,
+					TotalUnevictable:        28,
+					TotalUsageTowardLimit:   7, // TotalRss+(TotalCache-TotalInactiveFile)
+				},
+				CPUStat: garden.ContainerCPUStat{
+					Usage:  1,
+					User:   2,
+					System: 3,
+				},
+				DiskStat: garden.ContainerDiskStat{
+					TotalBytesUsed:  1,
+					TotalInodesUsed: 2,
+				},
+			}
+
+			Context("when getting the metrics succeeds", func() {
+				BeforeEach(func() {
+					fakeContainer.MetricsReturns(
+						containerMetrics,
+						nil,
+					)
+				})
+
+				It("returns the metrics from the container", func() {
+					value, err := container.Metrics()
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(value).Should(Equal(containerMetrics))
+				})
+
+				itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+					fakeContainer.MetricsStub = func() (garden.Metrics, error) { time.Sleep(timeToSleep); return garden.Metrics{}, nil }
+					_, err := container.Metrics()
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				itFailsWhenTheContainerIsNotFound(func() error {
+					_, err := container.Metrics()
+					return err
+				})
+			})
+
+			Context("when getting the metrics fails", func() {
+				BeforeEach(func() {
+					fakeContainer.MetricsReturns(garden.Metrics{}, errors.New("o no"))
+				})
+
+				It("returns an error", func() {
+					metrics, err := container.Metrics()
+					Ω(err).Should(HaveOccurred())
+					Ω(metrics).Should(Equal(garden.Metrics{}))
+				})
+			})
+		})
+
+		Describe("metrics history", func() {
+			var recordMetrics bool
+
+			containerMetrics := garden.Metrics{
+				MemoryStat: garden.ContainerMemoryStat{TotalRss: 42},
+			}
+
+			BeforeEach(func() {
+				recordMetrics = true
+
+				apiServer.SetMetricsRecording(server.MetricsRecording{
+					Interval:         10 * time.Millisecond,
+					Samples:          5,
+					MaxSamples:       10,
+					TombstoneSamples: 2,
+				})
+
+				fakeContainer.MetricsReturns(containerMetrics, nil)
+			})
+
+			JustBeforeEach(func() {
+				var err error
+
+				container, err = apiClient.Create(garden.ContainerSpec{RecordMetrics: recordMetrics})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				apiClient.Destroy("some-handle")
+			})
+
+			It("marks the container for the recording to survive a restart", func() {
+				Ω(serverBackend.CreateArgsForCall(serverBackend.CreateCallCount() - 1).Properties).Should(HaveKeyWithValue(garden.RecordMetricsProperty, "true"))
+			})
+
+			It("reports the recording in the container's info", func() {
+				info, err := container.Info()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(info.RecordingMetrics).Should(BeTrue())
+			})
+
+			It("samples the container's metrics at the interval, oldest first", func() {
+				Eventually(fakeContainer.MetricsCallCount).Should(BeNumerically(">=", 3))
+
+				history, err := container.(client.Container).MetricsHistory(time.Time{})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(len(history)).Should(BeNumerically(">=", 3))
+
+				for i, sample := range history {
+					Ω(sample.Metrics).Should(Equal(containerMetrics))
+
+					if i > 0 {
+						Ω(sample.SampledAt).Should(BeTemporally(">", history[i-1].SampledAt))
+					}
+				}
+			})
+
+			It("keeps only the configured number of samples", func() {
+				Eventually(fakeContainer.MetricsCallCount).Should(BeNumerically(">=", 8))
+
+				history, err := container.(client.Container).MetricsHistory(time.Time{})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(history).Should(HaveLen(5))
+			})
+
+			It("returns only the samples taken after the given time", func() {
+				Eventually(fakeContainer.MetricsCallCount).Should(BeNumerically(">=", 3))
+
+				history, err := container.(client.Container).MetricsHistory(time.Time{})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				since := history[1].SampledAt
+
+				later, err := container.(client.Container).MetricsHistory(since)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(later).ShouldNot(BeEmpty())
+
+				for _, sample := range later {
+					Ω(sample.SampledAt).Should(BeTemporally(">", since))
+				}
+			})
+
+			It("rejects a since that is not a timestamp", func() {
+				response, err := http.Get(fmt.Sprintf("http://%s/containers/some-handle/metrics/history?since=yesterday", gardenListenAddr))
+				Ω(err).ShouldNot(HaveOccurred())
+				defer response.Body.Close()
+
+				Ω(response.StatusCode).Should(Equal(http.StatusBadRequest))
+			})
+
+			It("keeps the latest samples in the tombstone once destroyed", func() {
+				Eventually(fakeContainer.MetricsCallCount).Should(BeNumerically(">=", 3))
+
+				Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
+				tombstone, err := client.New(connection.New(gardenListenNetwork, gardenListenAddr)).Tombstone("some-handle")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(tombstone.Metrics).Should(HaveLen(2))
+				Ω(tombstone.Metrics[0].Metrics).Should(Equal(containerMetrics))
+
+				sampled := fakeContainer.MetricsCallCount()
+				Consistently(fakeContainer.MetricsCallCount, 50*time.Millisecond).Should(Equal(sampled))
+			})
+
+			Context("when the container is destroyed behind the server's back", func() {
+				BeforeEach(func() {
+					fakeContainer.MetricsReturns(garden.Metrics{}, garden.ContainerNotFoundError{Handle: "some-handle"})
+				})
+
+				It("stops sampling it", func() {
+					Eventually(fakeContainer.MetricsCallCount).Should(Equal(1))
+					Consistently(fakeContainer.MetricsCallCount, 50*time.Millisecond).Should(Equal(1))
+				})
+			})
+
+			Context("when the recorded samples are fresh", func() {
+				BeforeEach(func() {
+					apiServer.SetMetricsRecording(server.MetricsRecording{
+						Interval: time.Hour,
+						Samples:  5,
+					})
+				})
+
+				It("answers bulk metrics from the latest sample", func() {
+					Eventually(fakeContainer.MetricsCallCount).Should(Equal(1))
+
+					bulkMetrics, err := apiClient.BulkMetrics([]string{"some-handle"})
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(bulkMetrics).Should(Equal(map[string]garden.ContainerMetricsEntry{
+						"some-handle": {Metrics: containerMetrics},
+					}))
+
+					Ω(serverBackend.BulkMetricsCallCount()).Should(BeZero())
+				})
+			})
+
+			Context("when the container is not recording its metrics", func() {
+				BeforeEach(func() {
+					recordMetrics = false
+				})
+
+				It("has no history", func() {
+					history, err := container.(client.Container).MetricsHistory(time.Time{})
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(history).Should(BeEmpty())
+
+					Ω(fakeContainer.MetricsCallCount()).Should(BeZero())
+				})
+
+				It("does not mark the container", func() {
+					Ω(serverBackend.CreateArgsForCall(serverBackend.CreateCallCount() - 1).Properties).ShouldNot(HaveKey(garden.RecordMetricsProperty))
+				})
+			})
+
+			Context("when there is no room for the container's samples", func() {
+				BeforeEach(func() {
+					apiServer.SetMetricsRecording(server.MetricsRecording{
+						Interval:   10 * time.Millisecond,
+						Samples:    5,
+						MaxSamples: 4,
+					})
+				})
+
+				It("creates the container without a recording", func() {
+					Consistently(fakeContainer.MetricsCallCount, 50*time.Millisecond).Should(BeZero())
+
+					history, err := container.(client.Container).MetricsHistory(time.Time{})
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(history).Should(BeEmpty())
+				})
+
+				It("reports that the container is not recording in its info", func() {
+					info, err := container.Info()
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(info.RecordingMetrics).Should(BeFalse())
+				})
+			})
+
+			itFailsWhenTheContainerIsNotFound(func() error {
+				_, err := container.(client.Container).MetricsHistory(time.Time{})
+				return err
+			})
+		})
+
+		Describe("properties", func() {
+			var (
+				properties  garden.Properties
+				propertiesL sync.Mutex
+			)
+
+			// storeProperties backs the container's properties with the
+			// properties map
+			storeProperties := func() {
+				fakeContainer.PropertiesStub = func() (garden.Properties, error) {
+					propertiesL.Lock()
+					defer propertiesL.Unlock()
+
+					copied := garden.Properties{}
+					for name, value := range properties {
+						copied[name] = value
+					}
+
+					return copied, nil
+				}
+
+				fakeContainer.SetPropertyStub = func(name, value string) error {
+					propertiesL.Lock()
+					defer propertiesL.Unlock()
+
+					properties[name] = value
+					return nil
+				}
+
+				fakeContainer.RemovePropertyStub = func(name string) error {
+					propertiesL.Lock()
+					defer propertiesL.Unlock()
+
+					delete(properties, name)
+					return nil
+				}
+			}
+
+			Describe("getting all", func() {
+				Context("when getting the properties succeeds", func() {
+					BeforeEach(func() {
+						fakeContainer.PropertiesReturns(garden.Properties{"foo": "bar"}, nil)
+					})
+
+					It("returns the properties from the container", func() {
+						value, err := container.Properties()
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(value).Should(Equal(garden.Properties{"foo": "bar"}))
+					})
+
+					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+						fakeContainer.PropertiesStub = func() (garden.Properties, error) { time.Sleep(timeToSleep); return nil, nil }
+						_, err := container.Properties()
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+
+					itFailsWhenTheContainerIsNotFound(func() error {
+						_, err := container.Properties()
+						return err
+					})
+
+					It("should not log any properties", func() {
+						_, err := container.Properties()
+						Ω(err).ShouldNot(HaveOccurred())
+
+						buffer := sink.Buffer()
+						Expect(buffer).ToNot(gbytes.Say("foo"))
+						Expect(buffer).ToNot(gbytes.Say("bar"))
+					})
+				})
+
+				Context("when the container has hundreds of properties", func() {
+					var properties garden.Properties
+
+					BeforeEach(func() {
+						properties = garden.Properties{}
+						for i := 0; i < 500; i++ {
+							properties[fmt.Sprintf("key-%d", i)] = strings.Repeat("v", 100)
+						}
+
+						fakeContainer.PropertiesReturns(properties, nil)
+					})
+
+					It("returns them all from one request", func() {
+						value, err := container.Properties()
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(value).Should(Equal(properties))
+						Ω(fakeContainer.PropertiesCallCount()).Should(Equal(1))
+					})
+				})
+
+				Context("when getting the properties fails", func() {
+					BeforeEach(func() {
+						fakeContainer.PropertiesReturns(nil, errors.New("o no"))
+					})
+
+					It("returns an error", func() {
+						properties, err := container.Properties()
+						Ω(err).Should(HaveOccurred())
+						Ω(properties).Should(BeEmpty())
+					})
+				})
+			})
+
+			Describe("getting", func() {
+				Context("when getting the property succeeds", func() {
+					BeforeEach(func() {
+						fakeContainer.PropertyReturns("some-property-value", nil)
+					})
+
+					It("returns the property from the container", func() {
+						value, err := container.Property("some-property")
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(value).Should(Equal("some-property-value"))
+
+						Ω(fakeContainer.PropertyCallCount()).Should(Equal(1))
+
+						name := fakeContainer.PropertyArgsForCall(0)
+						Ω(name).Should(Equal("some-property"))
+					})
+
+					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+						fakeContainer.PropertyStub = func(string) (string, error) { time.Sleep(timeToSleep); return "", nil }
+						_, err := container.Property("some-property")
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+
+					itFailsWhenTheContainerIsNotFound(func() error {
+						_, err := container.Property("some-property")
+						return err
+					})
+
+					It("should not log any properties", func() {
+						_, err := container.Property("some-property")
+						Ω(err).ShouldNot(HaveOccurred())
+
+						buffer := sink.Buffer()
+						Expect(buffer).ToNot(gbytes.Say("some-property"))
+						Expect(buffer).ToNot(gbytes.Say("some-property-value"))
+					})
+				})
+
+				Context("when getting the property fails", func() {
+					BeforeEach(func() {
+						fakeContainer.PropertyReturns("", errors.New("oh no!"))
+					})
+
+					It("returns an error", func() {
+						value, err := container.Property("some-property")
+						Ω(err).Should(HaveOccurred())
+						Ω(value).Should(BeZero())
+					})
+				})
+			})
+
+			Describe("setting", func() {
+				Context("when setting the property succeeds", func() {
+					BeforeEach(func() {
+						fakeContainer.SetPropertyReturns(nil)
+					})
+
+					It("sets the property on the container", func() {
+						err := container.SetProperty("some-property", "some-value")
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(fakeContainer.SetPropertyCallCount()).Should(Equal(1))
+
+						name, value := fakeContainer.SetPropertyArgsForCall(0)
+						Ω(name).Should(Equal("some-property"))
+						Ω(value).Should(Equal("some-value"))
+					})
+
+					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+						fakeContainer.SetPropertyStub = func(string, string) error { time.Sleep(timeToSleep); return nil }
+						err := container.SetProperty("some-property", "some-value")
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+
+					itFailsWhenTheContainerIsNotFound(func() error {
+						return container.SetProperty("some-property", "some-value")
+					})
+
+					It("should not log any properties", func() {
+						err := container.SetProperty("some-property", "some-value")
+						Ω(err).ShouldNot(HaveOccurred())
+
+						buffer := sink.Buffer()
+						Expect(buffer).ToNot(gbytes.Say("some-property"))
+						Expect(buffer).ToNot(gbytes.Say("some-value"))
+					})
+				})
+
+				Context("when setting the property fails", func() {
+					BeforeEach(func() {
+						fakeContainer.SetPropertyReturns(errors.New("oh no!"))
+					})
+
+					It("returns an error", func() {
+						err := container.SetProperty("some-property", "some-value")
+						Ω(err).Should(HaveOccurred())
+					})
+				})
+			})
+
+			Describe("removing", func() {
+				Context("when removing the property succeeds", func() {
+					BeforeEach(func() {
+						fakeContainer.RemovePropertyReturns(nil)
+					})
+
+					It("returns the property from the container", func() {
+						err := container.RemoveProperty("some-property")
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(fakeContainer.RemovePropertyCallCount()).Should(Equal(1))
+
+						name := fakeContainer.RemovePropertyArgsForCall(0)
+						Ω(name).Should(Equal("some-property"))
+					})
+
+					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+						fakeContainer.RemovePropertyStub = func(string) error { time.Sleep(timeToSleep); return nil }
+						err := container.RemoveProperty("some-property")
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+
+					itFailsWhenTheContainerIsNotFound(func() error {
+						return container.RemoveProperty("some-property")
+					})
+
+					It("should not log any properties", func() {
+						err := container.RemoveProperty("some-property")
+						Ω(err).ShouldNot(HaveOccurred())
+
+						buffer := sink.Buffer()
+						Expect(buffer).ToNot(gbytes.Say("some-property"))
+						Expect(buffer).ToNot(gbytes.Say("some-value"))
+					})
+				})
+
+				Context("when setting the property fails", func() {
+					BeforeEach(func() {
+						fakeContainer.RemovePropertyReturns(errors.New("oh no!"))
+					})
+
+					It("returns an error", func() {
+						err := container.RemoveProperty("some-property")
+						Ω(err).Should(HaveOccurred())
+					})
+				})
+			})
+
+			Describe("comparing and setting", func() {
+				BeforeEach(func() {
+					properties = garden.Properties{"owner": "some-scheduler"}
+					storeProperties()
+				})
+
+				Context("when the property holds the old value", func() {
+					It("sets it and reports the swap", func() {
+						swapped, err := container.(client.Container).CompareAndSetProperty("owner", "some-scheduler", "another-scheduler")
+						Ω(err).ShouldNot(HaveOccurred())
+						Ω(swapped).Should(BeTrue())
+
+						name, value := fakeContainer.SetPropertyArgsForCall(0)
+						Ω(name).Should(Equal("owner"))
+						Ω(value).Should(Equal("another-scheduler"))
+					})
+				})
+
+				Context("when the property holds some other value", func() {
+					It("leaves it alone and reports no swap", func() {
+						swapped, err := container.(client.Container).CompareAndSetProperty("owner", "another-scheduler", "a-third-scheduler")
+						Ω(err).ShouldNot(HaveOccurred())
+						Ω(swapped).Should(BeFalse())
+
+						Ω(fakeContainer.SetPropertyCallCount()).Should(BeZero())
+					})
+				})
+
+				Context("when the property is not set", func() {
+					It("matches an empty old value", func() {
+						swapped, err := container.(client.Container).CompareAndSetProperty("unset", "", "some-value")
+						Ω(err).ShouldNot(HaveOccurred())
+						Ω(swapped).Should(BeTrue())
+					})
+				})
+
+				Context("when several clients race to claim the container", func() {
+					BeforeEach(func() {
+						properties = garden.Properties{}
+
+						getProperties := fakeContainer.PropertiesStub
+						fakeContainer.PropertiesStub = func() (garden.Properties, error) {
+							// widen the window between reading and setting
+							time.Sleep(10 * time.Millisecond)
+							return getProperties()
+						}
+					})
+
+					It("lets exactly one of them win", func() {
+						wins := make(chan string, 10)
+
+						wg := new(sync.WaitGroup)
+						for i := 0; i < 10; i++ {
+							wg.Add(1)
+
+							go func(claimant string) {
+								defer GinkgoRecover()
+								defer wg.Done()
+
+								swapped, err := container.(client.Container).CompareAndSetProperty("owner", "", claimant)
+								Ω(err).ShouldNot(HaveOccurred())
+
+								if swapped {
+									wins <- claimant
+								}
+							}(fmt.Sprintf("scheduler-%d", i))
+						}
+
+						wg.Wait()
+						close(wins)
+
+						Ω(wins).Should(HaveLen(1))
+
+						winner := <-wins
+						owner, err := container.Properties()
+						Ω(err).ShouldNot(HaveOccurred())
+						Ω(owner).Should(HaveKeyWithValue("owner", winner))
+					})
+				})
+
+				Context("when setting the property fails", func() {
+					BeforeEach(func() {
+						fakeContainer.SetPropertyStub = nil
+						fakeContainer.SetPropertyReturns(errors.New("oh no!"))
+					})
+
+					It("returns an error", func() {
+						_, err := container.(client.Container).CompareAndSetProperty("owner", "some-scheduler", "another-scheduler")
+						Ω(err).Should(HaveOccurred())
+					})
+				})
+
+				itFailsWhenTheContainerIsNotFound(func() error {
+					_, err := container.(client.Container).CompareAndSetProperty("owner", "some-scheduler", "another-scheduler")
+					return err
+				})
+
+				It("should not log any properties", func() {
+					_, err := container.(client.Container).CompareAndSetProperty("owner", "some-scheduler", "another-scheduler")
+					Ω(err).ShouldNot(HaveOccurred())
+
+					buffer := sink.Buffer()
+					Expect(buffer).ToNot(gbytes.Say("some-scheduler"))
+					Expect(buffer).ToNot(gbytes.Say("another-scheduler"))
+				})
+			})
+
+			Describe("setting several", func() {
+				BeforeEach(func() {
+					properties = garden.Properties{"owner": "some-scheduler"}
+					storeProperties()
+				})
+
+				It("sets them all", func() {
+					err := container.(client.Container).SetProperties(garden.Properties{
+						"owner": "another-scheduler",
+						"app":   "some-app",
+					})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(container.Properties()).Should(Equal(garden.Properties{
+						"owner": "another-scheduler",
+						"app":   "some-app",
+					}))
+				})
+
+				Context("when setting one of them fails", func() {
+					BeforeEach(func() {
+						setProperty := fakeContainer.SetPropertyStub
+						fakeContainer.SetPropertyStub = func(name, value string) error {
+							if name == "zone" && value == "some-zone" {
+								return errors.New("oh no!")
+							}
+
+							return setProperty(name, value)
+						}
+					})
+
+					It("sets none of them", func() {
+						err := container.(client.Container).SetProperties(garden.Properties{
+							"owner": "another-scheduler",
+							"app":   "some-app",
+							"zone":  "some-zone",
+						})
+						Ω(err).Should(MatchError("oh no!"))
+
+						Ω(container.Properties()).Should(Equal(garden.Properties{"owner": "some-scheduler"}))
+					})
+				})
+
+				It("rejects more properties than the server accepts", func() {
+					apiServer.SetRequestLimits(server.RequestLimits{MaxProperties: 1})
+
+					err := container.(client.Container).SetProperties(garden.Properties{"a": "1", "b": "2"})
+					Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: "properties", Limit: 1}))
+
+					Ω(fakeContainer.SetPropertyCallCount()).Should(BeZero())
+				})
+
+				itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+					fakeContainer.SetPropertyStub = func(string, string) error { time.Sleep(timeToSleep); return nil }
+					err := container.(client.Container).SetProperties(garden.Properties{"app": "some-app"})
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				itFailsWhenTheContainerIsNotFound(func() error {
+					return container.(client.Container).SetProperties(garden.Properties{"app": "some-app"})
+				})
+
+				It("should not log any properties", func() {
+					err := container.(client.Container).SetProperties(garden.Properties{"app": "some-app"})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					buffer := sink.Buffer()
+					Expect(buffer).ToNot(gbytes.Say("some-app"))
+				})
+			})
+
+			Describe("removing several", func() {
+				BeforeEach(func() {
+					properties = garden.Properties{"owner": "some-scheduler", "app": "some-app", "zone": "some-zone"}
+					storeProperties()
+				})
+
+				It("removes them all, ignoring those that are not set", func() {
+					err := container.(client.Container).RemoveProperties([]string{"owner", "app", "unset"})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(container.Properties()).Should(Equal(garden.Properties{"zone": "some-zone"}))
+				})
+
+				Context("when removing one of them fails", func() {
+					BeforeEach(func() {
+						removeProperty := fakeContainer.RemovePropertyStub
+						fakeContainer.RemovePropertyStub = func(name string) error {
+							if name == "zone" {
+								return errors.New("oh no!")
+							}
+
+							return removeProperty(name)
+						}
+					})
+
+					It("removes none of them", func() {
+						err := container.(client.Container).RemoveProperties([]string{"owner", "app", "zone"})
+						Ω(err).Should(MatchError("oh no!"))
+
+						Ω(container.Properties()).Should(Equal(garden.Properties{
+							"owner": "some-scheduler",
+							"app":   "some-app",
+							"zone":  "some-zone",
+						}))
+					})
+				})
+
+				It("rejects more names than the server accepts", func() {
+					apiServer.SetRequestLimits(server.RequestLimits{MaxProperties: 1})
+
+					err := container.(client.Container).RemoveProperties([]string{"owner", "app"})
+					Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: "names", Limit: 1}))
+
+					Ω(fakeContainer.RemovePropertyCallCount()).Should(BeZero())
+				})
+
+				itFailsWhenTheContainerIsNotFound(func() error {
+					return container.(client.Container).RemoveProperties([]string{"owner"})
+				})
+			})
+
+			Describe("with a registered schema", func() {
+				invalid := garden.InvalidPropertyValueError{Property: "deploy", Reason: `missing required field "version"`}
+
+				BeforeEach(func() {
+					properties = garden.Properties{}
+					storeProperties()
+
+					apiServer.SetPropertySchemas(map[string]garden.PropertySchema{
+						"deploy": {Type: "object", Required: []string{"version"}},
+					})
+				})
+
+				It("sets a value the schema accepts", func() {
+					Ω(container.(client.Container).SetJSON("deploy", map[string]int{"version": 3})).Should(Succeed())
+
+					Ω(container.Properties()).Should(Equal(garden.Properties{"deploy": `{"version":3}`}))
+				})
+
+				It("rejects a value the schema does not accept without setting it", func() {
+					err := container.SetProperty("deploy", `{"owner":"me"}`)
+					Ω(err).Should(Equal(invalid))
+
+					Ω(fakeContainer.SetPropertyCallCount()).Should(BeZero())
+				})
+
+				It("rejects such a value among several", func() {
+					err := container.(client.Container).SetProperties(garden.Properties{
+						"owner":  "me",
+						"deploy": `{"owner":"me"}`,
+					})
+					Ω(err).Should(Equal(invalid))
+
+					Ω(fakeContainer.SetPropertyCallCount()).Should(BeZero())
+				})
+
+				It("rejects such a value as the new value of a compare and set", func() {
+					_, err := container.(client.Container).CompareAndSetProperty("deploy", "", `{"owner":"me"}`)
+					Ω(err).Should(Equal(invalid))
+
+					Ω(fakeContainer.SetPropertyCallCount()).Should(BeZero())
+				})
+
+				It("leaves other properties alone", func() {
+					Ω(container.SetProperty("owner", `{"not json`)).Should(Succeed())
+				})
+			})
+		})
+
+		Describe("streaming in", func() {
+			It("rejects a denied path without streaming", func() {
+				err := container.StreamIn(garden.StreamInSpec{User: "frank", Path: "/sys/kernel", TarStream: bytes.NewBufferString("data")})
+				Ω(err).Should(Equal(garden.InvalidPathError{Path: "/sys/kernel", Rule: "under denied prefix /sys"}))
+
+				Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
+			})
+
+			It("rejects a relative path that climbs into a denied path", func() {
+				err := container.StreamIn(garden.StreamInSpec{User: "frank", Path: "../../sys/kernel", TarStream: bytes.NewBufferString("data")})
+				Ω(err).Should(BeAssignableToTypeOf(garden.InvalidPathError{}))
+
+				Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
+			})
+
+			It("streams into the root directory", func() {
+				err := container.StreamIn(garden.StreamInSpec{User: "frank", Path: "/", TarStream: bytes.NewBufferString("data")})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(fakeContainer.StreamInCallCount()).Should(Equal(1))
+			})
+
+			It("streams the file in, waits for completion, and succeeds", func() {
+				data := bytes.NewBufferString("chunk-1;chunk-2;chunk-3;")
+
+				fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+					Ω(spec.Path).Should(Equal("/dst/path"))
+					Ω(spec.User).Should(Equal("frank"))
+					Ω(ioutil.ReadAll(spec.TarStream)).Should(Equal([]byte("chunk-1;chunk-2;chunk-3;")))
+					return nil
+				}
+
+				err := container.StreamIn(garden.StreamInSpec{User: "frank", Path: "/dst/path", TarStream: data})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(fakeContainer.StreamInCallCount()).Should(Equal(1))
+			})
+
+			itFailsWhenTheContainerIsNotFound(func() error {
+				return container.StreamIn(garden.StreamInSpec{Path: "/dst/path"})
+			})
+
+			Context("when copying in to the container fails", func() {
+				BeforeEach(func() {
+					fakeContainer.StreamInReturns(errors.New("oh no!"))
+				})
+
+				It("fails", func() {
+					err := container.StreamIn(garden.StreamInSpec{User: "bob", Path: "/dst/path"})
+					Ω(err).Should(HaveOccurred())
+				})
+			})
+
+			Context("when a checksum is given", func() {
+				// sha256 of "some-tar-contents"
+				const checksum = "b6b90dd7cf8ce493050a0c53595249ccfcbe6abeb542c0dc166424022642b7b1"
+
+				BeforeEach(func() {
+					fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+						_, err := ioutil.ReadAll(spec.TarStream)
+						return err
+					}
+				})
+
+				It("streams in when the checksum matches", func() {
+					err := container.StreamIn(garden.StreamInSpec{
+						Path:      "/dst/path",
+						TarStream: bytes.NewBufferString("some-tar-contents"),
+						Checksum:  checksum,
+					})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(fakeContainer.StreamInArgsForCall(0).Checksum).Should(Equal(checksum))
+				})
+
+				It("fails with a ChecksumMismatchError when the stream does not match", func() {
+					err := container.StreamIn(garden.StreamInSpec{
+						Path:      "/dst/path",
+						TarStream: bytes.NewBufferString("some-corrupted-contents"),
+						Checksum:  checksum,
+					})
+					Ω(err).Should(Equal(garden.ChecksumMismatchError{Handle: "some-handle"}))
+				})
+
+				Context("and the backend stops reading before the end of the stream", func() {
+					BeforeEach(func() {
+						fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+							_, err := spec.TarStream.Read(make([]byte, 4))
+							return err
+						}
+					})
+
+					It("verifies the rest of the stream", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:      "/dst/path",
+							TarStream: bytes.NewBufferString("some-corrupted-contents"),
+							Checksum:  checksum,
+						})
+						Ω(err).Should(Equal(garden.ChecksumMismatchError{Handle: "some-handle"}))
+					})
+
+					It("succeeds when the whole stream matches", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:      "/dst/path",
+							TarStream: bytes.NewBufferString("some-tar-contents"),
+							Checksum:  checksum,
+						})
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+				})
+
+				Context("and the backend stops reading at the end of the archive", func() {
+					var (
+						archive   []byte
+						extracted chan error
+					)
+
+					BeforeEach(func() {
+						buf := new(bytes.Buffer)
+						tarWriter := tar.NewWriter(buf)
+						Ω(tarWriter.WriteHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4})).Should(Succeed())
+						tarWriter.Write([]byte("data"))
+						Ω(tarWriter.Close()).Should(Succeed())
+						archive = buf.Bytes()
+
+						extracted = make(chan error, 1)
+						fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+							tarReader := tar.NewReader(spec.TarStream)
+							for {
+								_, err := tarReader.Next()
+								if err == io.EOF {
+									extracted <- nil
+									return nil
+								}
+
+								if err != nil {
+									extracted <- err
+									return err
+								}
+							}
+						}
+					})
+
+					streamIn := func(digested []byte) error {
+						digest := sha256.Sum256(digested)
+
+						return container.StreamIn(garden.StreamInSpec{
+							Path:      "/dst/path",
+							TarStream: io.MultiReader(bytes.NewReader(archive), strings.NewReader("trailing-bytes")),
+							Checksum:  hex.EncodeToString(digest[:]),
+						})
+					}
+
+					It("fails the backend's read of the end when bytes trailing the archive break the checksum", func() {
+						err := streamIn(archive)
+						Ω(err).Should(Equal(garden.ChecksumMismatchError{Handle: "some-handle"}))
+
+						Ω(<-extracted).Should(Equal(garden.ChecksumMismatchError{Handle: "some-handle"}))
+					})
+
+					It("lets the backend reach the end when the whole stream matches", func() {
+						err := streamIn(append(append([]byte{}, archive...), "trailing-bytes"...))
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(<-extracted).Should(Succeed())
+					})
+				})
+
+				Context("and the backend wraps the read error", func() {
+					BeforeEach(func() {
+						fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+							_, err := ioutil.ReadAll(spec.TarStream)
+							if err != nil {
+								return fmt.Errorf("extracting: %s", err)
+							}
+
+							return nil
+						}
+					})
+
+					It("still fails with a ChecksumMismatchError", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:      "/dst/path",
+							TarStream: bytes.NewBufferString("some-corrupted-contents"),
+							Checksum:  checksum,
+						})
+						Ω(err).Should(Equal(garden.ChecksumMismatchError{Handle: "some-handle"}))
+					})
+				})
+
+				It("rejects a checksum that is not a sha256 digest", func() {
+					err := container.StreamIn(garden.StreamInSpec{
+						Path:      "/dst/path",
+						TarStream: bytes.NewBufferString("some-tar-contents"),
+						Checksum:  "not-a-digest",
+					})
+					Ω(err).Should(Equal(garden.InvalidRequestError{Message: "checksum must be a hex-encoded sha256 digest"}))
+
+					Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
+				})
+			})
+
+			Context("when the container has a disk quota and no expected size is given", func() {
+				BeforeEach(func() {
+					fakeContainer.CurrentDiskLimitsReturns(garden.DiskLimits{ByteHard: 100}, nil)
+					fakeContainer.MetricsReturns(garden.Metrics{
+						DiskStat: garden.ContainerDiskStat{TotalBytesUsed: 90},
+					}, nil)
+
+					fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+						_, err := ioutil.ReadAll(spec.TarStream)
+						return err
+					}
+				})
+
+				It("streams what fits in the remaining quota", func() {
+					err := container.StreamIn(garden.StreamInSpec{
+						Path:      "/dst/path",
+						TarStream: bytes.NewBufferString("0123456789"),
+					})
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				It("aborts the stream with a QuotaExceededError once it reaches the quota", func() {
+					var read []byte
+					fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+						var err error
+						read, err = ioutil.ReadAll(spec.TarStream)
+						return err
+					}
+
+					err := container.StreamIn(garden.StreamInSpec{
+						Path:      "/dst/path",
+						TarStream: bytes.NewBufferString("0123456789abcdef"),
+					})
+					Ω(err).Should(Equal(garden.QuotaExceededError{Handle: "some-handle"}))
+					Ω(len(read)).Should(BeNumerically("<=", 10))
+				})
+
+				Context("and the quota cannot be looked up", func() {
+					BeforeEach(func() {
+						fakeContainer.MetricsReturns(garden.Metrics{}, errors.New("oh no!"))
+					})
+
+					It("streams in regardless", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:      "/dst/path",
+							TarStream: bytes.NewBufferString("0123456789abcdef"),
+						})
+						Ω(err).ShouldNot(HaveOccurred())
+
+						Ω(fakeContainer.StreamInCallCount()).Should(Equal(1))
+					})
+
+					It("fails a stream that claims a size, as it cannot be checked", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("12345"),
+							ExpectedBytes: 5,
+						})
+						Ω(err).Should(MatchError("oh no!"))
+
+						Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
+					})
+				})
+			})
+
+			Context("when the expected size is given", func() {
+				BeforeEach(func() {
+					fakeContainer.CurrentDiskLimitsReturns(garden.DiskLimits{ByteHard: 100}, nil)
+					fakeContainer.MetricsReturns(garden.Metrics{
+						DiskStat: garden.ContainerDiskStat{TotalBytesUsed: 90},
+					}, nil)
+
+					fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+						_, err := ioutil.ReadAll(spec.TarStream)
+						return err
+					}
+				})
+
+				It("rejects one that is not a number without streaming", func() {
+					request, err := http.NewRequest(
+						"PUT",
+						fmt.Sprintf("http://%s/containers/some-handle/files?destination=/dst/path&expected_bytes=lots", gardenListenAddr),
+						bytes.NewBufferString("12345"),
+					)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					response, err := http.DefaultClient.Do(request)
+					Ω(err).ShouldNot(HaveOccurred())
+					defer response.Body.Close()
+
+					Ω(response.StatusCode).Should(Equal(http.StatusBadRequest))
+					Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
+				})
+
+				It("passes it to the backend", func() {
+					err := container.StreamIn(garden.StreamInSpec{
+						Path:          "/dst/path",
+						TarStream:     bytes.NewBufferString("12345"),
+						ExpectedBytes: 5,
+					})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(fakeContainer.StreamInArgsForCall(0).ExpectedBytes).Should(Equal(uint64(5)))
+				})
+
+				Context("and it exceeds the remaining disk quota", func() {
+					It("fails without streaming anything in", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("0123456789abcdef"),
+							ExpectedBytes: 16,
+						})
+						Ω(err).Should(Equal(garden.QuotaExceededError{Handle: "some-handle"}))
+
+						Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
+					})
+				})
+
+				Context("and the stream is larger than it claimed", func() {
+					It("aborts the stream with a StreamSizeMismatchError", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("0123456789abcdef"),
+							ExpectedBytes: 5,
+						})
+						Ω(err).Should(Equal(garden.StreamSizeMismatchError{Handle: "some-handle", ExpectedBytes: 5}))
+					})
+				})
+
+				Context("and the stream is smaller than it claimed", func() {
+					It("fails the end of the stream with a StreamSizeMismatchError", func() {
+						var extracted error
+						fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+							_, extracted = ioutil.ReadAll(spec.TarStream)
+							return extracted
+						}
+
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("0123"),
+							ExpectedBytes: 5,
+						})
+						Ω(err).Should(Equal(garden.StreamSizeMismatchError{Handle: "some-handle", ExpectedBytes: 5}))
+
+						Ω(extracted).Should(Equal(garden.StreamSizeMismatchError{Handle: "some-handle", ExpectedBytes: 5}))
+					})
+
+					It("fails even if the backend stops reading before the end", func() {
+						fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+							_, err := spec.TarStream.Read(make([]byte, 2))
+							return err
+						}
+
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("0123"),
+							ExpectedBytes: 5,
+						})
+						Ω(err).Should(Equal(garden.StreamSizeMismatchError{Handle: "some-handle", ExpectedBytes: 5}))
+					})
+				})
+
+				Context("and the container has no disk quota", func() {
+					BeforeEach(func() {
+						fakeContainer.CurrentDiskLimitsReturns(garden.DiskLimits{}, nil)
+					})
+
+					It("fails a stream smaller than it claimed with a StreamSizeMismatchError", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("0123"),
+							ExpectedBytes: 5,
+						})
+						Ω(err).Should(Equal(garden.StreamSizeMismatchError{Handle: "some-handle", ExpectedBytes: 5}))
+					})
+
+					It("aborts a stream larger than it claimed with a StreamSizeMismatchError", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("0123456789abcdef"),
+							ExpectedBytes: 5,
+						})
+						Ω(err).Should(Equal(garden.StreamSizeMismatchError{Handle: "some-handle", ExpectedBytes: 5}))
+					})
+				})
+
+				Context("and the disk scope is exclusive", func() {
+					BeforeEach(func() {
+						fakeContainer.CurrentDiskLimitsReturns(garden.DiskLimits{
+							ByteHard: 100,
+							Scope:    garden.DiskLimitScopeExclusive,
+						}, nil)
+						fakeContainer.MetricsReturns(garden.Metrics{
+							DiskStat: garden.ContainerDiskStat{TotalBytesUsed: 100, ExclusiveBytesUsed: 10},
+						}, nil)
+					})
+
+					It("checks against the exclusive usage", func() {
+						err := container.StreamIn(garden.StreamInSpec{
+							Path:          "/dst/path",
+							TarStream:     bytes.NewBufferString("0123456789abcdef"),
+							ExpectedBytes: 16,
+						})
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+				})
+			})
+		})
+
+		Describe("streaming out", func() {
+			var streamOut io.ReadCloser
+
+			BeforeEach(func() {
+				streamOut = ioutil.NopCloser(bytes.NewBuffer([]byte("hello-world!")))
+			})
+
+			JustBeforeEach(func() {
+				fakeContainer.StreamOutReturns(streamOut, nil)
+			})
+
+			It("rejects a denied path without streaming", func() {
+				_, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/proc/self/environ"})
+				Ω(err).Should(MatchError(ContainSubstring("under denied prefix /proc")))
+
+				Ω(fakeContainer.StreamOutCallCount()).Should(BeZero())
+			})
+
+			It("accepts a path relative to the user's home directory", func() {
+				_, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "src/path"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(fakeContainer.StreamOutArgsForCall(0).Path).Should(Equal("src/path"))
+			})
+
+			It("streams the bits out and succeeds", func() {
+				reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(reader).ShouldNot(BeZero())
+
+				streamedContent, err := ioutil.ReadAll(reader)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(string(streamedContent)).Should(Equal("hello-world!"))
+
+				Ω(fakeContainer.StreamOutArgsForCall(0)).Should(Equal(garden.StreamOutSpec{User: "frank", Path: "/src/path"}))
+			})
+
+			It("closes the backend's stream once it has been streamed", func() {
+				closer := &closeChecker{}
+				fakeContainer.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
+					return struct {
+						io.Reader
+						io.Closer
+					}{bytes.NewBufferString("hello-world!"), closer}, nil
+				}
+
+				reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				_, err = ioutil.ReadAll(reader)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(closer.Closed).Should(BeTrue())
+			})
+
+			Context("when the connection dies as we're streaming", func() {
+				var closer *closeChecker
+
+				BeforeEach(func() {
+					closer = &closeChecker{}
+
+					streamOut = closer
+				})
+
+				It("closes the backend's stream", func() {
+					reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					err = reader.Close()
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Eventually(closer.Closed).Should(BeTrue())
+				})
+			})
+
+			itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+				fakeContainer.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
+					time.Sleep(timeToSleep)
+					return gbytes.NewBuffer(), nil
+				}
+				reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(reader).ShouldNot(BeZero())
+
+				_, err = ioutil.ReadAll(reader)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			itFailsWhenTheContainerIsNotFound(func() error {
+				_, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
+				return err
 			})
 
-			Context("when getting the metrics fails", func() {
+			Context("when only files changed since a marker are asked for", func() {
+				var marker time.Time
+
 				BeforeEach(func() {
-					fakeContainer.MetricsReturns(garden.Metrics{}, errors.New("o no"))
-				})
+					marker = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+					before := marker.Add(-time.Hour)
+					after := marker.Add(time.Hour)
+
+					buf := new(bytes.Buffer)
+					tarWriter := tar.NewWriter(buf)
+					for _, entry := range []struct {
+						header tar.Header
+						data   string
+					}{
+						{header: tar.Header{Name: "results/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: before}},
+						{header: tar.Header{Name: "results/old", Typeflag: tar.TypeReg, Mode: 0644, ModTime: before}, data: "old"},
+						{header: tar.Header{Name: "results/new", Typeflag: tar.TypeReg, Mode: 0644, ModTime: after}, data: "new"},
+						{header: tar.Header{Name: "results/old-link", Typeflag: tar.TypeSymlink, Linkname: "new", ModTime: before}},
+						{header: tar.Header{Name: "results/new-link", Typeflag: tar.TypeSymlink, Linkname: "old", ModTime: after}},
+						{header: tar.Header{Name: "results/old-hardlink", Typeflag: tar.TypeLink, Linkname: "results/old", ModTime: before}},
+						{header: tar.Header{Name: "results/new-hardlink", Typeflag: tar.TypeLink, Linkname: "results/new", ModTime: after}},
+					} {
+						header := entry.header
+						header.Size = int64(len(entry.data))
+						Ω(tarWriter.WriteHeader(&header)).Should(Succeed())
+						tarWriter.Write([]byte(entry.data))
+					}
+					Ω(tarWriter.Close()).Should(Succeed())
 
-				It("returns an error", func() {
-					metrics, err := container.Metrics()
-					Ω(err).Should(HaveOccurred())
-					Ω(metrics).Should(Equal(garden.Metrics{}))
+					streamOut = ioutil.NopCloser(buf)
 				})
-			})
-		})
 
-		Describe("properties", func() {
-			Describe("getting all", func() {
-				Context("when getting the properties succeeds", func() {
-					BeforeEach(func() {
-						fakeContainer.PropertiesReturns(garden.Properties{"foo": "bar"}, nil)
-					})
+				It("streams out only the entries changed after it, with their directories", func() {
+					reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/results", ChangedSince: marker})
+					Ω(err).ShouldNot(HaveOccurred())
+					defer reader.Close()
 
-					It("returns the properties from the container", func() {
-						value, err := container.Properties()
-						Ω(err).ShouldNot(HaveOccurred())
+					Ω(fakeContainer.StreamOutArgsForCall(0).ChangedSince.Equal(marker)).Should(BeTrue())
 
-						Ω(value).Should(Equal(garden.Properties{"foo": "bar"}))
-					})
+					var names []string
+					contents := map[string]string{}
+					tarReader := tar.NewReader(reader)
+					for {
+						header, err := tarReader.Next()
+						if err == io.EOF {
+							break
+						}
+						Ω(err).ShouldNot(HaveOccurred())
 
-					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
-						fakeContainer.PropertiesStub = func() (garden.Properties, error) { time.Sleep(timeToSleep); return nil, nil }
-						_, err := container.Properties()
+						names = append(names, header.Name)
+						data, err := ioutil.ReadAll(tarReader)
 						Ω(err).ShouldNot(HaveOccurred())
-					})
+						contents[header.Name] = string(data)
+					}
 
-					itFailsWhenTheContainerIsNotFound(func() error {
-						_, err := container.Properties()
-						return err
+					Ω(names).Should(Equal([]string{"results/", "results/new", "results/new-link", "results/new-hardlink"}))
+					Ω(contents["results/new"]).Should(Equal("new"))
+				})
+
+				Context("when the marker falls within the second a file was modified in", func() {
+					BeforeEach(func() {
+						buf := new(bytes.Buffer)
+						tarWriter := tar.NewWriter(buf)
+						for _, header := range []tar.Header{
+							{Name: "results/previous-second", Typeflag: tar.TypeReg, Mode: 0644, ModTime: marker.Add(-time.Second)},
+							{Name: "results/same-second", Typeflag: tar.TypeReg, Mode: 0644, ModTime: marker},
+						} {
+							header := header
+							Ω(tarWriter.WriteHeader(&header)).Should(Succeed())
+						}
+						Ω(tarWriter.Close()).Should(Succeed())
+
+						streamOut = ioutil.NopCloser(buf)
 					})
 
-					It("should not log any properties", func() {
-						_, err := container.Properties()
+					It("keeps the file, as its whole-second time may fall before the marker", func() {
+						reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/results", ChangedSince: marker.Add(500 * time.Millisecond)})
 						Ω(err).ShouldNot(HaveOccurred())
+						defer reader.Close()
+
+						var names []string
+						tarReader := tar.NewReader(reader)
+						for {
+							header, err := tarReader.Next()
+							if err == io.EOF {
+								break
+							}
+							Ω(err).ShouldNot(HaveOccurred())
 
-						buffer := sink.Buffer()
-						Expect(buffer).ToNot(gbytes.Say("foo"))
-						Expect(buffer).ToNot(gbytes.Say("bar"))
+							names = append(names, header.Name)
+						}
+
+						Ω(names).Should(Equal([]string{"results/same-second"}))
 					})
 				})
 
-				Context("when getting the properties fails", func() {
-					BeforeEach(func() {
-						fakeContainer.PropertiesReturns(nil, errors.New("o no"))
-					})
+				It("responds 400 to a marker that is not a timestamp, without streaming", func() {
+					response, err := http.Get(fmt.Sprintf("http://%s/containers/some-handle/files?source=/results&changed_since=yesterday", gardenListenAddr))
+					Ω(err).ShouldNot(HaveOccurred())
+					defer response.Body.Close()
 
-					It("returns an error", func() {
-						properties, err := container.Properties()
-						Ω(err).Should(HaveOccurred())
-						Ω(properties).Should(BeEmpty())
-					})
+					Ω(response.StatusCode).Should(Equal(http.StatusBadRequest))
+
+					var gardenErr garden.Error
+					Ω(json.NewDecoder(response.Body).Decode(&gardenErr)).Should(Succeed())
+					Ω(gardenErr.Err).Should(Equal(garden.InvalidRequestError{Message: `invalid changed_since: "yesterday" is not an RFC 3339 timestamp`}))
+
+					Ω(fakeContainer.StreamOutCallCount()).Should(BeZero())
 				})
 			})
 
-			Describe("getting", func() {
-				Context("when getting the property succeeds", func() {
-					BeforeEach(func() {
-						fakeContainer.PropertyReturns("some-property-value", nil)
-					})
+			Context("when a resumable archive is asked for", func() {
+				var accessedAt time.Time
 
-					It("returns the property from the container", func() {
-						value, err := container.Property("some-property")
-						Ω(err).ShouldNot(HaveOccurred())
+				JustBeforeEach(func() {
+					accessedAt = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+
+					// every walk of the tree reads the files, moving their
+					// access times on
+					fakeContainer.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
+						accessedAt = accessedAt.Add(time.Minute)
+
+						buf := new(bytes.Buffer)
+						tarWriter := tar.NewWriter(buf)
+						for _, entry := range []struct {
+							header tar.Header
+							data   string
+						}{
+							{header: tar.Header{Name: "results/", Typeflag: tar.TypeDir, Mode: 0755}},
+							{header: tar.Header{Name: "results/a", Typeflag: tar.TypeReg, Mode: 0644, Uname: "frank", Gname: "staff", PAXRecords: map[string]string{"SCHILY.xattr.user.tag": "a"}}, data: strings.Repeat("a", 1000)},
+							{header: tar.Header{Name: "results/b", Typeflag: tar.TypeReg, Mode: 0644, Uid: 1000, Gid: 1000}, data: strings.Repeat("b", 1000)},
+						} {
+							header := entry.header
+							header.Size = int64(len(entry.data))
+							header.ModTime = time.Date(2016, 1, 2, 3, 4, 5, 500000000, time.UTC)
+							header.AccessTime = accessedAt
+							header.ChangeTime = accessedAt
+							header.Format = tar.FormatPAX
+							Ω(tarWriter.WriteHeader(&header)).Should(Succeed())
+							tarWriter.Write([]byte(entry.data))
+						}
+						Ω(tarWriter.Close()).Should(Succeed())
 
-						Ω(value).Should(Equal("some-property-value"))
+						return ioutil.NopCloser(buf), nil
+					}
+				})
 
-						Ω(fakeContainer.PropertyCallCount()).Should(Equal(1))
+				streamOutAll := func(spec garden.StreamOutSpec) ([]byte, error) {
+					reader, err := container.StreamOut(spec)
+					if err != nil {
+						return nil, err
+					}
+					defer reader.Close()
 
-						name := fakeContainer.PropertyArgsForCall(0)
-						Ω(name).Should(Equal("some-property"))
-					})
+					return ioutil.ReadAll(reader)
+				}
 
-					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
-						fakeContainer.PropertyStub = func(string) (string, error) { time.Sleep(timeToSleep); return "", nil }
-						_, err := container.Property("some-property")
-						Ω(err).ShouldNot(HaveOccurred())
-					})
+				It("streams out the same bytes for the same files", func() {
+					first, err := streamOutAll(garden.StreamOutSpec{Path: "/results", Resumable: true})
+					Ω(err).ShouldNot(HaveOccurred())
 
-					itFailsWhenTheContainerIsNotFound(func() error {
-						_, err := container.Property("some-property")
-						return err
-					})
+					second, err := streamOutAll(garden.StreamOutSpec{Path: "/results", Resumable: true})
+					Ω(err).ShouldNot(HaveOccurred())
 
-					It("should not log any properties", func() {
-						_, err := container.Property("some-property")
+					Ω(second).Should(Equal(first))
+				})
+
+				It("encodes the entries canonically", func() {
+					archive, err := streamOutAll(garden.StreamOutSpec{Path: "/results", Resumable: true})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					var headers []*tar.Header
+					tarReader := tar.NewReader(bytes.NewReader(archive))
+					for {
+						header, err := tarReader.Next()
+						if err == io.EOF {
+							break
+						}
 						Ω(err).ShouldNot(HaveOccurred())
 
-						buffer := sink.Buffer()
-						Expect(buffer).ToNot(gbytes.Say("some-property"))
-						Expect(buffer).ToNot(gbytes.Say("some-property-value"))
-					})
+						headers = append(headers, header)
+					}
+
+					Ω(headers).Should(HaveLen(3))
+					Ω(headers[1].Name).Should(Equal("results/a"))
+					Ω(headers[1].ModTime.Equal(time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC))).Should(BeTrue())
+					Ω(headers[1].AccessTime.IsZero()).Should(BeTrue())
+					Ω(headers[1].ChangeTime.IsZero()).Should(BeTrue())
+					Ω(headers[1].Uname).Should(BeEmpty())
+					Ω(headers[1].Gname).Should(BeEmpty())
+					Ω(headers[1].PAXRecords).Should(HaveKeyWithValue("SCHILY.xattr.user.tag", "a"))
+					Ω(headers[2].Uid).Should(Equal(1000))
+					Ω(headers[2].Gid).Should(Equal(1000))
 				})
 
-				Context("when getting the property fails", func() {
-					BeforeEach(func() {
-						fakeContainer.PropertyReturns("", errors.New("oh no!"))
-					})
+				It("asks the backend for a resumable walk, but resumes it itself", func() {
+					_, err := streamOutAll(garden.StreamOutSpec{Path: "/results", Resumable: true})
+					Ω(err).ShouldNot(HaveOccurred())
 
-					It("returns an error", func() {
-						value, err := container.Property("some-property")
-						Ω(err).Should(HaveOccurred())
-						Ω(value).Should(BeZero())
-					})
+					Ω(fakeContainer.StreamOutArgsForCall(0)).Should(Equal(garden.StreamOutSpec{Path: "/results", Resumable: true}))
 				})
-			})
 
-			Describe("setting", func() {
-				Context("when setting the property succeeds", func() {
-					BeforeEach(func() {
-						fakeContainer.SetPropertyReturns(nil)
+				Context("and resumed from an offset", func() {
+					var archive []byte
+
+					JustBeforeEach(func() {
+						var err error
+						archive, err = streamOutAll(garden.StreamOutSpec{Path: "/results", Resumable: true})
+						Ω(err).ShouldNot(HaveOccurred())
 					})
 
-					It("sets the property on the container", func() {
-						err := container.SetProperty("some-property", "some-value")
+					generationOf := func(data []byte) string {
+						digest := sha256.Sum256(data)
+						return hex.EncodeToString(digest[:])
+					}
+
+					It("streams out the rest of the archive", func() {
+						rest, err := streamOutAll(garden.StreamOutSpec{
+							Path:       "/results",
+							Resumable:  true,
+							Offset:     700,
+							Generation: generationOf(archive[:700]),
+						})
 						Ω(err).ShouldNot(HaveOccurred())
 
-						Ω(fakeContainer.SetPropertyCallCount()).Should(Equal(1))
+						Ω(rest).Should(Equal(archive[700:]))
+					})
 
-						name, value := fakeContainer.SetPropertyArgsForCall(0)
-						Ω(name).Should(Equal("some-property"))
-						Ω(value).Should(Equal("some-value"))
+					It("fails with a StreamOutChangedError if the start of the archive changed", func() {
+						_, err := streamOutAll(garden.StreamOutSpec{
+							Path:       "/results",
+							Resumable:  true,
+							Offset:     700,
+							Generation: generationOf(bytes.Repeat([]byte("x"), 700)),
+						})
+						Ω(err).Should(Equal(garden.StreamOutChangedError{Handle: "some-handle", Path: "/results"}))
 					})
 
-					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
-						fakeContainer.SetPropertyStub = func(string, string) error { time.Sleep(timeToSleep); return nil }
-						err := container.SetProperty("some-property", "some-value")
-						Ω(err).ShouldNot(HaveOccurred())
+					It("fails with a StreamOutChangedError if the archive got shorter than the offset", func() {
+						_, err := streamOutAll(garden.StreamOutSpec{
+							Path:       "/results",
+							Resumable:  true,
+							Offset:     int64(len(archive) + 1),
+							Generation: generationOf(archive),
+						})
+						Ω(err).Should(Equal(garden.StreamOutChangedError{Handle: "some-handle", Path: "/results"}))
 					})
 
-					itFailsWhenTheContainerIsNotFound(func() error {
-						return container.SetProperty("some-property", "some-value")
+					It("rejects an offset into an archive that is not resumable", func() {
+						_, err := streamOutAll(garden.StreamOutSpec{
+							Path:       "/results",
+							Offset:     700,
+							Generation: generationOf(archive[:700]),
+						})
+						Ω(err).Should(Equal(garden.InvalidRequestError{Message: "offset requires a resumable stream"}))
 					})
 
-					It("should not log any properties", func() {
-						err := container.SetProperty("some-property", "some-value")
+					It("responds 400 to an offset that is not a number", func() {
+						response, err := http.Get(fmt.Sprintf("http://%s/containers/some-handle/files?source=/results&resumable=true&offset=abc", gardenListenAddr))
 						Ω(err).ShouldNot(HaveOccurred())
+						defer response.Body.Close()
 
-						buffer := sink.Buffer()
-						Expect(buffer).ToNot(gbytes.Say("some-property"))
-						Expect(buffer).ToNot(gbytes.Say("some-value"))
+						Ω(response.StatusCode).Should(Equal(http.StatusBadRequest))
 					})
 				})
 
-				Context("when setting the property fails", func() {
-					BeforeEach(func() {
-						fakeContainer.SetPropertyReturns(errors.New("oh no!"))
-					})
+				Context("when the backend walks the tree out of order", func() {
+					var entrySize int
 
-					It("returns an error", func() {
-						err := container.SetProperty("some-property", "some-value")
-						Ω(err).Should(HaveOccurred())
-					})
-				})
-			})
+					JustBeforeEach(func() {
+						fakeContainer.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
+							buf := new(bytes.Buffer)
+							tarWriter := tar.NewWriter(buf)
+							for _, name := range []string{"results/0", "results/b", "results/a"} {
+								Ω(tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(entrySize)})).Should(Succeed())
+								tarWriter.Write(bytes.Repeat([]byte(name[len(name)-1:]), entrySize))
+							}
+							Ω(tarWriter.Close()).Should(Succeed())
 
-			Describe("removing", func() {
-				Context("when removing the property succeeds", func() {
-					BeforeEach(func() {
-						fakeContainer.RemovePropertyReturns(nil)
+							return ioutil.NopCloser(buf), nil
+						}
 					})
 
-					It("returns the property from the container", func() {
-						err := container.RemoveProperty("some-property")
-						Ω(err).ShouldNot(HaveOccurred())
+					outOfOrder := garden.UnsupportedOperationError{
+						Message: `stream out entries are out of order: "results/a" after "results/b"; the backend cannot stream resumably`,
+					}
 
-						Ω(fakeContainer.RemovePropertyCallCount()).Should(Equal(1))
+					Context("early on", func() {
+						BeforeEach(func() {
+							entrySize = 1000
+						})
 
-						name := fakeContainer.RemovePropertyArgsForCall(0)
-						Ω(name).Should(Equal("some-property"))
+						It("refuses the stream before sending any of it", func() {
+							_, err := container.StreamOut(garden.StreamOutSpec{Path: "/results", Resumable: true})
+							Ω(err).Should(Equal(outOfOrder))
+						})
 					})
 
-					itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
-						fakeContainer.RemovePropertyStub = func(string) error { time.Sleep(timeToSleep); return nil }
-						err := container.RemoveProperty("some-property")
-						Ω(err).ShouldNot(HaveOccurred())
-					})
+					Context("past what the server holds back", func() {
+						BeforeEach(func() {
+							entrySize = 768 * 1024
+						})
 
-					itFailsWhenTheContainerIsNotFound(func() error {
-						return container.RemoveProperty("some-property")
-					})
+						It("breaks the stream off at the first entry out of order, and refuses to resume it", func() {
+							reader, err := container.StreamOut(garden.StreamOutSpec{Path: "/results", Resumable: true})
+							Ω(err).ShouldNot(HaveOccurred())
 
-					It("should not log any properties", func() {
-						err := container.RemoveProperty("some-property")
-						Ω(err).ShouldNot(HaveOccurred())
+							received, err := ioutil.ReadAll(reader)
+							Ω(err).Should(HaveOccurred())
+							Ω(reader.Close()).Should(Succeed())
 
-						buffer := sink.Buffer()
-						Expect(buffer).ToNot(gbytes.Say("some-property"))
-						Expect(buffer).ToNot(gbytes.Say("some-value"))
+							Ω(received).ShouldNot(BeEmpty())
+
+							digest := sha256.Sum256(received)
+							_, err = streamOutAll(garden.StreamOutSpec{
+								Path:       "/results",
+								Resumable:  true,
+								Offset:     int64(len(received)),
+								Generation: hex.EncodeToString(digest[:]),
+							})
+							Ω(err).Should(Equal(outOfOrder))
+						})
+
+						It("gives the cause to a resuming reader without retrying it", func() {
+							reader, err := container.(client.Container).ResumableStreamOut(garden.StreamOutSpec{Path: "/results"})
+							Ω(err).ShouldNot(HaveOccurred())
+							defer reader.Close()
+
+							_, err = ioutil.ReadAll(reader)
+							Ω(err).Should(Equal(outOfOrder))
+
+							Ω(fakeContainer.StreamOutCallCount()).Should(Equal(2))
+						})
 					})
 				})
+			})
 
-				Context("when setting the property fails", func() {
-					BeforeEach(func() {
-						fakeContainer.RemovePropertyReturns(errors.New("oh no!"))
-					})
+			Context("when streaming out of the container fails", func() {
+				JustBeforeEach(func() {
+					fakeContainer.StreamOutReturns(nil, errors.New("oh no!"))
+				})
 
-					It("returns an error", func() {
-						err := container.RemoveProperty("some-property")
-						Ω(err).Should(HaveOccurred())
-					})
+				It("returns an error", func() {
+					_, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
+					Ω(err).Should(HaveOccurred())
 				})
 			})
 		})
 
-		Describe("streaming in", func() {
-			It("streams the file in, waits for completion, and succeeds", func() {
-				data := bytes.NewBufferString("chunk-1;chunk-2;chunk-3;")
+		Describe("reading a single file", func() {
+			tarOf := func(header *tar.Header, data []byte) io.ReadCloser {
+				buf := new(bytes.Buffer)
+				tarWriter := tar.NewWriter(buf)
+				Ω(tarWriter.WriteHeader(header)).Should(Succeed())
+				tarWriter.Write(data)
+				Ω(tarWriter.Close()).Should(Succeed())
+				return ioutil.NopCloser(buf)
+			}
 
-				fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
-					Ω(spec.Path).Should(Equal("/dst/path"))
-					Ω(spec.User).Should(Equal("frank"))
-					Ω(ioutil.ReadAll(spec.TarStream)).Should(Equal([]byte("chunk-1;chunk-2;chunk-3;")))
-					return nil
-				}
+			It("returns the contents of the file streamed out of the container", func() {
+				fakeContainer.StreamOutReturns(tarOf(&tar.Header{Name: "file", Mode: 0644, Size: 5, Typeflag: tar.TypeReg}, []byte("hello")), nil)
 
-				err := container.StreamIn(garden.StreamInSpec{User: "frank", Path: "/dst/path", TarStream: data})
+				data, err := container.(client.Container).ReadFile("/some/file")
 				Ω(err).ShouldNot(HaveOccurred())
+				Ω(data).Should(Equal([]byte("hello")))
 
-				Ω(fakeContainer.StreamInCallCount()).Should(Equal(1))
+				Ω(fakeContainer.StreamOutArgsForCall(0)).Should(Equal(garden.StreamOutSpec{Path: "/some/file"}))
 			})
 
-			itFailsWhenTheContainerIsNotFound(func() error {
-				return container.StreamIn(garden.StreamInSpec{Path: "/dst/path"})
+			It("fails with a FileTooLargeError for a file over the limit", func() {
+				apiServer.SetInlineFileLimit(4)
+				defer apiServer.SetInlineFileLimit(1024 * 1024)
+
+				fakeContainer.StreamOutReturns(tarOf(&tar.Header{Name: "file", Mode: 0644, Size: 5, Typeflag: tar.TypeReg}, []byte("hello")), nil)
+
+				_, err := container.(client.Container).ReadFile("/some/file")
+				Ω(err).Should(Equal(garden.FileTooLargeError{Handle: "some-handle", Path: "/some/file", Limit: 4}))
 			})
 
-			Context("when copying in to the container fails", func() {
-				BeforeEach(func() {
-					fakeContainer.StreamInReturns(errors.New("oh no!"))
-				})
+			It("fails with an InvalidRequestError for a directory", func() {
+				fakeContainer.StreamOutReturns(tarOf(&tar.Header{Name: "dir/", Mode: 0755, Typeflag: tar.TypeDir}, nil), nil)
 
-				It("fails", func() {
-					err := container.StreamIn(garden.StreamInSpec{User: "bob", Path: "/dst/path"})
-					Ω(err).Should(HaveOccurred())
-				})
+				_, err := container.(client.Container).ReadFile("/some/dir")
+				Ω(err).Should(Equal(garden.InvalidRequestError{Message: "/some/dir is not a regular file"}))
 			})
-		})
 
-		Describe("streaming out", func() {
-			var streamOut io.ReadCloser
+			It("fails with a FileNotFoundError when nothing is streamed out", func() {
+				fakeContainer.StreamOutReturns(ioutil.NopCloser(new(bytes.Buffer)), nil)
 
-			BeforeEach(func() {
-				streamOut = ioutil.NopCloser(bytes.NewBuffer([]byte("hello-world!")))
+				_, err := container.(client.Container).ReadFile("/some/file")
+				Ω(err).Should(Equal(garden.FileNotFoundError{Handle: "some-handle", Path: "/some/file"}))
 			})
 
-			JustBeforeEach(func() {
-				fakeContainer.StreamOutReturns(streamOut, nil)
+			It("rejects a denied path without streaming", func() {
+				_, err := container.(client.Container).ReadFile("/proc/self/environ")
+				Ω(err).Should(MatchError(ContainSubstring("under denied prefix /proc")))
+
+				Ω(fakeContainer.StreamOutCallCount()).Should(BeZero())
 			})
+		})
 
-			It("streams the bits out and succeeds", func() {
-				reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
-				Ω(err).ShouldNot(HaveOccurred())
-				Ω(reader).ShouldNot(BeZero())
+		Describe("writing a single file", func() {
+			var streamedIn chan *tar.Header
 
-				streamedContent, err := ioutil.ReadAll(reader)
-				Ω(err).ShouldNot(HaveOccurred())
+			BeforeEach(func() {
+				streamedIn = make(chan *tar.Header, 1)
 
-				Ω(string(streamedContent)).Should(Equal("hello-world!"))
+				fakeContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+					tarReader := tar.NewReader(spec.TarStream)
 
-				Ω(fakeContainer.StreamOutArgsForCall(0)).Should(Equal(garden.StreamOutSpec{User: "frank", Path: "/src/path"}))
-			})
+					header, err := tarReader.Next()
+					Ω(err).ShouldNot(HaveOccurred())
 
-			Context("when the connection dies as we're streaming", func() {
-				var closer *closeChecker
+					data, err := ioutil.ReadAll(tarReader)
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(data).Should(Equal([]byte("hello")))
 
-				BeforeEach(func() {
-					closer = &closeChecker{}
+					streamedIn <- header
+					return nil
+				}
+			})
 
-					streamOut = closer
-				})
+			It("streams the file into its directory as a single-entry tar", func() {
+				err := container.(client.Container).WriteFile("/some/dir/file", []byte("hello"), 0600)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(fakeContainer.StreamInArgsForCall(0).Path).Should(Equal("/some/dir"))
 
-				It("closes the backend's stream", func() {
-					reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
-					Ω(err).ShouldNot(HaveOccurred())
+				var header *tar.Header
+				Ω(streamedIn).Should(Receive(&header))
+				Ω(header.Name).Should(Equal("file"))
+				Ω(header.Mode).Should(Equal(int64(0600)))
+				Ω(header.Size).Should(Equal(int64(5)))
+			})
 
-					err = reader.Close()
-					Ω(err).ShouldNot(HaveOccurred())
+			It("writes the file with mode 0644 when none is given", func() {
+				err := container.(client.Container).WriteFile("/some/dir/file", []byte("hello"), 0)
+				Ω(err).ShouldNot(HaveOccurred())
 
-					Eventually(closer.Closed).Should(BeTrue())
-				})
+				var header *tar.Header
+				Ω(streamedIn).Should(Receive(&header))
+				Ω(header.Mode).Should(Equal(int64(0644)))
 			})
 
-			itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
-				fakeContainer.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
-					time.Sleep(timeToSleep)
-					return gbytes.NewBuffer(), nil
+			It("rejects a path that does not name a file, without streaming", func() {
+				for _, filePath := range []string{"", "/", "/some/dir/", "/some/dir/."} {
+					err := container.(client.Container).WriteFile(filePath, []byte("hello"), 0600)
+					Ω(err).Should(Equal(garden.InvalidRequestError{Message: fmt.Sprintf("%q does not name a file", filePath)}))
 				}
-				reader, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
-				Ω(err).ShouldNot(HaveOccurred())
-				Ω(reader).ShouldNot(BeZero())
 
-				_, err = ioutil.ReadAll(reader)
-				Ω(err).ShouldNot(HaveOccurred())
+				Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
 			})
 
-			itFailsWhenTheContainerIsNotFound(func() error {
-				_, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
-				return err
+			It("fails with a FileTooLargeError for data over the limit, without streaming", func() {
+				apiServer.SetInlineFileLimit(4)
+				defer apiServer.SetInlineFileLimit(1024 * 1024)
+
+				err := container.(client.Container).WriteFile("/some/file", []byte("hello"), 0600)
+				Ω(err).Should(Equal(garden.FileTooLargeError{Handle: "some-handle", Path: "/some/file", Limit: 4}))
+
+				Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
 			})
 
-			Context("when streaming out of the container fails", func() {
-				JustBeforeEach(func() {
-					fakeContainer.StreamOutReturns(nil, errors.New("oh no!"))
-				})
+			It("stops reading a request far larger than the limit before decoding it", func() {
+				apiServer.SetInlineFileLimit(4)
+				defer apiServer.SetInlineFileLimit(1024 * 1024)
 
-				It("returns an error", func() {
-					_, err := container.StreamOut(garden.StreamOutSpec{User: "frank", Path: "/src/path"})
-					Ω(err).Should(HaveOccurred())
-				})
+				err := container.(client.Container).WriteFile("/some/file", make([]byte, 100*1024), 0600)
+				Ω(err).Should(BeAssignableToTypeOf(garden.RequestLimitExceededError{}))
+				Ω(err.(garden.RequestLimitExceededError).Field).Should(Equal(garden.RequestBodyField))
+
+				Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
+			})
+
+			It("rejects a denied path without streaming", func() {
+				err := container.(client.Container).WriteFile("/sys/some-file", []byte("hello"), 0600)
+				Ω(err).Should(MatchError(ContainSubstring("under denied prefix /sys")))
+
+				Ω(fakeContainer.StreamInCallCount()).Should(BeZero())
 			})
 		})
 
@@ -1246,6 +4492,266 @@ var _ = Describe("When a client connects", func() {
 				Ω(time.Since(before)).Should(BeNumerically(">=", graceTime))
 				Ω(time.Since(before)).Should(BeNumerically("<", graceTime+time.Second))
 			})
+
+			It("sets the grace time on the container", func() {
+				Ω(container.SetGraceTime(2 * time.Hour)).Should(Succeed())
+
+				Ω(fakeContainer.SetGraceTimeCallCount()).Should(Equal(1))
+				Ω(fakeContainer.SetGraceTimeArgsForCall(0)).Should(Equal(2 * time.Hour))
+			})
+
+			It("restarts the countdown from when it is set", func() {
+				time.Sleep(graceTime / 2)
+
+				before := time.Now()
+				Ω(container.SetGraceTime(graceTime)).Should(Succeed())
+
+				Eventually(serverBackend.DestroyCallCount, 2*time.Second).Should(Equal(1))
+				Ω(time.Since(before)).Should(BeNumerically(">=", graceTime))
+			})
+
+			Context("when it is set to zero", func() {
+				BeforeEach(func() {
+					fakeContainer, graceTime := fakeContainer, graceTime
+
+					serverBackend.GraceTimeStub = func(garden.Container) time.Duration {
+						if calls := fakeContainer.SetGraceTimeCallCount(); calls > 0 {
+							return fakeContainer.SetGraceTimeArgsForCall(calls - 1)
+						}
+
+						return graceTime
+					}
+				})
+
+				It("never destroys the container", func() {
+					Ω(container.SetGraceTime(0)).Should(Succeed())
+
+					Consistently(serverBackend.DestroyCallCount, 2*graceTime).Should(BeZero())
+				})
+			})
+
+			Context("while a request is in flight", func() {
+				BeforeEach(func() {
+					graceTime = 300 * time.Millisecond
+					serverBackend.GraceTimeReturns(graceTime)
+				})
+
+				It("does not destroy the container until the request finishes", func() {
+					streaming := make(chan struct{})
+					finish := make(chan struct{})
+					fakeContainer.StreamInStub = func(garden.StreamInSpec) error {
+						close(streaming)
+						<-finish
+						return nil
+					}
+
+					go func() {
+						defer GinkgoRecover()
+
+						Ω(container.StreamIn(garden.StreamInSpec{
+							Path:      "/some/path",
+							TarStream: bytes.NewBufferString("some-data"),
+						})).Should(Succeed())
+					}()
+
+					Eventually(streaming).Should(BeClosed())
+
+					Ω(container.SetGraceTime(graceTime)).Should(Succeed())
+
+					Consistently(serverBackend.DestroyCallCount, 2*graceTime).Should(BeZero())
+
+					close(finish)
+
+					Eventually(serverBackend.DestroyCallCount, 2*graceTime).Should(Equal(1))
+				})
+			})
+
+			Context("when setting it on the container fails", func() {
+				BeforeEach(func() {
+					fakeContainer.SetGraceTimeReturns(errors.New("oh no!"))
+				})
+
+				It("fails", func() {
+					Ω(container.SetGraceTime(time.Hour)).Should(MatchError("oh no!"))
+				})
+			})
+
+			itFailsWhenTheContainerIsNotFound(func() error {
+				return container.SetGraceTime(time.Second)
+			})
+		})
+
+		Describe("locking", func() {
+			var lockClient client.Client
+
+			BeforeEach(func() {
+				lockClient = client.New(connection.New(gardenListenNetwork, gardenListenAddr))
+			})
+
+			lockOf := func(container garden.Container) *garden.ContainerLock {
+				info, err := container.Info()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				return info.Lock
+			}
+
+			lockedError := func(err error) garden.ContainerLockedError {
+				Ω(err).Should(BeAssignableToTypeOf(garden.ContainerLockedError{}))
+				return err.(garden.ContainerLockedError)
+			}
+
+			It("keeps the container from being destroyed", func() {
+				before := time.Now()
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+
+				locked := lockedError(lockClient.Destroy("some-handle"))
+				Ω(locked.Handle).Should(Equal("some-handle"))
+				Ω(locked.Lock.Reason).Should(Equal("debugging"))
+				Ω(locked.Lock.Holder).Should(Equal("frank"))
+				Ω(locked.Lock.ExpiresAt).Should(BeTemporally("~", before.Add(time.Hour), time.Second))
+
+				lockedError(lockClient.DestroyAsync("some-handle"))
+
+				Ω(serverBackend.DestroyCallCount()).Should(BeZero())
+			})
+
+			It("lets other operations carry on", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+
+				Ω(container.Stop(false)).Should(Succeed())
+				Ω(fakeContainer.StopCallCount()).Should(Equal(1))
+			})
+
+			It("reports the lock in the container's info", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+
+				Ω(lockOf(container)).ShouldNot(BeNil())
+				Ω(lockOf(container).Reason).Should(Equal("debugging"))
+
+				serverBackend.BulkInfoReturns(map[string]garden.ContainerInfoEntry{
+					"some-handle": {Info: garden.ContainerInfo{State: "active"}},
+				}, nil)
+
+				bulkInfo, err := lockClient.BulkInfo([]string{"some-handle"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(bulkInfo["some-handle"].Info.Lock).ShouldNot(BeNil())
+				Ω(bulkInfo["some-handle"].Info.Lock.Reason).Should(Equal("debugging"))
+			})
+
+			It("skips the container when destroying matching containers", func() {
+				serverBackend.ContainersReturns([]garden.Container{fakeContainer}, nil)
+
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+
+				handles, multiErr, err := lockClient.DestroyAll(false)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(handles).Should(BeEmpty())
+				lockedError(multiErr.Errors["some-handle"].Err)
+
+				Ω(serverBackend.DestroyCallCount()).Should(BeZero())
+			})
+
+			It("can be forced past", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+
+				Ω(lockClient.ForceDestroy("some-handle")).Should(Succeed())
+				Ω(serverBackend.DestroyCallCount()).Should(Equal(1))
+			})
+
+			It("lets the container be destroyed once unlocked", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+				Ω(container.(client.Container).Unlock("frank")).Should(Succeed())
+
+				Ω(lockOf(container)).Should(BeNil())
+
+				Ω(lockClient.Destroy("some-handle")).Should(Succeed())
+			})
+
+			It("expires after its ttl", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", 100*time.Millisecond)).Should(Succeed())
+
+				lockedError(lockClient.Destroy("some-handle"))
+
+				time.Sleep(150 * time.Millisecond)
+
+				Ω(lockOf(container)).Should(BeNil())
+				Ω(lockClient.Destroy("some-handle")).Should(Succeed())
+			})
+
+			It("is released when the container is destroyed", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+				Ω(lockClient.ForceDestroy("some-handle")).Should(Succeed())
+
+				_, err := lockClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(lockClient.Destroy("some-handle")).Should(Succeed())
+			})
+
+			It("renews the lock for its holder", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+				Ω(container.(client.Container).Lock("frank", "still debugging", 2*time.Hour)).Should(Succeed())
+
+				Ω(lockOf(container).Reason).Should(Equal("still debugging"))
+			})
+
+			It("cannot be taken while another holder has it", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+
+				locked := lockedError(container.(client.Container).Lock("mary", "upgrading", time.Hour))
+				Ω(locked.Lock.Holder).Should(Equal("frank"))
+
+				Ω(lockOf(container).Holder).Should(Equal("frank"))
+			})
+
+			It("can only be released by its holder", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+
+				locked := lockedError(container.(client.Container).Unlock("mary"))
+				Ω(locked.Lock.Holder).Should(Equal("frank"))
+				Ω(lockOf(container)).ShouldNot(BeNil())
+
+				Ω(container.(client.Container).Unlock("frank")).Should(Succeed())
+				Ω(lockOf(container)).Should(BeNil())
+			})
+
+			It("can be released by force, whoever holds it", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", time.Hour)).Should(Succeed())
+				Ω(container.(client.Container).ForceUnlock()).Should(Succeed())
+
+				Ω(lockOf(container)).Should(BeNil())
+			})
+
+			It("rejects a lock without a holder", func() {
+				err := container.(client.Container).Lock("", "debugging", time.Hour)
+				Ω(err).Should(Equal(garden.InvalidRequestError{Message: "a lock must name its holder"}))
+			})
+
+			It("rejects an unlock without a holder", func() {
+				err := container.(client.Container).Unlock("")
+				Ω(err).Should(Equal(garden.InvalidRequestError{Message: "unlocking needs the lock's holder, or force"}))
+			})
+
+			It("rejects a ttl that is not positive", func() {
+				Ω(container.(client.Container).Lock("frank", "debugging", 0)).Should(Equal(garden.InvalidRequestError{Message: "a lock must have a positive ttl"}))
+			})
+
+			Context("when the grace time runs out", func() {
+				BeforeEach(func() {
+					serverBackend.GraceTimeReturns(100 * time.Millisecond)
+				})
+
+				It("destroys the container only once the lock has expired", func() {
+					Ω(container.(client.Container).Lock("frank", "debugging", 400*time.Millisecond)).Should(Succeed())
+
+					Consistently(serverBackend.DestroyCallCount, 300*time.Millisecond).Should(BeZero())
+					Eventually(serverBackend.DestroyCallCount, time.Second).Should(Equal(1))
+				})
+			})
+
+			itFailsWhenTheContainerIsNotFound(func() error {
+				return container.(client.Container).Lock("frank", "debugging", time.Hour)
+			})
 		})
 
 		Describe("net in", func() {
@@ -1479,6 +4985,73 @@ var _ = Describe("When a client connects", func() {
 			})
 		})
 
+		Describe("bulk net out", func() {
+			rules := []garden.NetOutRule{
+				{
+					Protocol: garden.ProtocolTCP,
+					Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP("1.2.3.4"))},
+					Ports:    []garden.PortRange{{Start: 80, End: 443}},
+				},
+				{
+					Protocol: garden.ProtocolICMP,
+					ICMPs:    &garden.ICMPControl{Type: 8},
+					Log:      true,
+				},
+			}
+
+			It("hands every rule to the container in one call", func() {
+				Ω(container.BulkNetOut(rules)).Should(Succeed())
+
+				Ω(fakeContainer.BulkNetOutCallCount()).Should(Equal(1))
+				Ω(fakeContainer.BulkNetOutArgsForCall(0)).Should(Equal(rules))
+				Ω(fakeContainer.NetOutCallCount()).Should(BeZero())
+			})
+
+			Context("when any rule is invalid", func() {
+				It("rejects the batch without applying any of it", func() {
+					invalid := append(rules, garden.NetOutRule{
+						Ports: []garden.PortRange{{Start: 443, End: 80}},
+					})
+
+					err := container.BulkNetOut(invalid)
+					Ω(err).Should(BeAssignableToTypeOf(garden.InvalidNetOutRuleError{}))
+					Ω(err).Should(MatchError(ContainSubstring("port range 443-80 ends before it starts")))
+
+					Ω(fakeContainer.BulkNetOutCallCount()).Should(BeZero())
+				})
+			})
+
+			It("rejects more rules than the server accepts", func() {
+				apiServer.SetRequestLimits(server.RequestLimits{MaxNetOutRules: 1})
+
+				err := container.BulkNetOut(rules)
+				Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: "rules", Limit: 1}))
+
+				Ω(fakeContainer.BulkNetOutCallCount()).Should(BeZero())
+			})
+
+			itResetsGraceTimeWhenHandling(func(timeToSleep time.Duration) {
+				fakeContainer.BulkNetOutStub = func([]garden.NetOutRule) error { time.Sleep(timeToSleep); return nil }
+				err := container.BulkNetOut(rules)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			itFailsWhenTheContainerIsNotFound(func() error {
+				return container.BulkNetOut(rules)
+			})
+
+			Context("when permitting traffic fails", func() {
+				BeforeEach(func() {
+					fakeContainer.BulkNetOutReturns(errors.New("oh no!"))
+				})
+
+				It("fails", func() {
+					err := container.BulkNetOut(rules)
+					Ω(err).Should(HaveOccurred())
+				})
+			})
+		})
+
 		Describe("info", func() {
 			containerInfo := garden.ContainerInfo{
 				State:         "active",
@@ -1496,6 +5069,12 @@ var _ = Describe("When a client connects", func() {
 					{HostPort: 1234, ContainerPort: 5678},
 					{HostPort: 1235, ContainerPort: 5679},
 				},
+				BindMounts: []garden.BindMount{
+					{SrcPath: "/src-z", DstPath: "/dst"},
+					{SrcPath: "/src-a", DstPath: "/dst/inner"},
+				},
+				IsolateIntraSubnet: true,
+				Privileged:         true,
 			}
 
 			It("reports information about the container", func() {
@@ -1547,6 +5126,15 @@ var _ = Describe("When a client connects", func() {
 				},
 			}
 
+			It("rejects more handles than the server accepts", func() {
+				apiServer.SetRequestLimits(server.RequestLimits{MaxBulkHandles: 1})
+
+				_, err := apiClient.BulkInfo(handles)
+				Ω(err).Should(Equal(garden.RequestLimitExceededError{Field: "handles", Limit: 1}))
+
+				Ω(serverBackend.BulkInfoCallCount()).Should(BeZero())
+			})
+
 			It("calls BulkInfo with empty slice when handles is empty", func() {
 				handles = nil
 				serverBackend.BulkInfoReturns(nil, nil)
@@ -1749,46 +5337,125 @@ var _ = Describe("When a client connects", func() {
 				})
 			})
 
-			Context("when waiting on the process fails server-side", func() {
+			Context("when waiting on the process fails server-side", func() {
+				BeforeEach(func() {
+					fakeContainer.AttachStub = func(id string, io garden.ProcessIO) (garden.Process, error) {
+						process := new(fakes.FakeProcess)
+
+						process.IDReturns("process-handle")
+						process.WaitReturns(0, errors.New("oh no!"))
+
+						return process, nil
+					}
+				})
+
+				It("bubbles the error up", func() {
+					process, err := container.Attach("process-handle", garden.ProcessIO{})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					_, err = process.Wait()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("oh no!"))
+				})
+			})
+
+			Context("when attaching fails", func() {
+				BeforeEach(func() {
+					fakeContainer.AttachReturns(nil, errors.New("oh no!"))
+				})
+
+				It("fails", func() {
+					_, err := container.Attach("process-handle", garden.ProcessIO{})
+					Ω(err).Should(HaveOccurred())
+				})
+
+				It("closes the stdin writer", func(done Done) {
+					container.Attach("process-handle", garden.ProcessIO{})
+
+					_, processIO := fakeContainer.AttachArgsForCall(0)
+					_, err := processIO.Stdin.Read([]byte{})
+					Ω(err).Should(Equal(io.EOF))
+
+					close(done)
+				})
+			})
+
+			Context("when the process is unknown", func() {
+				BeforeEach(func() {
+					fakeContainer.AttachReturns(nil, garden.ProcessNotFoundError{Handle: "some-handle", ProcessID: "process-handle"})
+				})
+
+				It("returns a ProcessNotFoundError", func() {
+					_, err := container.Attach("process-handle", garden.ProcessIO{})
+					Ω(err).Should(Equal(garden.ProcessNotFoundError{Handle: "some-handle", ProcessID: "process-handle"}))
+				})
+			})
+
+			Context("when the container is destroyed while attached", func() {
+				var exited chan struct{}
+
 				BeforeEach(func() {
-					fakeContainer.AttachStub = func(id string, io garden.ProcessIO) (garden.Process, error) {
-						process := new(fakes.FakeProcess)
+					exited = make(chan struct{})
 
+					fakeContainer.AttachStub = func(processID string, io garden.ProcessIO) (garden.Process, error) {
+						io.Stdout.Write([]byte("stdout data"))
+
+						process := new(fakes.FakeProcess)
 						process.IDReturns("process-handle")
-						process.WaitReturns(0, errors.New("oh no!"))
+						process.WaitStub = func() (int, error) {
+							<-exited
+							return 0, nil
+						}
 
 						return process, nil
 					}
 				})
 
-				It("bubbles the error up", func() {
-					process, err := container.Attach("process-handle", garden.ProcessIO{})
+				AfterEach(func() {
+					close(exited)
+				})
+
+				It("returns a ContainerDestroyedError from Wait and ends the output streams", func() {
+					stdout := gbytes.NewBuffer()
+
+					process, err := container.Attach("process-handle", garden.ProcessIO{
+						Stdout: stdout,
+					})
 					Ω(err).ShouldNot(HaveOccurred())
 
+					Eventually(stdout).Should(gbytes.Say("stdout data"))
+
+					Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
 					_, err = process.Wait()
-					Ω(err).Should(HaveOccurred())
-					Ω(err.Error()).Should(ContainSubstring("oh no!"))
+					Ω(err).Should(Equal(garden.ContainerDestroyedError{Handle: "some-handle"}))
 				})
 			})
 
-			Context("when attaching fails", func() {
+			Context("when the container is being destroyed as the attach is set up", func() {
+				var destroying, finishDestroying chan struct{}
+
 				BeforeEach(func() {
-					fakeContainer.AttachReturns(nil, errors.New("oh no!"))
-				})
+					destroying = make(chan struct{})
+					finishDestroying = make(chan struct{})
 
-				It("fails", func() {
-					_, err := container.Attach("process-handle", garden.ProcessIO{})
-					Ω(err).Should(HaveOccurred())
+					serverBackend.DestroyStub = func(string) error {
+						close(destroying)
+						<-finishDestroying
+						return nil
+					}
 				})
 
-				It("closes the stdin writer", func(done Done) {
-					container.Attach("process-handle", garden.ProcessIO{})
+				It("fails with a ContainerDestroyedError without attaching", func() {
+					go apiClient.Destroy("some-handle")
+					defer close(finishDestroying)
 
-					_, processIO := fakeContainer.AttachArgsForCall(0)
-					_, err := processIO.Stdin.Read([]byte{})
-					Ω(err).Should(Equal(io.EOF))
+					<-destroying
 
-					close(done)
+					_, err := container.Attach("process-handle", garden.ProcessIO{})
+					Ω(err).Should(MatchError(ContainSubstring("container destroyed: some-handle")))
+
+					Ω(fakeContainer.AttachCallCount()).Should(BeZero())
 				})
 			})
 		})
@@ -2036,6 +5703,451 @@ var _ = Describe("When a client connects", func() {
 				})
 			})
 
+			Context("when the process has limits of its own", func() {
+				BeforeEach(func() {
+					fakeProcess := new(fakes.FakeProcess)
+					fakeProcess.IDReturns("process-handle")
+					fakeContainer.RunReturns(fakeProcess, nil)
+
+					fakeContainer.CurrentMemoryLimitsReturns(garden.MemoryLimits{LimitInBytes: 1024}, nil)
+					fakeContainer.CurrentCPULimitsReturns(garden.CPULimits{LimitInShares: 100}, nil)
+
+					backendFeatures.limitsProcesses = true
+				})
+
+				It("runs the process when they fit within the container's limits", func() {
+					limits := &garden.ProcessLimits{MemoryInBytes: 512, CPUShares: 50}
+
+					_, err := container.Run(garden.ProcessSpec{ProcessLimits: limits}, garden.ProcessIO{})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					ranSpec, _ := fakeContainer.RunArgsForCall(0)
+					Ω(ranSpec.ProcessLimits).Should(Equal(limits))
+				})
+
+				It("fails without running when the memory limit exceeds the container's", func() {
+					_, err := container.Run(garden.ProcessSpec{
+						ProcessLimits: &garden.ProcessLimits{MemoryInBytes: 2048},
+					}, garden.ProcessIO{})
+					Ω(err).Should(Equal(garden.InvalidRequestError{Message: "process memory limit 2048 exceeds container memory limit 1024"}))
+
+					Ω(fakeContainer.RunCallCount()).Should(BeZero())
+				})
+
+				It("fails without running when the cpu shares exceed the container's", func() {
+					_, err := container.Run(garden.ProcessSpec{
+						ProcessLimits: &garden.ProcessLimits{CPUShares: 200},
+					}, garden.ProcessIO{})
+					Ω(err).Should(Equal(garden.InvalidRequestError{Message: "process cpu shares 200 exceed container cpu shares 100"}))
+
+					Ω(fakeContainer.RunCallCount()).Should(BeZero())
+				})
+
+				It("does not limit a process in a container without limits", func() {
+					fakeContainer.CurrentMemoryLimitsReturns(garden.MemoryLimits{}, nil)
+
+					_, err := container.Run(garden.ProcessSpec{
+						ProcessLimits: &garden.ProcessLimits{MemoryInBytes: 2048},
+					}, garden.ProcessIO{})
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				Context("when the backend cannot enforce them", func() {
+					BeforeEach(func() {
+						backendFeatures.limitsProcesses = false
+					})
+
+					It("fails with an UnsupportedOperationError without running", func() {
+						_, err := container.Run(garden.ProcessSpec{
+							ProcessLimits: &garden.ProcessLimits{MemoryInBytes: 512},
+						}, garden.ProcessIO{})
+						Ω(err).Should(Equal(garden.UnsupportedOperationError{Message: "the backend does not support process_limits"}))
+
+						Ω(fakeContainer.RunCallCount()).Should(BeZero())
+					})
+
+					It("runs a process without limits of its own", func() {
+						_, err := container.Run(garden.ProcessSpec{}, garden.ProcessIO{})
+						Ω(err).ShouldNot(HaveOccurred())
+					})
+				})
+			})
+
+			Context("when the process's output is captured", func() {
+				var (
+					captureDir string
+					exited     chan struct{}
+				)
+
+				// runWriting runs a process that writes the chunks to stdout
+				// and "oh no!" to stderr before it starts, then waits until
+				// exited is closed
+				runWriting := func(capture garden.OutputCapture, chunks ...string) (garden.Process, *gbytes.Buffer) {
+					exited := exited
+
+					fakeContainer.RunStub = func(spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error) {
+						for _, chunk := range chunks {
+							fmt.Fprint(io.Stdout, chunk)
+						}
+
+						fmt.Fprint(io.Stderr, "oh no!")
+
+						process := new(fakes.FakeProcess)
+						process.IDReturns("process-handle")
+						process.WaitStub = func() (int, error) {
+							<-exited
+							return 0, nil
+						}
+
+						return process, nil
+					}
+
+					stdout := gbytes.NewBuffer()
+
+					process, err := container.Run(garden.ProcessSpec{OutputCapture: &capture}, garden.ProcessIO{Stdout: stdout})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					return process, stdout
+				}
+
+				capturedOutput := func(stream string) (string, error) {
+					reader, err := container.(client.Container).CapturedOutput("process-handle", stream)
+					if err != nil {
+						return "", err
+					}
+					defer reader.Close()
+
+					output, err := ioutil.ReadAll(reader)
+					return string(output), err
+				}
+
+				BeforeEach(func() {
+					var err error
+					captureDir, err = ioutil.TempDir("", "captures")
+					Ω(err).ShouldNot(HaveOccurred())
+
+					exited = make(chan struct{})
+				})
+
+				JustBeforeEach(func() {
+					apiServer.SetOutputCaptureDir(captureDir)
+				})
+
+				AfterEach(func() {
+					close(exited)
+					os.RemoveAll(captureDir)
+				})
+
+				It("captures each stream while still streaming it", func() {
+					_, stdout := runWriting(garden.OutputCapture{}, "hello ", "world")
+
+					Eventually(stdout).Should(gbytes.Say("hello world"))
+
+					Ω(capturedOutput(garden.CapturedStdout)).Should(Equal("hello world"))
+					Ω(capturedOutput(garden.CapturedStderr)).Should(Equal("oh no!"))
+				})
+
+				It("keeps the capture readable once the process has exited", func() {
+					process, _ := runWriting(garden.OutputCapture{}, "hello")
+
+					close(exited)
+					exited = make(chan struct{})
+
+					_, err := process.Wait()
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(capturedOutput(garden.CapturedStdout)).Should(Equal("hello"))
+				})
+
+				It("rotates a stream's capture once it would exceed the cap, keeping the previous file", func() {
+					runWriting(garden.OutputCapture{MaxBytes: 4}, "abc", "def", "ghi")
+
+					Ω(capturedOutput(garden.CapturedStdout)).Should(Equal("defghi"))
+				})
+
+				It("keeps only the most recent output of a write larger than the cap", func() {
+					runWriting(garden.OutputCapture{MaxBytes: 4}, "ab", "cdefghij")
+
+					Ω(capturedOutput(garden.CapturedStdout)).Should(Equal("abghij"))
+
+					err := filepath.Walk(captureDir, func(path string, info os.FileInfo, err error) error {
+						if err == nil && !info.IsDir() {
+							Ω(info.Size()).Should(BeNumerically("<=", 4), path)
+						}
+
+						return err
+					})
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				It("lowers a cap larger than the server allows to the server's limit", func() {
+					apiServer.SetOutputCaptureMaxBytes(4)
+
+					runWriting(garden.OutputCapture{MaxBytes: 1024}, "abc", "def", "ghi")
+
+					Ω(capturedOutput(garden.CapturedStdout)).Should(Equal("defghi"))
+				})
+
+				It("fails with a ProcessNotFoundError for a process whose output was not captured", func() {
+					_, err := container.(client.Container).CapturedOutput("another-process", garden.CapturedStdout)
+					Ω(err).Should(Equal(garden.ProcessNotFoundError{Handle: "some-handle", ProcessID: "another-process"}))
+				})
+
+				It("fails with an InvalidRequestError for an unknown stream", func() {
+					runWriting(garden.OutputCapture{}, "hello")
+
+					_, err := capturedOutput("stdin")
+					Ω(err).Should(Equal(garden.InvalidRequestError{Message: "unknown stream: stdin"}))
+				})
+
+				Context("when the container is destroyed", func() {
+					It("removes the capture", func() {
+						runWriting(garden.OutputCapture{}, "hello")
+
+						Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
+						_, err := capturedOutput(garden.CapturedStdout)
+						Ω(err).Should(Equal(garden.ProcessNotFoundError{Handle: "some-handle", ProcessID: "process-handle"}))
+
+						Ω(ioutil.ReadDir(captureDir)).Should(BeEmpty())
+					})
+
+					It("removes the capture when the container is reaped", func() {
+						process, _ := runWriting(garden.OutputCapture{}, "hello")
+
+						close(exited)
+						exited = make(chan struct{})
+
+						_, err := process.Wait()
+						Ω(err).ShouldNot(HaveOccurred())
+
+						serverBackend.GraceTimeReturns(10 * time.Millisecond)
+						Ω(container.SetGraceTime(10 * time.Millisecond)).Should(Succeed())
+
+						Eventually(serverBackend.DestroyCallCount).Should(Equal(1))
+						Eventually(func() ([]os.FileInfo, error) {
+							return ioutil.ReadDir(captureDir)
+						}).Should(BeEmpty())
+					})
+
+					Context("and the capture was to be kept", func() {
+						It("keeps it for the tombstone retention", func() {
+							runWriting(garden.OutputCapture{KeepCapturedLogs: true}, "hello")
+
+							Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
+							Ω(capturedOutput(garden.CapturedStdout)).Should(Equal("hello"))
+						})
+
+						It("removes it once the retention has passed", func() {
+							apiServer.SetTombstoneRetention(10 * time.Millisecond)
+
+							runWriting(garden.OutputCapture{KeepCapturedLogs: true}, "hello")
+
+							Ω(apiClient.Destroy("some-handle")).Should(Succeed())
+
+							time.Sleep(20 * time.Millisecond)
+
+							_, err := capturedOutput(garden.CapturedStdout)
+							Ω(err).Should(Equal(garden.ProcessNotFoundError{Handle: "some-handle", ProcessID: "process-handle"}))
+
+							Ω(ioutil.ReadDir(captureDir)).Should(BeEmpty())
+						})
+					})
+				})
+
+				Context("when the process fails to start", func() {
+					It("removes its capture files", func() {
+						fakeContainer.RunReturns(nil, errors.New("oh no!"))
+
+						_, err := container.Run(garden.ProcessSpec{OutputCapture: &garden.OutputCapture{}}, garden.ProcessIO{})
+						Ω(err).Should(HaveOccurred())
+
+						dirs, err := ioutil.ReadDir(captureDir)
+						Ω(err).ShouldNot(HaveOccurred())
+						Ω(dirs).Should(HaveLen(1))
+
+						Ω(ioutil.ReadDir(filepath.Join(captureDir, dirs[0].Name()))).Should(BeEmpty())
+					})
+				})
+
+				Context("when the server has nowhere to capture output", func() {
+					BeforeEach(func() {
+						os.RemoveAll(captureDir)
+						captureDir = ""
+					})
+
+					It("fails with an UnsupportedOperationError without running the process", func() {
+						_, err := container.Run(garden.ProcessSpec{OutputCapture: &garden.OutputCapture{}}, garden.ProcessIO{})
+						Ω(err).Should(MatchError(ContainSubstring("output capture is not enabled on this server")))
+
+						Ω(fakeContainer.RunCallCount()).Should(BeZero())
+					})
+				})
+			})
+
+			Context("when the process has a maximum duration", func() {
+				var (
+					fakeProcess *fakes.FakeProcess
+					terminated  chan struct{}
+				)
+
+				BeforeEach(func() {
+					terminated = make(chan struct{})
+
+					fakeProcess = new(fakes.FakeProcess)
+					fakeProcess.IDReturns("process-handle")
+					fakeProcess.WaitStub = func() (int, error) {
+						<-terminated
+						return 143, nil
+					}
+					fakeProcess.SignalStub = func(signal garden.Signal) error {
+						if signal == garden.SignalTerminate {
+							close(terminated)
+						}
+
+						return nil
+					}
+
+					fakeContainer.RunReturns(fakeProcess, nil)
+				})
+
+				Context("and it exceeds it", func() {
+					It("terminates the process and returns its exit status with a ProcessTimeoutError", func() {
+						process, err := container.Run(garden.ProcessSpec{
+							Path:        "/some/script",
+							MaxDuration: 50 * time.Millisecond,
+						}, garden.ProcessIO{})
+						Ω(err).ShouldNot(HaveOccurred())
+
+						status, err := process.Wait()
+						Ω(err).Should(Equal(garden.ProcessTimeoutError{}))
+						Ω(status).Should(Equal(143))
+
+						Ω(fakeProcess.SignalCallCount()).Should(Equal(1))
+						Ω(fakeProcess.SignalArgsForCall(0)).Should(Equal(garden.SignalTerminate))
+					})
+
+					It("terminates the process even if the client has gone away", func() {
+						var clientConnection net.Conn
+
+						apiConnection := connection.NewWithDialerAndLogger(func(string, string) (net.Conn, error) {
+							var err error
+							clientConnection, err = net.DialTimeout(gardenListenNetwork, gardenListenAddr, 2*time.Second)
+							return clientConnection, err
+						}, lagertest.NewTestLogger("api-conn-dialer"))
+
+						container, err := client.New(apiConnection).Create(garden.ContainerSpec{})
+						Ω(err).ShouldNot(HaveOccurred())
+
+						_, err = container.Run(garden.ProcessSpec{
+							Path:        "/some/script",
+							MaxDuration: 50 * time.Millisecond,
+						}, garden.ProcessIO{})
+						Ω(err).ShouldNot(HaveOccurred())
+
+						clientConnection.Close()
+
+						Eventually(terminated).Should(BeClosed())
+					})
+				})
+
+				Context("and it exits in time", func() {
+					It("does not signal the process", func() {
+						fakeProcess.WaitReturns(0, nil)
+						fakeProcess.WaitStub = nil
+
+						process, err := container.Run(garden.ProcessSpec{
+							Path:        "/some/script",
+							MaxDuration: time.Minute,
+						}, garden.ProcessIO{})
+						Ω(err).ShouldNot(HaveOccurred())
+
+						status, err := process.Wait()
+						Ω(err).ShouldNot(HaveOccurred())
+						Ω(status).Should(Equal(0))
+
+						Consistently(fakeProcess.SignalCallCount).Should(BeZero())
+					})
+				})
+			})
+
+			Context("when the server limits how long streams stay open", func() {
+				var (
+					fakeProcess *fakes.FakeProcess
+					exited      chan struct{}
+					stopWriting chan struct{}
+				)
+
+				BeforeEach(func() {
+					exited = make(chan struct{})
+					stopWriting = make(chan struct{})
+
+					fakeProcess = new(fakes.FakeProcess)
+					fakeProcess.IDReturns("process-handle")
+					fakeProcess.WaitStub = func() (int, error) {
+						<-exited
+						return 0, nil
+					}
+
+					stop := stopWriting
+					fakeContainer.RunStub = func(spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error) {
+						go func() {
+							for {
+								select {
+								case <-stop:
+									return
+								case <-time.After(20 * time.Millisecond):
+									io.Stdout.Write([]byte("."))
+								}
+							}
+						}()
+
+						return fakeProcess, nil
+					}
+				})
+
+				AfterEach(func() {
+					close(exited)
+				})
+
+				It("closes the stream once it exceeds the lifetime, leaving the process running", func() {
+					apiServer.SetStreamLifetime(server.StreamLifetime{Max: 100 * time.Millisecond})
+					defer apiServer.SetStreamLifetime(server.StreamLifetime{})
+					defer close(stopWriting)
+
+					process, err := container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+					Ω(err).ShouldNot(HaveOccurred())
+
+					_, err = process.Wait()
+					Ω(err).Should(Equal(garden.StreamLifetimeExceededError{}))
+
+					Ω(fakeProcess.SignalCallCount()).Should(BeZero())
+				})
+
+				Context("and activity resets the timer", func() {
+					It("closes the stream once it has been idle for the lifetime", func() {
+						apiServer.SetStreamLifetime(server.StreamLifetime{Max: 200 * time.Millisecond, ResetOnActivity: true})
+						defer apiServer.SetStreamLifetime(server.StreamLifetime{})
+
+						process, err := container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+						Ω(err).ShouldNot(HaveOccurred())
+
+						waited := make(chan error, 1)
+						go func() {
+							_, err := process.Wait()
+							waited <- err
+						}()
+
+						Consistently(waited, 500*time.Millisecond).ShouldNot(Receive())
+
+						close(stopWriting)
+
+						Eventually(waited).Should(Receive(Equal(garden.StreamLifetimeExceededError{})))
+					})
+				})
+			})
+
 			Context("when the process's window size is set", func() {
 				var fakeProcess *fakes.FakeProcess
 
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/transport"
+)
+
+// RequestLimits bounds how many entries the lists and maps of a request may
+// hold. They are counted as the request is read, so that an oversized request
+// is rejected before it is read in full or decoded. Zero means no
+// limit.
+type RequestLimits struct {
+	// MaxProperties bounds the properties of a container spec, of a listing
+	// or destroy_matching filter, or set or removed in bulk.
+	MaxProperties int
+
+	// MaxEnv bounds the environment of a container or process spec.
+	MaxEnv int
+
+	// MaxBindMounts bounds the bind mounts of a container spec.
+	MaxBindMounts int
+
+	// MaxBulkHandles bounds the handles of a bulk info or metrics request.
+	MaxBulkHandles int
+
+	// MaxNetOutRules bounds the rules of a bulk net out request.
+	MaxNetOutRules int
+
+	// MaxIdempotentBodyBytes bounds the body of a request made with an
+	// idempotency key, which is read in full to tell retries apart.
+	MaxIdempotentBodyBytes int
+}
+
+// DefaultRequestLimits are generous enough for any legitimate request.
+var DefaultRequestLimits = RequestLimits{
+	MaxProperties:  10000,
+	MaxEnv:         10000,
+	MaxBindMounts:  1000,
+	MaxBulkHandles: 10000,
+	MaxNetOutRules: 10000,
+
+	MaxIdempotentBodyBytes: 16 << 20,
+}
+
+// limitedFields names the fields of each request message that the limits
+// bound, as readLimitedRequest counts them and the API spec advertises them.
+var limitedFields = map[reflect.Type]func(RequestLimits) map[string]int{
+	reflect.TypeOf(garden.ContainerSpec{}): func(limits RequestLimits) map[string]int {
+		return map[string]int{
+			"properties":  limits.MaxProperties,
+			"env":         limits.MaxEnv,
+			"bind_mounts": limits.MaxBindMounts,
+		}
+	},
+	reflect.TypeOf(garden.ProcessSpec{}): func(limits RequestLimits) map[string]int {
+		return map[string]int{"env": limits.MaxEnv}
+	},
+	reflect.TypeOf(transport.ListPageRequest{}): func(limits RequestLimits) map[string]int {
+		return map[string]int{
+			"properties": limits.MaxProperties,
+			"filter":     limits.MaxProperties,
+		}
+	},
+	reflect.TypeOf(transport.DestroyMatchingRequest{}): func(limits RequestLimits) map[string]int {
+		return map[string]int{"properties": limits.MaxProperties}
+	},
+	reflect.TypeOf(transport.SetPropertiesRequest{}): func(limits RequestLimits) map[string]int {
+		return map[string]int{"properties": limits.MaxProperties}
+	},
+	reflect.TypeOf(transport.RemovePropertiesRequest{}): func(limits RequestLimits) map[string]int {
+		return map[string]int{"names": limits.MaxProperties}
+	},
+	reflect.TypeOf(transport.BulkNetOutRequest{}): func(limits RequestLimits) map[string]int {
+		return map[string]int{"rules": limits.MaxNetOutRules}
+	},
+}
+
+// schemaLimits are the limits as the API spec advertises them.
+func (limits RequestLimits) schemaLimits() transport.SchemaLimits {
+	schemaLimits := transport.SchemaLimits{}
+	for message, fields := range limitedFields {
+		schemaLimits[message.String()] = fields(limits)
+	}
+
+	return schemaLimits
+}
+
+// SetRequestLimits sets how many entries the lists and maps of a request may
+// hold. It defaults to DefaultRequestLimits.
+func (s *GardenServer) SetRequestLimits(limits RequestLimits) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.requestLimits = limits
+}
+
+func (s *GardenServer) getRequestLimits() RequestLimits {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.requestLimits
+}
+
+// readLimitedRequest is readRequest for a JSON object whose fields are bounded
+// by a count of entries, as registered in limitedFields for the type msg
+// points to. It panics if the type is not registered, rather than reading it
+// unbounded.
+func (s *GardenServer) readLimitedRequest(msg interface{}, w http.ResponseWriter, r *http.Request) bool {
+	message := reflect.TypeOf(msg).Elem()
+
+	fields, found := limitedFields[message]
+	if !found {
+		panic(fmt.Sprintf("no request limits registered for %s", message))
+	}
+
+	err := decodeLimited(r.Body, msg, fields(s.getRequestLimits()))
+	if err != nil {
+		s.writeError(w, err, s.logger)
+		return false
+	}
+
+	return true
+}
+
+// splitLimitedHandles is splitHandles, failing before splitting if there are
+// more handles than the limit.
+func splitLimitedHandles(queryHandles string, limit int) ([]string, error) {
+	if limit > 0 && queryHandles != "" && strings.Count(queryHandles, ",")+1 > limit {
+		return nil, garden.RequestLimitExceededError{Field: "handles", Limit: uint64(limit)}
+	}
+
+	return splitHandles(queryHandles), nil
+}
+
+// decodeLimited decodes a JSON object into msg. The body is first read token
+// by token, counting the entries of the arrays or objects under the keys of
+// limits and failing as soon as there are too many, and only then decoded as
+// encoding/json does, from the bytes the count read.
+func decodeLimited(r io.Reader, msg interface{}, limits map[string]int) error {
+	read := new(bytes.Buffer)
+
+	err := countLimited(json.NewDecoder(io.TeeReader(r, read)), limits)
+	if err != nil {
+		return err
+	}
+
+	return json.NewDecoder(io.MultiReader(read, r)).Decode(msg)
+}
+
+// countLimited reads a JSON object, failing once a field under one of the
+// keys of limits has more entries than its limit. Keys match
+// case-insensitively, as encoding/json does when decoding into a struct.
+// Anything but an object is left for decoding to reject.
+func countLimited(decoder *json.Decoder, limits map[string]int) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != json.Delim('{') {
+		return nil
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		key := token.(string)
+
+		limit, limited := lookupLimit(limits, key)
+		if !limited {
+			if err := skipValue(decoder); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := countEntries(decoder, key, limit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookupLimit matches keys case-insensitively, as encoding/json does when
+// decoding into a struct.
+func lookupLimit(limits map[string]int, key string) (int, bool) {
+	for field, limit := range limits {
+		if limit > 0 && strings.EqualFold(field, key) {
+			return limit, true
+		}
+	}
+
+	return 0, false
+}
+
+// countEntries reads an array or object, failing once it has more entries
+// than the limit. Any other value is skipped, for decoding to reject if it
+// does not fit the field.
+func countEntries(decoder *json.Decoder, name string, limit int) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != json.Delim('[') && token != json.Delim('{') {
+		return nil
+	}
+
+	for n := 0; decoder.More(); n++ {
+		if n == limit {
+			return garden.RequestLimitExceededError{Field: name, Limit: uint64(limit)}
+		}
+
+		if token == json.Delim('{') {
+			// the entry's key
+			if _, err := decoder.Token(); err != nil {
+				return err
+			}
+		}
+
+		if err := skipValue(decoder); err != nil {
+			return err
+		}
+	}
+
+	_, err = decoder.Token()
+	return err
+}
+
+// skipValue reads the next value, however deeply nested, without keeping it.
+func skipValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// bodyLimitReader fails the read that would take a request body past the
+// given number of bytes.
+type bodyLimitReader struct {
+	r        io.Reader
+	limit    uint64
+	read     uint64
+	exceeded bool
+}
+
+func (b *bodyLimitReader) Read(p []byte) (int, error) {
+	// reading one byte past the limit tells a body that ends at it from one
+	// that goes on
+	allowed := b.limit - b.read
+	if uint64(len(p)) > allowed+1 {
+		p = p[:allowed+1]
+	}
+
+	n, err := b.r.Read(p)
+	if uint64(n) > allowed {
+		b.exceeded = true
+		return 0, b.exceededError()
+	}
+
+	b.read += uint64(n)
+
+	return n, err
+}
+
+func (b *bodyLimitReader) exceededError() error {
+	return garden.RequestLimitExceededError{Field: garden.RequestBodyField, Limit: b.limit}
+}
+
+// wrap reports an error decoding the body as the limit being exceeded if it
+// was, as decoders may wrap the read error.
+func (b *bodyLimitReader) wrap(err error) error {
+	if b.exceeded {
+		return b.exceededError()
+	}
+
+	return err
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"code.cloudfoundry.org/garden"
+)
+
+func benchmarkContainerSpec(b *testing.B) []byte {
+	spec := garden.ContainerSpec{
+		Handle:     "some-handle",
+		Properties: garden.Properties{},
+	}
+
+	for i := 0; i < 100; i++ {
+		spec.Properties[fmt.Sprintf("property-%d", i)] = "some-value"
+		spec.Env = append(spec.Env, fmt.Sprintf("VAR_%d=some-value", i))
+	}
+
+	for i := 0; i < 10; i++ {
+		spec.BindMounts = append(spec.BindMounts, garden.BindMount{
+			SrcPath: fmt.Sprintf("/src/%d", i),
+			DstPath: fmt.Sprintf("/dst/%d", i),
+		})
+	}
+
+	body, err := json.Marshal(spec)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return body
+}
+
+func BenchmarkDecodeLimited(b *testing.B) {
+	body := benchmarkContainerSpec(b)
+	limits := limitedFields[reflect.TypeOf(garden.ContainerSpec{})](DefaultRequestLimits)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var spec garden.ContainerSpec
+		if err := decodeLimited(bytes.NewReader(body), &spec, limits); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodeUnlimited is the baseline for BenchmarkDecodeLimited.
+func BenchmarkDecodeUnlimited(b *testing.B) {
+	body := benchmarkContainerSpec(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var spec garden.ContainerSpec
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&spec); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("limitedFields", func() {
+	jsonKeys := func(message reflect.Type) []string {
+		keys := []string{}
+		for i := 0; i < message.NumField(); i++ {
+			field := message.Field(i)
+
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+
+			keys = append(keys, name)
+		}
+
+		return keys
+	}
+
+	It("only limits fields that each message has", func() {
+		for message, fields := range limitedFields {
+			for field := range fields(DefaultRequestLimits) {
+				Ω(jsonKeys(message)).Should(ContainElement(field), "%s has no field %q", message, field)
+			}
+		}
+	})
+
+	It("rejects a limited field of the wrong type as decoding does", func() {
+		var spec struct {
+			Env []string `json:"env"`
+		}
+
+		err := decodeLimited(strings.NewReader(`{"env":"A=B"}`), &spec, map[string]int{"env": 1})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("cannot unmarshal string"))
+	})
+})
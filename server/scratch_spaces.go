@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+)
+
+func validateScratchSpaces(spec garden.ContainerSpec, policy garden.PathPolicy) error {
+	taken := []string{}
+	for _, bindMount := range spec.BindMounts {
+		taken = append(taken, filepath.Clean(bindMount.DstPath))
+	}
+
+	// relative scratch spaces could not be checked for overlaps
+	policy.AllowRelative = false
+
+	for _, scratch := range spec.ScratchSpaces {
+		if err := garden.ValidateTargetPath(scratch.Path, policy); err != nil {
+			return err
+		}
+
+		path := filepath.Clean(scratch.Path)
+		for _, other := range taken {
+			if overlaps(path, other) {
+				return garden.InvalidRequestError{Message: fmt.Sprintf("scratch space path %s overlaps %s", scratch.Path, other)}
+			}
+		}
+
+		taken = append(taken, path)
+	}
+
+	return nil
+}
+
+func overlaps(a, b string) bool {
+	return a == b || isWithin(a, b) || isWithin(b, a)
+}
+
+func isWithin(path, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+
+	return strings.HasPrefix(path, dir+"/")
+}
@@ -39,8 +39,48 @@ type GardenServer struct {
 
 	streamer *streamer.Streamer
 
-	destroys  map[string]struct{}
-	destroysL *sync.Mutex
+	// when each container being destroyed was asked to be, and how long
+	// destroys take on average; guarded by destroysL
+	destroys        map[string]time.Time
+	destroyDuration time.Duration
+	destroysL       *sync.Mutex
+
+	// destroys still running after their request was answered
+	asyncDestroys *sync.WaitGroup
+
+	// closed when the container with the given handle is destroyed; guarded
+	// by destroysL
+	destroyWatchers map[string]map[chan struct{}]struct{}
+
+	// the subnet address each container was created on, where the server
+	// knows it, and how many creates are sharing each container's subnet;
+	// guarded by destroysL, and broadcast on when a create stops sharing one
+	subnets       map[string]string
+	subnetSharers map[string]int
+	sharesEnded   *sync.Cond
+
+	tombstones *tombstones
+
+	outputCaptures *outputCaptures
+
+	locks *containerLocks
+
+	propertyMutations *propertyMutations
+
+	metricsRecorder *metricsRecorder
+
+	idempotencyKeys *idempotencyKeys
+
+	// guarded by settingsL
+	pathPolicy      garden.PathPolicy
+	compression     bool
+	streamLifetime  StreamLifetime
+	inlineFileLimit uint64
+	requestLimits   RequestLimits
+	handleGenerator HandleGenerator
+	allowPrivileged bool
+	propertySchemas map[string]garden.PropertySchema
+	settingsL       *sync.Mutex
 }
 
 func New(
@@ -65,38 +105,81 @@ func New(
 
 		streamer: streamer.New(time.Minute),
 
-		destroys:  make(map[string]struct{}),
+		destroys:  make(map[string]time.Time),
 		destroysL: new(sync.Mutex),
+
+		asyncDestroys: new(sync.WaitGroup),
+
+		destroyWatchers: make(map[string]map[chan struct{}]struct{}),
+
+		subnets:       make(map[string]string),
+		subnetSharers: make(map[string]int),
+
+		tombstones: newTombstones(defaultTombstoneRetention),
+
+		outputCaptures: newOutputCaptures(defaultTombstoneRetention),
+
+		locks: newContainerLocks(),
+
+		propertyMutations: newPropertyMutations(),
+
+		metricsRecorder: newMetricsRecorder(DefaultMetricsRecording),
+
+		idempotencyKeys: newIdempotencyKeys(defaultIdempotencyWindow),
+
+		pathPolicy:      garden.DefaultPathPolicy,
+		compression:     true,
+		inlineFileLimit: defaultInlineFileLimit,
+		requestLimits:   DefaultRequestLimits,
+		allowPrivileged: true,
+		settingsL:       new(sync.Mutex),
 	}
 
+	s.sharesEnded = sync.NewCond(s.destroysL)
+
 	handlers := map[string]http.Handler{
 		routes.Ping:                   http.HandlerFunc(s.handlePing),
 		routes.Capacity:               http.HandlerFunc(s.handleCapacity),
-		routes.Create:                 http.HandlerFunc(s.handleCreate),
-		routes.Destroy:                http.HandlerFunc(s.handleDestroy),
+		routes.Create:                 s.idempotent(s.handleCreate),
+		routes.Destroy:                s.idempotent(s.handleDestroy),
+		routes.DestroyMatching:        s.idempotent(s.handleDestroyMatching),
+		routes.Tombstone:              http.HandlerFunc(s.handleTombstone),
 		routes.List:                   http.HandlerFunc(s.handleList),
-		routes.Stop:                   http.HandlerFunc(s.handleStop),
+		routes.ListPage:               http.HandlerFunc(s.handleListPage),
+		routes.Stop:                   s.idempotent(s.handleStop),
+		routes.Lock:                   s.idempotent(s.handleLock),
+		routes.Unlock:                 s.idempotent(s.handleUnlock),
 		routes.StreamIn:               http.HandlerFunc(s.handleStreamIn),
 		routes.StreamOut:              http.HandlerFunc(s.handleStreamOut),
+		routes.ReadFile:               http.HandlerFunc(s.handleReadFile),
+		routes.WriteFile:              s.idempotent(s.handleWriteFile),
 		routes.CurrentBandwidthLimits: http.HandlerFunc(s.handleCurrentBandwidthLimits),
 		routes.CurrentCPULimits:       http.HandlerFunc(s.handleCurrentCPULimits),
 		routes.CurrentDiskLimits:      http.HandlerFunc(s.handleCurrentDiskLimits),
 		routes.CurrentMemoryLimits:    http.HandlerFunc(s.handleCurrentMemoryLimits),
-		routes.NetIn:                  http.HandlerFunc(s.handleNetIn),
-		routes.NetOut:                 http.HandlerFunc(s.handleNetOut),
+		routes.NetIn:                  s.idempotent(s.handleNetIn),
+		routes.NetOut:                 s.idempotent(s.handleNetOut),
+		routes.BulkNetOut:             s.idempotent(s.handleBulkNetOut),
 		routes.Info:                   http.HandlerFunc(s.handleInfo),
 		routes.BulkInfo:               http.HandlerFunc(s.handleBulkInfo),
 		routes.BulkMetrics:            http.HandlerFunc(s.handleBulkMetrics),
 		routes.Run:                    http.HandlerFunc(s.handleRun),
-		routes.Stdout:                 streamer.HandlerFunc(s.streamer.ServeStdout),
-		routes.Stderr:                 streamer.HandlerFunc(s.streamer.ServeStderr),
+		routes.Stdout:                 s.streamer.StdoutHandler(),
+		routes.Stderr:                 s.streamer.StderrHandler(),
 		routes.Attach:                 http.HandlerFunc(s.handleAttach),
+		routes.CapturedOutput:         http.HandlerFunc(s.handleCapturedOutput),
 		routes.Metrics:                http.HandlerFunc(s.handleMetrics),
+		routes.MetricsHistory:         http.HandlerFunc(s.handleMetricsHistory),
 		routes.Properties:             http.HandlerFunc(s.handleProperties),
 		routes.Property:               http.HandlerFunc(s.handleProperty),
-		routes.SetProperty:            http.HandlerFunc(s.handleSetProperty),
-		routes.RemoveProperty:         http.HandlerFunc(s.handleRemoveProperty),
-		routes.SetGraceTime:           http.HandlerFunc(s.handleSetGraceTime),
+		routes.SetProperty:            s.idempotent(s.handleSetProperty),
+		routes.RemoveProperty:         s.idempotent(s.handleRemoveProperty),
+		routes.CompareAndSetProperty:  s.idempotent(s.handleCompareAndSetProperty),
+		routes.SetProperties:          s.idempotent(s.handleSetProperties),
+		routes.RemoveProperties:       s.idempotent(s.handleRemoveProperties),
+		routes.SetGraceTime:           s.idempotent(s.handleSetGraceTime),
+		routes.RouteTable:             http.HandlerFunc(s.handleRouteTable),
+		routes.APISpec:                http.HandlerFunc(s.handleAPISpec),
 	}
 
 	mux, err := rata.NewRouter(routes.Routes, handlers)
@@ -108,6 +191,15 @@ func New(
 
 	s.server = &http.Server{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.compressionEnabled() {
+				// advertise that gzipped request bodies are accepted
+				w.Header().Set("Accept-Encoding", "gzip")
+			}
+
+			if !s.decompressRequest(w, r) {
+				return
+			}
+
 			mux.ServeHTTP(w, r)
 		}),
 
@@ -142,6 +234,110 @@ func New(
 	return s
 }
 
+// SetTombstoneRetention sets how long the server remembers why a container
+// was destroyed, and keeps the captured output that outlives it. It defaults
+// to an hour.
+func (s *GardenServer) SetTombstoneRetention(retention time.Duration) {
+	s.tombstones.setRetention(retention)
+	s.outputCaptures.setRetention(retention)
+}
+
+// SetOutputCaptureDir sets the directory in which the output of processes
+// run with an OutputCapture is kept. Until it is set, such processes fail to
+// run with an UnsupportedOperationError.
+func (s *GardenServer) SetOutputCaptureDir(dir string) {
+	s.outputCaptures.setDir(dir)
+}
+
+// SetOutputCaptureMaxBytes sets the largest MaxBytes a process's
+// OutputCapture may ask for, 64MiB by default. Larger caps are lowered to it.
+func (s *GardenServer) SetOutputCaptureMaxBytes(limit uint64) {
+	s.outputCaptures.setMaxBytesLimit(limit)
+}
+
+// SetCompression sets whether gzipped request bodies are accepted and large
+// JSON responses are gzipped for clients that accept it. It is enabled by
+// default.
+func (s *GardenServer) SetCompression(enabled bool) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.compression = enabled
+}
+
+func (s *GardenServer) compressionEnabled() bool {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.compression
+}
+
+// SetPathPolicy sets which container paths bind mounts, scratch spaces and
+// file streams may target. It defaults to garden.DefaultPathPolicy.
+func (s *GardenServer) SetPathPolicy(policy garden.PathPolicy) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.pathPolicy = policy
+}
+
+func (s *GardenServer) getPathPolicy() garden.PathPolicy {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.pathPolicy
+}
+
+// SetAllowPrivileged sets whether containers may be created privileged. It is
+// allowed by default; when it is not, such creates fail with a
+// PrivilegedContainersDisabledError. Existing privileged containers are unaffected.
+func (s *GardenServer) SetAllowPrivileged(allowed bool) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.allowPrivileged = allowed
+}
+
+func (s *GardenServer) privilegedAllowed() bool {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.allowPrivileged
+}
+
+// SetPropertySchemas registers the properties whose values are JSON, keyed
+// by name. Setting one of them to a value its schema does not accept, on
+// create or afterwards, fails with an InvalidPropertyValueError, and listing
+// filters can match the fields inside their values by dotted path. Values
+// set before their schema was registered are left as they are. The schemas
+// are advertised in the API spec. Other properties are unaffected.
+func (s *GardenServer) SetPropertySchemas(schemas map[string]garden.PropertySchema) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.propertySchemas = make(map[string]garden.PropertySchema, len(schemas))
+	for name, schema := range schemas {
+		s.propertySchemas[name] = schema
+	}
+}
+
+func (s *GardenServer) getPropertySchemas() map[string]garden.PropertySchema {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.propertySchemas
+}
+
+// streamPathPolicy is the path policy for StreamIn and StreamOut, whose paths
+// have always been allowed to be relative to the user's home directory, or
+// the root directory itself.
+func (s *GardenServer) streamPathPolicy() garden.PathPolicy {
+	policy := s.getPathPolicy()
+	policy.AllowRelative = true
+	policy.AllowRoot = true
+	return policy
+}
+
 func (s *GardenServer) Start() error {
 	s.started = true
 
@@ -175,6 +371,17 @@ func (s *GardenServer) Start() error {
 
 	for _, container := range containers {
 		s.bomberman.Strap(container)
+
+		if recorded, _ := container.Property(garden.RecordMetricsProperty); recorded != "true" {
+			continue
+		}
+
+		if !s.metricsRecorder.start(container, s.logger) {
+			s.logger.Info("not-recording-metrics", lager.Data{
+				"handle": container.Handle(),
+				"reason": "no room for its samples",
+			})
+		}
 	}
 
 	go s.server.Serve(listener)
@@ -207,6 +414,11 @@ func (s *GardenServer) Stop() {
 	s.logger.Info("waiting-for-connections-to-close")
 	s.handling.Wait()
 
+	s.logger.Info("waiting-for-destroys")
+	s.asyncDestroys.Wait()
+
+	s.metricsRecorder.stopAll()
+
 	s.logger.Info("stopping-backend")
 	s.backend.Stop()
 
@@ -237,14 +449,8 @@ func (s *GardenServer) reapContainer(container garden.Container) {
 		"grace-time": s.backend.GraceTime(container).String(),
 	})
 
-	s.destroysL.Lock()
-	_, alreadyDestroying := s.destroys[container.Handle()]
-	if !alreadyDestroying {
-		s.destroys[container.Handle()] = struct{}{}
-	}
-	s.destroysL.Unlock()
-
-	if alreadyDestroying {
+	requestedAt, ok := s.beginDestroy(container.Handle())
+	if !ok {
 		s.logger.Info("skipping reap due to concurrent delete request", lager.Data{
 			"handle":     container.Handle(),
 			"grace-time": s.backend.GraceTime(container).String(),
@@ -252,9 +458,27 @@ func (s *GardenServer) reapContainer(container garden.Container) {
 		return
 	}
 
-	s.backend.Destroy(container.Handle())
+	if lock, locked := s.locks.lookup(container.Handle()); locked {
+		s.finishDestroy(container.Handle(), requestedAt, false)
+
+		s.logger.Info("skipping reap of locked container", lager.Data{
+			"handle": container.Handle(),
+			"holder": lock.Holder,
+			"reason": lock.Reason,
+		})
+
+		// try again once another grace time has passed
+		s.bomberman.Strap(container)
+		return
+	}
+
+	err := s.backend.Destroy(container.Handle())
+	if err != nil {
+		s.finishDestroy(container.Handle(), requestedAt, false)
+		return
+	}
+
+	s.forget(container.Handle(), garden.DestroyReasonGraceTime, requestedAt)
 
-	s.destroysL.Lock()
-	delete(s.destroys, container.Handle())
-	s.destroysL.Unlock()
+	s.finishDestroy(container.Handle(), requestedAt, true)
 }
@@ -131,6 +131,42 @@ var _ = Describe("The Garden server", func() {
 		Ω(time.Since(before)).Should(BeNumerically(">", 100*time.Millisecond))
 	})
 
+	It("resumes recording the metrics of containers created with RecordMetrics", func() {
+		var err error
+		tmpdir, err = ioutil.TempDir(os.TempDir(), "api-server-test")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		fakeBackend := new(fakes.FakeBackend)
+
+		recorded := new(fakes.FakeContainer)
+		recorded.HandleReturns("recorded")
+		recorded.PropertyStub = func(name string) (string, error) {
+			if name == garden.RecordMetricsProperty {
+				return "true", nil
+			}
+
+			return "", errors.New("no such property")
+		}
+
+		unrecorded := new(fakes.FakeContainer)
+		unrecorded.HandleReturns("unrecorded")
+
+		fakeBackend.ContainersReturns([]garden.Container{recorded, unrecorded}, nil)
+
+		apiServer := server.New(gardenListenNetwork, gardenListenAddr, 0, fakeBackend, logger)
+		apiServer.SetMetricsRecording(server.MetricsRecording{
+			Interval: 10 * time.Millisecond,
+			Samples:  5,
+		})
+
+		err = apiServer.Start()
+		Ω(err).ShouldNot(HaveOccurred())
+		defer apiServer.Stop()
+
+		Eventually(recorded.MetricsCallCount).Should(BeNumerically(">=", 2))
+		Ω(unrecorded.MetricsCallCount()).Should(BeZero())
+	})
+
 	Context("when starting the backend fails", func() {
 		disaster := errors.New("oh no!")
 
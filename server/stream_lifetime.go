@@ -0,0 +1,105 @@
+package server
+
+import (
+	"io"
+	"time"
+)
+
+// StreamLifetime bounds how long the connection of a Run or Attach request is
+// kept open, so that abandoned sessions do not hold it forever.
+type StreamLifetime struct {
+	// Max is how long the connection may stay open. Zero means no limit.
+	Max time.Duration
+
+	// ResetOnActivity restarts the timer whenever stdin, stdout or stderr data
+	// is transferred, making Max an idle timeout rather than an absolute one.
+	ResetOnActivity bool
+}
+
+// SetStreamLifetime sets how long Run and Attach connections are kept open.
+// When it is exceeded the connection is closed and Process.Wait returns
+// garden.StreamLifetimeExceededError; the process keeps running. There is no
+// limit by default.
+func (s *GardenServer) SetStreamLifetime(lifetime StreamLifetime) {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	s.streamLifetime = lifetime
+}
+
+func (s *GardenServer) getStreamLifetime() StreamLifetime {
+	s.settingsL.Lock()
+	defer s.settingsL.Unlock()
+
+	return s.streamLifetime
+}
+
+// lifetimeTimer closes expired once a stream has exceeded its lifetime.
+type lifetimeTimer struct {
+	lifetime StreamLifetime
+	timer    *time.Timer
+	expired  chan struct{}
+}
+
+func newLifetimeTimer(lifetime StreamLifetime) *lifetimeTimer {
+	t := &lifetimeTimer{lifetime: lifetime}
+
+	if lifetime.Max > 0 {
+		expired := make(chan struct{})
+		t.expired = expired
+		t.timer = time.AfterFunc(lifetime.Max, func() { close(expired) })
+	}
+
+	return t
+}
+
+// touch records activity on the stream.
+func (t *lifetimeTimer) touch() {
+	if t.timer == nil || !t.lifetime.ResetOnActivity {
+		return
+	}
+
+	// once the timer has fired, the stream is already being closed
+	if t.timer.Stop() {
+		t.timer.Reset(t.lifetime.Max)
+	}
+}
+
+func (t *lifetimeTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// wrapReader and wrapWriter record the stream's reads and writes as activity.
+func (t *lifetimeTimer) wrapReader(r io.Reader) io.Reader {
+	return activityReader{Reader: r, touch: t.touch}
+}
+
+func (t *lifetimeTimer) wrapWriter(w io.Writer) io.Writer {
+	return activityWriter{Writer: w, touch: t.touch}
+}
+
+type activityReader struct {
+	io.Reader
+	touch func()
+}
+
+func (r activityReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.touch()
+	}
+
+	return n, err
+}
+
+type activityWriter struct {
+	io.Writer
+	touch func()
+}
+
+func (w activityWriter) Write(p []byte) (int, error) {
+	w.touch()
+	return w.Writer.Write(p)
+}
@@ -3,20 +3,92 @@ package streamer
 import (
 	"io"
 	"net/http"
+
+	"code.cloudfoundry.org/garden/transport"
 )
 
 type HandlerFunc func(StreamID, io.Writer)
 
+// StdoutHandler serves the standard output of the stream named by the :streamid parameter.
+func (m *Streamer) StdoutHandler() http.Handler {
+	return m.checked(HandlerFunc(m.ServeStdout))
+}
+
+// StderrHandler serves the standard error of the stream named by the :streamid parameter.
+func (m *Streamer) StderrHandler() http.Handler {
+	return m.checked(HandlerFunc(m.ServeStderr))
+}
+
+// checked rejects requests for streams that cannot be served before handing
+// them to h: 400 for malformed IDs, 410 for IDs issued before a restart and
+// 404 for streams that are gone.
+func (m *Streamer) checked(h HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := m.Check(StreamID(r.FormValue(":streamid")))
+		switch err {
+		case nil:
+			h.ServeHTTP(w, r)
+		case ErrMalformedStreamID:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case ErrStaleStreamID:
+			http.Error(w, err.Error(), http.StatusGone)
+		default:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		}
+	})
+}
+
+// ServeHTTP hijacks the connection and streams to it directly. When the
+// connection cannot be hijacked, such as behind an HTTP/2 proxy, the stream is
+// sent as the response body instead, flushed after every write.
 func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	id := StreamID(r.FormValue(":streamid"))
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		h.serveFlushed(id, w)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 
-	conn, _, err := w.(http.Hijacker).Hijack()
+	conn, _, err := hijacker.Hijack()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		// the status has already been sent; all that is left is to end the
+		// response
 		return
 	}
 
 	defer conn.Close()
 	h(id, conn)
 }
+
+func (h HandlerFunc) serveFlushed(id StreamID, w http.ResponseWriter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(transport.StreamTransportHeader, transport.StreamTransportChunked)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	h(id, &flushWriter{w: w, flusher: flusher})
+}
+
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	w.flusher.Flush()
+
+	return n, nil
+}
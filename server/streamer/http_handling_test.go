@@ -0,0 +1,322 @@
+package streamer_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden/server/streamer"
+	"code.cloudfoundry.org/garden/server/streamer/streamertest"
+	"code.cloudfoundry.org/garden/transport"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HandlerFunc", func() {
+	var (
+		handler streamer.HandlerFunc
+		served  chan streamer.StreamID
+	)
+
+	BeforeEach(func() {
+		served = make(chan streamer.StreamID, 1)
+		handler = streamer.HandlerFunc(func(id streamer.StreamID, w io.Writer) {
+			served <- id
+			w.Write([]byte("chunk-1;"))
+			w.Write([]byte("chunk-2;"))
+		})
+	})
+
+	Context("when the connection can be hijacked", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(handler)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("writes the status and then streams on the raw connection", func() {
+			resp, err := http.Get(server.URL + "/?:streamid=42")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+			Ω(resp.Header.Get(transport.StreamTransportHeader)).Should(BeEmpty())
+
+			Eventually(served).Should(Receive(Equal(streamer.StreamID("42"))))
+		})
+	})
+
+	Context("when the connection cannot be hijacked but can be flushed", func() {
+		var recorder *httptest.ResponseRecorder
+
+		BeforeEach(func() {
+			recorder = httptest.NewRecorder()
+		})
+
+		It("streams the output as the response body", func() {
+			request, err := http.NewRequest("GET", "/?:streamid=42", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handler.ServeHTTP(recorder, request)
+
+			Ω(recorder.Code).Should(Equal(http.StatusOK))
+			Ω(recorder.Header().Get(transport.StreamTransportHeader)).Should(Equal(transport.StreamTransportChunked))
+			Ω(recorder.Flushed).Should(BeTrue())
+			Ω(recorder.Body.String()).Should(Equal("chunk-1;chunk-2;"))
+
+			Ω(served).Should(Receive(Equal(streamer.StreamID("42"))))
+		})
+
+		It("flushes after every write", func() {
+			flushes := &countingFlusher{ResponseWriter: recorder}
+
+			request, err := http.NewRequest("GET", "/", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handler.ServeHTTP(flushes, request)
+
+			// one for the headers, one per write
+			Ω(flushes.count).Should(Equal(3))
+		})
+	})
+
+	Context("when the connection can neither be hijacked nor flushed", func() {
+		It("responds with an internal server error", func() {
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest("GET", "/", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handler.ServeHTTP(plainWriter{recorder}, request)
+
+			Ω(recorder.Code).Should(Equal(http.StatusInternalServerError))
+			Ω(served).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the response is read by an HTTP client", func() {
+		It("arrives chunked and can be decoded", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler.ServeHTTP(flushOnly{w}, r)
+			}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			Ω(resp.TransferEncoding).Should(Equal([]string{"chunked"}))
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(body)).Should(Equal("chunk-1;chunk-2;"))
+		})
+	})
+})
+
+var _ = Describe("Streamer handlers", func() {
+	var (
+		str    *streamer.Streamer
+		server *httptest.Server
+		sid    streamer.StreamID
+	)
+
+	BeforeEach(func() {
+		str = streamer.New(10 * time.Millisecond)
+		sid = str.Stream(make(chan []byte), make(chan []byte))
+
+		server = httptest.NewServer(str.StdoutHandler())
+	})
+
+	AfterEach(func() {
+		str.Stop(sid)
+		server.Close()
+	})
+
+	get := func(id string) *http.Response {
+		resp, err := http.Get(server.URL + "/?" + url.Values{":streamid": {id}}.Encode())
+		Ω(err).ShouldNot(HaveOccurred())
+		return resp
+	}
+
+	It("serves streams it issued", func() {
+		resp := get(string(sid))
+		defer resp.Body.Close()
+
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+	})
+
+	It("responds with 410 Gone for streams issued before a restart", func() {
+		restarted := streamer.New(10 * time.Millisecond)
+		staleServer := httptest.NewServer(restarted.StdoutHandler())
+		defer staleServer.Close()
+
+		resp, err := http.Get(staleServer.URL + "/?" + url.Values{":streamid": {string(sid)}}.Encode())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		Ω(resp.StatusCode).Should(Equal(http.StatusGone))
+	})
+
+	It("responds with 404 Not Found for unknown streams", func() {
+		nonce := strings.SplitN(string(sid), "-", 2)[0]
+
+		resp := get(nonce + "-99")
+		defer resp.Body.Close()
+
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
+	It("responds with 400 Bad Request for malformed IDs", func() {
+		resp := get("7")
+		defer resp.Body.Close()
+
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+	})
+})
+
+var _ = Describe("Streaming over HTTP", func() {
+	// httpTransport serves each stream from its own server, whose handler can
+	// be wrapped to hide the writer's optional interfaces
+	httpTransport := func(wrap func(http.ResponseWriter) http.ResponseWriter, get func(string) (io.ReadCloser, error)) streamertest.Transport {
+		return func(str *streamer.Streamer, sid streamer.StreamID, stderr bool) (io.ReadCloser, error) {
+			handler := str.StdoutHandler()
+			if stderr {
+				handler = str.StderrHandler()
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler.ServeHTTP(wrap(w), r)
+			}))
+
+			body, err := get(server.URL + "/?" + url.Values{":streamid": {string(sid)}}.Encode())
+			if err != nil {
+				server.Close()
+				return nil, err
+			}
+
+			return &closingBody{ReadCloser: body, server: server}, nil
+		}
+	}
+
+	Context("on a hijacked connection", func() {
+		// the stream follows the response header on the raw connection, as the
+		// garden client reads it
+		getRaw := func(rawURL string) (io.ReadCloser, error) {
+			parsed, err := url.Parse(rawURL)
+			if err != nil {
+				return nil, err
+			}
+
+			conn, err := net.Dial("tcp", parsed.Host)
+			if err != nil {
+				return nil, err
+			}
+
+			request, err := http.NewRequest("GET", rawURL, nil)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+
+			if err := request.Write(conn); err != nil {
+				conn.Close()
+				return nil, err
+			}
+
+			reader := bufio.NewReader(conn)
+
+			resp, err := http.ReadResponse(reader, request)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				conn.Close()
+				return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+			}
+
+			return struct {
+				io.Reader
+				io.Closer
+			}{reader, conn}, nil
+		}
+
+		streamertest.ItConformsToTheStreamerContract(httpTransport(func(w http.ResponseWriter) http.ResponseWriter {
+			return w
+		}, getRaw))
+	})
+
+	Context("as a flushed response body", func() {
+		get := func(rawURL string) (io.ReadCloser, error) {
+			resp, err := http.Get(rawURL)
+			if err != nil {
+				return nil, err
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+			}
+
+			return resp.Body, nil
+		}
+
+		streamertest.ItConformsToTheStreamerContract(httpTransport(func(w http.ResponseWriter) http.ResponseWriter {
+			return flushOnly{w}
+		}, get))
+	})
+})
+
+// closingBody shuts the server down along with the response
+type closingBody struct {
+	io.ReadCloser
+	server *httptest.Server
+}
+
+func (b *closingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.server.Close()
+	return err
+}
+
+type countingFlusher struct {
+	http.ResponseWriter
+	count int
+}
+
+func (f *countingFlusher) Flush() {
+	f.count++
+}
+
+// plainWriter hides every optional interface of the wrapped writer
+type plainWriter struct {
+	w http.ResponseWriter
+}
+
+func (p plainWriter) Header() http.Header         { return p.w.Header() }
+func (p plainWriter) Write(b []byte) (int, error) { return p.w.Write(b) }
+func (p plainWriter) WriteHeader(code int)        { p.w.WriteHeader(code) }
+
+// flushOnly hides the Hijacker of the wrapped writer, as an HTTP/2 server's
+// writer would not have one
+type flushOnly struct {
+	w http.ResponseWriter
+}
+
+func (f flushOnly) Header() http.Header         { return f.w.Header() }
+func (f flushOnly) Write(b []byte) (int, error) { return f.w.Write(b) }
+func (f flushOnly) WriteHeader(code int)        { f.w.WriteHeader(code) }
+func (f flushOnly) Flush()                      { f.w.(http.Flusher).Flush() }
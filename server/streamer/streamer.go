@@ -1,111 +1,514 @@
+// Package streamer fans out the output of a producer to any number of readers.
+//
+// A producer opens a stream with NewStream and writes its standard output and error to it with WriteChunk, which
+// splits large writes into chunks of at most MaxChunkSize bytes.
+// Readers serve either channel of the stream to an io.Writer with ServeStdout or ServeStderr. The contract is:
+//
+//   - Every reader receives the chunks of its channel in the order they were sent, each exactly once, until its
+//     Serve call returns.
+//   - Chunks sent while no reader is serving a channel stay buffered, in the channel and then in the stream, and go
+//     to the next reader. A reader otherwise sees only the chunks sent after it joined.
+//   - A reader whose writes fail stops being served; the chunk it failed to write is lost to it alone.
+//   - A reader that falls a full buffer behind is disconnected rather than hold up the others: it is served the
+//     chunks it has buffered and its Serve call returns.
+//   - CloseProducer and Stop end the stream's output. Readers attached at the time, and any attaching before the
+//     stream is removed, drain what is buffered and then return.
+//   - Stop removes the stream once the grace time has passed. Chunks no reader collected by then are discarded and
+//     later Serve calls return straight away.
+//
+// No method panics, whatever the state of the stream it is given.
 package streamer
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 // StreamID identifies a pair of standard output and error channels used for streaming.
+//
+// It has the form <nonce>-<n>, where the nonce is chosen at random when the Streamer is created, so that IDs
+// issued by another Streamer, such as one from before a restart, are recognised rather than mistaken for
+// streams of this one.
 type StreamID string
 
+var (
+	// ErrMalformedStreamID is returned by Check for an ID that no Streamer could have issued.
+	ErrMalformedStreamID = errors.New("malformed stream id")
+
+	// ErrStaleStreamID is returned by Check for an ID issued by another Streamer.
+	ErrStaleStreamID = errors.New("stream id was issued by a previous server")
+
+	// ErrStreamNotFound is returned by Check for an ID issued by this Streamer whose stream no longer exists,
+	// and by WriteChunk for any ID that does not name a stream.
+	ErrStreamNotFound = errors.New("stream not found")
+
+	// ErrStreamClosed is returned by WriteChunk once the stream's producer has been closed.
+	ErrStreamClosed = errors.New("stream closed")
+
+	// ErrStreamFull is returned by WriteChunk when the stream's channel had no room for a chunk. The chunk and
+	// those after it in the same write are dropped.
+	ErrStreamFull = errors.New("stream full")
+)
+
+const nonceLength = 16
+
+// Options configure a Streamer.
+type Options struct {
+	// GraceTime is how long a stopped stream stays registered for readers to
+	// attach and collect its remaining output.
+	GraceTime time.Duration
+
+	// ReaderBufferSize bounds the chunks buffered for each reader. A reader
+	// whose buffer is full when a chunk arrives is disconnected, so that it
+	// cannot hold up the other readers of the same channel. It defaults to
+	// DefaultReaderBufferSize.
+	ReaderBufferSize int
+
+	// MaxChunkSize bounds the size of the chunks WriteChunk sends, larger
+	// writes being split. It defaults to DefaultMaxChunkSize.
+	MaxChunkSize int
+}
+
+const (
+	// DefaultReaderBufferSize is the ReaderBufferSize used when none is given.
+	DefaultReaderBufferSize = 1000
+
+	// DefaultMaxChunkSize is the MaxChunkSize used when none is given.
+	DefaultMaxChunkSize = 64 * 1024
+
+	// producerBufferSize is how many chunks the channels of a stream opened
+	// with NewStream buffer before WriteChunk drops them.
+	producerBufferSize = 1000
+)
+
 // New creates a Streamer with the specified grace time which limits the duration of memory consumption by a stopped stream.
 func New(graceTime time.Duration) *Streamer {
+	return NewWithOptions(Options{GraceTime: graceTime})
+}
+
+// NewWithOptions creates a Streamer configured by opts.
+func NewWithOptions(opts Options) *Streamer {
+	if opts.ReaderBufferSize <= 0 {
+		opts.ReaderBufferSize = DefaultReaderBufferSize
+	}
+
+	if opts.MaxChunkSize <= 0 {
+		opts.MaxChunkSize = DefaultMaxChunkSize
+	}
+
 	return &Streamer{
-		graceTime: graceTime,
-		streams:   make(map[StreamID]*stream),
+		nonce:   newNonce(),
+		opts:    opts,
+		streams: make(map[StreamID]*stream),
 	}
 }
 
+func newNonce() string {
+	b := make([]byte, nonceLength/2)
+	if _, err := rand.Read(b); err != nil {
+		binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()))
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Streamer registers streams and serves them to readers. It is safe for concurrent use.
 type Streamer struct {
 	mu           sync.RWMutex
+	nonce        string
 	nextStreamID uint64
-	graceTime    time.Duration
+	opts         Options
 	streams      map[StreamID]*stream
 }
 
 type stream struct {
-	ch   [2]chan []byte
-	done chan struct{}
+	ch         [2]chan []byte
+	bufferSize int
+	done       chan struct{}
+	doneOnce   sync.Once
+	stopOnce   sync.Once
+
+	mu      sync.Mutex
+	readers [2]map[*reader]struct{}
+	pending [2][][]byte
+	pumping [2]bool
+	drained [2]bool
 }
 
-type stdoutOrErr int
+// reader is a single consumer of one of the channels of a stream. It is fed
+// by the stream's pump through a bounded buffer of its own, which the pump
+// closes when it disconnects the reader.
+type reader struct {
+	ch   chan []byte
+	gone chan struct{}
+}
+
+// StdStream selects the standard output or standard error channel of a stream.
+type StdStream int
 
 const (
-	stdout stdoutOrErr = 0
-	stderr stdoutOrErr = 1
+	Stdout StdStream = 0
+	Stderr StdStream = 1
 )
 
-// Stream sets up streaming for the given pair of channels and returns a StreamID to identify the pair.
+// NewStream sets up a stream for a producer to write to with WriteChunk and returns its StreamID.
+//
 // The caller must call Stop to avoid leaking memory.
+func (m *Streamer) NewStream() StreamID {
+	return m.Stream(make(chan []byte, producerBufferSize), make(chan []byte, producerBufferSize))
+}
+
+// Stream sets up streaming for the given pair of channels and returns a StreamID to identify the pair.
+//
+// The Streamer only receives from the channels; the producer must not close them. The caller must call Stop to
+// avoid leaking memory.
+//
+// Deprecated: use NewStream and WriteChunk, which keep chunks within MaxChunkSize.
 func (m *Streamer) Stream(stdout, stderr chan []byte) StreamID {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	var sid StreamID = StreamID(fmt.Sprintf("%d", m.nextStreamID))
+	var sid StreamID = StreamID(fmt.Sprintf("%s-%d", m.nonce, m.nextStreamID))
 	m.nextStreamID++
 
 	m.streams[sid] = &stream{
-		ch:   [2]chan []byte{stdout, stderr},
-		done: make(chan struct{}),
+		ch:         [2]chan []byte{stdout, stderr},
+		bufferSize: m.opts.ReaderBufferSize,
+		done:       make(chan struct{}),
+		readers:    [2]map[*reader]struct{}{{}, {}},
 	}
 
 	return sid
 }
 
-// StreamStdout streams to the specified writer from the standard output channel of the specified pair of channels.
+// ServeStdout streams to the specified writer from the standard output channel of the specified pair of channels.
+//
+// Any number of readers may serve the same stream concurrently. Each receives every chunk produced after it
+// joined, and returns once the stream has been stopped and drained or a write to it fails. It returns straight
+// away for a stream that does not exist.
 func (m *Streamer) ServeStdout(streamID StreamID, writer io.Writer) {
-	m.serve(streamID, writer, stdout)
+	m.serve(streamID, writer, Stdout)
 }
 
-// StreamStderr streams to the specified writer from the standard error channel of the specified pair of channels.
+// ServeStderr streams to the specified writer from the standard error channel of the specified pair of channels.
+//
+// It supports concurrent readers in the same way as ServeStdout.
 func (m *Streamer) ServeStderr(streamID StreamID, writer io.Writer) {
-	m.serve(streamID, writer, stderr)
+	m.serve(streamID, writer, Stderr)
+}
+
+// Check reports whether the specified StreamID names a stream that can currently be served.
+func (m *Streamer) Check(streamID StreamID) error {
+	nonce, ok := parseStreamID(streamID)
+	if !ok {
+		return ErrMalformedStreamID
+	}
+
+	if nonce != m.nonce {
+		return ErrStaleStreamID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, found := m.streams[streamID]; !found {
+		return ErrStreamNotFound
+	}
+
+	return nil
 }
 
-func (m *Streamer) serve(streamID StreamID, writer io.Writer, chanIndex stdoutOrErr) {
+// parseStreamID returns the nonce of a well-formed StreamID. Only the exact
+// form produced by Stream is accepted: lower-case hex and no leading zeros.
+func parseStreamID(streamID StreamID) (string, bool) {
+	parts := strings.SplitN(string(streamID), "-", 2)
+	if len(parts) != 2 || len(parts[0]) != nonceLength {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(parts[0]); err != nil || strings.ToLower(parts[0]) != parts[0] {
+		return "", false
+	}
+
+	n, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || strconv.FormatUint(n, 10) != parts[1] {
+		return "", false
+	}
+
+	return parts[0], true
+}
+
+func (m *Streamer) serve(streamID StreamID, writer io.Writer, chanIndex StdStream) {
 	strm := m.streamFromID(streamID)
+	if strm == nil {
+		return
+	}
+
+	rdr := strm.subscribe(chanIndex)
+	defer strm.unsubscribe(chanIndex, rdr)
+
+	for b := range rdr.ch {
+		if _, err := writer.Write(b); err != nil {
+			return
+		}
+	}
+}
+
+func (s *stream) subscribe(chanIndex StdStream) *reader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rdr := &reader{
+		ch:   make(chan []byte, s.bufferSize),
+		gone: make(chan struct{}),
+	}
+
+	if s.drained[chanIndex] {
+		close(rdr.ch)
+		return rdr
+	}
+
+	s.readers[chanIndex][rdr] = struct{}{}
+
+	if !s.pumping[chanIndex] {
+		s.pumping[chanIndex] = true
+		go s.pump(chanIndex)
+	}
+
+	return rdr
+}
+
+func (s *stream) unsubscribe(chanIndex StdStream, rdr *reader) {
+	s.mu.Lock()
+	delete(s.readers[chanIndex], rdr)
+	s.mu.Unlock()
 
-	ch := strm.ch[chanIndex]
+	close(rdr.gone)
+}
+
+// pump moves chunks from one channel of the stream to all of its readers. It
+// is started by the first reader so that chunks produced before anyone is
+// listening stay buffered in the channel, and stops when the last reader
+// leaves so that they do again.
+func (s *stream) pump(chanIndex StdStream) {
+	if !s.broadcastPending(chanIndex) {
+		return
+	}
+
+	ch := s.ch[chanIndex]
 	for {
 		select {
 		case b := <-ch:
-			if _, err := writer.Write(b); err != nil {
+			if !s.broadcast(chanIndex, b) {
 				return
 			}
-		case <-strm.done:
-			drain(ch, writer)
+		case <-s.done:
+			s.drain(chanIndex)
 			return
 		}
 	}
 }
 
-func drain(ch chan []byte, writer io.Writer) {
+func (s *stream) drain(chanIndex StdStream) {
+	ch := s.ch[chanIndex]
 	for {
 		select {
 		case b := <-ch:
-			writer.Write(b)
+			if !s.broadcast(chanIndex, b) {
+				return
+			}
 		default:
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.drained[chanIndex] = true
+			for rdr := range s.readers[chanIndex] {
+				close(rdr.ch)
+				delete(s.readers[chanIndex], rdr)
+			}
+
 			return
 		}
 	}
 }
 
+// broadcastPending delivers the chunks kept back while the channel had no
+// readers. They leave the stream only while there is a reader to take them.
+func (s *stream) broadcastPending(chanIndex StdStream) bool {
+	for {
+		s.mu.Lock()
+		if len(s.pending[chanIndex]) == 0 {
+			s.mu.Unlock()
+			return true
+		}
+
+		readers, ok := s.readersOrStop(chanIndex)
+		if !ok {
+			s.mu.Unlock()
+			return false
+		}
+
+		b := s.pending[chanIndex][0]
+		s.pending[chanIndex] = s.pending[chanIndex][1:]
+		s.mu.Unlock()
+
+		s.deliver(chanIndex, readers, b)
+	}
+}
+
+// broadcast delivers a chunk to every reader of the channel. If the channel
+// has no readers left the chunk is kept back for the next one, the pump stops
+// and false is returned.
+func (s *stream) broadcast(chanIndex StdStream, b []byte) bool {
+	s.mu.Lock()
+	readers, ok := s.readersOrStop(chanIndex)
+	if !ok {
+		s.pending[chanIndex] = append(s.pending[chanIndex], b)
+		s.mu.Unlock()
+
+		return false
+	}
+	s.mu.Unlock()
+
+	s.deliver(chanIndex, readers, b)
+
+	return true
+}
+
+// readersOrStop returns the current readers of the channel or, if there are
+// none, marks the pump stopped. It must be called with s.mu held.
+func (s *stream) readersOrStop(chanIndex StdStream) ([]*reader, bool) {
+	if len(s.readers[chanIndex]) == 0 {
+		s.pumping[chanIndex] = false
+		return nil, false
+	}
+
+	readers := make([]*reader, 0, len(s.readers[chanIndex]))
+	for rdr := range s.readers[chanIndex] {
+		readers = append(readers, rdr)
+	}
+
+	return readers, true
+}
+
+// deliver hands a chunk to every reader without waiting for any of them. A
+// reader with no room left in its buffer is disconnected.
+func (s *stream) deliver(chanIndex StdStream, readers []*reader, b []byte) {
+	for _, rdr := range readers {
+		select {
+		case rdr.ch <- b:
+		case <-rdr.gone:
+		default:
+			s.disconnect(chanIndex, rdr)
+		}
+	}
+}
+
+// disconnect stops feeding a reader, which returns once it has written what
+// it has buffered. Only the pump sends on or closes a reader's buffer, so it
+// must only be called from the pump.
+func (s *stream) disconnect(chanIndex StdStream, rdr *reader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, serving := s.readers[chanIndex][rdr]; !serving {
+		return
+	}
+
+	delete(s.readers[chanIndex], rdr)
+	close(rdr.ch)
+}
+
+// WriteChunk sends p on the specified channel of a stream, split into chunks of at most MaxChunkSize bytes which
+// readers receive in order. p is copied, so the caller may reuse it.
+//
+// WriteChunk never blocks. If the channel has no room for a chunk, it and the rest of p are dropped and
+// ErrStreamFull is returned, so that a slow reader cannot hold up the producer. Writing to a stream that does not
+// exist returns ErrStreamNotFound, and to one whose producer has been closed, ErrStreamClosed.
+func (m *Streamer) WriteChunk(streamID StreamID, std StdStream, p []byte) error {
+	strm := m.streamFromID(streamID)
+	if strm == nil || (std != Stdout && std != Stderr) {
+		return ErrStreamNotFound
+	}
+
+	for len(p) > 0 {
+		n := len(p)
+		if n > m.opts.MaxChunkSize {
+			n = m.opts.MaxChunkSize
+		}
+
+		chunk := make([]byte, n)
+		copy(chunk, p)
+		p = p[n:]
+
+		select {
+		case <-strm.done:
+			return ErrStreamClosed
+		default:
+		}
+
+		select {
+		case strm.ch[std] <- chunk:
+		default:
+			return ErrStreamFull
+		}
+	}
+
+	return nil
+}
+
+// CloseProducer marks the specified pair of channels as complete: the producer will send nothing more.
+//
+// Readers receive the chunks still buffered in the channels, after which their Serve calls return. Unlike Stop,
+// the stream stays registered, so that readers attaching later are served whatever remains and end cleanly
+// rather than finding no stream. Stop must still be called to remove it.
+func (m *Streamer) CloseProducer(streamID StreamID) {
+	strm := m.streamFromID(streamID)
+	if strm == nil {
+		return
+	}
+
+	strm.closeProducer()
+}
+
+func (s *stream) closeProducer() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
 // Stop stops streaming from the specified pair of channels.
+//
+// It ends the stream's output as CloseProducer does and removes the stream once the grace time has passed. Readers
+// attached before then are served the remaining output; chunks nobody collected are discarded. Stopping a stream
+// again, or one that does not exist, does nothing.
 func (m *Streamer) Stop(streamID StreamID) {
 	strm := m.streamFromID(streamID)
-	close(strm.done)
+	if strm == nil {
+		return
+	}
+
+	strm.closeProducer()
 
-	go func() {
-		// wait some time to ensure clients have connected, once they've
-		// retrieved the stream from the map it's safe to delete the key
-		time.Sleep(m.graceTime)
+	strm.stopOnce.Do(func() {
+		go func() {
+			// wait some time to ensure clients have connected, once they've
+			// retrieved the stream from the map it's safe to delete the key
+			time.Sleep(m.opts.GraceTime)
 
-		m.mu.Lock()
-		defer m.mu.Unlock()
-		delete(m.streams, streamID)
-	}()
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.streams, streamID)
+		}()
+	})
 }
 
 func (m *Streamer) streamFromID(streamID StreamID) *stream {
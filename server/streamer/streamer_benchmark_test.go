@@ -0,0 +1,46 @@
+package streamer_test
+
+import (
+	"io/ioutil"
+	"runtime"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/garden/server/streamer"
+)
+
+func BenchmarkWriteChunkSmall(b *testing.B) {
+	str := streamer.New(time.Minute)
+	sid := str.NewStream()
+	defer str.Stop(sid)
+
+	go str.ServeStdout(sid, ioutil.Discard)
+
+	chunk := []byte("a line of process output\n")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for str.WriteChunk(sid, streamer.Stdout, chunk) == streamer.ErrStreamFull {
+			// let the reader catch up, as a blocking send would
+			runtime.Gosched()
+		}
+	}
+}
+
+func BenchmarkStreamChannelSmall(b *testing.B) {
+	str := streamer.New(time.Minute)
+	stdout := make(chan []byte, 1000)
+	sid := str.Stream(stdout, make(chan []byte, 1000))
+	defer str.Stop(sid)
+
+	go str.ServeStdout(sid, ioutil.Discard)
+
+	chunk := []byte("a line of process output\n")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data := make([]byte, len(chunk))
+		copy(data, chunk)
+		stdout <- data
+	}
+}
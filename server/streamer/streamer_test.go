@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"errors"
 	"runtime/pprof"
+	"strings"
 	"sync"
 	"time"
 
 	"code.cloudfoundry.org/garden/server/streamer"
+	"code.cloudfoundry.org/garden/server/streamer/streamertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
@@ -105,8 +107,416 @@ var _ = Describe("Streamer", func() {
 		It("should not leak unused streams for longer than the grace time after streaming has been stopped", func() {
 			sid := str.Stream(stdoutChan, stderrChan)
 			str.Stop(sid)
-			time.Sleep(graceTime)
-			Expect(func() { str.Stop(sid) }).To(Panic(), "stream was not removed")
+			Eventually(func() error { return str.Check(sid) }, 10*graceTime).Should(Equal(streamer.ErrStreamNotFound), "stream was not removed")
+		})
+
+		It("can be stopped again, both before and after the stream has been removed", func() {
+			sid := str.Stream(stdoutChan, stderrChan)
+			str.Stop(sid)
+			str.Stop(sid)
+
+			Eventually(func() error { return str.Check(sid) }, 10*graceTime).Should(Equal(streamer.ErrStreamNotFound))
+			str.Stop(sid)
+		})
+	})
+
+	It("ignores CloseProducer and Stop for streams it does not know", func() {
+		str.CloseProducer(streamer.StreamID("unknown"))
+		str.Stop(streamer.StreamID("unknown"))
+	})
+
+	Context("when the only reader of a channel goes away", func() {
+		BeforeEach(func() {
+			channelBufferSize = 10
+		})
+
+		It("keeps the chunks produced until the next reader attaches", func() {
+			sid := str.Stream(stdoutChan, stderrChan)
+
+			failing := &syncBuffer{Buffer: new(bytes.Buffer), fail: true}
+			served := make(chan struct{})
+			go func() {
+				str.ServeStdout(sid, failing)
+				close(served)
+			}()
+
+			stdoutChan <- []byte("lost-to-the-failed-reader")
+			Eventually(served).Should(BeClosed())
+
+			stdoutChan <- []byte("a")
+			stdoutChan <- []byte("b")
+			time.Sleep(10 * time.Millisecond)
+			str.CloseProducer(sid)
+
+			w := new(bytes.Buffer)
+			str.ServeStdout(sid, w)
+			Expect(w.String()).To(Equal("ab"))
+
+			str.Stop(sid)
+		})
+	})
+
+	Context("with a reader buffer size", func() {
+		It("serves readers as usual", func() {
+			str = streamer.NewWithOptions(streamer.Options{GraceTime: graceTime, ReaderBufferSize: 1})
+			sid := str.Stream(stdoutChan, stderrChan)
+
+			w := &syncBuffer{Buffer: new(bytes.Buffer)}
+			go str.ServeStdout(sid, w)
+
+			for i := 1; i <= 5; i++ {
+				stdoutChan <- testByteSlice
+				Eventually(w.String).Should(HaveLen(i))
+			}
+
+			Expect(w.String()).To(Equal("xxxxx"))
+			str.Stop(sid)
+		})
+
+		It("disconnects a reader that falls a full buffer behind, without holding up the others", func() {
+			str = streamer.NewWithOptions(streamer.Options{GraceTime: graceTime, ReaderBufferSize: 5})
+			sid := str.Stream(stdoutChan, stderrChan)
+
+			stuck := &stuckWriter{writing: make(chan struct{}), released: make(chan struct{})}
+
+			stuckServed := make(chan struct{})
+			go func() {
+				defer close(stuckServed)
+				str.ServeStdout(sid, stuck)
+			}()
+
+			fast := &syncBuffer{Buffer: new(bytes.Buffer)}
+			go str.ServeStdout(sid, fast)
+
+			// readers only see chunks produced after they joined, so prime
+			// both before sending the payload
+			Eventually(func() bool {
+				stdoutChan <- []byte(".")
+
+				select {
+				case <-stuck.writing:
+					return fast.String() != ""
+				default:
+					return false
+				}
+			}).Should(BeTrue())
+
+			// pace the payload to the fast reader, so that only the stuck one
+			// falls behind
+			for i := 1; i <= 20; i++ {
+				stdoutChan <- testByteSlice
+
+				Eventually(func() string {
+					return strings.TrimLeft(fast.String(), ".")
+				}).Should(Equal(strings.Repeat(testString, i)))
+			}
+
+			// the stream goes on, but the stuck reader returns once released
+			close(stuck.released)
+			Eventually(stuckServed).Should(BeClosed())
+
+			str.Stop(sid)
+		})
+	})
+
+	Describe("writing chunks", func() {
+		It("splits writes into chunks of at most MaxChunkSize, in order", func() {
+			str = streamer.NewWithOptions(streamer.Options{GraceTime: graceTime, MaxChunkSize: 3})
+			sid := str.NewStream()
+
+			Expect(str.WriteChunk(sid, streamer.Stdout, []byte("abcdefgh"))).To(Succeed())
+			str.CloseProducer(sid)
+
+			var chunks []string
+			str.ServeStdout(sid, writerFunc(func(p []byte) (int, error) {
+				chunks = append(chunks, string(p))
+				return len(p), nil
+			}))
+			Expect(chunks).To(Equal([]string{"abc", "def", "gh"}))
+
+			str.Stop(sid)
+		})
+
+		It("defaults MaxChunkSize to DefaultMaxChunkSize", func() {
+			sid := str.NewStream()
+
+			Expect(str.WriteChunk(sid, streamer.Stderr, make([]byte, streamer.DefaultMaxChunkSize+1))).To(Succeed())
+			str.CloseProducer(sid)
+
+			var sizes []int
+			str.ServeStderr(sid, writerFunc(func(p []byte) (int, error) {
+				sizes = append(sizes, len(p))
+				return len(p), nil
+			}))
+			Expect(sizes).To(Equal([]int{streamer.DefaultMaxChunkSize, 1}))
+
+			str.Stop(sid)
+		})
+
+		It("copies what it is given", func() {
+			sid := str.NewStream()
+
+			p := []byte("before")
+			Expect(str.WriteChunk(sid, streamer.Stdout, p)).To(Succeed())
+			copy(p, "after!")
+			str.CloseProducer(sid)
+
+			w := new(bytes.Buffer)
+			str.ServeStdout(sid, w)
+			Expect(w.String()).To(Equal("before"))
+
+			str.Stop(sid)
+		})
+
+		It("drops the rest of a write the channel has no room for", func() {
+			str = streamer.NewWithOptions(streamer.Options{GraceTime: graceTime, MaxChunkSize: 1})
+			sid := str.Stream(stdoutChan, stderrChan)
+
+			Expect(str.WriteChunk(sid, streamer.Stdout, []byte("abc"))).To(Equal(streamer.ErrStreamFull))
+			str.CloseProducer(sid)
+
+			w := new(bytes.Buffer)
+			str.ServeStdout(sid, w)
+			Expect(w.String()).To(Equal("a"))
+
+			str.Stop(sid)
+		})
+
+		It("fails for a closed stream or one it does not know", func() {
+			sid := str.NewStream()
+			str.CloseProducer(sid)
+
+			Expect(str.WriteChunk(sid, streamer.Stdout, testByteSlice)).To(Equal(streamer.ErrStreamClosed))
+			Expect(str.WriteChunk(streamer.StreamID("unknown"), streamer.Stdout, testByteSlice)).To(Equal(streamer.ErrStreamNotFound))
+
+			str.Stop(sid)
+		})
+	})
+
+	Describe("served directly", func() {
+		streamertest.ItConformsToTheStreamerContract(streamertest.Direct)
+	})
+
+	Context("when the producer has been closed", func() {
+		var (
+			sid     streamer.StreamID
+			stopped bool
+		)
+
+		JustBeforeEach(func() {
+			sid = str.Stream(stdoutChan, stderrChan)
+			stopped = false
+		})
+
+		AfterEach(func() {
+			if !stopped {
+				str.Stop(sid)
+			}
+		})
+
+		It("lets attached readers drain the remaining output and return", func() {
+			w := &syncBuffer{Buffer: new(bytes.Buffer)}
+
+			served := make(chan struct{})
+			go func() {
+				str.ServeStdout(sid, w)
+				close(served)
+			}()
+
+			stdoutChan <- testByteSlice
+			Eventually(w.String).Should(Equal(testString))
+
+			stdoutChan <- testByteSlice
+			str.CloseProducer(sid)
+
+			Eventually(served).Should(BeClosed())
+			Expect(w.String()).To(Equal(testString + testString))
+		})
+
+		It("keeps the stream registered", func() {
+			str.CloseProducer(sid)
+
+			time.Sleep(2 * graceTime)
+			Expect(str.Check(sid)).To(Succeed())
+		})
+
+		It("serves readers attaching after the close but before the stop, and lets them return", func() {
+			stderrChan <- testByteSlice
+			str.CloseProducer(sid)
+
+			w := new(bytes.Buffer)
+			str.ServeStderr(sid, w)
+			Expect(w.String()).To(Equal(testString))
+
+			w = new(bytes.Buffer)
+			str.ServeStderr(sid, w)
+			Expect(w.String()).To(BeEmpty())
+		})
+
+		It("can be closed again, and stopped afterwards", func() {
+			str.CloseProducer(sid)
+			str.CloseProducer(sid)
+		})
+
+		It("is removed the grace time after it is stopped", func() {
+			str.CloseProducer(sid)
+			str.Stop(sid)
+			stopped = true
+
+			Eventually(func() error { return str.Check(sid) }).Should(Equal(streamer.ErrStreamNotFound))
+		})
+	})
+
+	Context("when several readers serve the same stream", func() {
+		var (
+			sid     streamer.StreamID
+			writers []*syncBuffer
+			served  []chan struct{}
+		)
+
+		BeforeEach(func() {
+			channelBufferSize = 10
+		})
+
+		JustBeforeEach(func() {
+			sid = str.Stream(stdoutChan, stderrChan)
+
+			writers = []*syncBuffer{
+				{Buffer: new(bytes.Buffer)},
+				{Buffer: new(bytes.Buffer)},
+				{Buffer: new(bytes.Buffer), delay: 10 * time.Millisecond},
+			}
+
+			served = nil
+			for _, w := range writers {
+				done := make(chan struct{})
+				served = append(served, done)
+
+				go func(w *syncBuffer) {
+					defer close(done)
+					str.ServeStdout(sid, w)
+				}(w)
+			}
+
+			// readers only see chunks produced after they joined, so prime
+			// every reader before sending the payload
+			Eventually(func() bool {
+				stdoutChan <- []byte(".")
+
+				for _, w := range writers {
+					if w.String() == "" {
+						return false
+					}
+				}
+
+				return true
+			}).Should(BeTrue())
+		})
+
+		It("delivers every chunk to every reader, including a slow one", func() {
+			for _, chunk := range []string{"a", "b", "c", "d", "e"} {
+				stdoutChan <- []byte(chunk)
+			}
+
+			for _, w := range writers {
+				Eventually(func() string {
+					return strings.TrimLeft(w.String(), ".")
+				}).Should(Equal("abcde"))
+			}
+
+			str.Stop(sid)
+		})
+
+		It("terminates all readers once the stream is stopped and drained", func() {
+			stdoutChan <- []byte("a")
+			stdoutChan <- []byte("b")
+			str.Stop(sid)
+
+			for i, w := range writers {
+				Eventually(served[i]).Should(BeClosed())
+				Expect(strings.TrimLeft(w.String(), ".")).To(Equal("ab"))
+			}
+		})
+
+		It("keeps serving the remaining readers when one of them fails", func() {
+			writers[0].mu.Lock()
+			writers[0].fail = true
+			writers[0].mu.Unlock()
+
+			stdoutChan <- []byte("a")
+			Eventually(served[0]).Should(BeClosed())
+
+			stdoutChan <- []byte("b")
+			for _, w := range writers[1:] {
+				Eventually(func() string {
+					return strings.TrimLeft(w.String(), ".")
+				}).Should(Equal("ab"))
+			}
+
+			str.Stop(sid)
+		})
+	})
+
+	Describe("stream IDs", func() {
+		It("accepts the IDs it issued", func() {
+			sid1 := str.Stream(stdoutChan, stderrChan)
+			sid2 := str.Stream(stdoutChan, stderrChan)
+			Ω(sid1).ShouldNot(Equal(sid2))
+
+			Ω(str.Check(sid1)).Should(Succeed())
+			Ω(str.Check(sid2)).Should(Succeed())
+		})
+
+		It("rejects IDs issued before a restart as stale", func() {
+			sid := str.Stream(stdoutChan, stderrChan)
+
+			restarted := streamer.New(graceTime)
+			restarted.Stream(stdoutChan, stderrChan)
+
+			Ω(restarted.Check(sid)).Should(Equal(streamer.ErrStaleStreamID))
+		})
+
+		It("rejects IDs it did not issue", func() {
+			sid := str.Stream(stdoutChan, stderrChan)
+			nonce := strings.SplitN(string(sid), "-", 2)[0]
+
+			Ω(str.Check(streamer.StreamID(nonce + "-99"))).Should(Equal(streamer.ErrStreamNotFound))
+		})
+
+		It("rejects malformed IDs", func() {
+			sid := str.Stream(stdoutChan, stderrChan)
+			nonce := strings.SplitN(string(sid), "-", 2)[0]
+
+			for _, id := range []string{
+				"",
+				"0",
+				nonce,
+				nonce + "-",
+				nonce + "-00",
+				nonce + "-+0",
+				nonce + "-0-0",
+				strings.ToUpper(nonce) + "-0",
+				nonce[1:] + "-0",
+			} {
+				Ω(str.Check(streamer.StreamID(id))).Should(Equal(streamer.ErrMalformedStreamID), id)
+			}
+		})
+
+		It("reports streams removed after the grace time as not found", func() {
+			sid := str.Stream(stdoutChan, stderrChan)
+			str.Stop(sid)
+
+			Eventually(func() error { return str.Check(sid) }).Should(Equal(streamer.ErrStreamNotFound))
+		})
+
+		It("returns straight away when serving an unknown stream", func() {
+			done := make(chan struct{})
+			go func() {
+				str.ServeStdout(streamer.StreamID("unknown"), new(bytes.Buffer))
+				close(done)
+			}()
+
+			Eventually(done).Should(BeClosed())
 		})
 	})
 
@@ -137,13 +547,22 @@ var _ = Describe("Streamer", func() {
 	})
 })
 
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
 type syncBuffer struct {
 	*bytes.Buffer
-	fail bool
-	mu   sync.Mutex
+	fail  bool
+	delay time.Duration
+	mu    sync.Mutex
 }
 
 func (sb *syncBuffer) Write(p []byte) (int, error) {
+	time.Sleep(sb.delay)
+
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 	if sb.fail {
@@ -158,3 +577,17 @@ func (sb *syncBuffer) String() string {
 	defer sb.mu.Unlock()
 	return sb.Buffer.String()
 }
+
+// stuckWriter blocks every write until it is released
+type stuckWriter struct {
+	writing  chan struct{}
+	released chan struct{}
+	once     sync.Once
+}
+
+func (w *stuckWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.released
+
+	return len(p), nil
+}
@@ -0,0 +1,137 @@
+// Package streamertest checks that a transport carrying streams out of a
+// streamer.Streamer keeps to the Streamer's contract.
+package streamertest
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+
+	"code.cloudfoundry.org/garden/server/streamer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Transport carries one channel of a stream, its standard error if stderr is
+// set and its standard output otherwise, from str to a consumer. The returned
+// reader is what the consumer receives; it ends once str stops serving the
+// stream. A transport may instead fail to attach to a stream that does not
+// exist.
+type Transport func(str *streamer.Streamer, streamID streamer.StreamID, stderr bool) (io.ReadCloser, error)
+
+// Direct serves the stream through an in-memory pipe. It is the reference
+// transport that every other is held to.
+func Direct(str *streamer.Streamer, streamID streamer.StreamID, stderr bool) (io.ReadCloser, error) {
+	r, w := io.Pipe()
+
+	go func() {
+		if stderr {
+			str.ServeStderr(streamID, w)
+		} else {
+			str.ServeStdout(streamID, w)
+		}
+
+		w.Close()
+	}()
+
+	return r, nil
+}
+
+const graceTime = 100 * time.Millisecond
+
+// ItConformsToTheStreamerContract adds specs to the enclosing container that
+// stream through the transport.
+func ItConformsToTheStreamerContract(transport Transport) {
+	var (
+		str    *streamer.Streamer
+		stdout chan []byte
+		stderr chan []byte
+		sid    streamer.StreamID
+	)
+
+	BeforeEach(func() {
+		str = streamer.NewWithOptions(streamer.Options{GraceTime: graceTime})
+		stdout = make(chan []byte, 10)
+		stderr = make(chan []byte, 10)
+		sid = str.Stream(stdout, stderr)
+	})
+
+	AfterEach(func() {
+		str.Stop(sid)
+	})
+
+	attach := func(fromStderr bool) io.ReadCloser {
+		r, err := transport(str, sid, fromStderr)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return r
+	}
+
+	readAll := func(r io.ReadCloser) <-chan string {
+		read := make(chan string, 1)
+
+		go func() {
+			defer r.Close()
+
+			contents, _ := ioutil.ReadAll(r)
+			read <- string(contents)
+		}()
+
+		return read
+	}
+
+	It("delivers the chunks of each channel in order", func() {
+		out := readAll(attach(false))
+		errs := readAll(attach(true))
+
+		for _, chunk := range []string{"a", "b", "c"} {
+			stdout <- []byte(chunk)
+			stderr <- []byte("err-" + chunk)
+		}
+
+		// the readers may still be attaching; wait until they have been fed
+		// before closing, as chunks sent before anyone reads are kept back
+		Eventually(func() int { return len(stdout) + len(stderr) }).Should(BeZero())
+		str.CloseProducer(sid)
+
+		Eventually(out).Should(Receive(Equal("abc")))
+		Eventually(errs).Should(Receive(Equal("err-aerr-berr-c")))
+	})
+
+	It("delivers chunks sent before any consumer attached", func() {
+		stdout <- []byte("early")
+		str.CloseProducer(sid)
+
+		Eventually(readAll(attach(false))).Should(Receive(Equal("early")))
+	})
+
+	It("delivers the remaining output and ends once the stream is stopped", func() {
+		stdout <- []byte("a")
+		stdout <- []byte("b")
+		str.Stop(sid)
+
+		Eventually(readAll(attach(false))).Should(Receive(Equal("ab")))
+	})
+
+	It("ends consumers attaching after the output has been collected", func() {
+		stdout <- []byte("a")
+		str.CloseProducer(sid)
+
+		Eventually(readAll(attach(false))).Should(Receive(Equal("a")))
+		Eventually(readAll(attach(false))).Should(Receive(BeEmpty()))
+	})
+
+	It("does not serve a stream that has been removed", func() {
+		stdout <- []byte("discarded")
+		str.Stop(sid)
+
+		Eventually(func() error { return str.Check(sid) }, 10*graceTime).Should(Equal(streamer.ErrStreamNotFound))
+
+		r, err := transport(str, sid, false)
+		if err != nil {
+			return
+		}
+
+		Eventually(readAll(r)).Should(Receive(BeEmpty()))
+	})
+}
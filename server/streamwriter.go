@@ -0,0 +1,32 @@
+package server
+
+import (
+	"code.cloudfoundry.org/garden/server/streamer"
+	"code.cloudfoundry.org/lager"
+)
+
+// streamWriter writes a process's output to one channel of a stream.
+type streamWriter struct {
+	streamer *streamer.Streamer
+	streamID streamer.StreamID
+	std      streamer.StdStream
+	logger   lager.Logger
+
+	dropping bool
+}
+
+func (w *streamWriter) Write(d []byte) (int, error) {
+	// writes never block or fail the process; output the stream has no room
+	// for is dropped, and logged once each time it starts being dropped
+	err := w.streamer.WriteChunk(w.streamID, w.std, d)
+	if err == streamer.ErrStreamFull && !w.dropping {
+		w.logger.Info("dropping-output", lager.Data{
+			"stream": w.std,
+			"reason": "no reader is keeping up with it",
+		})
+	}
+
+	w.dropping = err == streamer.ErrStreamFull
+
+	return len(d), nil
+}
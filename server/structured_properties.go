@@ -0,0 +1,69 @@
+package server
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// validatePropertyValues checks the properties that have a registered schema
+// against it.
+func validatePropertyValues(properties garden.Properties, schemas map[string]garden.PropertySchema) error {
+	for _, name := range sortedNames(properties) {
+		schema, found := schemas[name]
+		if !found {
+			continue
+		}
+
+		if err := schema.Validate(name, properties[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withStructuredPaths adds the dotted paths into a container's structured
+// properties, for filters to match. Properties set under the same names as
+// paths are kept, and values that are not JSON, such as those set before
+// their schema was registered, have no paths.
+func withStructuredPaths(properties garden.Properties, schemas map[string]garden.PropertySchema) garden.Properties {
+	if len(schemas) == 0 {
+		return properties
+	}
+
+	expanded := garden.Properties{}
+	for name := range schemas {
+		value, found := properties[name]
+		if !found {
+			continue
+		}
+
+		paths, err := garden.FlattenJSONProperty(name, value)
+		if err != nil {
+			continue
+		}
+
+		for path, leaf := range paths {
+			expanded[path] = leaf
+		}
+	}
+
+	for name, value := range properties {
+		expanded[name] = value
+	}
+
+	return expanded
+}
+
+// isStructuredPath reports whether the name is a dotted path into a
+// structured property.
+func isStructuredPath(name string, schemas map[string]garden.PropertySchema) bool {
+	for property := range schemas {
+		if strings.HasPrefix(name, property+".") {
+			return true
+		}
+	}
+
+	return false
+}
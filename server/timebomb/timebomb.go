@@ -24,9 +24,13 @@ func New(countdown time.Duration, detonate func()) *TimeBomb {
 	}
 }
 
+// Strap starts the countdown, unless the bomb is paused, in which case it
+// starts once the bomb is unpaused.
 func (b *TimeBomb) Strap() {
 	b.lock.Lock()
-	b.timer = time.AfterFunc(b.countdown, b.detonate)
+	if b.pauses == 0 {
+		b.timer = time.AfterFunc(b.countdown, b.detonate)
+	}
 	b.lock.Unlock()
 }
 
@@ -283,4 +283,54 @@ var _ = Describe("THE TIMEBOMB", func() {
 			})
 		})
 	})
+
+	Context("WHEN STRAPPED WHILE PAUSED", func() {
+		It("DOES NOT DETONATE", func() {
+			detonated := make(chan time.Time)
+
+			countdown := 100 * time.Millisecond
+
+			bomb := timebomb.New(
+				countdown,
+				func() {
+					detonated <- time.Now()
+				},
+			)
+
+			bomb.Pause()
+			bomb.Strap()
+
+			delay := 50 * time.Millisecond
+
+			select {
+			case <-detonated:
+				Fail("MILLIONS ARE DEAD")
+			case <-time.After(countdown + delay):
+			}
+		})
+
+		Context("AND THEN UNPAUSED", func() {
+			It("DETONATES AFTER THE COUNTDOWN", func() {
+				detonated := make(chan time.Time)
+
+				countdown := 100 * time.Millisecond
+
+				bomb := timebomb.New(
+					countdown,
+					func() {
+						detonated <- time.Now()
+					},
+				)
+
+				bomb.Pause()
+				bomb.Strap()
+
+				before := time.Now()
+
+				bomb.Unpause()
+
+				Ω((<-detonated).Sub(before)).Should(BeNumerically(">=", countdown))
+			})
+		})
+	})
 })
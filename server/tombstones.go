@@ -0,0 +1,98 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+const (
+	defaultTombstoneRetention = time.Hour
+	maxTombstones             = 1000
+)
+
+// tombstones remembers why recently destroyed containers were destroyed.
+// Entries are dropped once they are older than the retention, or, oldest
+// first, once there are more than maxTombstones of them.
+type tombstones struct {
+	retention time.Duration
+
+	entries map[string]*garden.Tombstone
+	order   []*garden.Tombstone
+	mu      sync.Mutex
+}
+
+func newTombstones(retention time.Duration) *tombstones {
+	return &tombstones{
+		retention: retention,
+		entries:   make(map[string]*garden.Tombstone),
+	}
+}
+
+func (t *tombstones) setRetention(retention time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.retention = retention
+}
+
+func (t *tombstones) record(handle, reason string, requestedAt time.Time, metrics []garden.MetricsSample) {
+	t.add(&garden.Tombstone{
+		Handle:      handle,
+		Reason:      reason,
+		RequestedAt: requestedAt,
+		DestroyedAt: time.Now(),
+		Metrics:     metrics,
+	})
+}
+
+// recordFailure records a destroy that failed after its request had already
+// been answered, so that the client can still find out about it.
+func (t *tombstones) recordFailure(handle, reason string, requestedAt time.Time, err error) {
+	t.add(&garden.Tombstone{
+		Handle:      handle,
+		Reason:      reason,
+		RequestedAt: requestedAt,
+		DestroyedAt: time.Now(),
+		Error:       err.Error(),
+	})
+}
+
+func (t *tombstones) add(tombstone *garden.Tombstone) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries[tombstone.Handle] = tombstone
+	t.order = append(t.order, tombstone)
+
+	t.prune()
+}
+
+func (t *tombstones) lookup(handle string) (garden.Tombstone, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+
+	tombstone, found := t.entries[handle]
+	if !found {
+		return garden.Tombstone{}, false
+	}
+
+	return *tombstone, true
+}
+
+func (t *tombstones) prune() {
+	cutoff := time.Now().Add(-t.retention)
+
+	for len(t.order) > 0 && (len(t.order) > maxTombstones || t.order[0].DestroyedAt.Before(cutoff)) {
+		oldest := t.order[0]
+		t.order = t.order[1:]
+
+		// the handle may have been destroyed again since
+		if t.entries[oldest.Handle] == oldest {
+			delete(t.entries, oldest.Handle)
+		}
+	}
+}
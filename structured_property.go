@@ -0,0 +1,120 @@
+package garden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// PropertySchema declares a property whose value is JSON. A server with the
+// property's schema registered rejects values the schema does not accept, and
+// lets filters match the fields inside them by dotted path, as in
+// "deploy.version".
+type PropertySchema struct {
+	// Type is the JSON type the value must have: "object", "array", "string",
+	// "number" or "boolean". Empty accepts any JSON value.
+	Type string `json:"type,omitempty"`
+
+	// Required names the fields an object value must have.
+	Required []string `json:"required,omitempty"`
+}
+
+// InvalidPropertyValueError is returned when a property with a registered
+// schema is set to a value the schema does not accept.
+type InvalidPropertyValueError struct {
+	Property string
+	Reason   string
+}
+
+func (err InvalidPropertyValueError) Error() string {
+	return fmt.Sprintf("invalid value for property %q: %s", err.Property, err.Reason)
+}
+
+// Validate checks that the value is JSON the schema accepts, returning an
+// InvalidPropertyValueError for the named property if it is not.
+func (schema PropertySchema) Validate(name, value string) error {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return InvalidPropertyValueError{Property: name, Reason: "not valid JSON"}
+	}
+
+	if schema.Type != "" && jsonType(decoded) != schema.Type {
+		return InvalidPropertyValueError{Property: name, Reason: fmt.Sprintf("must be of type %s", schema.Type)}
+	}
+
+	if len(schema.Required) == 0 {
+		return nil
+	}
+
+	object, ok := decoded.(map[string]interface{})
+	if !ok {
+		return InvalidPropertyValueError{Property: name, Reason: "must be an object with required fields"}
+	}
+
+	for _, field := range schema.Required {
+		if _, found := object[field]; !found {
+			return InvalidPropertyValueError{Property: name, Reason: fmt.Sprintf("missing required field %q", field)}
+		}
+	}
+
+	return nil
+}
+
+// FlattenJSONProperty returns the leaves of a property's JSON value as
+// properties named by their dotted path under the property's name, so that
+// {"version":3} in "deploy" becomes "deploy.version" set to "3". Array
+// elements are named by their index. Strings are unquoted; other leaves are
+// as written in the JSON.
+func FlattenJSONProperty(name, value string) (Properties, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	flattened := Properties{}
+	flattenJSON(name, decoded, flattened)
+
+	return flattened, nil
+}
+
+func flattenJSON(path string, value interface{}, flattened Properties) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, child := range v {
+			flattenJSON(path+"."+field, child, flattened)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenJSON(path+"."+strconv.Itoa(i), child, flattened)
+		}
+	case string:
+		flattened[path] = v
+	case json.Number:
+		flattened[path] = v.String()
+	case bool:
+		flattened[path] = strconv.FormatBool(v)
+	case nil:
+		flattened[path] = "null"
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
@@ -0,0 +1,75 @@
+package garden_test
+
+import (
+	"code.cloudfoundry.org/garden"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PropertySchema", func() {
+	Describe("Validate", func() {
+		It("accepts any JSON value without a type", func() {
+			Ω(garden.PropertySchema{}.Validate("deploy", `[1, "two"]`)).Should(Succeed())
+		})
+
+		It("rejects a value that is not JSON", func() {
+			Ω(garden.PropertySchema{}.Validate("deploy", `{"version":`)).Should(Equal(garden.InvalidPropertyValueError{
+				Property: "deploy",
+				Reason:   "not valid JSON",
+			}))
+		})
+
+		It("rejects a value of another type", func() {
+			Ω(garden.PropertySchema{Type: "object"}.Validate("deploy", `3`)).Should(Equal(garden.InvalidPropertyValueError{
+				Property: "deploy",
+				Reason:   "must be of type object",
+			}))
+		})
+
+		It("accepts an object with its required fields", func() {
+			schema := garden.PropertySchema{Type: "object", Required: []string{"version"}}
+			Ω(schema.Validate("deploy", `{"version": 3, "owner": "me"}`)).Should(Succeed())
+		})
+
+		It("rejects an object missing a required field", func() {
+			schema := garden.PropertySchema{Type: "object", Required: []string{"version"}}
+			Ω(schema.Validate("deploy", `{"owner": "me"}`)).Should(Equal(garden.InvalidPropertyValueError{
+				Property: "deploy",
+				Reason:   `missing required field "version"`,
+			}))
+		})
+	})
+})
+
+var _ = Describe("FlattenJSONProperty", func() {
+	It("names each leaf by its dotted path", func() {
+		flattened, err := garden.FlattenJSONProperty("deploy", `{
+			"version": 3,
+			"name": "web",
+			"canary": false,
+			"owner": null,
+			"zones": ["z1", "z2"],
+			"limits": {"memory": 1.5e9}
+		}`)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(flattened).Should(Equal(garden.Properties{
+			"deploy.version":       "3",
+			"deploy.name":          "web",
+			"deploy.canary":        "false",
+			"deploy.owner":         "null",
+			"deploy.zones.0":       "z1",
+			"deploy.zones.1":       "z2",
+			"deploy.limits.memory": "1.5e9",
+		}))
+	})
+
+	It("names a scalar value by the property's name", func() {
+		Ω(garden.FlattenJSONProperty("version", `"3.1"`)).Should(Equal(garden.Properties{"version": "3.1"}))
+	})
+
+	It("fails for a value that is not JSON", func() {
+		_, err := garden.FlattenJSONProperty("deploy", `{`)
+		Ω(err).Should(HaveOccurred())
+	})
+})
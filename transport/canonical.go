@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// CanonicalBytes encodes a message so that equal messages always yield the
+// same bytes, for computing signatures and cache keys over them. Decoding is
+// the same as for any other encoding of the message.
+//
+// The encoding is JSON with no insignificant whitespace, in which:
+//
+// * the keys of every object, and so every property name, are in byte order;
+// * env vars are stably sorted by name, so duplicates keep their precedence;
+// * net out rules are sorted by their own canonical encoding.
+//
+// Bind mounts keep their order, since later mounts shadow earlier ones.
+func CanonicalBytes(msg interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(canonicalMessage(msg))
+	if err != nil {
+		return nil, err
+	}
+
+	// struct fields are encoded in declaration order, so re-encode them as an
+	// object to sort them along with map keys
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(generic); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// WriteCanonicalMessage writes the message as encoded by CanonicalBytes.
+func WriteCanonicalMessage(writer io.Writer, msg interface{}) error {
+	encoded, err := CanonicalBytes(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(encoded)
+	return err
+}
+
+// canonicalMessage returns a copy of the message with its order-insensitive
+// lists sorted.
+func canonicalMessage(msg interface{}) interface{} {
+	switch m := msg.(type) {
+	case *garden.ContainerSpec:
+		return canonicalMessage(*m)
+	case garden.ContainerSpec:
+		m.Env = sortedEnv(m.Env)
+		return m
+
+	case *garden.ProcessSpec:
+		return canonicalMessage(*m)
+	case garden.ProcessSpec:
+		m.Env = sortedEnv(m.Env)
+		return m
+
+	case *BulkNetOutRequest:
+		return canonicalMessage(*m)
+	case BulkNetOutRequest:
+		m.Rules = sortedNetOutRules(m.Rules)
+		return m
+	}
+
+	return msg
+}
+
+func sortedEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+
+	sorted := append([]string{}, env...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return envName(sorted[i]) < envName(sorted[j])
+	})
+
+	return sorted
+}
+
+func envName(envVar string) string {
+	return strings.SplitN(envVar, "=", 2)[0]
+}
+
+func sortedNetOutRules(rules []garden.NetOutRule) []garden.NetOutRule {
+	if rules == nil {
+		return nil
+	}
+
+	type keyedRule struct {
+		key  string
+		rule garden.NetOutRule
+	}
+
+	keyed := make([]keyedRule, len(rules))
+	for i, rule := range rules {
+		// a rule holds no maps, so its plain encoding is already stable
+		encoded, _ := json.Marshal(rule)
+		keyed[i] = keyedRule{key: string(encoded), rule: rule}
+	}
+
+	sort.SliceStable(keyed, func(i, j int) bool {
+		return keyed[i].key < keyed[j].key
+	})
+
+	sorted := make([]garden.NetOutRule, len(keyed))
+	for i, k := range keyed {
+		sorted[i] = k.rule
+	}
+
+	return sorted
+}
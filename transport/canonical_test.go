@@ -0,0 +1,152 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/transport"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CanonicalBytes", func() {
+	var spec garden.ContainerSpec
+
+	BeforeEach(func() {
+		spec = garden.ContainerSpec{
+			Handle: "some-handle",
+			Properties: garden.Properties{
+				"zeta":  "last",
+				"alpha": "first",
+				"mu":    "<middle>",
+			},
+			Env: []string{"PATH=/bin", "HOME=/root", "PATH=/usr/bin"},
+			BindMounts: []garden.BindMount{
+				{SrcPath: "/src-z", DstPath: "/dst-z"},
+				{SrcPath: "/src-a", DstPath: "/dst-a"},
+			},
+			Limits: garden.Limits{
+				Memory: garden.MemoryLimits{LimitInBytes: 1 << 62},
+			},
+		}
+	})
+
+	It("encodes the documented order", func() {
+		encoded, err := transport.CanonicalBytes(spec)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(string(encoded)).Should(Equal(
+			`{"bind_mounts":[{"dst_path":"/dst-z","src_path":"/src-z"},{"dst_path":"/dst-a","src_path":"/src-a"}],` +
+				`"env":["HOME=/root","PATH=/bin","PATH=/usr/bin"],` +
+				`"handle":"some-handle",` +
+				`"limits":{"bandwidth_limits":{},"cpu_limits":{},"disk_limits":{},"memory_limits":{"limit_in_bytes":4611686018427387904}},` +
+				`"properties":{"alpha":"first","mu":"<middle>","zeta":"last"}}`,
+		))
+	})
+
+	It("encodes the same message as the same bytes every time", func() {
+		first, err := transport.CanonicalBytes(spec)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for i := 0; i < 100; i++ {
+			properties := garden.Properties{}
+			for name, value := range spec.Properties {
+				properties[name] = value
+			}
+			spec.Properties = properties
+
+			encoded, err := transport.CanonicalBytes(&spec)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(encoded).Should(Equal(first))
+		}
+	})
+
+	It("encodes env vars given in a different order as the same bytes", func() {
+		reordered := spec
+		reordered.Env = []string{"HOME=/root", "PATH=/bin", "PATH=/usr/bin"}
+
+		Ω(transport.CanonicalBytes(reordered)).Should(Equal(mustCanonical(spec)))
+	})
+
+	It("keeps the precedence of duplicate env vars", func() {
+		reordered := spec
+		reordered.Env = []string{"PATH=/usr/bin", "HOME=/root", "PATH=/bin"}
+
+		Ω(transport.CanonicalBytes(reordered)).ShouldNot(Equal(mustCanonical(spec)))
+	})
+
+	It("keeps the order of bind mounts", func() {
+		reordered := spec
+		reordered.BindMounts = []garden.BindMount{spec.BindMounts[1], spec.BindMounts[0]}
+
+		Ω(transport.CanonicalBytes(reordered)).ShouldNot(Equal(mustCanonical(spec)))
+	})
+
+	It("does not modify the message", func() {
+		_, err := transport.CanonicalBytes(&spec)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(spec.Env).Should(Equal([]string{"PATH=/bin", "HOME=/root", "PATH=/usr/bin"}))
+	})
+
+	It("decodes as the message", func() {
+		var decoded garden.ContainerSpec
+		Ω(json.Unmarshal(mustCanonical(spec), &decoded)).Should(Succeed())
+
+		spec.Env = []string{"HOME=/root", "PATH=/bin", "PATH=/usr/bin"}
+		Ω(decoded).Should(Equal(spec))
+	})
+
+	It("sorts the env vars of a process", func() {
+		encoded, err := transport.CanonicalBytes(garden.ProcessSpec{
+			Path: "ls",
+			Env:  []string{"B=2", "A=1"},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(string(encoded)).Should(Equal(`{"env":["A=1","B=2"],"path":"ls","rlimits":{}}`))
+	})
+
+	Describe("net out rules", func() {
+		var rules []garden.NetOutRule
+
+		BeforeEach(func() {
+			rules = []garden.NetOutRule{
+				{Protocol: garden.ProtocolUDP, Networks: []garden.IPRange{garden.IPRangeFromIP(net.ParseIP("10.0.0.1"))}},
+				{Protocol: garden.ProtocolTCP, Ports: []garden.PortRange{garden.PortRangeFromPort(80)}},
+			}
+		})
+
+		It("encodes them given in a different order as the same bytes", func() {
+			reordered := []garden.NetOutRule{rules[1], rules[0]}
+
+			Ω(transport.CanonicalBytes(&transport.BulkNetOutRequest{Rules: reordered})).Should(
+				Equal(mustCanonical(transport.BulkNetOutRequest{Rules: rules})),
+			)
+		})
+
+		It("decodes as the rules", func() {
+			var decoded transport.BulkNetOutRequest
+			Ω(json.Unmarshal(mustCanonical(transport.BulkNetOutRequest{Rules: rules}), &decoded)).Should(Succeed())
+
+			Ω(decoded.Rules).Should(ConsistOf(rules))
+		})
+	})
+
+	Describe("WriteCanonicalMessage", func() {
+		It("writes the canonical bytes", func() {
+			buf := new(bytes.Buffer)
+			Ω(transport.WriteCanonicalMessage(buf, spec)).Should(Succeed())
+
+			Ω(buf.Bytes()).Should(Equal(mustCanonical(spec)))
+		})
+	})
+})
+
+func mustCanonical(msg interface{}) []byte {
+	encoded, err := transport.CanonicalBytes(msg)
+	Ω(err).ShouldNot(HaveOccurred())
+	return encoded
+}
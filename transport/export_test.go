@@ -0,0 +1,12 @@
+package transport
+
+// DescribedRoutes returns the names of the routes whose wire messages are
+// described.
+func DescribedRoutes() []string {
+	names := make([]string, 0, len(routeMessages))
+	for name := range routeMessages {
+		names = append(names, name)
+	}
+
+	return names
+}
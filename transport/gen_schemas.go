@@ -0,0 +1,25 @@
+//go:build ignore
+// +build ignore
+
+// gen_schemas writes schemas.json, the JSON Schema documents of the wire
+// messages, for consumers that validate payloads without importing garden.
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+
+	"code.cloudfoundry.org/garden/transport"
+)
+
+func main() {
+	encoded, err := json.MarshalIndent(transport.Schemas(nil), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile("schemas.json", append(encoded, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
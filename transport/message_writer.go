@@ -5,6 +5,10 @@ import (
 	"io"
 )
 
+// CompressionThreshold is the size in bytes below which JSON bodies are sent
+// uncompressed, even if the other side accepts gzip.
+const CompressionThreshold = 8 * 1024
+
 func WriteMessage(writer io.Writer, req interface{}) error {
 	return json.NewEncoder(writer).Encode(req)
 }
@@ -1,6 +1,17 @@
+// Package transport defines the messages exchanged between garden clients
+// and servers.
+//
+// Slice and map fields are omitted when empty and never sent as null.
+// Decoding accepts a missing key, null or an empty list alike.
 package transport
 
-import "code.cloudfoundry.org/garden"
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/routes"
+)
 
 type Source int
 
@@ -17,6 +28,7 @@ type ProcessPayload struct {
 	Data       *string         `json:"data,omitempty"`
 	ExitStatus *int            `json:"exit_status,omitempty"`
 	Error      *string         `json:"error,omitempty"`
+	ErrorType  *garden.Error   `json:"error_type,omitempty"`
 	TTY        *garden.TTYSpec `json:"tty,omitempty"`
 	Signal     *garden.Signal  `json:"signal,omitempty"`
 }
@@ -31,3 +43,117 @@ type NetInResponse struct {
 	HostPort      uint32 `json:"host_port,omitempty"`
 	ContainerPort uint32 `json:"container_port,omitempty"`
 }
+
+type BulkNetOutRequest struct {
+	Rules []garden.NetOutRule `json:"rules"`
+}
+
+type MetricsHistoryResponse struct {
+	Samples []garden.MetricsSample `json:"samples"`
+}
+
+type APISpecResponse struct {
+	Routes  []routes.Description `json:"routes"`
+	Schemas map[string]*Schema   `json:"schemas"`
+
+	// PropertySchemas are the properties the server holds as JSON.
+	PropertySchemas map[string]garden.PropertySchema `json:"property_schemas,omitempty"`
+}
+
+type CreateResponse struct {
+	Handle string
+
+	// Info is only set when the request asked for the created container's
+	// info to be echoed.
+	Info *garden.ContainerInfo `json:"info,omitempty"`
+
+	// EffectiveSpec is only set when the request asked for the created
+	// container's effective spec to be echoed, and holds only the fields it
+	// asked for, if it named any.
+	EffectiveSpec *garden.EffectiveSpec `json:"effective_spec,omitempty"`
+
+	// Warnings are about the spec, when the container was created but not
+	// quite as asked.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type ListResponse struct {
+	Handles []string `json:"Handles,omitempty"`
+}
+
+type ListPageRequest struct {
+	Properties garden.Properties `json:"properties,omitempty"`
+
+	// Filter further narrows the containers listed to those it matches.
+	Filter garden.PropertyFilter `json:"filter,omitempty"`
+
+	garden.ListOptions
+}
+
+type DestroyMatchingRequest struct {
+	Properties garden.Properties `json:"properties,omitempty"`
+	DryRun     bool              `json:"dry_run,omitempty"`
+
+	// All must be set for an empty filter, which matches every container.
+	All bool `json:"all,omitempty"`
+}
+
+type DestroyMatchingResponse struct {
+	Handles []string                 `json:"handles,omitempty"`
+	Errors  map[string]*garden.Error `json:"errors,omitempty"`
+}
+
+type WriteFileRequest struct {
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+	Data []byte      `json:"data,omitempty"`
+}
+
+type ReadFileResponse struct {
+	Data []byte `json:"data,omitempty"`
+}
+
+type StopRequest struct {
+	Kill bool `json:"kill"`
+}
+
+type LockRequest struct {
+	Holder string        `json:"holder"`
+	Reason string        `json:"reason"`
+	TTL    time.Duration `json:"ttl"`
+}
+
+type PropertyResponse struct {
+	Value string `json:"value"`
+}
+
+type SetPropertyRequest struct {
+	Value string `json:"value"`
+}
+
+type SetPropertiesRequest struct {
+	Properties garden.Properties `json:"properties,omitempty"`
+}
+
+type RemovePropertiesRequest struct {
+	Names []string `json:"names,omitempty"`
+}
+
+type CompareAndSetPropertyRequest struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type CompareAndSetPropertyResponse struct {
+	Swapped bool `json:"swapped"`
+}
+
+// StreamTransportHeader is set on process output stream responses that are
+// sent as a chunked body because the server could not hijack the connection.
+const StreamTransportHeader = "X-Garden-Stream-Transport"
+
+const StreamTransportChunked = "chunked"
+
+// IdempotencyKeyHeader carries a key identifying a mutating request across
+// retries, so that the server applies it only once.
+const IdempotencyKeyHeader = "Idempotency-Key"
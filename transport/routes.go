@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"reflect"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/routes"
+)
+
+type messages struct {
+	// the Go types exchanged as JSON, named by reflection so that the table
+	// cannot drift from them
+	request  reflect.Type
+	response reflect.Type
+
+	// the media types of raw bodies, exchanged instead of JSON
+	requestMedia  string
+	responseMedia string
+
+	hijacks bool
+}
+
+func message(v interface{}) reflect.Type {
+	return reflect.TypeOf(v)
+}
+
+var routeMessages = map[string]messages{
+	routes.Ping:     {},
+	routes.Capacity: {response: message(garden.Capacity{})},
+
+	routes.List:        {response: message(ListResponse{})},
+	routes.ListPage:    {request: message(ListPageRequest{}), response: message(garden.ContainerPage{})},
+	routes.Create:      {request: message(garden.ContainerSpec{}), response: message(CreateResponse{})},
+	routes.Info:        {response: message(garden.ContainerInfo{})},
+	routes.BulkInfo:    {response: message(map[string]garden.ContainerInfoEntry{})},
+	routes.BulkMetrics: {response: message(map[string]garden.ContainerMetricsEntry{})},
+	routes.Destroy:     {},
+
+	routes.DestroyMatching: {request: message(DestroyMatchingRequest{}), response: message(DestroyMatchingResponse{})},
+	routes.Tombstone:       {response: message(garden.Tombstone{})},
+
+	routes.Stop: {request: message(StopRequest{})},
+
+	routes.Lock:   {request: message(LockRequest{})},
+	routes.Unlock: {},
+
+	routes.StreamIn:  {requestMedia: "application/x-tar"},
+	routes.StreamOut: {responseMedia: "application/x-tar"},
+
+	routes.ReadFile:  {response: message(ReadFileResponse{})},
+	routes.WriteFile: {request: message(WriteFileRequest{})},
+
+	routes.Stdout: {responseMedia: "application/octet-stream"},
+	routes.Stderr: {responseMedia: "application/octet-stream"},
+
+	routes.CurrentBandwidthLimits: {response: message(garden.BandwidthLimits{})},
+	routes.CurrentCPULimits:       {response: message(garden.CPULimits{})},
+	routes.CurrentDiskLimits:      {response: message(garden.DiskLimits{})},
+	routes.CurrentMemoryLimits:    {response: message(garden.MemoryLimits{})},
+
+	routes.NetIn:      {request: message(NetInRequest{}), response: message(NetInResponse{})},
+	routes.NetOut:     {request: message(garden.NetOutRule{})},
+	routes.BulkNetOut: {request: message(BulkNetOutRequest{})},
+
+	routes.Run:    {request: message(garden.ProcessSpec{}), response: message(ProcessPayload{}), hijacks: true},
+	routes.Attach: {response: message(ProcessPayload{}), hijacks: true},
+
+	routes.CapturedOutput: {responseMedia: "application/octet-stream"},
+
+	routes.SetGraceTime: {request: message(time.Duration(0))},
+
+	routes.Properties:     {response: message(garden.Properties{})},
+	routes.Property:       {response: message(PropertyResponse{})},
+	routes.SetProperty:    {request: message(SetPropertyRequest{})},
+	routes.RemoveProperty: {},
+
+	routes.CompareAndSetProperty: {request: message(CompareAndSetPropertyRequest{}), response: message(CompareAndSetPropertyResponse{})},
+	routes.SetProperties:         {request: message(SetPropertiesRequest{})},
+	routes.RemoveProperties:      {request: message(RemovePropertiesRequest{})},
+
+	routes.Metrics:        {response: message(garden.Metrics{})},
+	routes.MetricsHistory: {response: message(MetricsHistoryResponse{})},
+
+	routes.RouteTable: {response: message([]routes.Description{})},
+	routes.APISpec:    {response: message(APISpecResponse{})},
+}
+
+// DescribeRoutes returns a Description for every route in routes.Routes, in
+// the same order.
+func DescribeRoutes() []routes.Description {
+	descriptions := make([]routes.Description, 0, len(routes.Routes))
+	for _, route := range routes.Routes {
+		msgs := routeMessages[route.Name]
+		descriptions = append(descriptions, routes.Description{
+			Name:     route.Name,
+			Method:   route.Method,
+			Path:     route.Path,
+			Request:  messageName(msgs.request, msgs.requestMedia),
+			Response: messageName(msgs.response, msgs.responseMedia),
+			Hijacks:  msgs.hijacks,
+		})
+	}
+
+	return descriptions
+}
+
+func messageName(t reflect.Type, media string) string {
+	if t == nil {
+		return media
+	}
+
+	return t.String()
+}
+
+// routeMessageTypes are the JSON messages of the route table, by name.
+func routeMessageTypes() map[string]reflect.Type {
+	types := map[string]reflect.Type{}
+	for _, msgs := range routeMessages {
+		for _, t := range []reflect.Type{msgs.request, msgs.response} {
+			if t != nil {
+				types[t.String()] = t
+			}
+		}
+	}
+
+	return types
+}
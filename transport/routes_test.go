@@ -0,0 +1,45 @@
+package transport_test
+
+import (
+	"code.cloudfoundry.org/garden/routes"
+	"code.cloudfoundry.org/garden/transport"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DescribeRoutes", func() {
+	It("describes the wire messages of every route", func() {
+		described := transport.DescribedRoutes()
+
+		for _, route := range routes.Routes {
+			Ω(described).Should(ContainElement(route.Name), "add the route to routeMessages")
+		}
+	})
+
+	It("describes no route that is not served", func() {
+		var served []string
+		for _, route := range routes.Routes {
+			served = append(served, route.Name)
+		}
+
+		for _, name := range transport.DescribedRoutes() {
+			Ω(served).Should(ContainElement(name))
+		}
+	})
+
+	It("names messages by their Go types", func() {
+		for _, route := range transport.DescribeRoutes() {
+			switch route.Name {
+			case routes.Create:
+				Ω(route.Request).Should(Equal("garden.ContainerSpec"))
+				Ω(route.Response).Should(Equal("transport.CreateResponse"))
+			case routes.BulkInfo:
+				Ω(route.Response).Should(Equal("map[string]garden.ContainerInfoEntry"))
+			case routes.StreamIn:
+				Ω(route.Request).Should(Equal("application/x-tar"))
+			case routes.Run:
+				Ω(route.Hijacks).Should(BeTrue())
+			}
+		}
+	})
+})
@@ -0,0 +1,215 @@
+package transport
+
+//go:generate go run gen_schemas.go
+
+import (
+	"encoding"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// SchemaDialect is the JSON Schema draft the schemas are written in.
+const SchemaDialect = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema document describing a wire message, or a part of
+// one. Fields the server may refuse depending on its configuration or
+// backend are marked with x-capability-gated.
+type Schema struct {
+	Dialect string `json:"$schema,omitempty"`
+	Title   string `json:"title,omitempty"`
+
+	Type   string `json:"type,omitempty"`
+	Format string `json:"format,omitempty"`
+
+	Minimum *int64  `json:"minimum,omitempty"`
+	Maximum *uint64 `json:"maximum,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+
+	CapabilityGated bool `json:"x-capability-gated,omitempty"`
+}
+
+// SchemaLimits bounds the number of entries of message fields, keyed by
+// message name, then JSON field name.
+type SchemaLimits map[string]map[string]int
+
+// messageTypes are the messages named by the route table.
+var messageTypes = routeMessageTypes()
+
+// capabilityGated names the fields of messages that a server may refuse,
+// keyed by message name.
+var capabilityGated = map[string][]string{
+	// refused by servers that disallow privileged containers
+	"garden.ContainerSpec": {"privileged"},
+
+	// refused by backends that cannot limit processes individually, and by
+	// servers with nowhere to capture output
+	"garden.ProcessSpec": {"process_limits", "output_capture"},
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	ipType            = reflect.TypeOf(net.IP{})
+	errorType         = reflect.TypeOf(garden.Error{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	byteSliceType     = reflect.TypeOf([]byte{})
+)
+
+// Schemas returns a JSON Schema document for every message named by the route
+// table, keyed by the name it is given there. The entries of bounded fields
+// are limited as given.
+func Schemas(limits SchemaLimits) map[string]*Schema {
+	schemas := make(map[string]*Schema, len(messageTypes))
+
+	for name, t := range messageTypes {
+		schema := schemaFor(t, map[reflect.Type]bool{})
+		schema.Dialect = SchemaDialect
+		schema.Title = name
+
+		for _, field := range capabilityGated[name] {
+			if property, found := schema.Properties[field]; found {
+				property.CapabilityGated = true
+			}
+		}
+
+		for field, limit := range limits[name] {
+			if property, found := schema.Properties[field]; found && limit > 0 {
+				limit := limit
+
+				switch property.Type {
+				case "array":
+					property.MaxItems = &limit
+				case "object":
+					property.MaxProperties = &limit
+				}
+			}
+		}
+
+		schemas[name] = schema
+	}
+
+	return schemas
+}
+
+func schemaFor(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case ipType:
+		return &Schema{Type: "string"}
+	case byteSliceType:
+		return &Schema{Type: "string", Format: "byte"}
+	case errorType:
+		return schemaFor(garden.ErrorWireType(), visiting)
+	}
+
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), visiting)
+
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema := &Schema{Type: "integer"}
+		if bits := t.Bits(); bits < 64 {
+			min := int64(-1) << uint(bits-1)
+			max := uint64(1)<<uint(bits-1) - 1
+			schema.Minimum, schema.Maximum = &min, &max
+		}
+
+		return schema
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		min := int64(0)
+		max := uint64(math.MaxUint64)
+		if bits := t.Bits(); bits < 64 {
+			max = uint64(1)<<uint(bits) - 1
+		}
+
+		return &Schema{Type: "integer", Minimum: &min, Maximum: &max}
+
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem(), visiting)}
+
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), visiting)}
+
+	case reflect.Struct:
+		if visiting[t] {
+			// recursive; leave the nested value unconstrained
+			return &Schema{Type: "object"}
+		}
+
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(schema, t, visiting)
+
+		return schema
+	}
+
+	// interfaces and anything else may hold any value
+	return &Schema{}
+}
+
+// addFields adds the struct's fields as encoding/json names them, flattening
+// embedded structs.
+func addFields(schema *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				addFields(schema, embedded, visiting)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = schemaFor(field.Type, visiting)
+	}
+}
@@ -0,0 +1,94 @@
+package transport_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"code.cloudfoundry.org/garden/transport"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schemas", func() {
+	It("matches the generated schemas.json", func() {
+		generated, err := ioutil.ReadFile("schemas.json")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		encoded, err := json.Marshal(transport.Schemas(nil))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(encoded).Should(MatchJSON(generated), "the wire messages have changed; run go generate in the transport package")
+	})
+
+	It("has a schema for every JSON message named by the route table", func() {
+		schemas := transport.Schemas(nil)
+
+		for _, route := range transport.DescribeRoutes() {
+			for _, message := range []string{route.Request, route.Response} {
+				if message == "" || strings.Contains(message, "/") {
+					// no body, or a raw one
+					continue
+				}
+
+				Ω(schemas).Should(HaveKey(message), route.Name)
+				Ω(schemas[message].Title).Should(Equal(message))
+				Ω(schemas[message].Dialect).Should(Equal(transport.SchemaDialect))
+			}
+		}
+	})
+
+	It("describes fields by their JSON names and types", func() {
+		spec := transport.Schemas(nil)["garden.ContainerSpec"]
+
+		Ω(spec.Type).Should(Equal("object"))
+		Ω(spec.Properties["handle"].Type).Should(Equal("string"))
+		Ω(spec.Properties["grace_time"].Type).Should(Equal("integer"))
+		Ω(spec.Properties["env"].Type).Should(Equal("array"))
+		Ω(spec.Properties["env"].Items.Type).Should(Equal("string"))
+		Ω(spec.Properties["properties"].Type).Should(Equal("object"))
+		Ω(spec.Properties["properties"].AdditionalProperties.Type).Should(Equal("string"))
+
+		tombstone := transport.Schemas(nil)["garden.Tombstone"]
+		Ω(tombstone.Properties["destroyed_at"].Format).Should(Equal("date-time"))
+	})
+
+	It("describes errors by every field they are sent with", func() {
+		bulkInfo := transport.Schemas(nil)["map[string]garden.ContainerInfoEntry"]
+		wireError := bulkInfo.AdditionalProperties.Properties["Err"]
+
+		Ω(wireError.Type).Should(Equal("object"))
+		for _, key := range []string{"Type", "Message", "Handle", "Path", "Key", "Field", "Property", "Reason"} {
+			Ω(wireError.Properties[key].Type).Should(Equal("string"), key)
+		}
+		Ω(wireError.Properties["Limit"].Type).Should(Equal("integer"))
+		Ω(wireError.Properties["EstimatedCompletion"].Format).Should(Equal("date-time"))
+		Ω(wireError.Properties["Lock"].Properties).Should(HaveKey("holder"))
+	})
+
+	It("bounds unsigned and narrow integers", func() {
+		netIn := transport.Schemas(nil)["transport.NetInRequest"]
+
+		Ω(*netIn.Properties["host_port"].Minimum).Should(BeZero())
+		Ω(*netIn.Properties["host_port"].Maximum).Should(Equal(uint64(1<<32 - 1)))
+	})
+
+	It("marks capability-gated fields", func() {
+		schemas := transport.Schemas(nil)
+
+		Ω(schemas["garden.ContainerSpec"].Properties["privileged"].CapabilityGated).Should(BeTrue())
+		Ω(schemas["garden.ContainerSpec"].Properties["handle"].CapabilityGated).Should(BeFalse())
+		Ω(schemas["garden.ProcessSpec"].Properties["process_limits"].CapabilityGated).Should(BeTrue())
+		Ω(schemas["garden.ProcessSpec"].Properties["output_capture"].CapabilityGated).Should(BeTrue())
+	})
+
+	It("limits the entries of bounded fields", func() {
+		schemas := transport.Schemas(transport.SchemaLimits{
+			"garden.ContainerSpec": {"env": 5, "properties": 7},
+		})
+
+		Ω(*schemas["garden.ContainerSpec"].Properties["env"].MaxItems).Should(Equal(5))
+		Ω(*schemas["garden.ContainerSpec"].Properties["properties"].MaxProperties).Should(Equal(7))
+		Ω(schemas["garden.ContainerSpec"].Properties["bind_mounts"].MaxItems).Should(BeNil())
+	})
+})
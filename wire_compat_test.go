@@ -0,0 +1,86 @@
+package garden_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/transport"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// The fixtures under testdata/wire are JSON as sent by older clients and
+// servers, and must keep decoding the same way.
+var _ = Describe("Wire compatibility", func() {
+	decodeFixture := func(dir, name string, into interface{}) {
+		encoded, err := ioutil.ReadFile(filepath.Join("testdata", "wire", dir, name+".json"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(json.Unmarshal(encoded, into)).Should(Succeed())
+	}
+
+	itAcceptsEveryEmptyListForm := func(dir, prefix string, newValue func() interface{}) {
+		It("decodes null, omitted and empty "+prefix+" alike, and re-encodes them without null", func() {
+			var canonical []byte
+
+			for _, form := range []string{"null", "omitted", "empty"} {
+				value := newValue()
+				decodeFixture(dir, prefix+"_"+form, value)
+
+				encoded, err := json.Marshal(value)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(string(encoded)).ShouldNot(ContainSubstring("null"))
+
+				if canonical == nil {
+					canonical = encoded
+				}
+
+				Ω(encoded).Should(MatchJSON(canonical), form)
+			}
+		})
+	}
+
+	Describe("ContainerSpec", func() {
+		itAcceptsEveryEmptyListForm("container_spec", "bind_mounts", func() interface{} {
+			return &garden.ContainerSpec{}
+		})
+
+		It("decodes a populated spec", func() {
+			var spec garden.ContainerSpec
+			decodeFixture("container_spec", "populated", &spec)
+
+			Ω(spec).Should(Equal(garden.ContainerSpec{
+				Handle:     "some-handle",
+				GraceTime:  time.Second,
+				RootFSPath: "docker:///busybox",
+				BindMounts: []garden.BindMount{{
+					SrcPath: "/a",
+					DstPath: "/b",
+					Mode:    garden.BindMountModeRW,
+					Origin:  garden.BindMountOriginContainer,
+				}},
+				Network:    "10.0.0.0/30",
+				Properties: garden.Properties{"foo": "bar"},
+				Env:        []string{"A=B"},
+				Limits: garden.Limits{
+					Memory: garden.MemoryLimits{LimitInBytes: 1024},
+				},
+			}))
+		})
+	})
+
+	Describe("ContainerInfo", func() {
+		itAcceptsEveryEmptyListForm("container_info", "lists", func() interface{} {
+			return &garden.ContainerInfo{}
+		})
+	})
+
+	Describe("ListResponse", func() {
+		itAcceptsEveryEmptyListForm("list_response", "handles", func() interface{} {
+			return &transport.ListResponse{}
+		})
+	})
+})